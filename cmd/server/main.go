@@ -20,15 +20,20 @@ import (
 	"pos-fiber-app/internal/auth"
 	"pos-fiber-app/internal/business"
 	"pos-fiber-app/internal/category"
+	"pos-fiber-app/internal/changefeed"
 	"pos-fiber-app/internal/config"
+	"pos-fiber-app/internal/customer"
 	"pos-fiber-app/internal/expense"
+	"pos-fiber-app/internal/giftcard"
 	"pos-fiber-app/internal/inventory"
+	"pos-fiber-app/internal/loyalty"
 	"pos-fiber-app/internal/middleware"
 	"pos-fiber-app/internal/notification"
 	"pos-fiber-app/internal/onboarding"
 	"pos-fiber-app/internal/outlet"
 	"pos-fiber-app/internal/printing"
 	"pos-fiber-app/internal/product"
+	"pos-fiber-app/internal/promotion"
 	"pos-fiber-app/internal/recipe"
 	"pos-fiber-app/internal/reconciliation"
 	"pos-fiber-app/internal/report"
@@ -96,6 +101,8 @@ func main() {
 
 	// === Start Background Tasks ===
 	archiver.StartDataLifecycleManager(db)
+	sale.StartPrepSLAMonitor(db)     // Flags kitchen orders that go past the prep SLA
+	sale.StartCustomerDisplayHub(db) // Customer-facing till screens and their adverts
 	// report.StartReportScheduler(db) // DEPRECATED: Now handled by external scheduler service via API
 
 	app := fiber.New(fiber.Config{
@@ -172,6 +179,7 @@ func main() {
 	seed.RegisterPublicRoutes(apiV1, db)
 	advert.RegisterPublicAdvertRoutes(apiV1, db)
 	product.RegisterPublicProductRoutes(apiV1, db)
+	sale.RegisterPublicSaleRoutes(apiV1, db)
 	reconciliation.RegisterRoutes(apiV1, db)
 
 	// Auth (Public part: login, verify-otp, password-reset)
@@ -208,6 +216,11 @@ func main() {
 	inventory.RegisterInventoryRoutes(businessScoped, db)
 	sale.RegisterSaleRoutes(businessScoped, db)
 	expense.RegisterRoutes(businessScoped, db)
+	customer.RegisterCustomerRoutes(businessScoped, db)
+	loyalty.RegisterLoyaltyRoutes(businessScoped, db)
+	giftcard.RegisterGiftCardRoutes(businessScoped, db)
+	promotion.RegisterPromotionRoutes(businessScoped, db)
+	changefeed.RegisterChangefeedRoutes(businessScoped, db)
 	seed.RegisterRoutes(businessScoped, db)
 
 	// Subscriptions & Shift/Table
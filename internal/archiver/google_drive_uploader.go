@@ -49,7 +49,7 @@ func UploadBackupToDrive(db *gorm.DB, biz *business.Business, from, to time.Time
 	for _, s := range sales {
 		itemsDesc := ""
 		for _, item := range s.SaleItems {
-			itemsDesc += fmt.Sprintf("%dx %s; ", item.Quantity, item.ProductName)
+			itemsDesc += fmt.Sprintf("%gx %s; ", item.Quantity, item.ProductName)
 		}
 		writer.Write([]string{
 			fmt.Sprintf("%d", s.ID),
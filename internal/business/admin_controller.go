@@ -2,6 +2,7 @@ package business
 
 import (
 	"pos-fiber-app/internal/common"
+	"pos-fiber-app/internal/inventory"
 	"pos-fiber-app/internal/user"
 	"strings"
 	"time"
@@ -161,7 +162,7 @@ func (ac *AdminBusinessController) ResetBusinessData(c *fiber.Ctx) error {
 		// 1. Restock logic for COMPLETED sales
 		type ItemStock struct {
 			ProductID uint
-			Quantity  int
+			Quantity  float64
 		}
 		var items []ItemStock
 
@@ -176,6 +177,7 @@ func (ac *AdminBusinessController) ResetBusinessData(c *fiber.Ctx) error {
 		}
 
 		// Restore stock levels
+		restock := inventory.WithMovement(tx, inventory.Movement{Reason: inventory.ReasonAdjustment, Note: "sales wiped by data reset"})
 		for _, item := range items {
 			// Update products table
 			if err := tx.Table("products").
@@ -190,6 +192,16 @@ func (ac *AdminBusinessController) ResetBusinessData(c *fiber.Ctx) error {
 				UpdateColumn("current_stock", gorm.Expr("current_stock + ?", item.Quantity)).Error; err != nil {
 				return err
 			}
+
+			var balance float64
+			tx.Table("products").
+				Select("COALESCE(inventories.current_stock, products.stock)").
+				Joins("LEFT JOIN inventories ON inventories.product_id = products.id AND inventories.business_id = products.business_id").
+				Where("products.id = ?", item.ProductID).
+				Scan(&balance)
+			if err := inventory.RecordMovement(restock, item.ProductID, businessID, item.Quantity, balance); err != nil {
+				return err
+			}
 		}
 
 		// 2. Perform deletions (Wipe transaction data)
@@ -119,7 +119,7 @@ func UpdateHandler(db *gorm.DB) fiber.Handler {
 		}
 
 		// Basic validation
-		if req.Name == "" && req.Type == "" && req.Address == "" && req.City == "" && req.DataRetentionMonths == nil && req.AutoArchiveEnabled == nil && req.ArchiveFrequency == "" && req.WhatsAppEnabled == nil && req.WhatsAppNumber == "" && req.TableManagementEnabled == nil && req.SaveToDraftEnabled == nil && req.Slug == "" {
+		if req.Name == "" && req.Type == "" && req.Address == "" && req.City == "" && req.DataRetentionMonths == nil && req.AutoArchiveEnabled == nil && req.ArchiveFrequency == "" && req.WhatsAppEnabled == nil && req.WhatsAppNumber == "" && req.TableManagementEnabled == nil && req.SaveToDraftEnabled == nil && req.Slug == "" && req.OverrideApprovalPercent == nil && req.VATRate == nil && req.PricesIncludeTax == nil && req.ServiceChargePercent == nil && req.ServiceChargeMinPartySize == nil && req.EReceiptsEnabled == nil && req.LayawayForfeitPercent == nil && req.PrepSLAMinutes == nil && req.ScaleBarcodeValue == "" && req.PaystackPublicKey == nil && req.PaystackSecretKey == nil {
 			return fiber.NewError(fiber.StatusBadRequest, "at least one field must be provided for update")
 		}
 
@@ -165,6 +165,66 @@ func UpdateHandler(db *gorm.DB) fiber.Handler {
 		if req.SaveToDraftEnabled != nil {
 			updates["save_to_draft_enabled"] = *req.SaveToDraftEnabled
 		}
+		if req.OverrideApprovalPercent != nil {
+			if *req.OverrideApprovalPercent < 0 || *req.OverrideApprovalPercent > 100 {
+				return fiber.NewError(fiber.StatusBadRequest, "override_approval_percent must be between 0 and 100")
+			}
+			updates["override_approval_percent"] = *req.OverrideApprovalPercent
+		}
+		if req.VATRate != nil {
+			if *req.VATRate < 0 || *req.VATRate > 100 {
+				return fiber.NewError(fiber.StatusBadRequest, "vat_rate must be between 0 and 100")
+			}
+			updates["vat_rate"] = *req.VATRate
+		}
+		if req.PricesIncludeTax != nil {
+			updates["prices_include_tax"] = *req.PricesIncludeTax
+		}
+		if req.ServiceChargePercent != nil {
+			if *req.ServiceChargePercent < 0 || *req.ServiceChargePercent > 100 {
+				return fiber.NewError(fiber.StatusBadRequest, "service_charge_percent must be between 0 and 100")
+			}
+			updates["service_charge_percent"] = *req.ServiceChargePercent
+		}
+		if req.ServiceChargeMinPartySize != nil {
+			if *req.ServiceChargeMinPartySize < 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "service_charge_min_party_size cannot be negative")
+			}
+			updates["service_charge_min_party_size"] = *req.ServiceChargeMinPartySize
+		}
+		if req.EReceiptsEnabled != nil {
+			updates["e_receipts_enabled"] = *req.EReceiptsEnabled
+		}
+		if req.LayawayForfeitPercent != nil {
+			if *req.LayawayForfeitPercent < 0 || *req.LayawayForfeitPercent > 100 {
+				return fiber.NewError(fiber.StatusBadRequest, "layaway_forfeit_percent must be between 0 and 100")
+			}
+			updates["layaway_forfeit_percent"] = *req.LayawayForfeitPercent
+		}
+		if req.PrepSLAMinutes != nil {
+			if *req.PrepSLAMinutes < 0 {
+				return fiber.NewError(fiber.StatusBadRequest, "prep_sla_minutes cannot be negative")
+			}
+			updates["prep_sla_minutes"] = *req.PrepSLAMinutes
+		}
+		if req.ScaleBarcodeValue != "" {
+			if req.ScaleBarcodeValue != "WEIGHT" && req.ScaleBarcodeValue != "PRICE" {
+				return fiber.NewError(fiber.StatusBadRequest, "scale_barcode_value must be WEIGHT or PRICE")
+			}
+			updates["scale_barcode_value"] = req.ScaleBarcodeValue
+		}
+		if req.PaystackPublicKey != nil {
+			if k := strings.TrimSpace(*req.PaystackPublicKey); k != "" && !strings.HasPrefix(k, "pk_") {
+				return fiber.NewError(fiber.StatusBadRequest, "paystack_public_key must be a Paystack public key (pk_...)")
+			}
+			updates["paystack_public_key"] = strings.TrimSpace(*req.PaystackPublicKey)
+		}
+		if req.PaystackSecretKey != nil {
+			if k := strings.TrimSpace(*req.PaystackSecretKey); k != "" && !strings.HasPrefix(k, "sk_") {
+				return fiber.NewError(fiber.StatusBadRequest, "paystack_secret_key must be a Paystack secret key (sk_...)")
+			}
+			updates["paystack_secret_key"] = strings.TrimSpace(*req.PaystackSecretKey)
+		}
 		if req.Slug != "" {
 			// Validate slug uniqueness
 			var existingCount int64
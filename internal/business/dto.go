@@ -31,4 +31,23 @@ type UpdateBusinessRequest struct {
 	TableManagementEnabled *bool  `json:"table_management_enabled,omitempty"`
 	SaveToDraftEnabled     *bool  `json:"save_to_draft_enabled,omitempty"`
 	Slug                   string `json:"slug,omitempty" validate:"omitempty,min=3,max=50"`
+	// Overrides
+	OverrideApprovalPercent *float64 `json:"override_approval_percent,omitempty"`
+	// Tax
+	VATRate          *float64 `json:"vat_rate,omitempty"`
+	PricesIncludeTax *bool    `json:"prices_include_tax,omitempty"`
+	// Service charge
+	ServiceChargePercent      *float64 `json:"service_charge_percent,omitempty"`
+	ServiceChargeMinPartySize *int     `json:"service_charge_min_party_size,omitempty"`
+	// Digital receipts
+	EReceiptsEnabled *bool `json:"e_receipts_enabled,omitempty"`
+	// Layaways
+	LayawayForfeitPercent *float64 `json:"layaway_forfeit_percent,omitempty"`
+	// Kitchen
+	PrepSLAMinutes *int `json:"prep_sla_minutes,omitempty"`
+	// Scales
+	ScaleBarcodeValue string `json:"scale_barcode_value,omitempty"` // WEIGHT or PRICE
+	// Online payments
+	PaystackPublicKey *string `json:"paystack_public_key,omitempty"`
+	PaystackSecretKey *string `json:"paystack_secret_key,omitempty"`
 }
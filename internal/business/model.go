@@ -42,10 +42,27 @@ type Business struct {
 	Slug                   string     `gorm:"uniqueIndex;size:100" json:"slug"`
 	ActiveModules          []string   `gorm:"-" json:"active_modules,omitempty"` // populated on fetch
 	VATRate                float64    `gorm:"default:7.5" json:"vat_rate"`
+	PricesIncludeTax       bool       `gorm:"default:true" json:"prices_include_tax"` // false: VAT is added on top of prices
 	TaxNumber              string     `gorm:"size:50" json:"tax_number"`
 	DefaultPromoCode       string     `gorm:"size:50" json:"default_promo_code,omitempty"`
 	LaunchOfferSent            bool       `gorm:"default:false" json:"launch_offer_sent"`
 	PaymentVerificationEnabled bool       `gorm:"default:false" json:"payment_verification_enabled"`
+	// Cashier discounts and price overrides taking more than this percent off need a manager's approval
+	OverrideApprovalPercent float64 `gorm:"default:10" json:"override_approval_percent"`
+	// Automatic service charge on eat-in orders; with a minimum party size, only at tables seating that many
+	ServiceChargePercent      float64 `gorm:"default:0" json:"service_charge_percent"`
+	ServiceChargeMinPartySize int     `gorm:"default:0" json:"service_charge_min_party_size"`
+	// Email or WhatsApp a receipt link to customers who gave an address or number
+	EReceiptsEnabled bool `gorm:"default:false" json:"e_receipts_enabled"`
+	// Share of a layaway's total kept, out of what was deposited, when the layaway is cancelled
+	LayawayForfeitPercent float64 `gorm:"default:0" json:"layaway_forfeit_percent"`
+	// Minutes a kitchen order may wait for its items to be ready before KDS screens flag it overdue; 0 turns the alert off
+	PrepSLAMinutes int `gorm:"default:0" json:"prep_sla_minutes"`
+	// What the 5-digit value in a weighing scale's EAN-13 labels is: WEIGHT in grams, or PRICE in kobo
+	ScaleBarcodeValue string `gorm:"size:10;default:'WEIGHT'" json:"scale_barcode_value"`
+	// The business's own Paystack account, which QR menu customers pay into
+	PaystackPublicKey string `gorm:"size:100" json:"paystack_public_key,omitempty"`
+	PaystackSecretKey string `gorm:"size:100" json:"-"`
 
 	// Trial Activation System
 	TrialActivated          bool       `gorm:"default:false" json:"trial_activated"`
@@ -2,6 +2,8 @@
 package category
 
 import (
+	"pos-fiber-app/internal/common"
+
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
@@ -30,20 +32,24 @@ func ListHandler(db *gorm.DB) fiber.Handler {
 // @Security BearerAuth
 // @Accept json
 // @Produce json
-// @Param body body object{name=string,description=string} true "Category info"
+// @Param body body object{name=string,description=string,tax_class=string} true "Category info"
 // @Success 201 {object} Category
 // @Router /categories [post]
 func CreateHandler(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var req struct {
-			Name        string `json:"name" validate:"required"`
-			Description string `json:"description"`
+			Name        string          `json:"name" validate:"required"`
+			Description string          `json:"description"`
+			TaxClass    common.TaxClass `json:"tax_class"` // STANDARD, ZERO_RATED or EXEMPT
 		}
 		if err := c.BodyParser(&req); err != nil {
 			return fiber.NewError(400, "invalid payload")
 		}
+		if req.TaxClass != "" && !req.TaxClass.Valid() {
+			return fiber.NewError(400, "tax_class must be STANDARD, ZERO_RATED or EXEMPT")
+		}
 		bizID := c.Locals("current_business_id").(uint)
-		cat, err := Create(db, bizID, req.Name, req.Description)
+		cat, err := Create(db, bizID, req.Name, req.Description, req.TaxClass)
 		if err != nil {
 			return fiber.ErrInternalServerError
 		}
@@ -81,7 +87,7 @@ func GetHandler(db *gorm.DB) fiber.Handler {
 // @Accept json
 // @Produce json
 // @Param id path uint true "Category ID"
-// @Param body body object{name=string,description=string} true "Updated category info"
+// @Param body body object{name=string,description=string,tax_class=string} true "Updated category info"
 // @Success 200 {object} Category
 // @Router /categories/{id} [put]
 func UpdateHandler(db *gorm.DB) fiber.Handler {
@@ -91,14 +97,18 @@ func UpdateHandler(db *gorm.DB) fiber.Handler {
 			return fiber.NewError(400, "invalid category ID")
 		}
 		var req struct {
-			Name        string `json:"name" validate:"required"`
-			Description string `json:"description"`
+			Name        string          `json:"name" validate:"required"`
+			Description string          `json:"description"`
+			TaxClass    common.TaxClass `json:"tax_class"` // STANDARD, ZERO_RATED or EXEMPT
 		}
 		if err := c.BodyParser(&req); err != nil {
 			return fiber.NewError(400, "invalid payload")
 		}
+		if req.TaxClass != "" && !req.TaxClass.Valid() {
+			return fiber.NewError(400, "tax_class must be STANDARD, ZERO_RATED or EXEMPT")
+		}
 		bizID := c.Locals("current_business_id").(uint)
-		cat, err := Update(db, uint(id), bizID, req.Name, req.Description)
+		cat, err := Update(db, uint(id), bizID, req.Name, req.Description, req.TaxClass)
 		if err != nil {
 			return fiber.ErrInternalServerError
 		}
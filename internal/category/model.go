@@ -4,15 +4,18 @@ package category
 import (
 	"time"
 
+	"pos-fiber-app/internal/common"
+
 	"gorm.io/gorm"
 )
 
 type Category struct {
-	ID          uint           `gorm:"primaryKey" json:"id"`
-	BusinessID  uint           `gorm:"index" json:"business_id"`
-	Name        string         `gorm:"size:100;not null" json:"name"`
-	Description string         `json:"description,omitempty"`
-	CreatedAt   time.Time      `json:"-"`
-	UpdatedAt   time.Time      `json:"-"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	BusinessID  uint            `gorm:"index" json:"business_id"`
+	Name        string          `gorm:"size:100;not null" json:"name"`
+	Description string          `json:"description,omitempty"`
+	TaxClass    common.TaxClass `gorm:"type:varchar(20)" json:"tax_class,omitempty"` // default for its products; empty = STANDARD
+	CreatedAt   time.Time       `json:"-"`
+	UpdatedAt   time.Time       `json:"-"`
+	DeletedAt   gorm.DeletedAt  `gorm:"index" json:"-"`
 }
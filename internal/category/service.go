@@ -1,10 +1,14 @@
 // internal/category/service.go
 package category
 
-import "gorm.io/gorm"
+import (
+	"pos-fiber-app/internal/common"
 
-func Create(db *gorm.DB, businessID uint, name, desc string) (*Category, error) {
-	cat := &Category{BusinessID: businessID, Name: name, Description: desc}
+	"gorm.io/gorm"
+)
+
+func Create(db *gorm.DB, businessID uint, name, desc string, taxClass common.TaxClass) (*Category, error) {
+	cat := &Category{BusinessID: businessID, Name: name, Description: desc, TaxClass: taxClass}
 	return cat, db.Create(cat).Error
 }
 
@@ -20,13 +24,14 @@ func Get(db *gorm.DB, id, businessID uint) (*Category, error) {
 	return &cat, err
 }
 
-func Update(db *gorm.DB, id, businessID uint, name, desc string) (*Category, error) {
+func Update(db *gorm.DB, id, businessID uint, name, desc string, taxClass common.TaxClass) (*Category, error) {
 	cat, err := Get(db, id, businessID)
 	if err != nil {
 		return nil, err
 	}
 	cat.Name = name
 	cat.Description = desc
+	cat.TaxClass = taxClass
 	return cat, db.Save(cat).Error
 }
 
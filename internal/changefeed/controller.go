@@ -0,0 +1,41 @@
+// internal/changefeed/controller.go
+package changefeed
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetChanges godoc
+// @Summary Get catalogue and settings changes for a device
+// @Description Change feed for keeping a device's local database current. Without since it returns a full snapshot; otherwise the rows inserted, updated or deleted since the cursor, each with its current data (deletes carry none). Covers the business and its settings, users, printers, categories, products, modifiers, recipe ingredients and tables. Keep calling while has_more is true.
+// @Tags Sync
+// @Security BearerAuth
+// @Produce json
+// @Param since query string false "Cursor from the previous response"
+// @Param limit query int false "Changes per page (default 500, max 1000)"
+// @Success 200 {object} Feed
+// @Failure 400 {object} map[string]string
+// @Router /sync/changes [get]
+func GetChangesHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		limit := c.QueryInt("limit", 500)
+		if limit < 1 || limit > 1000 {
+			limit = 500
+		}
+
+		feed, err := Changes(db, bizID, c.Query("since"), limit)
+		if err != nil {
+			if strings.Contains(err.Error(), "invalid cursor") {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(feed)
+	}
+}
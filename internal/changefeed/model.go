@@ -0,0 +1,43 @@
+// internal/changefeed/model.go
+package changefeed
+
+import "time"
+
+const (
+	OpUpsert = "UPSERT"
+	OpDelete = "DELETE" // deleted or soft-deleted
+)
+
+// Change is one write to a synced table, recorded by a database trigger so that
+// every insert, update and delete is caught, whoever makes it. TxID is the writing
+// transaction, which is what the feed's cursor follows.
+type Change struct {
+	ID         uint64    `gorm:"primaryKey;index:idx_sync_changes_cursor,priority:2" json:"id"`
+	TxID       int64     `gorm:"index:idx_sync_changes_cursor,priority:1" json:"tx_id"`
+	Entity     string    `gorm:"size:50" json:"entity"` // table name, e.g. "products"
+	EntityID   uint      `json:"entity_id"`
+	BusinessID uint      `gorm:"index" json:"business_id"` // 0 for tenant-wide rows (users, printers)
+	TenantID   string    `gorm:"size:8;index" json:"tenant_id"`
+	Op         string    `gorm:"size:10" json:"op"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+func (Change) TableName() string {
+	return "sync_changes"
+}
+
+// Feed is a page of the change feed
+type Feed struct {
+	Cursor  string     `json:"cursor"`   // pass back as since to continue
+	HasMore bool       `json:"has_more"` // more changes are waiting; call again straight away
+	Full    bool       `json:"full"`     // a full snapshot: local rows not in it should be dropped
+	Changes []FeedItem `json:"changes"`
+}
+
+// FeedItem is the latest state of one row: its data for an upsert, nothing for a delete
+type FeedItem struct {
+	Entity string      `json:"entity"`
+	ID     uint        `json:"id"`
+	Op     string      `json:"op"`
+	Data   interface{} `json:"data,omitempty"`
+}
@@ -0,0 +1,11 @@
+// internal/changefeed/route.go
+package changefeed
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func RegisterChangefeedRoutes(r fiber.Router, db *gorm.DB) {
+	r.Get("/sync/changes", GetChangesHandler(db))
+}
@@ -0,0 +1,272 @@
+// internal/changefeed/service.go
+package changefeed
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"pos-fiber-app/internal/business"
+	"pos-fiber-app/internal/category"
+	"pos-fiber-app/internal/product"
+	"pos-fiber-app/internal/recipe"
+	"pos-fiber-app/internal/table"
+	"pos-fiber-app/internal/terminal"
+	"pos-fiber-app/internal/user"
+
+	"gorm.io/gorm"
+)
+
+type scope int
+
+const (
+	scopeBusiness scope = iota // rows with a business_id
+	scopeTenant                // rows shared by a tenant's businesses
+	scopeSelf                  // the business row itself
+)
+
+// entity is a table the feed serves to devices
+type entity struct {
+	table string
+	scope scope
+	find  func(db *gorm.DB) ([]interface{}, error)
+}
+
+var entities = []entity{
+	{"businesses", scopeSelf, rows[business.Business]},
+	{"users", scopeTenant, rows[user.User]},
+	{"printers", scopeTenant, rows[terminal.Printer]},
+	{"categories", scopeBusiness, rows[category.Category]},
+	{"products", scopeBusiness, rows[product.Product]},
+	{"modifier_groups", scopeBusiness, rows[product.ModifierGroup]},
+	{"modifier_options", scopeBusiness, rows[product.ModifierOption]},
+	{"product_modifier_groups", scopeBusiness, rows[product.ProductModifierGroup]},
+	{"recipe_ingredients", scopeBusiness, rows[recipe.RecipeIngredient]},
+	{"tables", scopeBusiness, rows[table.Table]},
+}
+
+func rows[T any](db *gorm.DB) ([]interface{}, error) {
+	var found []T
+	if err := db.Find(&found).Error; err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(found))
+	for i := range found {
+		out[i] = found[i]
+	}
+	return out, nil
+}
+
+func (e entity) query(db *gorm.DB, businessID uint, tenantID string) *gorm.DB {
+	q := db.Table(e.table)
+	switch e.scope {
+	case scopeTenant:
+		return q.Where("tenant_id = ?", tenantID)
+	case scopeSelf:
+		return q.Where("id = ?", businessID)
+	default:
+		return q.Where("business_id = ?", businessID)
+	}
+}
+
+const triggerFunction = `
+CREATE OR REPLACE FUNCTION record_sync_change() RETURNS trigger AS $$
+DECLARE
+	r jsonb;
+	op text := 'UPSERT';
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		r := to_jsonb(OLD);
+		op := 'DELETE';
+	ELSE
+		r := to_jsonb(NEW);
+		IF TG_OP = 'UPDATE' AND r = to_jsonb(OLD) THEN
+			RETURN NULL;
+		END IF;
+		IF r->>'deleted_at' IS NOT NULL THEN
+			op := 'DELETE';
+		END IF;
+	END IF;
+
+	INSERT INTO sync_changes (tx_id, entity, entity_id, business_id, tenant_id, op, changed_at)
+	VALUES (
+		txid_current(),
+		TG_TABLE_NAME,
+		(r->>'id')::bigint,
+		COALESCE((r->>'business_id')::bigint, CASE WHEN TG_TABLE_NAME = 'businesses' THEN (r->>'id')::bigint END, 0),
+		COALESCE(r->>'tenant_id', ''),
+		op,
+		clock_timestamp()
+	);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql`
+
+// InstallTriggers makes every synced table record its writes in sync_changes.
+// Run after the tables are migrated; it is safe to run again.
+func InstallTriggers(db *gorm.DB) error {
+	if err := db.Exec(triggerFunction).Error; err != nil {
+		return fmt.Errorf("failed to create sync trigger function: %w", err)
+	}
+	for _, e := range entities {
+		if err := db.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS sync_changes ON %s", e.table)).Error; err != nil {
+			return err
+		}
+		stmt := fmt.Sprintf("CREATE TRIGGER sync_changes AFTER INSERT OR UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE FUNCTION record_sync_change()", e.table)
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to add sync trigger to %s: %w", e.table, err)
+		}
+	}
+	return nil
+}
+
+// Changes returns what changed for a business since the cursor, or a full snapshot
+// when since is empty.
+//
+// The cursor follows transactions, not row IDs: a page only holds changes from
+// transactions older than every one still running, so a change committed late by a
+// slow transaction is never skipped. A long-running transaction therefore holds the
+// feed back until it finishes.
+func Changes(db *gorm.DB, businessID uint, since string, limit int) (*Feed, error) {
+	var tenantID string
+	if err := db.Table("businesses").Select("tenant_id").Where("id = ?", businessID).Scan(&tenantID).Error; err != nil {
+		return nil, err
+	}
+
+	var horizon int64
+	if err := db.Raw("SELECT txid_snapshot_xmin(txid_current_snapshot())").Scan(&horizon).Error; err != nil {
+		return nil, err
+	}
+
+	if since == "" {
+		return snapshot(db, businessID, tenantID, horizon)
+	}
+
+	afterTx, afterID, err := parseCursor(since)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	if err := db.Where("(business_id = ? OR (business_id = 0 AND tenant_id = ?)) AND tx_id < ?", businessID, tenantID, horizon).
+		Where("tx_id > ? OR (tx_id = ? AND id > ?)", afterTx, afterTx, afterID).
+		Order("tx_id ASC, id ASC").
+		Limit(limit + 1).
+		Find(&changes).Error; err != nil {
+		return nil, err
+	}
+
+	feed := &Feed{Changes: []FeedItem{}}
+	if len(changes) > limit {
+		changes = changes[:limit]
+		feed.HasMore = true
+	}
+	switch {
+	case feed.HasMore:
+		last := changes[len(changes)-1]
+		feed.Cursor = formatCursor(last.TxID, last.ID)
+	case horizon > afterTx:
+		// Every transaction before the horizon has been read
+		feed.Cursor = formatCursor(horizon, 0)
+	default:
+		feed.Cursor = since
+	}
+
+	feed.Changes, err = resolve(db, businessID, tenantID, changes)
+	if err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// snapshot returns every synced row of the business. Changes from transactions at or
+// after horizon may already be in it; the feed sends them again, which is harmless.
+func snapshot(db *gorm.DB, businessID uint, tenantID string, horizon int64) (*Feed, error) {
+	feed := &Feed{Cursor: formatCursor(horizon, 0), Full: true, Changes: []FeedItem{}}
+	for _, e := range entities {
+		found, err := e.find(e.query(db, businessID, tenantID).Order("id ASC"))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range found {
+			feed.Changes = append(feed.Changes, FeedItem{Entity: e.table, ID: rowID(row), Op: OpUpsert, Data: row})
+		}
+	}
+	return feed, nil
+}
+
+// resolve turns change records into the current state of each row changed, in the
+// order of their last change. A row gone by now is sent as a delete.
+func resolve(db *gorm.DB, businessID uint, tenantID string, changes []Change) ([]FeedItem, error) {
+	type key struct {
+		entity string
+		id     uint
+	}
+	last := make(map[key]int, len(changes))
+	for i, ch := range changes {
+		last[key{ch.Entity, ch.EntityID}] = i
+	}
+
+	upserts := make(map[string][]uint)
+	for i, ch := range changes {
+		if last[key{ch.Entity, ch.EntityID}] == i && ch.Op == OpUpsert {
+			upserts[ch.Entity] = append(upserts[ch.Entity], ch.EntityID)
+		}
+	}
+
+	current := make(map[key]interface{})
+	for _, e := range entities {
+		ids := upserts[e.table]
+		if len(ids) == 0 {
+			continue
+		}
+		found, err := e.find(e.query(db, businessID, tenantID).Where("id IN ?", ids))
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range found {
+			current[key{e.table, rowID(row)}] = row
+		}
+	}
+
+	items := make([]FeedItem, 0, len(last))
+	for i, ch := range changes {
+		k := key{ch.Entity, ch.EntityID}
+		if last[k] != i {
+			continue
+		}
+		item := FeedItem{Entity: ch.Entity, ID: ch.EntityID, Op: OpDelete}
+		if row, ok := current[k]; ok {
+			item.Op = OpUpsert
+			item.Data = row
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func rowID(row interface{}) uint {
+	return uint(reflect.ValueOf(row).FieldByName("ID").Uint())
+}
+
+func formatCursor(txID int64, id uint64) string {
+	return strconv.FormatInt(txID, 10) + "." + strconv.FormatUint(id, 10)
+}
+
+func parseCursor(cursor string) (int64, uint64, error) {
+	tx, id, ok := strings.Cut(cursor, ".")
+	if !ok {
+		return 0, 0, errors.New("invalid cursor")
+	}
+	txID, err := strconv.ParseInt(tx, 10, 64)
+	if err != nil {
+		return 0, 0, errors.New("invalid cursor")
+	}
+	changeID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, 0, errors.New("invalid cursor")
+	}
+	return txID, changeID, nil
+}
@@ -30,3 +30,17 @@ const (
 	CurrencyEUR Currency = "EUR" // Euro
 	// Add more currencies as needed
 )
+
+// TaxClass decides how VAT applies to a product
+type TaxClass string
+
+const (
+	TaxStandard  TaxClass = "STANDARD"   // charged at the business's VAT rate
+	TaxZeroRated TaxClass = "ZERO_RATED" // taxable, at 0%
+	TaxExempt    TaxClass = "EXEMPT"     // outside VAT altogether
+)
+
+// Valid reports whether t is one of the known tax classes
+func (t TaxClass) Valid() bool {
+	return t == TaxStandard || t == TaxZeroRated || t == TaxExempt
+}
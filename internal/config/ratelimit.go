@@ -42,3 +42,19 @@ func LoginLimiter() fiber.Handler {
 		},
 	})
 }
+
+// PublicOrderLimiter limits orders placed from the public QR menu, per customer IP and business
+func PublicOrderLimiter() fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:        5,
+		Expiration: 1 * time.Minute,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.IP() + ":" + c.Params("slug")
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error": "Too many orders, please try again in a minute.",
+			})
+		},
+	})
+}
@@ -0,0 +1,261 @@
+// internal/customer/controller.go
+package customer
+
+import (
+	"strings"
+	"time"
+
+	"pos-fiber-app/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func handleCustomerError(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "not found") {
+		return fiber.NewError(fiber.StatusNotFound, msg)
+	}
+	if strings.Contains(msg, "exceeds") || strings.Contains(msg, "required") ||
+		strings.Contains(msg, "cannot") || strings.Contains(msg, "must") ||
+		strings.Contains(msg, "invalid") || strings.Contains(msg, "no email") {
+		return fiber.NewError(fiber.StatusBadRequest, msg)
+	}
+	return fiber.NewError(fiber.StatusInternalServerError, msg)
+}
+
+// ListCustomers godoc
+// @Summary List customers
+// @Tags Customers
+// @Security BearerAuth
+// @Produce json
+// @Param q query string false "Search by name, phone or email"
+// @Success 200 {array} Customer
+// @Router /customers [get]
+func ListHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		customers, err := List(db, bizID, c.Query("q"))
+		if err != nil {
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(customers)
+	}
+}
+
+// CreateCustomer godoc
+// @Summary Create a customer
+// @Description Add a customer with an optional credit limit and opening balance
+// @Tags Customers
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body CreateCustomerRequest true "Customer details"
+// @Success 201 {object} Customer
+// @Failure 400 {object} map[string]string
+// @Router /customers [post]
+func CreateHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req CreateCustomerRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		cust, err := Create(db, bizID, claims.UserID, req)
+		if err != nil {
+			return handleCustomerError(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(cust)
+	}
+}
+
+// GetCustomer godoc
+// @Summary Get a customer
+// @Tags Customers
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Customer ID"
+// @Success 200 {object} Customer
+// @Failure 404 {object} map[string]string
+// @Router /customers/{id} [get]
+func GetHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid customer ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		cust, err := Get(db, uint(id), bizID)
+		if err != nil {
+			return handleCustomerError(err)
+		}
+
+		return c.JSON(cust)
+	}
+}
+
+// UpdateCustomer godoc
+// @Summary Update a customer
+// @Description Update details or credit limit. The balance only changes through the ledger.
+// @Tags Customers
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Customer ID"
+// @Param body body UpdateCustomerRequest true "Fields to update"
+// @Success 200 {object} Customer
+// @Failure 404 {object} map[string]string
+// @Router /customers/{id} [put]
+func UpdateHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid customer ID")
+		}
+
+		var req UpdateCustomerRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		cust, err := Update(db, uint(id), bizID, req)
+		if err != nil {
+			return handleCustomerError(err)
+		}
+
+		return c.JSON(cust)
+	}
+}
+
+// GetLedger godoc
+// @Summary Customer account ledger
+// @Description Credit sales, repayments, refunds and voids on a customer's account, newest first
+// @Tags Customers
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Customer ID"
+// @Success 200 {array} LedgerEntry
+// @Failure 404 {object} map[string]string
+// @Router /customers/{id}/ledger [get]
+func LedgerHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid customer ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		entries, err := ListLedger(db, uint(id), bizID)
+		if err != nil {
+			return handleCustomerError(err)
+		}
+
+		return c.JSON(entries)
+	}
+}
+
+// RecordRepayment godoc
+// @Summary Record a repayment
+// @Description Credit money received from a customer against their outstanding balance
+// @Tags Customers
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Customer ID"
+// @Param body body RepaymentRequest true "Repayment"
+// @Success 201 {object} LedgerEntry
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /customers/{id}/repayments [post]
+func RepaymentHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid customer ID")
+		}
+
+		var req RepaymentRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		entry, err := RecordRepayment(db, uint(id), bizID, claims.UserID, req)
+		if err != nil {
+			return handleCustomerError(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(entry)
+	}
+}
+
+// ListDebtors godoc
+// @Summary List debtors with ageing
+// @Description Customers with an outstanding balance, split into 0-30, 31-60, 61-90 and 90+ day buckets
+// @Tags Customers
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} DebtorsReport
+// @Router /customers/debtors [get]
+func DebtorsHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		report, err := ListDebtors(db, bizID, time.Now())
+		if err != nil {
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(report)
+	}
+}
+
+// SendStatement godoc
+// @Summary Email a balance statement
+// @Description Email the customer their account activity for a period and the balance due
+// @Tags Customers
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Customer ID"
+// @Param body body StatementRequest false "Statement period"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /customers/{id}/statement [post]
+func StatementHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid customer ID")
+		}
+
+		var req StatementRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+			}
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		if err := SendStatement(db, uint(id), bizID, req); err != nil {
+			return handleCustomerError(err)
+		}
+
+		return c.JSON(fiber.Map{"message": "statement sent"})
+	}
+}
@@ -0,0 +1,115 @@
+// internal/customer/model.go
+package customer
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Customer is a named customer of a business. Balance is what the customer
+// currently owes on their credit (tab) account.
+type Customer struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	BusinessID    uint           `gorm:"index" json:"business_id"`
+	Name          string         `gorm:"size:150;not null" json:"name"`
+	Phone         string         `gorm:"size:30;index" json:"phone,omitempty"`
+	Email         string         `gorm:"size:150" json:"email,omitempty"`
+	Address       string         `json:"address,omitempty"`
+	Notes         string         `gorm:"type:text" json:"notes,omitempty"`
+	CreditLimit   float64        `gorm:"type:decimal(12,2);default:0" json:"credit_limit"` // 0 = no credit allowed
+	Balance       float64        `gorm:"type:decimal(12,2);default:0" json:"balance"`
+	LoyaltyPoints int            `gorm:"default:0" json:"loyalty_points"` // kept in step by the loyalty package
+	Active        bool           `gorm:"default:true" json:"active"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+type EntryType string
+
+const (
+	EntryOpeningBalance EntryType = "OPENING_BALANCE"
+	EntryCreditSale     EntryType = "CREDIT_SALE"
+	EntryRepayment      EntryType = "REPAYMENT"
+	EntryRefund         EntryType = "REFUND"
+	EntryVoid           EntryType = "VOID"
+)
+
+// LedgerEntry is one movement on a customer's account. Amount is signed:
+// debits (credit sales) are positive and credits (repayments, refunds) are negative.
+type LedgerEntry struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CustomerID   uint      `gorm:"index" json:"customer_id"`
+	BusinessID   uint      `gorm:"index" json:"business_id"`
+	Type         EntryType `gorm:"type:varchar(20)" json:"type"`
+	SaleID       *uint     `gorm:"index" json:"sale_id,omitempty"`
+	Amount       float64   `gorm:"type:decimal(12,2)" json:"amount"`
+	BalanceAfter float64   `gorm:"type:decimal(12,2)" json:"balance_after"`
+	Method       string    `gorm:"size:30" json:"method,omitempty"` // how a repayment was made
+	Reference    string    `gorm:"size:100" json:"reference,omitempty"`
+	Notes        string    `json:"notes,omitempty"`
+	RecordedBy   uint      `json:"recorded_by"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+func (LedgerEntry) TableName() string {
+	return "customer_ledger_entries"
+}
+
+type CreateCustomerRequest struct {
+	Name           string  `json:"name" validate:"required"`
+	Phone          string  `json:"phone"`
+	Email          string  `json:"email" validate:"omitempty,email"`
+	Address        string  `json:"address"`
+	Notes          string  `json:"notes"`
+	CreditLimit    float64 `json:"credit_limit" validate:"gte=0"`
+	OpeningBalance float64 `json:"opening_balance" validate:"gte=0"` // debt carried over from before
+}
+
+type UpdateCustomerRequest struct {
+	Name        *string  `json:"name"`
+	Phone       *string  `json:"phone"`
+	Email       *string  `json:"email"`
+	Address     *string  `json:"address"`
+	Notes       *string  `json:"notes"`
+	CreditLimit *float64 `json:"credit_limit"`
+	Active      *bool    `json:"active"`
+}
+
+type RepaymentRequest struct {
+	Amount    float64 `json:"amount" validate:"required,gt=0"`
+	Method    string  `json:"method" validate:"required"` // CASH, TRANSFER, CARD, ...
+	Reference string  `json:"reference"`
+	Notes     string  `json:"notes"`
+}
+
+type StatementRequest struct {
+	From string `json:"from"` // YYYY-MM-DD, defaults to 30 days ago
+	To   string `json:"to"`   // YYYY-MM-DD, defaults to today
+}
+
+// AgeingBuckets splits an outstanding balance by how long it has been owed
+type AgeingBuckets struct {
+	Days0To30  float64 `json:"0_30"`
+	Days31To60 float64 `json:"31_60"`
+	Days61To90 float64 `json:"61_90"`
+	Over90     float64 `json:"90_plus"`
+}
+
+type Debtor struct {
+	CustomerID     uint          `json:"customer_id"`
+	Name           string        `json:"name"`
+	Phone          string        `json:"phone,omitempty"`
+	CreditLimit    float64       `json:"credit_limit"`
+	Balance        float64       `json:"balance"`
+	OldestDebtDate *time.Time    `json:"oldest_debt_date,omitempty"`
+	Ageing         AgeingBuckets `json:"ageing"`
+}
+
+type DebtorsReport struct {
+	AsOf             time.Time     `json:"as_of"`
+	TotalOutstanding float64       `json:"total_outstanding"`
+	Totals           AgeingBuckets `json:"totals"`
+	Debtors          []Debtor      `json:"debtors"`
+}
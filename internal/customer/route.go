@@ -0,0 +1,19 @@
+// internal/customer/route.go
+package customer
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func RegisterCustomerRoutes(r fiber.Router, db *gorm.DB) {
+	group := r.Group("/customers")
+	group.Get("/", ListHandler(db))
+	group.Post("/", CreateHandler(db))
+	group.Get("/debtors", DebtorsHandler(db))
+	group.Get("/:id", GetHandler(db))
+	group.Put("/:id", UpdateHandler(db))
+	group.Get("/:id/ledger", LedgerHandler(db))
+	group.Post("/:id/repayments", RepaymentHandler(db))
+	group.Post("/:id/statement", StatementHandler(db))
+}
@@ -0,0 +1,395 @@
+// internal/customer/service.go
+package customer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Create adds a customer, recording any opening balance on the ledger
+func Create(db *gorm.DB, businessID, userID uint, req CreateCustomerRequest) (*Customer, error) {
+	if req.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if req.CreditLimit < 0 || req.OpeningBalance < 0 {
+		return nil, errors.New("credit limit and opening balance cannot be negative")
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	cust := &Customer{
+		BusinessID:  businessID,
+		Name:        req.Name,
+		Phone:       req.Phone,
+		Email:       req.Email,
+		Address:     req.Address,
+		Notes:       req.Notes,
+		CreditLimit: req.CreditLimit,
+		Active:      true,
+	}
+	if err := tx.Create(cust).Error; err != nil {
+		return nil, err
+	}
+
+	if req.OpeningBalance > 0 {
+		entry := &LedgerEntry{
+			Type:       EntryOpeningBalance,
+			Amount:     roundMoney(req.OpeningBalance),
+			RecordedBy: userID,
+		}
+		if err := postEntry(tx, cust, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return cust, nil
+}
+
+// List returns the customers of a business, optionally matching a name/phone search
+func List(db *gorm.DB, businessID uint, search string) ([]Customer, error) {
+	customers := []Customer{}
+	query := db.Where("business_id = ?", businessID)
+	if search != "" {
+		term := "%" + search + "%"
+		query = query.Where("name ILIKE ? OR phone ILIKE ? OR email ILIKE ?", term, term, term)
+	}
+	err := query.Order("name ASC").Find(&customers).Error
+	return customers, err
+}
+
+// Get retrieves a customer, ensuring it belongs to the business
+func Get(db *gorm.DB, id, businessID uint) (*Customer, error) {
+	var cust Customer
+	if err := db.First(&cust, "id = ? AND business_id = ?", id, businessID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("customer not found")
+		}
+		return nil, err
+	}
+	return &cust, nil
+}
+
+// Update modifies a customer's details. The balance only moves through the ledger.
+func Update(db *gorm.DB, id, businessID uint, req UpdateCustomerRequest) (*Customer, error) {
+	cust, err := Get(db, id, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil && *req.Name != "" {
+		cust.Name = *req.Name
+	}
+	if req.Phone != nil {
+		cust.Phone = *req.Phone
+	}
+	if req.Email != nil {
+		cust.Email = *req.Email
+	}
+	if req.Address != nil {
+		cust.Address = *req.Address
+	}
+	if req.Notes != nil {
+		cust.Notes = *req.Notes
+	}
+	if req.CreditLimit != nil {
+		if *req.CreditLimit < 0 {
+			return nil, errors.New("credit limit cannot be negative")
+		}
+		cust.CreditLimit = *req.CreditLimit
+	}
+	if req.Active != nil {
+		cust.Active = *req.Active
+	}
+
+	// Balances are owned by the ledgers, so only the editable columns are written
+	if err := db.Model(cust).
+		Select("name", "phone", "email", "address", "notes", "credit_limit", "active").
+		Updates(cust).Error; err != nil {
+		return nil, err
+	}
+	return cust, nil
+}
+
+// ListLedger returns a customer's account movements, newest first
+func ListLedger(db *gorm.DB, customerID, businessID uint) ([]LedgerEntry, error) {
+	if _, err := Get(db, customerID, businessID); err != nil {
+		return nil, err
+	}
+	entries := []LedgerEntry{}
+	err := db.Where("customer_id = ? AND business_id = ?", customerID, businessID).
+		Order("created_at DESC, id DESC").
+		Find(&entries).Error
+	return entries, err
+}
+
+// ChargeCredit debits a credit sale to the customer's account, refusing it when the
+// new balance would go over the customer's credit limit. Must run inside the sale's transaction.
+func ChargeCredit(tx *gorm.DB, businessID, customerID, saleID uint, amount float64, userID uint) (*Customer, error) {
+	cust, err := lockCustomer(tx, customerID, businessID)
+	if err != nil {
+		return nil, err
+	}
+	if !cust.Active {
+		return nil, fmt.Errorf("customer %s is inactive and cannot buy on credit", cust.Name)
+	}
+
+	amount = roundMoney(amount)
+	available := roundMoney(cust.CreditLimit - cust.Balance)
+	if amount > available {
+		if available < 0 {
+			available = 0
+		}
+		return nil, fmt.Errorf("credit limit exceeded: %s has %.2f of %.2f available", cust.Name, available, cust.CreditLimit)
+	}
+
+	entry := &LedgerEntry{
+		Type:       EntryCreditSale,
+		SaleID:     &saleID,
+		Amount:     amount,
+		RecordedBy: userID,
+	}
+	if err := postEntry(tx, cust, entry); err != nil {
+		return nil, err
+	}
+	return cust, nil
+}
+
+// CreditRefund credits a customer's account for money refunded to a CREDIT tender
+func CreditRefund(tx *gorm.DB, businessID, customerID, saleID uint, amount float64, userID uint) error {
+	cust, err := lockCustomer(tx, customerID, businessID)
+	if err != nil {
+		return err
+	}
+	entry := &LedgerEntry{
+		Type:       EntryRefund,
+		SaleID:     &saleID,
+		Amount:     -roundMoney(amount),
+		RecordedBy: userID,
+	}
+	return postEntry(tx, cust, entry)
+}
+
+// ReverseSaleCredit credits back whatever a voided sale still has on the customer's account
+// (its credit charges less any refunds already credited). A no-op for sales not sold on credit.
+func ReverseSaleCredit(tx *gorm.DB, businessID, saleID uint, userID uint) error {
+	var rows []struct {
+		CustomerID uint
+		Net        float64
+	}
+	if err := tx.Model(&LedgerEntry{}).
+		Select("customer_id, COALESCE(SUM(amount), 0) AS net").
+		Where("sale_id = ? AND business_id = ?", saleID, businessID).
+		Group("customer_id").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, r := range rows {
+		if roundMoney(r.Net) <= 0 {
+			continue
+		}
+		cust, err := lockCustomer(tx, r.CustomerID, businessID)
+		if err != nil {
+			return err
+		}
+		entry := &LedgerEntry{
+			Type:       EntryVoid,
+			SaleID:     &saleID,
+			Amount:     -roundMoney(r.Net),
+			RecordedBy: userID,
+		}
+		if err := postEntry(tx, cust, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordRepayment credits money received from a customer against their balance
+func RecordRepayment(db *gorm.DB, customerID, businessID, userID uint, req RepaymentRequest) (*LedgerEntry, error) {
+	if req.Amount <= 0 {
+		return nil, errors.New("repayment amount must be greater than zero")
+	}
+	if req.Method == "" {
+		return nil, errors.New("repayment method is required")
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	cust, err := lockCustomer(tx, customerID, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := roundMoney(req.Amount)
+	if amount > roundMoney(cust.Balance) {
+		return nil, fmt.Errorf("repayment of %.2f exceeds outstanding balance of %.2f", amount, cust.Balance)
+	}
+
+	entry := &LedgerEntry{
+		Type:       EntryRepayment,
+		Amount:     -amount,
+		Method:     req.Method,
+		Reference:  req.Reference,
+		Notes:      req.Notes,
+		RecordedBy: userID,
+	}
+	if err := postEntry(tx, cust, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// ListDebtors returns every customer with an outstanding balance, aged into
+// 0-30/31-60/61-90/90+ day buckets. Payments settle the oldest debts first.
+func ListDebtors(db *gorm.DB, businessID uint, asOf time.Time) (*DebtorsReport, error) {
+	var customers []Customer
+	if err := db.Where("business_id = ? AND balance > 0", businessID).Order("balance DESC").Find(&customers).Error; err != nil {
+		return nil, err
+	}
+
+	report := &DebtorsReport{AsOf: asOf, Debtors: []Debtor{}}
+	for _, cust := range customers {
+		var entries []LedgerEntry
+		if err := db.Where("customer_id = ? AND created_at <= ?", cust.ID, asOf).
+			Order("created_at ASC, id ASC").
+			Find(&entries).Error; err != nil {
+			return nil, err
+		}
+
+		debtor := Debtor{
+			CustomerID:  cust.ID,
+			Name:        cust.Name,
+			Phone:       cust.Phone,
+			CreditLimit: cust.CreditLimit,
+		}
+		for _, debt := range outstandingDebts(entries) {
+			if debtor.OldestDebtDate == nil {
+				date := debt.date
+				debtor.OldestDebtDate = &date
+			}
+			debtor.Balance += debt.amount
+			addToBucket(&debtor.Ageing, asOf.Sub(debt.date), debt.amount)
+		}
+		if roundMoney(debtor.Balance) <= 0 {
+			continue
+		}
+
+		debtor.Balance = roundMoney(debtor.Balance)
+		roundBuckets(&debtor.Ageing)
+
+		report.TotalOutstanding += debtor.Balance
+		report.Totals.Days0To30 += debtor.Ageing.Days0To30
+		report.Totals.Days31To60 += debtor.Ageing.Days31To60
+		report.Totals.Days61To90 += debtor.Ageing.Days61To90
+		report.Totals.Over90 += debtor.Ageing.Over90
+		report.Debtors = append(report.Debtors, debtor)
+	}
+
+	report.TotalOutstanding = roundMoney(report.TotalOutstanding)
+	roundBuckets(&report.Totals)
+	sort.SliceStable(report.Debtors, func(i, j int) bool {
+		return report.Debtors[i].Balance > report.Debtors[j].Balance
+	})
+
+	return report, nil
+}
+
+type openDebt struct {
+	date   time.Time
+	amount float64
+}
+
+// outstandingDebts applies every credit on the account to the oldest debits first
+// and returns what is still unpaid of each debit, oldest first
+func outstandingDebts(entries []LedgerEntry) []openDebt {
+	var debts []openDebt
+	var credits float64
+	for _, e := range entries {
+		if e.Amount > 0 {
+			debts = append(debts, openDebt{date: e.CreatedAt, amount: e.Amount})
+		} else {
+			credits -= e.Amount
+		}
+	}
+
+	open := []openDebt{}
+	for _, d := range debts {
+		if credits >= d.amount {
+			credits -= d.amount
+			continue
+		}
+		d.amount -= credits
+		credits = 0
+		open = append(open, d)
+	}
+	return open
+}
+
+func addToBucket(b *AgeingBuckets, age time.Duration, amount float64) {
+	days := int(age.Hours() / 24)
+	switch {
+	case days <= 30:
+		b.Days0To30 += amount
+	case days <= 60:
+		b.Days31To60 += amount
+	case days <= 90:
+		b.Days61To90 += amount
+	default:
+		b.Over90 += amount
+	}
+}
+
+func roundBuckets(b *AgeingBuckets) {
+	b.Days0To30 = roundMoney(b.Days0To30)
+	b.Days31To60 = roundMoney(b.Days31To60)
+	b.Days61To90 = roundMoney(b.Days61To90)
+	b.Over90 = roundMoney(b.Over90)
+}
+
+// lockCustomer loads a customer row for update so concurrent postings serialize
+func lockCustomer(tx *gorm.DB, customerID, businessID uint) (*Customer, error) {
+	var cust Customer
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&cust, "id = ? AND business_id = ?", customerID, businessID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("customer not found")
+		}
+		return nil, err
+	}
+	return &cust, nil
+}
+
+// postEntry writes a ledger entry and moves the customer's running balance with it
+func postEntry(tx *gorm.DB, cust *Customer, entry *LedgerEntry) error {
+	cust.Balance = roundMoney(cust.Balance + entry.Amount)
+
+	entry.CustomerID = cust.ID
+	entry.BusinessID = cust.BusinessID
+	entry.BalanceAfter = cust.Balance
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(cust).Update("balance", cust.Balance).Error
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,104 @@
+// internal/customer/statement.go
+package customer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"pos-fiber-app/internal/email"
+
+	"gorm.io/gorm"
+)
+
+var entryDescriptions = map[EntryType]string{
+	EntryOpeningBalance: "Opening balance",
+	EntryCreditSale:     "Purchase on credit",
+	EntryRepayment:      "Payment received",
+	EntryRefund:         "Refund",
+	EntryVoid:           "Sale cancelled",
+}
+
+// SendStatement emails a customer their account activity for a period together with
+// the balance brought forward and the balance now due
+func SendStatement(db *gorm.DB, customerID, businessID uint, req StatementRequest) error {
+	cust, err := Get(db, customerID, businessID)
+	if err != nil {
+		return err
+	}
+	if cust.Email == "" {
+		return errors.New("customer has no email address")
+	}
+
+	to := time.Now()
+	if req.To != "" {
+		t, err := time.Parse("2006-01-02", req.To)
+		if err != nil {
+			return errors.New("invalid to date, use YYYY-MM-DD")
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -30)
+	if req.From != "" {
+		t, err := time.Parse("2006-01-02", req.From)
+		if err != nil {
+			return errors.New("invalid from date, use YYYY-MM-DD")
+		}
+		from = t
+	}
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, to.Location()).AddDate(0, 0, 1)
+
+	var opening float64
+	db.Model(&LedgerEntry{}).
+		Where("customer_id = ? AND created_at < ?", cust.ID, start).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&opening)
+
+	var entries []LedgerEntry
+	if err := db.Where("customer_id = ? AND created_at >= ? AND created_at < ?", cust.ID, start, end).
+		Order("created_at ASC, id ASC").
+		Find(&entries).Error; err != nil {
+		return err
+	}
+
+	var biz struct {
+		Name     string
+		Currency string
+	}
+	db.Table("businesses").Select("name, currency").Where("id = ?", businessID).Scan(&biz)
+
+	data := email.EmailData{
+		Name:           cust.Name,
+		BusinessName:   biz.Name,
+		Currency:       biz.Currency,
+		Period:         fmt.Sprintf("%s to %s", start.Format("02 Jan 2006"), end.AddDate(0, 0, -1).Format("02 Jan 2006")),
+		OpeningBalance: fmt.Sprintf("%.2f", opening),
+	}
+
+	running := opening
+	for _, e := range entries {
+		running += e.Amount
+		line := email.EmailStatementLine{
+			Date:        e.CreatedAt.Format("02 Jan 2006"),
+			Description: entryDescriptions[e.Type],
+			Balance:     fmt.Sprintf("%.2f", running),
+		}
+		if e.SaleID != nil {
+			line.Description += fmt.Sprintf(" (sale #%d)", *e.SaleID)
+		}
+		if e.Reference != "" {
+			line.Description += " - " + e.Reference
+		}
+		if e.Amount >= 0 {
+			line.Debit = fmt.Sprintf("%.2f", e.Amount)
+		} else {
+			line.Credit = fmt.Sprintf("%.2f", -e.Amount)
+		}
+		data.StatementLines = append(data.StatementLines, line)
+	}
+	data.ClosingBalance = fmt.Sprintf("%.2f", running)
+
+	sender := email.NewSender(email.LoadConfig())
+	return sender.SendCustomerStatement(cust.Email, data)
+}
@@ -42,14 +42,35 @@ type EmailData struct {
 	Expenses  string
 	NetProfit string
 	Profit    string // Gross Profit
+	// Value of customers' unspent loyalty points, empty when the business runs no programme
+	LoyaltyLiability string
 	// Launch Offer Data
 	Discount string
 	PlanName string
+
+	// Customer Statement Data
+	Period         string
+	OpeningBalance string
+	ClosingBalance string
+	StatementLines []EmailStatementLine
+
+	// E-receipt Data
+	ReceiptNo  string
+	ReceiptURL string
+	Total      string
 }
 
 type EmailInventoryItem struct {
 	Name  string
-	Stock int
+	Stock float64
+}
+
+type EmailStatementLine struct {
+	Date        string
+	Description string
+	Debit       string
+	Credit      string
+	Balance     string
 }
 
 var baseTemplates = []string{"templates/header.html", "templates/footer.html"}
@@ -160,3 +160,43 @@ func (s *Sender) SendMonthlyReport(toEmail string, data EmailData) error {
 
 	return s.dialer.DialAndSend(m)
 }
+
+func (s *Sender) SendCustomerStatement(toEmail string, data EmailData) error {
+	data.AppName = s.config.AppName
+	data.AppURL = s.config.AppURL
+	data.SupportEmail = s.config.SupportEmail
+	data.Subject = "Account Statement from " + data.BusinessName
+
+	renderedSubject, htmlBody, err := RenderTemplate("customer_statement.html", data)
+	if err != nil {
+		return err
+	}
+
+	m := mail.NewMessage()
+	m.SetHeader("From", s.config.SMTPFrom)
+	m.SetHeader("To", toEmail)
+	m.SetHeader("Subject", renderedSubject)
+	m.SetBody("text/html", htmlBody)
+
+	return s.dialer.DialAndSend(m)
+}
+
+func (s *Sender) SendEReceipt(toEmail string, data EmailData) error {
+	data.AppName = s.config.AppName
+	data.AppURL = s.config.AppURL
+	data.SupportEmail = s.config.SupportEmail
+	data.Subject = "Your receipt from " + data.BusinessName
+
+	renderedSubject, htmlBody, err := RenderTemplate("e_receipt.html", data)
+	if err != nil {
+		return err
+	}
+
+	m := mail.NewMessage()
+	m.SetHeader("From", s.config.SMTPFrom)
+	m.SetHeader("To", toEmail)
+	m.SetHeader("Subject", renderedSubject)
+	m.SetBody("text/html", htmlBody)
+
+	return s.dialer.DialAndSend(m)
+}
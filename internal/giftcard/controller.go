@@ -0,0 +1,159 @@
+// internal/giftcard/controller.go
+package giftcard
+
+import (
+	"strings"
+
+	"pos-fiber-app/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func handleGiftCardError(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "not found") {
+		return fiber.NewError(fiber.StatusNotFound, msg)
+	}
+	if strings.Contains(msg, "already") || strings.Contains(msg, "must") ||
+		strings.Contains(msg, "invalid") {
+		return fiber.NewError(fiber.StatusBadRequest, msg)
+	}
+	return fiber.NewError(fiber.StatusInternalServerError, msg)
+}
+
+// ListGiftCards godoc
+// @Summary List gift cards
+// @Tags Gift Cards
+// @Security BearerAuth
+// @Produce json
+// @Param q query string false "Search by code or barcode"
+// @Success 200 {array} Card
+// @Router /gift-cards [get]
+func ListHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		cards, err := List(db, bizID, c.Query("q"))
+		if err != nil {
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(cards)
+	}
+}
+
+// IssueGiftCard godoc
+// @Summary Issue a gift card
+// @Description Issue a complimentary or promotional card. Cards bought by customers are issued by selling a gift card product.
+// @Tags Gift Cards
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body IssueRequest true "Card value and optional pre-printed barcode"
+// @Success 201 {object} Card
+// @Failure 400 {object} map[string]string
+// @Router /gift-cards [post]
+func IssueHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req IssueRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		card, err := Issue(db, bizID, claims.UserID, req)
+		if err != nil {
+			return handleGiftCardError(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(card)
+	}
+}
+
+// CheckGiftCardBalance godoc
+// @Summary Check a gift card balance
+// @Tags Gift Cards
+// @Security BearerAuth
+// @Produce json
+// @Param code path string true "Card code or barcode"
+// @Success 200 {object} Balance
+// @Failure 404 {object} map[string]string
+// @Router /gift-cards/balance/{code} [get]
+func BalanceHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		balance, err := CheckBalance(db, bizID, c.Params("code"))
+		if err != nil {
+			return handleGiftCardError(err)
+		}
+
+		return c.JSON(balance)
+	}
+}
+
+// GetGiftCard godoc
+// @Summary Get a gift card
+// @Description A card with every issue, top-up, redemption and refund on it, newest first
+// @Tags Gift Cards
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Card ID"
+// @Success 200 {object} CardDetails
+// @Failure 404 {object} map[string]string
+// @Router /gift-cards/{id} [get]
+func GetHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid gift card ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		details, err := Get(db, uint(id), bizID)
+		if err != nil {
+			return handleGiftCardError(err)
+		}
+
+		return c.JSON(details)
+	}
+}
+
+// DeactivateGiftCard godoc
+// @Summary Deactivate a gift card
+// @Description Block a lost or stolen card so it can no longer be redeemed or topped up
+// @Tags Gift Cards
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Card ID"
+// @Success 200 {object} Card
+// @Failure 404 {object} map[string]string
+// @Router /gift-cards/{id}/deactivate [post]
+func DeactivateHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid gift card ID")
+		}
+
+		var req struct {
+			Notes string `json:"notes"`
+		}
+		_ = c.BodyParser(&req)
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		card, err := Deactivate(db, uint(id), bizID, claims.UserID, req.Notes)
+		if err != nil {
+			return handleGiftCardError(err)
+		}
+
+		return c.JSON(card)
+	}
+}
@@ -0,0 +1,107 @@
+// internal/giftcard/model.go
+package giftcard
+
+import (
+	"time"
+)
+
+type Kind string
+
+const (
+	KindGiftCard    Kind = "GIFT_CARD"
+	KindStoreCredit Kind = "STORE_CREDIT" // issued instead of cash on a refund
+)
+
+// Card is a stored-value card. Code is generated and printed on the card; Barcode is
+// the optional number of a pre-printed card. Either can be used to find the card.
+type Card struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	BusinessID   uint       `gorm:"index;uniqueIndex:idx_gift_card_code" json:"business_id"`
+	Code         string     `gorm:"size:32;uniqueIndex:idx_gift_card_code" json:"code"`
+	Barcode      string     `gorm:"size:100;index" json:"barcode,omitempty"`
+	Kind         Kind       `gorm:"type:varchar(20);default:'GIFT_CARD'" json:"kind"`
+	InitialValue float64    `gorm:"type:decimal(12,2)" json:"initial_value"`
+	Balance      float64    `gorm:"type:decimal(12,2);default:0" json:"balance"`
+	CustomerID   *uint      `gorm:"index" json:"customer_id,omitempty"`
+	Active       bool       `gorm:"default:true" json:"active"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	IssuedBy     uint       `json:"issued_by"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (Card) TableName() string {
+	return "gift_cards"
+}
+
+type TransactionType string
+
+const (
+	TxIssue      TransactionType = "ISSUE"
+	TxTopUp      TransactionType = "TOP_UP"
+	TxRedeem     TransactionType = "REDEEM"
+	TxRefund     TransactionType = "REFUND" // redeemed value put back on the card
+	TxVoid       TransactionType = "VOID"   // issue or top-up taken back (sale voided or refunded)
+	TxDeactivate TransactionType = "DEACTIVATE"
+)
+
+// Transaction is one movement on a card. Amount is signed: value loaded is
+// positive and value spent or removed is negative.
+type Transaction struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	CardID       uint            `gorm:"index" json:"card_id"`
+	BusinessID   uint            `gorm:"index" json:"business_id"`
+	Type         TransactionType `gorm:"type:varchar(20)" json:"type"`
+	Amount       float64         `gorm:"type:decimal(12,2)" json:"amount"`
+	BalanceAfter float64         `gorm:"type:decimal(12,2)" json:"balance_after"`
+	SaleID       *uint           `gorm:"index" json:"sale_id,omitempty"`
+	SaleItemID   *uint           `gorm:"index" json:"sale_item_id,omitempty"` // the gift card line that loaded the value
+	RefundID     *uint           `gorm:"index" json:"refund_id,omitempty"`
+	ReversalOf   *uint           `gorm:"index" json:"reversal_of,omitempty"` // the load a VOID takes back
+	Notes        string          `json:"notes,omitempty"`
+	RecordedBy   uint            `json:"recorded_by"`
+	CreatedAt    time.Time       `gorm:"index" json:"created_at"`
+}
+
+func (Transaction) TableName() string {
+	return "gift_card_transactions"
+}
+
+// IssueRequest issues a card outside a sale, e.g. a complimentary or promotional card.
+// Cards bought by customers are issued by selling a gift card product.
+type IssueRequest struct {
+	Amount     float64 `json:"amount" validate:"required,gt=0"`
+	Barcode    string  `json:"barcode"`
+	CustomerID *uint   `json:"customer_id"`
+	ExpiresAt  string  `json:"expires_at"` // YYYY-MM-DD, optional
+	Notes      string  `json:"notes"`
+}
+
+// Load describes value put on a card: a new card when Code is empty or unknown, a
+// top-up of an existing card otherwise
+type Load struct {
+	Code       string
+	Amount     float64
+	CustomerID *uint
+	SaleID     *uint
+	SaleItemID *uint
+	RefundID   *uint
+	Kind       Kind
+	ExpiresAt  *time.Time
+	Notes      string
+	RecordedBy uint
+}
+
+type CardDetails struct {
+	Card         *Card         `json:"card"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+// Balance is what a balance check shows, without the card's history
+type Balance struct {
+	Code      string     `json:"code"`
+	Kind      Kind       `json:"kind"`
+	Balance   float64    `json:"balance"`
+	Active    bool       `json:"active"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,16 @@
+// internal/giftcard/route.go
+package giftcard
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func RegisterGiftCardRoutes(r fiber.Router, db *gorm.DB) {
+	group := r.Group("/gift-cards")
+	group.Get("/", ListHandler(db))
+	group.Post("/", IssueHandler(db))
+	group.Get("/balance/:code", BalanceHandler(db))
+	group.Get("/:id", GetHandler(db))
+	group.Post("/:id/deactivate", DeactivateHandler(db))
+}
@@ -0,0 +1,469 @@
+// internal/giftcard/service.go
+package giftcard
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// codeAlphabet leaves out characters that are easy to misread (0/O, 1/I/L)
+const codeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+const codeLength = 16
+
+// LoadValue puts value on a card inside the caller's transaction. A Load without a
+// code, or with a code no card has yet (a pre-printed card), issues a new card;
+// otherwise the existing card is topped up.
+func LoadValue(tx *gorm.DB, businessID uint, l Load) (*Card, error) {
+	amount := roundMoney(l.Amount)
+	if amount <= 0 {
+		return nil, errors.New("gift card amount must be greater than zero")
+	}
+
+	if l.Code != "" {
+		card, err := lockCard(tx, businessID, l.Code)
+		if err == nil {
+			if err := checkUsable(card); err != nil {
+				return nil, err
+			}
+			entry := &Transaction{
+				Type:       TxTopUp,
+				Amount:     amount,
+				SaleID:     l.SaleID,
+				SaleItemID: l.SaleItemID,
+				RefundID:   l.RefundID,
+				Notes:      l.Notes,
+				RecordedBy: l.RecordedBy,
+			}
+			if err := postTransaction(tx, card, entry); err != nil {
+				return nil, err
+			}
+			return card, nil
+		}
+		if !errors.Is(err, errCardNotFound) {
+			return nil, err
+		}
+	}
+
+	code, err := generateCode(tx, businessID)
+	if err != nil {
+		return nil, err
+	}
+	kind := l.Kind
+	if kind == "" {
+		kind = KindGiftCard
+	}
+	card := &Card{
+		BusinessID:   businessID,
+		Code:         code,
+		Barcode:      strings.TrimSpace(l.Code),
+		Kind:         kind,
+		InitialValue: amount,
+		CustomerID:   l.CustomerID,
+		Active:       true,
+		ExpiresAt:    l.ExpiresAt,
+		IssuedBy:     l.RecordedBy,
+	}
+	if err := tx.Create(card).Error; err != nil {
+		return nil, err
+	}
+
+	entry := &Transaction{
+		Type:       TxIssue,
+		Amount:     amount,
+		SaleID:     l.SaleID,
+		SaleItemID: l.SaleItemID,
+		RefundID:   l.RefundID,
+		Notes:      l.Notes,
+		RecordedBy: l.RecordedBy,
+	}
+	if err := postTransaction(tx, card, entry); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// Redeem spends amount from a card to pay for a sale. Partial redemption is the
+// norm: whatever the card does not cover is paid with other tenders.
+func Redeem(tx *gorm.DB, businessID uint, code string, amount float64, saleID, userID uint) (*Card, error) {
+	card, err := lockCard(tx, businessID, code)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUsable(card); err != nil {
+		return nil, err
+	}
+
+	amount = roundMoney(amount)
+	if amount > card.Balance {
+		return nil, fmt.Errorf("insufficient gift card balance: %.2f available on %s", card.Balance, card.Code)
+	}
+
+	entry := &Transaction{
+		Type:       TxRedeem,
+		Amount:     -amount,
+		SaleID:     &saleID,
+		RecordedBy: userID,
+	}
+	if err := postTransaction(tx, card, entry); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// RefundToCards puts amount back on the cards that paid for a sale, in the order they
+// were used, as when money paid by gift card is refunded
+func RefundToCards(tx *gorm.DB, businessID, saleID uint, refundID *uint, amount float64, userID uint) error {
+	var rows []struct {
+		CardID uint
+		Net    float64
+	}
+	if err := tx.Model(&Transaction{}).
+		Select("card_id, -SUM(amount) AS net, MIN(id) AS first_id").
+		Where("sale_id = ? AND business_id = ? AND type IN ?", saleID, businessID, []TransactionType{TxRedeem, TxRefund}).
+		Group("card_id").
+		Order("first_id ASC").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	remaining := roundMoney(amount)
+	for _, r := range rows {
+		if remaining <= 0 {
+			break
+		}
+		available := roundMoney(r.Net)
+		if available <= 0 {
+			continue
+		}
+		portion := math.Min(available, remaining)
+
+		var card Card
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&card, r.CardID).Error; err != nil {
+			return err
+		}
+		entry := &Transaction{
+			Type:       TxRefund,
+			Amount:     roundMoney(portion),
+			SaleID:     &saleID,
+			RefundID:   refundID,
+			RecordedBy: userID,
+		}
+		if err := postTransaction(tx, &card, entry); err != nil {
+			return err
+		}
+		remaining = roundMoney(remaining - portion)
+	}
+
+	if remaining > 0 {
+		return fmt.Errorf("cannot refund %.2f to gift cards: more than was paid by gift card", remaining)
+	}
+	return nil
+}
+
+// ReverseLoads takes back the value loaded by units of a gift card line, as when the
+// line is refunded. Only cards that still hold the loaded value can be taken back.
+func ReverseLoads(tx *gorm.DB, businessID, saleItemID uint, units int, refundID *uint, userID uint) error {
+	var loads []Transaction
+	if err := tx.Where("sale_item_id = ? AND business_id = ? AND type IN ?", saleItemID, businessID, []TransactionType{TxIssue, TxTopUp}).
+		Order("id ASC").
+		Find(&loads).Error; err != nil {
+		return err
+	}
+
+	reversed := 0
+	for _, load := range loads {
+		if reversed >= units {
+			break
+		}
+		done, err := reverseLoad(tx, load, refundID, userID)
+		if err != nil {
+			return err
+		}
+		if done {
+			reversed++
+		}
+	}
+
+	if reversed < units {
+		return fmt.Errorf("cannot refund gift card: %d of the cards have already been used", units-reversed)
+	}
+	return nil
+}
+
+// ReverseSale undoes a voided sale on gift cards: cards it sold or topped up are
+// emptied and value it redeemed goes back on the cards
+func ReverseSale(tx *gorm.DB, businessID, saleID, userID uint) error {
+	var loads []Transaction
+	if err := tx.Where("sale_id = ? AND business_id = ? AND sale_item_id IS NOT NULL AND type IN ?", saleID, businessID, []TransactionType{TxIssue, TxTopUp}).
+		Order("id ASC").
+		Find(&loads).Error; err != nil {
+		return err
+	}
+	for _, load := range loads {
+		done, err := reverseLoad(tx, load, nil, userID)
+		if err != nil {
+			return err
+		}
+		if !done && !isReversed(tx, load) {
+			return errors.New("cannot void sale: a gift card it sold has already been used")
+		}
+	}
+
+	var redeemed float64
+	if err := tx.Model(&Transaction{}).
+		Select("COALESCE(-SUM(amount), 0)").
+		Where("sale_id = ? AND business_id = ? AND type IN ?", saleID, businessID, []TransactionType{TxRedeem, TxRefund}).
+		Scan(&redeemed).Error; err != nil {
+		return err
+	}
+	if roundMoney(redeemed) <= 0 {
+		return nil
+	}
+	return RefundToCards(tx, businessID, saleID, nil, redeemed, userID)
+}
+
+// Issue issues a card outside a sale
+func Issue(db *gorm.DB, businessID, userID uint, req IssueRequest) (*Card, error) {
+	load := Load{
+		Code:       req.Barcode,
+		Amount:     req.Amount,
+		CustomerID: req.CustomerID,
+		Kind:       KindGiftCard,
+		Notes:      req.Notes,
+		RecordedBy: userID,
+	}
+	if req.ExpiresAt != "" {
+		expires, err := time.Parse("2006-01-02", req.ExpiresAt)
+		if err != nil {
+			return nil, errors.New("invalid expiry date, use YYYY-MM-DD")
+		}
+		load.ExpiresAt = &expires
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	if req.Barcode != "" {
+		if _, err := findCard(tx, businessID, req.Barcode); err == nil {
+			return nil, errors.New("a gift card with this barcode already exists")
+		}
+	}
+
+	card, err := LoadValue(tx, businessID, load)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// List returns a business's cards, newest first, optionally matching a code or barcode
+func List(db *gorm.DB, businessID uint, search string) ([]Card, error) {
+	cards := []Card{}
+	query := db.Where("business_id = ?", businessID)
+	if search != "" {
+		term := "%" + normalizeCode(search) + "%"
+		query = query.Where("code LIKE ? OR barcode LIKE ?", term, "%"+strings.TrimSpace(search)+"%")
+	}
+	err := query.Order("created_at DESC").Find(&cards).Error
+	return cards, err
+}
+
+// Get returns a card with its movements, newest first
+func Get(db *gorm.DB, id, businessID uint) (*CardDetails, error) {
+	var card Card
+	if err := db.First(&card, "id = ? AND business_id = ?", id, businessID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errCardNotFound
+		}
+		return nil, err
+	}
+
+	transactions := []Transaction{}
+	if err := db.Where("card_id = ?", card.ID).Order("created_at DESC, id DESC").Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	return &CardDetails{Card: &card, Transactions: transactions}, nil
+}
+
+// CheckBalance looks a card up by code or barcode
+func CheckBalance(db *gorm.DB, businessID uint, code string) (*Balance, error) {
+	card, err := findCard(db, businessID, code)
+	if err != nil {
+		return nil, err
+	}
+	return &Balance{
+		Code:      card.Code,
+		Kind:      card.Kind,
+		Balance:   card.Balance,
+		Active:    card.Active,
+		ExpiresAt: card.ExpiresAt,
+	}, nil
+}
+
+// Deactivate blocks a lost or stolen card. The balance stays on the card's record.
+func Deactivate(db *gorm.DB, id, businessID, userID uint, notes string) (*Card, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var card Card
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&card, "id = ? AND business_id = ?", id, businessID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errCardNotFound
+		}
+		return nil, err
+	}
+	if !card.Active {
+		return nil, errors.New("gift card is already inactive")
+	}
+
+	card.Active = false
+	if err := tx.Model(&card).Update("active", false).Error; err != nil {
+		return nil, err
+	}
+	entry := &Transaction{
+		Type:       TxDeactivate,
+		Notes:      notes,
+		RecordedBy: userID,
+	}
+	if err := postTransaction(tx, &card, entry); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &card, nil
+}
+
+var errCardNotFound = errors.New("gift card not found")
+
+// reverseLoad empties the value a load put on its card, unless it has been taken back
+// already. It reports false when the card no longer holds that value.
+func reverseLoad(tx *gorm.DB, load Transaction, refundID *uint, userID uint) (bool, error) {
+	if isReversed(tx, load) {
+		return false, nil
+	}
+
+	var card Card
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&card, load.CardID).Error; err != nil {
+		return false, err
+	}
+	if card.Balance < load.Amount {
+		return false, nil
+	}
+
+	entry := &Transaction{
+		Type:       TxVoid,
+		Amount:     -load.Amount,
+		SaleID:     load.SaleID,
+		SaleItemID: load.SaleItemID,
+		RefundID:   refundID,
+		ReversalOf: &load.ID,
+		RecordedBy: userID,
+	}
+	if load.Type == TxIssue {
+		card.Active = false
+		if err := tx.Model(&card).Update("active", false).Error; err != nil {
+			return false, err
+		}
+	}
+	if err := postTransaction(tx, &card, entry); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func isReversed(tx *gorm.DB, load Transaction) bool {
+	var count int64
+	tx.Model(&Transaction{}).
+		Where("reversal_of = ?", load.ID).
+		Count(&count)
+	return count > 0
+}
+
+func checkUsable(card *Card) error {
+	if !card.Active {
+		return fmt.Errorf("gift card %s is inactive", card.Code)
+	}
+	if card.ExpiresAt != nil && card.ExpiresAt.Before(time.Now()) {
+		return fmt.Errorf("gift card %s expired on %s", card.Code, card.ExpiresAt.Format("2006-01-02"))
+	}
+	return nil
+}
+
+func findCard(db *gorm.DB, businessID uint, code string) (*Card, error) {
+	var card Card
+	err := db.Where("business_id = ? AND (code = ? OR barcode = ?)", businessID, normalizeCode(code), strings.TrimSpace(code)).
+		First(&card).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errCardNotFound
+		}
+		return nil, err
+	}
+	return &card, nil
+}
+
+// lockCard loads a card by code or barcode for update so concurrent movements serialize
+func lockCard(tx *gorm.DB, businessID uint, code string) (*Card, error) {
+	return findCard(tx.Clauses(clause.Locking{Strength: "UPDATE"}), businessID, code)
+}
+
+// postTransaction writes a movement and moves the card's balance with it
+func postTransaction(tx *gorm.DB, card *Card, entry *Transaction) error {
+	card.Balance = roundMoney(card.Balance + entry.Amount)
+
+	entry.CardID = card.ID
+	entry.BusinessID = card.BusinessID
+	entry.BalanceAfter = card.Balance
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(card).Update("balance", card.Balance).Error
+}
+
+func generateCode(tx *gorm.DB, businessID uint) (string, error) {
+	alphabetSize := big.NewInt(int64(len(codeAlphabet)))
+	for attempt := 0; attempt < 5; attempt++ {
+		b := make([]byte, codeLength)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, alphabetSize)
+			if err != nil {
+				return "", err
+			}
+			b[i] = codeAlphabet[n.Int64()]
+		}
+		code := string(b)
+
+		var count int64
+		tx.Model(&Card{}).Where("business_id = ? AND code = ?", businessID, code).Count(&count)
+		if count == 0 {
+			return code, nil
+		}
+	}
+	return "", errors.New("could not generate a unique gift card code")
+}
+
+// normalizeCode lets codes be typed with spaces or dashes and in any case
+func normalizeCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
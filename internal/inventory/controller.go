@@ -1,7 +1,10 @@
 package inventory
 
 import (
+	"time"
+
 	"pos-fiber-app/internal/notification"
+	"pos-fiber-app/internal/types"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -14,21 +17,41 @@ import (
 // @Accept json
 // @Produce json
 // @Param product_id path uint true "Product ID"
-// @Param body body object{quantity=int} true "Stock adjustment (positive = add, negative = deduct)"
+// @Description Reason is RESTOCK, ADJUSTMENT, WASTAGE or TRANSFER; it defaults to RESTOCK for additions and ADJUSTMENT for deductions
+// @Param body body object{quantity=number,reason=string,note=string} true "Stock adjustment (positive = add, negative = deduct)"
 // @Success 200 {object} Inventory
 // @Router /products/{product_id}/stock [post]
 func RestockHandler(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		productID, _ := c.ParamsInt("product_id")
 		var req struct {
-			Quantity int `json:"quantity"`
+			Quantity float64        `json:"quantity"`
+			Reason   MovementReason `json:"reason"`
+			Note     string         `json:"note"`
 		}
 		if err := c.BodyParser(&req); err != nil {
 			return fiber.NewError(400, "invalid quantity")
 		}
 		bizID := c.Locals("current_business_id").(uint)
 
-		if err := AdjustStock(db, uint(productID), bizID, req.Quantity); err != nil {
+		switch req.Reason {
+		case "":
+			req.Reason = ReasonRestock
+			if req.Quantity < 0 {
+				req.Reason = ReasonAdjustment
+			}
+		case ReasonRestock, ReasonAdjustment, ReasonTransfer:
+		case ReasonWastage:
+			if req.Quantity > 0 {
+				return fiber.NewError(400, "wastage must be a negative quantity")
+			}
+		default:
+			return fiber.NewError(400, "reason must be RESTOCK, ADJUSTMENT, WASTAGE or TRANSFER")
+		}
+
+		m := movementBy(c)
+		m.Reason, m.Note = req.Reason, req.Note
+		if err := AdjustStock(WithMovement(db, m), uint(productID), bizID, req.Quantity); err != nil {
 			return fiber.ErrInternalServerError
 		}
 
@@ -134,7 +157,7 @@ func StartRoundHandler(db *gorm.DB) fiber.Handler {
 		}
 		bizID := c.Locals("current_business_id").(uint)
 
-		round, err := StartNewRound(db, bizID, req.ProductID, req.TotalVolume, req.Cost)
+		round, err := StartNewRound(WithMovement(db, movementBy(c)), bizID, req.ProductID, req.TotalVolume, req.Cost)
 		if err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 		}
@@ -156,7 +179,7 @@ func CloseRoundHandler(db *gorm.DB) fiber.Handler {
 		roundID, _ := c.ParamsInt("id")
 		bizID := c.Locals("current_business_id").(uint)
 
-		if err := CloseRound(db, bizID, uint(roundID)); err != nil {
+		if err := CloseRound(WithMovement(db, movementBy(c)), bizID, uint(roundID)); err != nil {
 			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 		}
 
@@ -216,3 +239,73 @@ func GetInventorySummaryHandler(db *gorm.DB) fiber.Handler {
 		return c.JSON(summary)
 	}
 }
+
+// StockCard godoc
+// @Summary Product stock card
+// @Description Every movement of a product's stock over a period, with its opening and closing balances
+// @Tags Inventory
+// @Security BearerAuth
+// @Produce json
+// @Param product_id path uint true "Product ID"
+// @Param from query string false "YYYY-MM-DD (defaults to the first of this month)"
+// @Param to query string false "YYYY-MM-DD (defaults to today)"
+// @Success 200 {object} StockCard
+// @Failure 400 {object} map[string]string
+// @Router /inventory/products/{product_id}/stock-card [get]
+func StockCardHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		productID, err := c.ParamsInt("product_id")
+		if err != nil || productID <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid product ID")
+		}
+		bizID := c.Locals("current_business_id").(uint)
+
+		now := time.Now()
+		from := c.Query("from", time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Format("2006-01-02"))
+		to := c.Query("to", now.Format("2006-01-02"))
+
+		card, err := GetStockCard(db, bizID, uint(productID), from, to)
+		if err != nil {
+			if err.Error() == "product not found" {
+				return fiber.NewError(fiber.StatusNotFound, err.Error())
+			}
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		return c.JSON(card)
+	}
+}
+
+// StockValuation godoc
+// @Summary Stock valuation as of a date
+// @Description Each product's stock at the end of the day, valued at its cost when last moved
+// @Tags Inventory
+// @Security BearerAuth
+// @Produce json
+// @Param as_of query string false "YYYY-MM-DD (defaults to today)"
+// @Success 200 {object} StockValuation
+// @Failure 400 {object} map[string]string
+// @Router /inventory/valuation [get]
+func StockValuationHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+		asOf := c.Query("as_of", time.Now().Format("2006-01-02"))
+
+		valuation, err := GetStockValuation(db, bizID, asOf)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		return c.JSON(valuation)
+	}
+}
+
+// movementBy returns the user and outlet a request's stock changes are recorded against
+func movementBy(c *fiber.Ctx) Movement {
+	var m Movement
+	if claims, ok := c.Locals("user").(*types.UserClaims); ok && claims != nil {
+		m.UserID = &claims.UserID
+		m.OutletID = claims.OutletID
+	}
+	return m
+}
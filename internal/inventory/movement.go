@@ -0,0 +1,267 @@
+// internal/inventory/movement.go
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MovementReason says why a product's stock moved
+type MovementReason string
+
+const (
+	ReasonSale              MovementReason = "SALE"
+	ReasonVoid              MovementReason = "VOID"
+	ReasonRefund            MovementReason = "REFUND"
+	ReasonRestock           MovementReason = "RESTOCK"
+	ReasonAdjustment        MovementReason = "ADJUSTMENT"
+	ReasonTransfer          MovementReason = "TRANSFER"
+	ReasonWastage           MovementReason = "WASTAGE"
+	ReasonRecipeConsumption MovementReason = "RECIPE_CONSUMPTION" // ingredients used up by a recipe product
+)
+
+// StockMovement is one change to a product's stock. Delta is signed (sales and
+// wastage are negative) and Balance is the stock on hand after it. Movements are
+// never updated or deleted: a mistake is corrected by another movement.
+type StockMovement struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	ProductID  uint           `gorm:"index" json:"product_id"`
+	BusinessID uint           `gorm:"index" json:"business_id"`
+	OutletID   *uint          `gorm:"index" json:"outlet_id,omitempty"`
+	Delta      float64        `gorm:"type:decimal(14,3)" json:"delta"`
+	Balance    float64        `gorm:"type:decimal(14,3)" json:"balance"`
+	UnitCost   float64        `gorm:"type:decimal(12,2)" json:"unit_cost"` // product cost at the time, for valuation
+	Reason     MovementReason `gorm:"type:varchar(20);index" json:"reason"`
+	RefType    string         `gorm:"size:30" json:"ref_type,omitempty"` // source document, e.g. "sale", "refund"
+	RefID      uint           `json:"ref_id,omitempty"`
+	UserID     *uint          `json:"user_id,omitempty"`
+	Note       string         `json:"note,omitempty"`
+	CreatedAt  time.Time      `gorm:"index" json:"created_at"`
+}
+
+var errMovementImmutable = errors.New("stock movements cannot be changed")
+
+func (StockMovement) BeforeUpdate(*gorm.DB) error { return errMovementImmutable }
+func (StockMovement) BeforeDelete(*gorm.DB) error { return errMovementImmutable }
+
+// Movement is what the stock changes made on a session are recorded against
+type Movement struct {
+	Reason   MovementReason
+	RefType  string
+	RefID    uint
+	OutletID *uint
+	UserID   *uint
+	Note     string
+}
+
+type movementKey struct{}
+
+// WithMovement returns a session whose stock changes are recorded with m's reason,
+// source document and user. Changes made without one are recorded as adjustments.
+func WithMovement(db *gorm.DB, m Movement) *gorm.DB {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return db.WithContext(context.WithValue(ctx, movementKey{}, m))
+}
+
+// WithReason keeps the session's source document and user but records its stock
+// changes with a different reason, e.g. the ingredients consumed by a sale
+func WithReason(db *gorm.DB, reason MovementReason) *gorm.DB {
+	m := movementFrom(db)
+	m.Reason = reason
+	return WithMovement(db, m)
+}
+
+// movementFrom returns the movement set by WithMovement, an adjustment if none was
+func movementFrom(db *gorm.DB) Movement {
+	var m Movement
+	if db.Statement != nil && db.Statement.Context != nil {
+		m, _ = db.Statement.Context.Value(movementKey{}).(Movement)
+	}
+	if m.Reason == "" {
+		m.Reason = ReasonAdjustment
+	}
+	return m
+}
+
+// RecordMovement appends a movement of delta, leaving balance on hand, to a product's
+// stock card. Callers that change stock without AdjustStock must record it themselves.
+func RecordMovement(db *gorm.DB, productID, businessID uint, delta, balance float64) error {
+	if delta == 0 {
+		return nil
+	}
+	m := movementFrom(db)
+
+	var unitCost float64
+	db.Table("products").Select("cost").Where("id = ?", productID).Scan(&unitCost)
+
+	movement := StockMovement{
+		ProductID:  productID,
+		BusinessID: businessID,
+		OutletID:   m.OutletID,
+		Delta:      RoundQuantity(delta),
+		Balance:    RoundQuantity(balance),
+		UnitCost:   unitCost,
+		Reason:     m.Reason,
+		RefType:    m.RefType,
+		RefID:      m.RefID,
+		UserID:     m.UserID,
+		Note:       m.Note,
+	}
+	if err := db.Create(&movement).Error; err != nil {
+		return fmt.Errorf("failed to record stock movement: %w", err)
+	}
+	return nil
+}
+
+// OpenLedger books the stock already on hand of products with no movements yet as an
+// opening balance, so their stock cards add up to their current stock
+func OpenLedger(db *gorm.DB) error {
+	var rows []struct {
+		ProductID  uint
+		BusinessID uint
+		Stock      float64
+	}
+	err := db.Table("products").
+		Select("products.id AS product_id, products.business_id, CASE WHEN products.track_by_round THEN products.stock ELSE COALESCE(inventories.current_stock, products.stock) END AS stock").
+		Joins("LEFT JOIN inventories ON inventories.product_id = products.id AND inventories.business_id = products.business_id").
+		Where("products.deleted_at IS NULL AND products.is_gift_card = ?", false).
+		Where("NOT EXISTS (SELECT 1 FROM stock_movements WHERE stock_movements.product_id = products.id)").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	opening := WithMovement(db, Movement{Reason: ReasonAdjustment, Note: "opening balance"})
+	for _, r := range rows {
+		if err := RecordMovement(opening, r.ProductID, r.BusinessID, r.Stock, r.Stock); err != nil {
+			return err
+		}
+	}
+	if len(rows) > 0 {
+		log.Printf("Inventory: opened stock ledger for %d products", len(rows))
+	}
+	return nil
+}
+
+// StockCard is a product's movements over a period between its opening and closing balances
+type StockCard struct {
+	ProductID      uint            `json:"product_id"`
+	ProductName    string          `json:"product_name"`
+	From           string          `json:"from"`
+	To             string          `json:"to"`
+	OpeningBalance float64         `json:"opening_balance"`
+	TotalIn        float64         `json:"total_in"`
+	TotalOut       float64         `json:"total_out"`
+	ClosingBalance float64         `json:"closing_balance"`
+	Movements      []StockMovement `json:"movements"`
+}
+
+// GetStockCard lists a product's movements between from and to (YYYY-MM-DD, inclusive)
+func GetStockCard(db *gorm.DB, businessID, productID uint, from, to string) (*StockCard, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, errors.New("invalid from date, use YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return nil, errors.New("invalid to date, use YYYY-MM-DD")
+	}
+	end = end.AddDate(0, 0, 1)
+
+	var name string
+	if err := db.Table("products").Select("name").Where("id = ? AND business_id = ?", productID, businessID).Scan(&name).Error; err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, errors.New("product not found")
+	}
+
+	card := &StockCard{ProductID: productID, ProductName: name, From: from, To: to, Movements: []StockMovement{}}
+
+	var last StockMovement
+	err = db.Where("product_id = ? AND business_id = ? AND created_at < ?", productID, businessID, start).
+		Order("id DESC").First(&last).Error
+	if err == nil {
+		card.OpeningBalance = last.Balance
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if err := db.Where("product_id = ? AND business_id = ? AND created_at >= ? AND created_at < ?", productID, businessID, start, end).
+		Order("id ASC").Find(&card.Movements).Error; err != nil {
+		return nil, err
+	}
+
+	card.ClosingBalance = card.OpeningBalance
+	for _, m := range card.Movements {
+		if m.Delta > 0 {
+			card.TotalIn += m.Delta
+		} else {
+			card.TotalOut -= m.Delta
+		}
+		card.ClosingBalance = m.Balance
+	}
+	card.TotalIn = RoundQuantity(card.TotalIn)
+	card.TotalOut = RoundQuantity(card.TotalOut)
+	return card, nil
+}
+
+// ValuationLine is a product's stock and its cost value at the valuation date
+type ValuationLine struct {
+	ProductID   uint    `json:"product_id"`
+	ProductName string  `json:"product_name"`
+	Balance     float64 `json:"balance"`
+	UnitCost    float64 `json:"unit_cost"`
+	Value       float64 `json:"value"`
+}
+
+// StockValuation is the cost of the stock a business had on hand at the end of a day
+type StockValuation struct {
+	AsOf       string          `json:"as_of"`
+	TotalValue float64         `json:"total_value"`
+	Lines      []ValuationLine `json:"lines"`
+}
+
+// GetStockValuation values each product's last balance on or before asOf (YYYY-MM-DD)
+// at the cost it had when that movement was recorded
+func GetStockValuation(db *gorm.DB, businessID uint, asOf string) (*StockValuation, error) {
+	day, err := time.Parse("2006-01-02", asOf)
+	if err != nil {
+		return nil, errors.New("invalid as_of date, use YYYY-MM-DD")
+	}
+	end := day.AddDate(0, 0, 1)
+
+	valuation := &StockValuation{AsOf: asOf, Lines: []ValuationLine{}}
+	err = db.Table("stock_movements").
+		Select("stock_movements.product_id, products.name AS product_name, stock_movements.balance, stock_movements.unit_cost").
+		Joins("JOIN products ON products.id = stock_movements.product_id").
+		Where(`stock_movements.id IN (SELECT MAX(id) FROM stock_movements
+			WHERE business_id = ? AND created_at < ? GROUP BY product_id)`, businessID, end).
+		Where("stock_movements.balance <> 0").
+		Order("products.name").
+		Scan(&valuation.Lines).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range valuation.Lines {
+		line := &valuation.Lines[i]
+		line.Value = roundMoney(line.Balance * line.UnitCost)
+		valuation.TotalValue += line.Value
+	}
+	valuation.TotalValue = roundMoney(valuation.TotalValue)
+	return valuation, nil
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
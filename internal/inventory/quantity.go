@@ -0,0 +1,50 @@
+// internal/inventory/quantity.go
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// MaxQuantityPrecision is the most decimal places stock is kept to (e.g. grams of a kg)
+const MaxQuantityPrecision = 3
+
+// RoundQuantity rounds a stock quantity to MaxQuantityPrecision places, dropping float
+// noise such as 0.30000000000000004
+func RoundQuantity(q float64) float64 {
+	return RoundToPrecision(q, MaxQuantityPrecision)
+}
+
+// RoundToPrecision rounds a quantity to the given number of decimal places
+func RoundToPrecision(q float64, precision int) float64 {
+	if precision < 0 {
+		precision = 0
+	}
+	if precision > MaxQuantityPrecision {
+		precision = MaxQuantityPrecision
+	}
+	p := math.Pow10(precision)
+	return math.Round(q*p) / p
+}
+
+// CheckQuantity makes sure a quantity is positive and has no more decimal places than a
+// product sells in: whole units for precision 0, e.g. 0.125 for precision 3
+func CheckQuantity(q float64, precision int) error {
+	if q <= 0 {
+		return errors.New("quantity must be greater than zero")
+	}
+	if math.Abs(RoundToPrecision(q, precision)-q) > 1e-9 {
+		if precision == 0 {
+			return fmt.Errorf("quantity %s must be a whole number", FormatQuantity(q))
+		}
+		return fmt.Errorf("quantity %s has more than %d decimal places", FormatQuantity(q), precision)
+	}
+	return nil
+}
+
+// FormatQuantity prints a quantity without trailing zeros, e.g. 2 or 1.25
+func FormatQuantity(q float64) string {
+	return strconv.FormatFloat(RoundQuantity(q), 'f', -1, 64)
+}
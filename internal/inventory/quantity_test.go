@@ -0,0 +1,52 @@
+// internal/inventory/quantity_test.go
+package inventory
+
+import "testing"
+
+func TestCheckQuantity(t *testing.T) {
+	tests := []struct {
+		qty       float64
+		precision int
+		ok        bool
+	}{
+		{2, 0, true},
+		{2.5, 0, false},
+		{1.25, 2, true},
+		{1.255, 2, false},
+		{0.125, 3, true},
+		{0.1 + 0.2, 1, true}, // float noise is not an extra decimal place
+		{0.0005, 3, false},
+		{0, 3, false},
+		{-1, 0, false},
+		{1.2345, 5, false}, // precision is capped at MaxQuantityPrecision
+	}
+
+	for _, tt := range tests {
+		err := CheckQuantity(tt.qty, tt.precision)
+		if (err == nil) != tt.ok {
+			t.Errorf("CheckQuantity(%v, %d) = %v, want ok %v", tt.qty, tt.precision, err, tt.ok)
+		}
+	}
+}
+
+func TestRoundAndFormatQuantity(t *testing.T) {
+	tests := []struct {
+		qty  float64
+		want string
+	}{
+		{0.1 + 0.2, "0.3"},
+		{2, "2"},
+		{1.25, "1.25"},
+		{0.3333333, "0.333"},
+		{1.0005, "1.001"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatQuantity(tt.qty); got != tt.want {
+			t.Errorf("FormatQuantity(%v) = %s, want %s", tt.qty, got, tt.want)
+		}
+	}
+	if got := RoundToPrecision(1.26, 1); got != 1.3 {
+		t.Errorf("RoundToPrecision(1.26, 1) = %v, want 1.3", got)
+	}
+}
@@ -10,16 +10,21 @@ import (
 
 // StockReservation tracks reserved stock for draft/held orders
 type StockReservation struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	ProductID  uint      `gorm:"index:idx_product_business" json:"product_id"`
-	BusinessID uint      `gorm:"index:idx_product_business" json:"business_id"`
-	SaleID     uint      `gorm:"index" json:"sale_id"` // Links to draft/held sale
-	Quantity   int       `json:"quantity"`
-	CashierID  uint      `json:"cashier_id"`
-	ExpireAt   time.Time `json:"expire_at"` // Auto-release after X hours
-	CreatedAt  time.Time `json:"created_at"`
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ProductID   uint      `gorm:"index:idx_product_business" json:"product_id"`
+	BusinessID  uint      `gorm:"index:idx_product_business" json:"business_id"`
+	SaleID      uint      `gorm:"index" json:"sale_id"`                          // Links to draft/held sale
+	QuotationID uint      `gorm:"index;default:0" json:"quotation_id,omitempty"` // Set instead of SaleID while a quotation holds the stock
+	Quantity    float64   `gorm:"type:decimal(14,3)" json:"quantity"`
+	CashierID   uint      `json:"cashier_id"`
+	ExpireAt    time.Time `json:"expire_at"` // Auto-release after X hours
+	CreatedAt   time.Time `json:"created_at"`
 }
 
+// NoExpiry is the expiry of reservations that last until they are released, such as
+// the stock held for a layaway
+var NoExpiry = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
 // ReservationService handles stock reservation operations
 type ReservationService struct {
 	db *gorm.DB
@@ -31,7 +36,7 @@ func NewReservationService(db *gorm.DB) *ReservationService {
 }
 
 // ReserveStock reserves stock for a draft/held sale
-func (s *ReservationService) ReserveStock(saleID, productID, businessID, cashierID uint, quantity int) error {
+func (s *ReservationService) ReserveStock(saleID, productID, businessID, cashierID uint, quantity float64) error {
 	if quantity <= 0 {
 		return errors.New("quantity must be greater than zero")
 	}
@@ -59,6 +64,31 @@ func (s *ReservationService) ReserveStock(saleID, productID, businessID, cashier
 	return s.db.Create(reservation).Error
 }
 
+// ReserveUntilReleased holds stock for a sale with no expiry; it stays held until the
+// reservation is released
+func (s *ReservationService) ReserveUntilReleased(saleID, productID, businessID, cashierID uint, quantity float64) error {
+	if quantity <= 0 {
+		return errors.New("quantity must be greater than zero")
+	}
+
+	available, err := s.GetAvailableStock(productID, businessID)
+	if err != nil {
+		return err
+	}
+	if available < quantity {
+		return errors.New("insufficient stock available for reservation")
+	}
+
+	return s.db.Create(&StockReservation{
+		ProductID:  productID,
+		BusinessID: businessID,
+		SaleID:     saleID,
+		Quantity:   quantity,
+		CashierID:  cashierID,
+		ExpireAt:   NoExpiry,
+	}).Error
+}
+
 // ReleaseReservation releases stock reservation for a sale
 func (s *ReservationService) ReleaseReservation(saleID, productID uint) error {
 	result := s.db.Where("sale_id = ? AND product_id = ?", saleID, productID).Delete(&StockReservation{})
@@ -74,7 +104,7 @@ func (s *ReservationService) ReleaseAllReservations(saleID uint) error {
 }
 
 // GetAvailableStock returns available stock (current - reserved)
-func (s *ReservationService) GetAvailableStock(productID, businessID uint) (int, error) {
+func (s *ReservationService) GetAvailableStock(productID, businessID uint) (float64, error) {
 	// Get current stock
 	var inv Inventory
 	if err := s.db.First(&inv, "product_id = ? AND business_id = ?", productID, businessID).Error; err != nil {
@@ -85,7 +115,7 @@ func (s *ReservationService) GetAvailableStock(productID, businessID uint) (int,
 	}
 
 	// Get total reserved stock
-	var totalReserved int
+	var totalReserved float64
 	err := s.db.Model(&StockReservation{}).
 		Where("product_id = ? AND business_id = ? AND expire_at > ?", productID, businessID, time.Now()).
 		Select("COALESCE(SUM(quantity), 0)").
@@ -104,8 +134,8 @@ func (s *ReservationService) GetAvailableStock(productID, businessID uint) (int,
 }
 
 // GetReservedStock returns total reserved stock for a product
-func (s *ReservationService) GetReservedStock(productID, businessID uint) (int, error) {
-	var totalReserved int
+func (s *ReservationService) GetReservedStock(productID, businessID uint) (float64, error) {
+	var totalReserved float64
 	err := s.db.Model(&StockReservation{}).
 		Where("product_id = ? AND business_id = ? AND expire_at > ?", productID, businessID, time.Now()).
 		Select("COALESCE(SUM(quantity), 0)").
@@ -139,7 +169,7 @@ func (s *ReservationService) GetReservationsBySale(saleID uint) ([]StockReservat
 }
 
 // UpdateReservationQuantity updates the quantity of an existing reservation
-func (s *ReservationService) UpdateReservationQuantity(saleID, productID uint, newQuantity int) error {
+func (s *ReservationService) UpdateReservationQuantity(saleID, productID uint, newQuantity float64) error {
 	if newQuantity < 0 {
 		return errors.New("quantity cannot be negative")
 	}
@@ -175,6 +205,96 @@ func (s *ReservationService) UpdateReservationQuantity(saleID, productID uint, n
 	return s.db.Save(&reservation).Error
 }
 
+// TransferReservation moves part of a sale's reservation for a product onto another sale.
+// Stock is already held, so no availability check is made. It is a no-op when the
+// source sale has no reservation for the product (e.g. drafts created without reservations).
+func (s *ReservationService) TransferReservation(fromSaleID, toSaleID, productID uint, quantity float64) error {
+	if quantity <= 0 {
+		return errors.New("quantity must be greater than zero")
+	}
+
+	var source StockReservation
+	if err := s.db.First(&source, "sale_id = ? AND product_id = ?", fromSaleID, productID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if quantity > source.Quantity {
+		quantity = source.Quantity
+	}
+
+	var target StockReservation
+	err := s.db.First(&target, "sale_id = ? AND product_id = ?", toSaleID, productID).Error
+	switch {
+	case err == nil:
+		target.Quantity += quantity
+		if source.ExpireAt.After(target.ExpireAt) {
+			target.ExpireAt = source.ExpireAt
+		}
+		if err := s.db.Save(&target).Error; err != nil {
+			return err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		target = StockReservation{
+			ProductID:  productID,
+			BusinessID: source.BusinessID,
+			SaleID:     toSaleID,
+			Quantity:   quantity,
+			CashierID:  source.CashierID,
+			ExpireAt:   source.ExpireAt,
+		}
+		if err := s.db.Create(&target).Error; err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	if source.Quantity == quantity {
+		return s.db.Delete(&source).Error
+	}
+	return s.db.Model(&source).Update("quantity", source.Quantity-quantity).Error
+}
+
+// ReserveForQuotation holds stock for a quotation until it expires
+func (s *ReservationService) ReserveForQuotation(quotationID, productID, businessID, userID uint, quantity float64, until time.Time) error {
+	if quantity <= 0 {
+		return errors.New("quantity must be greater than zero")
+	}
+
+	available, err := s.GetAvailableStock(productID, businessID)
+	if err != nil {
+		return err
+	}
+	if available < quantity {
+		return errors.New("insufficient stock available for reservation")
+	}
+
+	return s.db.Create(&StockReservation{
+		ProductID:   productID,
+		BusinessID:  businessID,
+		QuotationID: quotationID,
+		Quantity:    quantity,
+		CashierID:   userID,
+		ExpireAt:    until,
+	}).Error
+}
+
+// ReleaseQuotationReservations releases the stock held for a quotation
+func (s *ReservationService) ReleaseQuotationReservations(quotationID uint) error {
+	return s.db.Where("quotation_id = ? AND sale_id = 0", quotationID).Delete(&StockReservation{}).Error
+}
+
+// MoveQuotationReservations hands the stock held for a quotation over to the sale it
+// became, with the usual draft expiry
+func (s *ReservationService) MoveQuotationReservations(quotationID, saleID uint) error {
+	return s.db.Model(&StockReservation{}).
+		Where("quotation_id = ? AND sale_id = 0", quotationID).
+		Updates(map[string]interface{}{"sale_id": saleID, "expire_at": time.Now().Add(4 * time.Hour)}).Error
+}
+
 // MigrateReservations runs the database migration for reservations
 func MigrateReservations(db *gorm.DB) error {
 	return db.AutoMigrate(&StockReservation{})
@@ -11,6 +11,8 @@ func RegisterInventoryRoutes(r fiber.Router, db *gorm.DB) {
 	r.Get("/inventory/low-stock", LowStockHandler(db))
 	r.Get("/inventory", AllInventoryHandler(db))
 	r.Get("/inventory/summary", GetInventorySummaryHandler(db))
+	r.Get("/inventory/valuation", StockValuationHandler(db))
+	r.Get("/inventory/products/:product_id/stock-card", StockCardHandler(db))
 
 	// Bulk Stock Rounds
 	r.Post("/inventory/rounds", StartRoundHandler(db))
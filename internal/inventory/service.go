@@ -4,17 +4,18 @@ package inventory
 import (
 	"errors"
 	"fmt"
+	"math"
 	"pos-fiber-app/internal/notification"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-func AdjustStock(tx *gorm.DB, productID, businessID uint, quantity int) error {
+func AdjustStock(tx *gorm.DB, productID, businessID uint, quantity float64) error {
 	// 1. Get product tracking info and current stock in one go
 	var prodInfo struct {
 		TrackByRound bool
-		Stock        int
+		Stock        float64
 	}
 	if err := tx.Table("products").Select("track_by_round, stock").Where("id = ? AND business_id = ?", productID, businessID).Scan(&prodInfo).Error; err != nil {
 		return fmt.Errorf("failed to fetch product info: %w", err)
@@ -22,7 +23,7 @@ func AdjustStock(tx *gorm.DB, productID, businessID uint, quantity int) error {
 
 	// 2. If tracked by round, delegate and return
 	if prodInfo.TrackByRound {
-		return AdjustStockFromRound(tx, productID, businessID, float64(quantity))
+		return AdjustStockFromRound(tx, productID, businessID, quantity)
 	}
 
 	// 3. Normal inventory adjustment
@@ -50,9 +51,13 @@ func AdjustStock(tx *gorm.DB, productID, businessID uint, quantity int) error {
 	}
 
 	// 5. Final validation and updates
-	newStock := inv.CurrentStock + quantity
+	newStock := RoundQuantity(inv.CurrentStock + quantity)
 	if newStock < 0 {
-		return fmt.Errorf("available: %d, needed: %d", inv.CurrentStock, -quantity)
+		record := shortfallRecorder(tx)
+		if record == nil {
+			return fmt.Errorf("available: %s, needed: %s", FormatQuantity(inv.CurrentStock), FormatQuantity(-quantity))
+		}
+		record(Shortfall{ProductID: productID, Available: math.Max(inv.CurrentStock, 0), Needed: -quantity})
 	}
 
 	inv.CurrentStock = newStock
@@ -66,6 +71,10 @@ func AdjustStock(tx *gorm.DB, productID, businessID uint, quantity int) error {
 		return fmt.Errorf("failed to sync product stock: %w", err)
 	}
 
+	if err := RecordMovement(tx, productID, businessID, quantity, newStock); err != nil {
+		return err
+	}
+
 	// 6. Check for Low Stock Alert
 	if newStock <= inv.LowStockAlert && quantity < 0 { // Alert only on deduction
 		go func() {
@@ -87,6 +96,10 @@ func AdjustStockFromRound(db *gorm.DB, productID, businessID uint, quantity floa
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) && quantity < 0 {
+			if record := shortfallRecorder(db); record != nil {
+				record(Shortfall{ProductID: productID, Needed: -quantity})
+				return nil
+			}
 			return errors.New("no open stock round found for this product")
 		}
 		// If it's a restock (voiding) or other error, return it unless we decide to ignore it for restocks
@@ -95,7 +108,11 @@ func AdjustStockFromRound(db *gorm.DB, productID, businessID uint, quantity floa
 
 	newRemaining := round.RemainingVolume + quantity
 	if newRemaining < 0 && quantity < 0 {
-		return fmt.Errorf("insufficient round stock: available %.3f, requested %.3f", round.RemainingVolume, -quantity)
+		record := shortfallRecorder(db)
+		if record == nil {
+			return fmt.Errorf("insufficient round stock: available %.3f, requested %.3f", round.RemainingVolume, -quantity)
+		}
+		record(Shortfall{ProductID: productID, Available: math.Max(round.RemainingVolume, 0), Needed: -quantity})
 	}
 
 	round.RemainingVolume = newRemaining
@@ -105,17 +122,21 @@ func AdjustStockFromRound(db *gorm.DB, productID, businessID uint, quantity floa
 	}
 
 	// Sync products.stock field so frontend can easily check availability
-	if err := db.Table("products").Where("id = ? AND business_id = ?", productID, businessID).Update("stock", int(newRemaining)).Error; err != nil {
+	if err := db.Table("products").Where("id = ? AND business_id = ?", productID, businessID).Update("stock", newRemaining).Error; err != nil {
 		return fmt.Errorf("failed to sync product stock: %w", err)
 	}
 
+	if err := RecordMovement(db, productID, businessID, quantity, newRemaining); err != nil {
+		return err
+	}
+
 	// Check for Low Stock in Bulk Round (15% threshold as default)
 	if newRemaining <= (0.15*round.TotalVolume) && quantity < 0 {
 		go func() {
 			notifier := notification.GetDefaultService(db)
 			var prodName string
 			db.Table("products").Select("name").Where("id = ?", productID).Scan(&prodName)
-			notifier.SendLowStockAlert(businessID, prodName+" (Bulk)", int(newRemaining), int(0.15*round.TotalVolume))
+			notifier.SendLowStockAlert(businessID, prodName+" (Bulk)", newRemaining, 0.15*round.TotalVolume)
 		}()
 	}
 
@@ -147,6 +168,12 @@ func StartNewRound(db *gorm.DB, businessID, productID uint, totalVolume, purchas
 	// Update the product's cost to match the latest restock cost (as current cost per ton)
 	db.Table("products").Where("id = ?", productID).Update("cost", purchaseCost/totalVolume)
 
+	m := movementFrom(db)
+	m.Reason, m.RefType, m.RefID = ReasonRestock, "inventory_round", round.ID
+	if err := RecordMovement(WithMovement(db, m), productID, businessID, totalVolume, totalVolume); err != nil {
+		return nil, err
+	}
+
 	return round, nil
 }
 
@@ -160,7 +187,14 @@ func CloseRound(db *gorm.DB, businessID, roundID uint) error {
 	round.Status = "CLOSED"
 	round.ClosedAt = &now
 
-	return db.Save(&round).Error
+	if err := db.Save(&round).Error; err != nil {
+		return err
+	}
+
+	// Whatever is left in the round is written off; the next round starts from its own volume
+	m := movementFrom(db)
+	m.Reason, m.RefType, m.RefID, m.Note = ReasonWastage, "inventory_round", round.ID, "left in closed round"
+	return RecordMovement(WithMovement(db, m), round.ProductID, businessID, -round.RemainingVolume, 0)
 }
 
 func GetActiveRound(db *gorm.DB, businessID, productID uint) (*InventoryRound, error) {
@@ -200,7 +234,7 @@ func ListInventoryByBusiness(db *gorm.DB, businessID uint) ([]Inventory, error)
 }
 
 // GetEffectiveStock returns current stock, syncing from Product table if necessary
-func GetEffectiveStock(db *gorm.DB, productID, businessID uint) (int, error) {
+func GetEffectiveStock(db *gorm.DB, productID, businessID uint) (float64, error) {
 	var inv Inventory
 	err := db.Where("product_id = ? AND business_id = ?", productID, businessID).First(&inv).Error
 	if err == nil {
@@ -208,7 +242,7 @@ func GetEffectiveStock(db *gorm.DB, productID, businessID uint) (int, error) {
 		// it might have been created by a previous failed attempt/bug.
 		if inv.CurrentStock == 0 && inv.CreatedAt.Equal(inv.UpdatedAt) {
 			var prod struct {
-				Stock int
+				Stock float64
 			}
 			if err := db.Table("products").Select("stock").Where("id = ? AND business_id = ?", productID, businessID).Scan(&prod).Error; err == nil {
 				if prod.Stock > 0 {
@@ -221,7 +255,7 @@ func GetEffectiveStock(db *gorm.DB, productID, businessID uint) (int, error) {
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		var prod struct {
-			Stock int
+			Stock float64
 		}
 		if err := db.Table("products").Select("stock").Where("id = ? AND business_id = ?", productID, businessID).Scan(&prod).Error; err == nil {
 			return prod.Stock, nil
@@ -234,7 +268,7 @@ func GetEffectiveStock(db *gorm.DB, productID, businessID uint) (int, error) {
 
 type InventorySummary struct {
 	TotalItems         int64   `json:"total_items"`
-	TotalStockQuantity int64   `json:"total_stock_quantity"`
+	TotalStockQuantity float64 `json:"total_stock_quantity"`
 	TotalPurchaseCost  float64 `json:"total_purchase_cost"`
 	TotalSellingValue  float64 `json:"total_selling_value"`
 	PotentialProfit    float64 `json:"potential_profit"`
@@ -0,0 +1,37 @@
+// internal/inventory/shortfall.go
+package inventory
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Shortfall is stock a sale took that was not on hand
+type Shortfall struct {
+	ProductID uint    `json:"product_id"`
+	Available float64 `json:"available"`
+	Needed    float64 `json:"needed"`
+}
+
+type shortfallKey struct{}
+
+// AllowShortfalls returns a session on which stock deductions never fail for lack of
+// stock: stock goes negative instead, and each shortfall is passed to record. It is
+// for sales that have already happened, such as ones uploaded by an offline device.
+func AllowShortfalls(db *gorm.DB, record func(Shortfall)) *gorm.DB {
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return db.WithContext(context.WithValue(ctx, shortfallKey{}, record))
+}
+
+// shortfallRecorder returns the recorder set by AllowShortfalls, or nil
+func shortfallRecorder(db *gorm.DB) func(Shortfall) {
+	if db.Statement == nil || db.Statement.Context == nil {
+		return nil
+	}
+	record, _ := db.Statement.Context.Value(shortfallKey{}).(func(Shortfall))
+	return record
+}
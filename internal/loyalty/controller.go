@@ -0,0 +1,118 @@
+// internal/loyalty/controller.go
+package loyalty
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func handleLoyaltyError(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "not found") {
+		return fiber.NewError(fiber.StatusNotFound, msg)
+	}
+	if strings.Contains(msg, "cannot") || strings.Contains(msg, "must") {
+		return fiber.NewError(fiber.StatusBadRequest, msg)
+	}
+	return fiber.NewError(fiber.StatusInternalServerError, msg)
+}
+
+// GetLoyaltyConfig godoc
+// @Summary Get loyalty configuration
+// @Tags Loyalty
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} Config
+// @Router /loyalty/config [get]
+func GetConfigHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		cfg, err := GetConfig(db, bizID)
+		if err != nil {
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(cfg)
+	}
+}
+
+// UpdateLoyaltyConfig godoc
+// @Summary Update loyalty configuration
+// @Description Set the earn rate, redemption value, expiry and excluded categories
+// @Tags Loyalty
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body UpdateConfigRequest true "Fields to update"
+// @Success 200 {object} Config
+// @Failure 400 {object} map[string]string
+// @Router /loyalty/config [put]
+func UpdateConfigHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		var req UpdateConfigRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		cfg, err := UpdateConfig(db, bizID, req)
+		if err != nil {
+			return handleLoyaltyError(err)
+		}
+
+		return c.JSON(cfg)
+	}
+}
+
+// GetPointsHistory godoc
+// @Summary Customer points history
+// @Description A customer's points balance and every earn, redemption, reversal and expiry, newest first
+// @Tags Loyalty
+// @Security BearerAuth
+// @Produce json
+// @Param customer_id path uint true "Customer ID"
+// @Success 200 {object} PointsHistory
+// @Failure 404 {object} map[string]string
+// @Router /loyalty/customers/{customer_id}/history [get]
+func HistoryHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("customer_id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid customer ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		history, err := GetHistory(db, uint(id), bizID)
+		if err != nil {
+			return handleLoyaltyError(err)
+		}
+
+		return c.JSON(history)
+	}
+}
+
+// GetLoyaltyLiability godoc
+// @Summary Loyalty liability
+// @Description Unspent, unexpired points and their value at the current redemption value
+// @Tags Loyalty
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} Liability
+// @Router /loyalty/liability [get]
+func LiabilityHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		liability, err := GetLiability(db, bizID)
+		if err != nil {
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(liability)
+	}
+}
@@ -0,0 +1,82 @@
+// internal/loyalty/model.go
+package loyalty
+
+import (
+	"time"
+)
+
+// Config is a business's loyalty programme. Customers earn EarnRate points per
+// currency unit spent and each point is worth RedemptionValue when redeemed.
+type Config struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	BusinessID          uint      `gorm:"uniqueIndex" json:"business_id"`
+	Enabled             bool      `gorm:"default:false" json:"enabled"`
+	EarnRate            float64   `gorm:"type:decimal(10,4);default:0" json:"earn_rate"`        // points per currency unit
+	RedemptionValue     float64   `gorm:"type:decimal(10,4);default:0" json:"redemption_value"` // currency per point
+	ExpiryDays          int       `gorm:"default:0" json:"expiry_days"`                         // 0 = points never expire
+	ExcludedCategoryIDs []uint    `gorm:"serializer:json;type:text" json:"excluded_category_ids"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+func (Config) TableName() string {
+	return "loyalty_configs"
+}
+
+type EntryType string
+
+const (
+	EntryEarn          EntryType = "EARN"
+	EntryRedeem        EntryType = "REDEEM"
+	EntryReverseEarn   EntryType = "REVERSE_EARN"   // points taken back when a sale is refunded or voided
+	EntryReverseRedeem EntryType = "REVERSE_REDEEM" // redeemed points given back on refund or void
+	EntryExpire        EntryType = "EXPIRE"
+)
+
+// Entry is one line of a customer's points history. Points is signed.
+// Entries that add points (EARN, REVERSE_REDEEM) are lots: Remaining tracks how much
+// of the lot is still spendable and ExpiresAt when it lapses. Points are spent
+// from the lots that expire soonest.
+type Entry struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	BusinessID uint       `gorm:"index" json:"business_id"`
+	CustomerID uint       `gorm:"index" json:"customer_id"`
+	SaleID     *uint      `gorm:"index" json:"sale_id,omitempty"`
+	Type       EntryType  `gorm:"type:varchar(20)" json:"type"`
+	Points     int        `json:"points"`
+	Remaining  int        `gorm:"default:0" json:"remaining,omitempty"`
+	Amount     float64    `gorm:"type:decimal(12,2);default:0" json:"amount,omitempty"` // money earned on / redeemed
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+}
+
+func (Entry) TableName() string {
+	return "loyalty_entries"
+}
+
+type UpdateConfigRequest struct {
+	Enabled             *bool    `json:"enabled"`
+	EarnRate            *float64 `json:"earn_rate" validate:"omitempty,gte=0"`
+	RedemptionValue     *float64 `json:"redemption_value" validate:"omitempty,gte=0"`
+	ExpiryDays          *int     `json:"expiry_days" validate:"omitempty,gte=0"`
+	ExcludedCategoryIDs []uint   `json:"excluded_category_ids"`
+}
+
+// EarnLine is a sold line considered for earning points
+type EarnLine struct {
+	ProductID uint
+	Amount    float64
+}
+
+type PointsHistory struct {
+	CustomerID uint    `json:"customer_id"`
+	Balance    int     `json:"balance"`
+	Value      float64 `json:"value"` // balance at the current redemption value
+	Entries    []Entry `json:"entries"`
+}
+
+// Liability is the money value of all unexpired, unspent points of a business
+type Liability struct {
+	Points int     `json:"points"`
+	Value  float64 `json:"value"`
+}
@@ -0,0 +1,15 @@
+// internal/loyalty/route.go
+package loyalty
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func RegisterLoyaltyRoutes(r fiber.Router, db *gorm.DB) {
+	group := r.Group("/loyalty")
+	group.Get("/config", GetConfigHandler(db))
+	group.Put("/config", UpdateConfigHandler(db))
+	group.Get("/liability", LiabilityHandler(db))
+	group.Get("/customers/:customer_id/history", HistoryHandler(db))
+}
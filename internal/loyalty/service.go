@@ -0,0 +1,489 @@
+// internal/loyalty/service.go
+package loyalty
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"pos-fiber-app/internal/customer"
+	"pos-fiber-app/internal/product"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetConfig returns the business's loyalty configuration. Businesses that never
+// configured the programme get a disabled one.
+func GetConfig(db *gorm.DB, businessID uint) (*Config, error) {
+	var cfg Config
+	err := db.Where("business_id = ?", businessID).First(&cfg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &Config{BusinessID: businessID, ExcludedCategoryIDs: []uint{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ExcludedCategoryIDs == nil {
+		cfg.ExcludedCategoryIDs = []uint{}
+	}
+	return &cfg, nil
+}
+
+// UpdateConfig creates or updates the business's loyalty configuration
+func UpdateConfig(db *gorm.DB, businessID uint, req UpdateConfigRequest) (*Config, error) {
+	cfg, err := GetConfig(db, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Enabled != nil {
+		cfg.Enabled = *req.Enabled
+	}
+	if req.EarnRate != nil {
+		if *req.EarnRate < 0 {
+			return nil, errors.New("earn rate cannot be negative")
+		}
+		cfg.EarnRate = *req.EarnRate
+	}
+	if req.RedemptionValue != nil {
+		if *req.RedemptionValue < 0 {
+			return nil, errors.New("redemption value cannot be negative")
+		}
+		cfg.RedemptionValue = *req.RedemptionValue
+	}
+	if req.ExpiryDays != nil {
+		if *req.ExpiryDays < 0 {
+			return nil, errors.New("expiry days cannot be negative")
+		}
+		cfg.ExpiryDays = *req.ExpiryDays
+	}
+	if req.ExcludedCategoryIDs != nil {
+		cfg.ExcludedCategoryIDs = req.ExcludedCategoryIDs
+	}
+
+	if cfg.Enabled && cfg.RedemptionValue <= 0 {
+		return nil, errors.New("redemption value must be greater than zero to enable the programme")
+	}
+
+	if err := db.Save(cfg).Error; err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Earn credits a customer with points for a completed sale. Lines are the sale's lines
+// at what was actually charged for them; lines in excluded categories earn nothing, and
+// neither does the part of the sale paid for with points. Returns the points earned.
+// A no-op when the programme is disabled. Must run inside the sale's transaction.
+func Earn(tx *gorm.DB, businessID, customerID, saleID uint, lines []EarnLine, paidWithPoints float64) (int, error) {
+	cfg, err := GetConfig(tx, businessID)
+	if err != nil {
+		return 0, err
+	}
+	if !cfg.Enabled || cfg.EarnRate <= 0 {
+		return 0, nil
+	}
+
+	excluded, err := excludedProducts(tx, businessID, cfg.ExcludedCategoryIDs, lines)
+	if err != nil {
+		return 0, err
+	}
+
+	var eligible float64
+	for _, l := range lines {
+		if !excluded[l.ProductID] {
+			eligible += l.Amount
+		}
+	}
+	eligible = roundMoney(eligible - paidWithPoints)
+	if eligible <= 0 {
+		return 0, nil
+	}
+
+	points := int(math.Floor(eligible*cfg.EarnRate + 1e-9))
+	if points <= 0 {
+		return 0, nil
+	}
+
+	cust, err := lockCustomer(tx, customerID, businessID)
+	if err != nil {
+		return 0, err
+	}
+
+	entry := &Entry{
+		SaleID:    &saleID,
+		Type:      EntryEarn,
+		Points:    points,
+		Remaining: points,
+		Amount:    eligible,
+		ExpiresAt: cfg.expiryFrom(time.Now()),
+	}
+	if err := postEntry(tx, cust, entry); err != nil {
+		return 0, err
+	}
+	return points, nil
+}
+
+// Redeem spends enough of a customer's points to pay amount of a sale, oldest-expiring
+// points first. Returns the points spent. Must run inside the sale's transaction.
+func Redeem(tx *gorm.DB, businessID, customerID, saleID uint, amount float64) (int, error) {
+	cfg, err := GetConfig(tx, businessID)
+	if err != nil {
+		return 0, err
+	}
+	if !cfg.Enabled || cfg.RedemptionValue <= 0 {
+		return 0, errors.New("loyalty program is not enabled for this business")
+	}
+
+	cust, err := lockCustomer(tx, customerID, businessID)
+	if err != nil {
+		return 0, err
+	}
+	if err := expireLots(tx, cust); err != nil {
+		return 0, err
+	}
+
+	amount = roundMoney(amount)
+	points := int(math.Ceil(amount/cfg.RedemptionValue - 1e-9))
+	if points > cust.LoyaltyPoints {
+		return 0, fmt.Errorf("insufficient loyalty points: %d needed, %d available", points, cust.LoyaltyPoints)
+	}
+
+	if err := consumeLots(tx, cust.ID, points); err != nil {
+		return 0, err
+	}
+	entry := &Entry{
+		SaleID: &saleID,
+		Type:   EntryRedeem,
+		Points: -points,
+		Amount: amount,
+	}
+	if err := postEntry(tx, cust, entry); err != nil {
+		return 0, err
+	}
+	return points, nil
+}
+
+// ReverseEarned takes back the given fraction (0-1] of the points a sale earned, as
+// when part of it is refunded. Points the customer has already spent are not clawed
+// back below zero.
+func ReverseEarned(tx *gorm.DB, businessID, saleID uint, fraction float64) error {
+	var earned []Entry
+	if err := tx.Where("sale_id = ? AND business_id = ? AND type = ?", saleID, businessID, EntryEarn).
+		Find(&earned).Error; err != nil {
+		return err
+	}
+
+	for _, e := range earned {
+		var reversed int
+		tx.Model(&Entry{}).
+			Where("sale_id = ? AND customer_id = ? AND type = ?", saleID, e.CustomerID, EntryReverseEarn).
+			Select("COALESCE(SUM(-points), 0)").Scan(&reversed)
+
+		points := int(math.Round(float64(e.Points) * fraction))
+		if fraction >= 1 || points > e.Points-reversed {
+			points = e.Points - reversed
+		}
+		if points <= 0 {
+			continue
+		}
+
+		cust, err := lockCustomer(tx, e.CustomerID, businessID)
+		if err != nil {
+			return err
+		}
+		if points > cust.LoyaltyPoints {
+			points = cust.LoyaltyPoints
+		}
+		if points <= 0 {
+			continue
+		}
+
+		// The sale's own lot goes first, then the customer's other points
+		fromLot := e.Remaining
+		if fromLot > points {
+			fromLot = points
+		}
+		if fromLot > 0 {
+			if err := tx.Model(&Entry{}).Where("id = ?", e.ID).
+				Update("remaining", gorm.Expr("remaining - ?", fromLot)).Error; err != nil {
+				return err
+			}
+		}
+		if err := consumeLots(tx, cust.ID, points-fromLot); err != nil {
+			return err
+		}
+
+		entry := &Entry{
+			SaleID: &saleID,
+			Type:   EntryReverseEarn,
+			Points: -points,
+		}
+		if err := postEntry(tx, cust, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreRedeemed gives back the points that paid for amount of a sale, as when money
+// paid with points is refunded. The restored points start a fresh expiry period.
+func RestoreRedeemed(tx *gorm.DB, businessID, saleID uint, amount float64) error {
+	var redeemed []Entry
+	if err := tx.Where("sale_id = ? AND business_id = ? AND type = ?", saleID, businessID, EntryRedeem).
+		Find(&redeemed).Error; err != nil {
+		return err
+	}
+
+	cfg, err := GetConfig(tx, businessID)
+	if err != nil {
+		return err
+	}
+
+	remaining := roundMoney(amount)
+	for _, e := range redeemed {
+		if remaining <= 0 {
+			break
+		}
+
+		var restored struct {
+			Points int
+			Amount float64
+		}
+		tx.Model(&Entry{}).
+			Where("sale_id = ? AND customer_id = ? AND type = ?", saleID, e.CustomerID, EntryReverseRedeem).
+			Select("COALESCE(SUM(points), 0) AS points, COALESCE(SUM(amount), 0) AS amount").
+			Scan(&restored)
+
+		spent := -e.Points
+		available := roundMoney(e.Amount - restored.Amount)
+		if available <= 0 || spent-restored.Points <= 0 {
+			continue
+		}
+		portion := math.Min(available, remaining)
+
+		points := int(math.Round(float64(spent) * portion / e.Amount))
+		if portion >= available || points > spent-restored.Points {
+			points = spent - restored.Points
+		}
+		remaining = roundMoney(remaining - portion)
+		if points <= 0 {
+			continue
+		}
+
+		cust, err := lockCustomer(tx, e.CustomerID, businessID)
+		if err != nil {
+			return err
+		}
+		entry := &Entry{
+			SaleID:    &saleID,
+			Type:      EntryReverseRedeem,
+			Points:    points,
+			Remaining: points,
+			Amount:    roundMoney(portion),
+			ExpiresAt: cfg.expiryFrom(time.Now()),
+		}
+		if err := postEntry(tx, cust, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReverseSale undoes all loyalty activity of a voided sale: the points it earned are
+// taken back and the points spent on it are given back
+func ReverseSale(tx *gorm.DB, businessID, saleID uint) error {
+	if err := ReverseEarned(tx, businessID, saleID, 1); err != nil {
+		return err
+	}
+
+	var redeemed float64
+	if err := tx.Model(&Entry{}).
+		Where("sale_id = ? AND business_id = ? AND type = ?", saleID, businessID, EntryRedeem).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&redeemed).Error; err != nil {
+		return err
+	}
+	if redeemed <= 0 {
+		return nil
+	}
+	return RestoreRedeemed(tx, businessID, saleID, redeemed)
+}
+
+// GetHistory returns a customer's points balance and movements, newest first
+func GetHistory(db *gorm.DB, customerID, businessID uint) (*PointsHistory, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	cust, err := lockCustomer(tx, customerID, businessID)
+	if err != nil {
+		return nil, err
+	}
+	if err := expireLots(tx, cust); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	cfg, err := GetConfig(db, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	if err := db.Where("customer_id = ? AND business_id = ?", customerID, businessID).
+		Order("created_at DESC, id DESC").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	return &PointsHistory{
+		CustomerID: cust.ID,
+		Balance:    cust.LoyaltyPoints,
+		Value:      roundMoney(float64(cust.LoyaltyPoints) * cfg.RedemptionValue),
+		Entries:    entries,
+	}, nil
+}
+
+// GetLiability values every unspent, unexpired point of a business at its current
+// redemption value
+func GetLiability(db *gorm.DB, businessID uint) (*Liability, error) {
+	cfg, err := GetConfig(db, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	var points int
+	if err := db.Model(&Entry{}).
+		Where("business_id = ? AND remaining > 0 AND (expires_at IS NULL OR expires_at > ?)", businessID, time.Now()).
+		Select("COALESCE(SUM(remaining), 0)").
+		Scan(&points).Error; err != nil {
+		return nil, err
+	}
+
+	return &Liability{
+		Points: points,
+		Value:  roundMoney(float64(points) * cfg.RedemptionValue),
+	}, nil
+}
+
+func (c *Config) expiryFrom(t time.Time) *time.Time {
+	if c.ExpiryDays <= 0 {
+		return nil
+	}
+	expires := t.AddDate(0, 0, c.ExpiryDays)
+	return &expires
+}
+
+// excludedProducts returns which of the lines' products sit in an excluded category
+func excludedProducts(tx *gorm.DB, businessID uint, categoryIDs []uint, lines []EarnLine) (map[uint]bool, error) {
+	excluded := make(map[uint]bool)
+	if len(categoryIDs) == 0 || len(lines) == 0 {
+		return excluded, nil
+	}
+
+	productIDs := make([]uint, 0, len(lines))
+	for _, l := range lines {
+		productIDs = append(productIDs, l.ProductID)
+	}
+
+	var ids []uint
+	if err := tx.Model(&product.Product{}).
+		Where("business_id = ? AND id IN ? AND category_id IN ?", businessID, productIDs, categoryIDs).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		excluded[id] = true
+	}
+	return excluded, nil
+}
+
+// expireLots writes off whatever is left of a customer's lapsed lots
+func expireLots(tx *gorm.DB, cust *customer.Customer) error {
+	var lots []Entry
+	if err := tx.Where("customer_id = ? AND remaining > 0 AND expires_at IS NOT NULL AND expires_at <= ?", cust.ID, time.Now()).
+		Order("expires_at ASC, id ASC").
+		Find(&lots).Error; err != nil {
+		return err
+	}
+
+	for _, lot := range lots {
+		if err := tx.Model(&Entry{}).Where("id = ?", lot.ID).Update("remaining", 0).Error; err != nil {
+			return err
+		}
+		entry := &Entry{
+			SaleID: lot.SaleID,
+			Type:   EntryExpire,
+			Points: -lot.Remaining,
+		}
+		if err := postEntry(tx, cust, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// consumeLots spends points from a customer's lots, soonest-expiring first
+func consumeLots(tx *gorm.DB, customerID uint, points int) error {
+	if points <= 0 {
+		return nil
+	}
+
+	var lots []Entry
+	if err := tx.Where("customer_id = ? AND remaining > 0", customerID).
+		Order("expires_at ASC NULLS LAST, id ASC").
+		Find(&lots).Error; err != nil {
+		return err
+	}
+
+	for _, lot := range lots {
+		if points <= 0 {
+			break
+		}
+		take := lot.Remaining
+		if take > points {
+			take = points
+		}
+		if err := tx.Model(&Entry{}).Where("id = ?", lot.ID).
+			Update("remaining", lot.Remaining-take).Error; err != nil {
+			return err
+		}
+		points -= take
+	}
+	return nil
+}
+
+// lockCustomer loads a customer row for update so concurrent postings serialize
+func lockCustomer(tx *gorm.DB, customerID, businessID uint) (*customer.Customer, error) {
+	var cust customer.Customer
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&cust, "id = ? AND business_id = ?", customerID, businessID).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("customer not found")
+		}
+		return nil, err
+	}
+	return &cust, nil
+}
+
+// postEntry writes a points entry and moves the customer's points balance with it
+func postEntry(tx *gorm.DB, cust *customer.Customer, entry *Entry) error {
+	cust.LoyaltyPoints += entry.Points
+
+	entry.CustomerID = cust.ID
+	entry.BusinessID = cust.BusinessID
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(cust).Update("loyalty_points", cust.LoyaltyPoints).Error
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
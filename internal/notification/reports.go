@@ -45,7 +45,7 @@ func (n *NotificationService) GenerateAndSendDailyReport(businessID uint) error
 	// b. Low Stock Items (using raw query to avoid inventory package import)
 	var lowStock []struct {
 		Name  string
-		Stock int
+		Stock float64
 	}
 	n.db.Table("inventories").
 		Select("products.name, inventories.current_stock as stock").
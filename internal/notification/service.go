@@ -2,11 +2,14 @@ package notification
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
 	"pos-fiber-app/internal/email"
+	"strconv"
 	"strings"
 
 	"gorm.io/gorm"
@@ -86,25 +89,30 @@ func (n *NotificationService) SendShiftOpenedAlert(businessID uint, cashierName
 }
 
 // SendLowStockAlert sends an alert when an item reaches its threshold
-func (n *NotificationService) SendLowStockAlert(businessID uint, productName string, remaining int, threshold int) {
+func (n *NotificationService) SendLowStockAlert(businessID uint, productName string, remaining, threshold float64) {
 	title := "Low Stock Alert"
 	message := fmt.Sprintf(
-		"Inventory Alert: '%s' is running low.\nCurrent Stock: %d (Threshold: %d)",
-		productName, remaining, threshold,
+		"Inventory Alert: '%s' is running low.\nCurrent Stock: %s (Threshold: %s)",
+		productName, formatStock(remaining), formatStock(threshold),
 	)
 	n.SendSecurityAlert(businessID, title, message)
 }
 
 // SendStockUpdateAlert sends an alert when stock is manually updated
-func (n *NotificationService) SendStockUpdateAlert(businessID uint, productName string, oldStock, newStock int, userName string) {
+func (n *NotificationService) SendStockUpdateAlert(businessID uint, productName string, oldStock, newStock float64, userName string) {
 	title := "Stock Level Updated"
 	message := fmt.Sprintf(
-		"Stock for '%s' was updated by %s.\nPrevious: %d | New: %d",
-		productName, userName, oldStock, newStock,
+		"Stock for '%s' was updated by %s.\nPrevious: %s | New: %s",
+		productName, userName, formatStock(oldStock), formatStock(newStock),
 	)
 	n.SendSecurityAlert(businessID, title, message)
 }
 
+// formatStock prints a stock level without trailing zeros, e.g. 12 or 3.25
+func formatStock(q float64) string {
+	return strconv.FormatFloat(math.Round(q*1000)/1000, 'f', -1, 64)
+}
+
 // SendSecurityAlert sends an alert to the business owner about sensitive actions
 func (n *NotificationService) SendSecurityAlert(businessID uint, title, message string) {
 	// 1. Find the owner of this business
@@ -173,63 +181,11 @@ func (n *NotificationService) SendSecurityAlert(businessID uint, title, message
 		}
 
 		// c. Send via Twilio API
-		accountSid := os.Getenv("TWILIO_ACCOUNT_SID")
-		authToken := os.Getenv("TWILIO_AUTH_TOKEN")
-		fromNumber := os.Getenv("TWILIO_FROM_NUMBER") // or WHATSAPP_SANDBOX_NUMBER if testing
-
-		if accountSid == "" || authToken == "" {
-			fmt.Println("WhatsApp Alert Error: Twilio credentials missing in .env")
-			return
-		}
-
-		// Using Twilio API directly to avoid external dependency issues if library not installed
-		apiURL := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", accountSid)
-
-		// Ensure number has whatsapp: prefix for Twilio WhatsApp
-		to := targetNumber
-		if !strings.HasPrefix(to, "whatsapp:") {
-			to = "whatsapp:" + to
-		}
-		from := fromNumber
-		if !strings.HasPrefix(from, "whatsapp:") {
-			// If env var is just a number, prepend whatsapp:
-			// If env var is WHATSAPP_SANDBOX_NUMBER, it usually already has it.
-			// Let's assume user configured it correctly or we prepend if missing
-			if os.Getenv("WHATSAPP_SANDBOX_NUMBER") != "" {
-				from = os.Getenv("WHATSAPP_SANDBOX_NUMBER")
-			} else {
-				from = "whatsapp:" + from
-			}
-		}
-
-		v := url.Values{}
-		v.Set("To", to)
-		v.Set("From", from)
-		v.Set("Body", fmt.Sprintf("*%s*\n\n%s", title, message))
-
-		req, err := http.NewRequest("POST", apiURL, strings.NewReader(v.Encode()))
-		if err != nil {
-			fmt.Printf("WhatsApp Alert Error creating request: %v\n", err)
-			return
-		}
-
-		req.SetBasicAuth(accountSid, authToken)
-		req.Header.Add("Accept", "application/json")
-		req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			fmt.Printf("WhatsApp Alert Network Error: %v\n", err)
+		if err := n.SendWhatsApp(targetNumber, fmt.Sprintf("*%s*\n\n%s", title, message)); err != nil {
+			fmt.Printf("WhatsApp Alert Error: %v\n", err)
 			return
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			fmt.Printf("WhatsApp Alert Sent to %s: %s\n", to, title)
-		} else {
-			fmt.Printf("WhatsApp Alert Failed (Status %d)\n", resp.StatusCode)
-		}
+		fmt.Printf("WhatsApp Alert Sent to %s: %s\n", targetNumber, title)
 	}()
 
 	// 4. Send via Push Notification (To all owner's registered devices)
@@ -293,3 +249,59 @@ func (n *NotificationService) SendPushToOwner(businessID uint, title, message st
 		}
 	}
 }
+
+// SendWhatsApp sends a WhatsApp message through the Twilio API
+func (n *NotificationService) SendWhatsApp(toNumber, body string) error {
+	accountSid := os.Getenv("TWILIO_ACCOUNT_SID")
+	authToken := os.Getenv("TWILIO_AUTH_TOKEN")
+	fromNumber := os.Getenv("TWILIO_FROM_NUMBER") // or WHATSAPP_SANDBOX_NUMBER if testing
+
+	if accountSid == "" || authToken == "" {
+		return errors.New("twilio credentials missing in .env")
+	}
+
+	// Using Twilio API directly to avoid external dependency issues if library not installed
+	apiURL := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", accountSid)
+
+	// Ensure number has whatsapp: prefix for Twilio WhatsApp
+	to := toNumber
+	if !strings.HasPrefix(to, "whatsapp:") {
+		to = "whatsapp:" + to
+	}
+	from := fromNumber
+	if !strings.HasPrefix(from, "whatsapp:") {
+		// If env var is just a number, prepend whatsapp:
+		// If env var is WHATSAPP_SANDBOX_NUMBER, it usually already has it.
+		if os.Getenv("WHATSAPP_SANDBOX_NUMBER") != "" {
+			from = os.Getenv("WHATSAPP_SANDBOX_NUMBER")
+		} else {
+			from = "whatsapp:" + from
+		}
+	}
+
+	v := url.Values{}
+	v.Set("To", to)
+	v.Set("From", from)
+	v.Set("Body", body)
+
+	req, err := http.NewRequest("POST", apiURL, strings.NewReader(v.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req.SetBasicAuth(accountSid, authToken)
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("whatsapp send failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"pos-fiber-app/internal/subscription"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
@@ -83,3 +84,55 @@ func TestPrintHandler(db *gorm.DB) fiber.Handler {
 		return c.JSON(fiber.Map{"status": "queued", "message": "Test job sent to outlet agents"})
 	}
 }
+
+// GetReceiptTemplate godoc
+// @Summary Get the receipt template
+// @Description How the business's receipts look: header, logo, address, tax number, footer and paper width. Falls back to the business's details when none is set.
+// @Tags Printing
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} ReceiptTemplate
+// @Router /receipt-template [get]
+func GetReceiptTemplateHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		tmpl, err := GetReceiptTemplate(db, bizID)
+		if err != nil {
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(tmpl)
+	}
+}
+
+// SaveReceiptTemplate godoc
+// @Summary Save the receipt template
+// @Tags Printing
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body ReceiptTemplateRequest true "Receipt template"
+// @Success 200 {object} ReceiptTemplate
+// @Failure 400 {object} map[string]string
+// @Router /receipt-template [put]
+func SaveReceiptTemplateHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		var req ReceiptTemplateRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		tmpl, err := SaveReceiptTemplate(db, bizID, req)
+		if err != nil {
+			if strings.Contains(err.Error(), "must") {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(tmpl)
+	}
+}
@@ -0,0 +1,166 @@
+// internal/printing/receipt.go
+package printing
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"pos-fiber-app/internal/terminal"
+
+	"gorm.io/gorm"
+)
+
+// Receipt is the customer-facing view of a sale, as the renderers lay it out
+type Receipt struct {
+	SaleID        uint             `json:"sale_id"`
+	ReceiptNo     string           `json:"receipt_no"`
+	Date          time.Time        `json:"date"`
+	Cashier       string           `json:"cashier,omitempty"`
+	TableNumber   string           `json:"table_number,omitempty"`
+	CustomerName  string           `json:"customer_name,omitempty"`
+	Currency      string           `json:"currency"`
+	Lines         []ReceiptLine    `json:"lines"`
+	Subtotal      float64          `json:"subtotal"`
+	Discount      float64          `json:"discount,omitempty"`
+	Tax           float64          `json:"tax"`
+	TaxInclusive  bool             `json:"tax_inclusive"`
+	ServiceCharge float64          `json:"service_charge,omitempty"`
+	DeliveryFee   float64          `json:"delivery_fee,omitempty"`
+	Total         float64          `json:"total"`
+	Payments      []ReceiptPayment `json:"payments"`
+	Tip           float64          `json:"tip,omitempty"`
+	Change        float64          `json:"change,omitempty"`
+	Copy          bool             `json:"copy"` // a reprint, marked COPY
+
+	// Documents other than receipts, e.g. QUOTATION, carry a title and may expire
+	Title      string     `json:"title,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+}
+
+// ReceiptLine is one item on a receipt. Details are printed under it: modifiers,
+// discounts and the like.
+type ReceiptLine struct {
+	Name     string   `json:"name"`
+	Quantity float64  `json:"quantity"`
+	Price    float64  `json:"price"` // unit price
+	Total    float64  `json:"total"`
+	Details  []string `json:"details,omitempty"`
+}
+
+type ReceiptPayment struct {
+	Method string  `json:"method"`
+	Amount float64 `json:"amount"`
+}
+
+// ReceiptTemplate is how a business's receipts look. Businesses without one get
+// their name, address and tax number on an 80mm receipt.
+type ReceiptTemplate struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	BusinessID   uint      `gorm:"uniqueIndex" json:"business_id"`
+	BusinessName string    `gorm:"size:255" json:"business_name"`
+	Header       string    `gorm:"type:text" json:"header,omitempty"` // printed under the name, e.g. a slogan
+	LogoURL      string    `json:"logo_url,omitempty"`                // shown on HTML receipts
+	Address      string    `gorm:"type:text" json:"address,omitempty"`
+	Phone        string    `gorm:"size:30" json:"phone,omitempty"`
+	TaxNumber    string    `gorm:"size:50" json:"tax_number,omitempty"`
+	Footer       string    `gorm:"type:text" json:"footer,omitempty"`
+	PaperWidth   int       `gorm:"default:80" json:"paper_width"` // mm: 58 or 80
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type ReceiptTemplateRequest struct {
+	BusinessName string `json:"business_name"`
+	Header       string `json:"header"`
+	LogoURL      string `json:"logo_url"`
+	Address      string `json:"address"`
+	Phone        string `json:"phone"`
+	TaxNumber    string `json:"tax_number"`
+	Footer       string `json:"footer"`
+	PaperWidth   int    `json:"paper_width"`
+}
+
+// GetReceiptTemplate returns the business's receipt template, or one made from its
+// details if it has not set one up
+func GetReceiptTemplate(db *gorm.DB, businessID uint) (*ReceiptTemplate, error) {
+	var tmpl ReceiptTemplate
+	err := db.Where("business_id = ?", businessID).First(&tmpl).Error
+	if err == nil {
+		return &tmpl, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var biz struct {
+		Name      string
+		Address   string
+		City      string
+		TaxNumber string
+	}
+	if err := db.Table("businesses").Select("name, address, city, tax_number").Where("id = ?", businessID).Scan(&biz).Error; err != nil {
+		return nil, err
+	}
+	address := biz.Address
+	if biz.City != "" {
+		address = strings.TrimPrefix(address+", "+biz.City, ", ")
+	}
+	return &ReceiptTemplate{
+		BusinessID:   businessID,
+		BusinessName: biz.Name,
+		Address:      address,
+		TaxNumber:    biz.TaxNumber,
+		Footer:       "Thank you for your patronage",
+		PaperWidth:   80,
+	}, nil
+}
+
+// SaveReceiptTemplate creates or replaces the business's receipt template
+func SaveReceiptTemplate(db *gorm.DB, businessID uint, req ReceiptTemplateRequest) (*ReceiptTemplate, error) {
+	if req.PaperWidth == 0 {
+		req.PaperWidth = 80
+	}
+	if req.PaperWidth != 58 && req.PaperWidth != 80 {
+		return nil, errors.New("paper_width must be 58 or 80")
+	}
+
+	tmpl, err := GetReceiptTemplate(db, businessID)
+	if err != nil {
+		return nil, err
+	}
+	if req.BusinessName != "" {
+		tmpl.BusinessName = req.BusinessName
+	}
+	tmpl.Header = req.Header
+	tmpl.LogoURL = req.LogoURL
+	tmpl.Address = req.Address
+	tmpl.Phone = req.Phone
+	tmpl.TaxNumber = req.TaxNumber
+	tmpl.Footer = req.Footer
+	tmpl.PaperWidth = req.PaperWidth
+
+	if err := db.Save(tmpl).Error; err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// PrintReceipt sends a receipt to the receipt printers of an outlet. It reports
+// whether any printer was found.
+func PrintReceipt(db *gorm.DB, tenantID string, outletID uint, r Receipt, t *ReceiptTemplate) (bool, error) {
+	var printers []terminal.Printer
+	if err := db.Where("tenant_id = ? AND outlet_id = ? AND type = ? AND is_active = ?", tenantID, outletID, terminal.PrinterReceipt, true).
+		Find(&printers).Error; err != nil {
+		return false, err
+	}
+	if len(printers) == 0 {
+		return false, nil
+	}
+
+	content := string(RenderESCPOS(r, t))
+	for _, p := range printers {
+		GlobalPrintingHub.SendJobToOutlet(outletID, PrintJob{PrinterID: p.ID, Content: content, Data: r})
+	}
+	return true, nil
+}
@@ -0,0 +1,417 @@
+// internal/printing/render.go
+package printing
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type align int
+
+const (
+	alignLeft align = iota
+	alignCenter
+)
+
+// textLine is one printed line of a receipt. Large lines are double width, so they
+// hold half as many characters.
+type textLine struct {
+	text  string
+	align align
+	bold  bool
+	large bool
+}
+
+// columns is how many characters fit across the paper in the printer's normal font
+func columns(paperWidth int) int {
+	if paperWidth == 58 {
+		return 32
+	}
+	return 48
+}
+
+// layoutReceipt lays a receipt out as lines of text, for printers and PDF
+func layoutReceipt(r Receipt, t *ReceiptTemplate) []textLine {
+	width := columns(t.PaperWidth)
+	rule := textLine{text: strings.Repeat("-", width)}
+
+	var out []textLine
+	center := func(text string, bold bool) {
+		for _, l := range wrap(text, width) {
+			out = append(out, textLine{text: l, align: alignCenter, bold: bold})
+		}
+	}
+	left := func(text string) {
+		for _, l := range wrap(text, width) {
+			out = append(out, textLine{text: l})
+		}
+	}
+	pair := func(label, amount string, bold bool) {
+		for _, l := range columnsPair(label, amount, width) {
+			out = append(out, textLine{text: l, bold: bold})
+		}
+	}
+
+	if r.Copy {
+		center("*** COPY ***", true)
+	}
+	for _, l := range wrap(t.BusinessName, width/2) {
+		out = append(out, textLine{text: l, align: alignCenter, bold: true, large: true})
+	}
+	for _, h := range strings.Split(t.Header, "\n") {
+		if h != "" {
+			center(h, false)
+		}
+	}
+	if t.Address != "" {
+		center(t.Address, false)
+	}
+	if t.Phone != "" {
+		center("Tel: "+t.Phone, false)
+	}
+	if t.TaxNumber != "" {
+		center("Tax No: "+t.TaxNumber, false)
+	}
+
+	out = append(out, rule)
+	if r.Title != "" {
+		center(r.Title, true)
+		left("No: " + r.ReceiptNo)
+	} else {
+		left("Receipt: " + r.ReceiptNo)
+	}
+	left("Date: " + r.Date.Format("02 Jan 2006 15:04"))
+	if r.ValidUntil != nil {
+		left("Valid until: " + r.ValidUntil.Format("02 Jan 2006"))
+	}
+	if r.Cashier != "" {
+		left("Cashier: " + r.Cashier)
+	}
+	if r.TableNumber != "" {
+		left("Table: " + r.TableNumber)
+	}
+	if r.CustomerName != "" {
+		left("Customer: " + r.CustomerName)
+	}
+	out = append(out, rule)
+
+	for _, line := range r.Lines {
+		left(line.Name)
+		pair(fmt.Sprintf("  %s x %s", quantity(line.Quantity), money(line.Price)), money(line.Total), false)
+		for _, d := range line.Details {
+			for _, l := range wrap("- "+d, width-2) {
+				out = append(out, textLine{text: "  " + l})
+			}
+		}
+	}
+	out = append(out, rule)
+
+	pair("Subtotal", money(r.Subtotal), false)
+	if r.Discount > 0 {
+		pair("Discount", "-"+money(r.Discount), false)
+	}
+	if r.ServiceCharge > 0 {
+		pair("Service charge", money(r.ServiceCharge), false)
+	}
+	if r.DeliveryFee > 0 {
+		pair("Delivery fee", money(r.DeliveryFee), false)
+	}
+	if r.TaxInclusive {
+		pair("VAT (included)", money(r.Tax), false)
+	} else {
+		pair("VAT", money(r.Tax), false)
+	}
+	pair("TOTAL "+r.Currency, money(r.Total), true)
+	out = append(out, rule)
+
+	for _, p := range r.Payments {
+		pair(p.Method, money(p.Amount), false)
+	}
+	if r.Tip > 0 {
+		pair("Tip", money(r.Tip), false)
+	}
+	if r.Change > 0 {
+		pair("Change", money(r.Change), false)
+	}
+
+	if t.Footer != "" {
+		out = append(out, rule)
+		for _, f := range strings.Split(t.Footer, "\n") {
+			if f != "" {
+				center(f, false)
+			}
+		}
+	}
+	if r.Copy {
+		center("*** COPY ***", true)
+	}
+	return out
+}
+
+// RenderESCPOS renders a receipt as ESC/POS commands for a thermal printer
+func RenderESCPOS(r Receipt, t *ReceiptTemplate) []byte {
+	var b bytes.Buffer
+	b.WriteString("\x1b\x40") // Initialise printer
+
+	for _, l := range layoutReceipt(r, t) {
+		if l.align == alignCenter {
+			b.WriteString("\x1b\x61\x01")
+		} else {
+			b.WriteString("\x1b\x61\x00")
+		}
+		mode := byte(0)
+		if l.bold {
+			mode |= 0x08
+		}
+		if l.large {
+			mode |= 0x30 // Double height and width
+		}
+		b.WriteString("\x1b\x21")
+		b.WriteByte(mode)
+		b.WriteString(ascii(l.text))
+		b.WriteByte('\n')
+	}
+
+	b.WriteString("\x1b\x21\x00\x1b\x61\x00") // Reset font and alignment
+	b.WriteString("\n\n\n\x1dV\x00")          // Feed and cut
+	return b.Bytes()
+}
+
+var receiptHTML = template.Must(template.New("receipt").Funcs(template.FuncMap{
+	"money":    money,
+	"quantity": quantity,
+	"lines": func(s string) []string {
+		var out []string
+		for _, l := range strings.Split(s, "\n") {
+			if l != "" {
+				out = append(out, l)
+			}
+		}
+		return out
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .R.Title}}{{.R.Title}}{{else}}Receipt{{end}} {{.R.ReceiptNo}}</title>
+<style>
+body { margin: 0; background: #f3f3f3; font-family: "Courier New", monospace; font-size: 13px; color: #111; }
+.receipt { width: {{.T.PaperWidth}}mm; max-width: 100%; margin: 16px auto; padding: 12px; background: #fff; box-sizing: border-box; }
+.center { text-align: center; }
+.name { font-size: 18px; font-weight: bold; margin: 4px 0; }
+.copy { text-align: center; font-weight: bold; letter-spacing: 2px; border: 1px dashed #111; padding: 2px; }
+.logo { max-width: 60%; max-height: 80px; }
+hr { border: 0; border-top: 1px dashed #111; }
+table { width: 100%; border-collapse: collapse; }
+td { vertical-align: top; padding: 1px 0; }
+td.amount { text-align: right; white-space: nowrap; }
+.detail { padding-left: 12px; color: #555; }
+.total td { font-weight: bold; font-size: 15px; }
+</style>
+</head>
+<body>
+<div class="receipt">
+{{if .R.Copy}}<div class="copy">COPY</div>{{end}}
+<div class="center">
+{{if .T.LogoURL}}<img class="logo" src="{{.T.LogoURL}}" alt=""><br>{{end}}
+<div class="name">{{.T.BusinessName}}</div>
+{{range lines .T.Header}}<div>{{.}}</div>{{end}}
+{{if .T.Address}}<div>{{.T.Address}}</div>{{end}}
+{{if .T.Phone}}<div>Tel: {{.T.Phone}}</div>{{end}}
+{{if .T.TaxNumber}}<div>Tax No: {{.T.TaxNumber}}</div>{{end}}
+</div>
+<hr>
+{{if .R.Title}}<div class="center"><strong>{{.R.Title}}</strong></div>
+<div>No: {{.R.ReceiptNo}}</div>{{else}}<div>Receipt: {{.R.ReceiptNo}}</div>{{end}}
+<div>Date: {{.R.Date.Format "02 Jan 2006 15:04"}}</div>
+{{if .R.ValidUntil}}<div>Valid until: {{.R.ValidUntil.Format "02 Jan 2006"}}</div>{{end}}
+{{if .R.Cashier}}<div>Cashier: {{.R.Cashier}}</div>{{end}}
+{{if .R.TableNumber}}<div>Table: {{.R.TableNumber}}</div>{{end}}
+{{if .R.CustomerName}}<div>Customer: {{.R.CustomerName}}</div>{{end}}
+<hr>
+<table>
+{{range .R.Lines}}<tr><td colspan="2">{{.Name}}</td></tr>
+<tr><td>&nbsp;&nbsp;{{quantity .Quantity}} x {{money .Price}}</td><td class="amount">{{money .Total}}</td></tr>
+{{range .Details}}<tr><td colspan="2" class="detail">- {{.}}</td></tr>{{end}}{{end}}
+</table>
+<hr>
+<table>
+<tr><td>Subtotal</td><td class="amount">{{money .R.Subtotal}}</td></tr>
+{{if gt .R.Discount 0.0}}<tr><td>Discount</td><td class="amount">-{{money .R.Discount}}</td></tr>{{end}}
+{{if gt .R.ServiceCharge 0.0}}<tr><td>Service charge</td><td class="amount">{{money .R.ServiceCharge}}</td></tr>{{end}}
+{{if gt .R.DeliveryFee 0.0}}<tr><td>Delivery fee</td><td class="amount">{{money .R.DeliveryFee}}</td></tr>{{end}}
+<tr><td>VAT{{if .R.TaxInclusive}} (included){{end}}</td><td class="amount">{{money .R.Tax}}</td></tr>
+<tr class="total"><td>TOTAL {{.R.Currency}}</td><td class="amount">{{money .R.Total}}</td></tr>
+</table>
+<hr>
+<table>
+{{range .R.Payments}}<tr><td>{{.Method}}</td><td class="amount">{{money .Amount}}</td></tr>{{end}}
+{{if gt .R.Tip 0.0}}<tr><td>Tip</td><td class="amount">{{money .R.Tip}}</td></tr>{{end}}
+{{if gt .R.Change 0.0}}<tr><td>Change</td><td class="amount">{{money .R.Change}}</td></tr>{{end}}
+</table>
+{{if .T.Footer}}<hr><div class="center">{{range lines .T.Footer}}<div>{{.}}</div>{{end}}</div>{{end}}
+{{if .R.Copy}}<div class="copy">COPY</div>{{end}}
+</div>
+</body>
+</html>
+`))
+
+// RenderHTML renders a receipt as a web page
+func RenderHTML(r Receipt, t *ReceiptTemplate) ([]byte, error) {
+	var b bytes.Buffer
+	if err := receiptHTML.Execute(&b, struct {
+		R Receipt
+		T *ReceiptTemplate
+	}{r, t}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// RenderPDF renders a receipt as a single-page PDF the width of the paper, set in
+// Courier like the printed receipt
+func RenderPDF(r Receipt, t *ReceiptTemplate) []byte {
+	lines := layoutReceipt(r, t)
+	cols := columns(t.PaperWidth)
+
+	const margin = 8.0
+	pageWidth := float64(t.PaperWidth) * 72 / 25.4
+	size := (pageWidth - 2*margin) / (float64(cols) * 0.6) // Courier glyphs are 0.6em wide
+	leading := size * 1.3
+
+	height := 2 * margin
+	for _, l := range lines {
+		if l.large {
+			height += leading * 2
+		} else {
+			height += leading
+		}
+	}
+
+	var content bytes.Buffer
+	y := height - margin
+	for _, l := range lines {
+		fontSize, step := size, leading
+		if l.large {
+			fontSize, step = size*2, leading*2
+		}
+		y -= step
+		font := "F1"
+		if l.bold {
+			font = "F2"
+		}
+		x := margin
+		if l.align == alignCenter {
+			x += (pageWidth - 2*margin - float64(utf8.RuneCountInString(l.text))*fontSize*0.6) / 2
+		}
+		fmt.Fprintf(&content, "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, fontSize, x, y+step-fontSize, pdfEscape(l.text))
+	}
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", pageWidth, math.Ceil(height)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = pdf.Len()
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return pdf.Bytes()
+}
+
+// wrap breaks text into lines of at most width characters, at spaces where it can
+func wrap(text string, width int) []string {
+	var out []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		for utf8.RuneCountInString(word) > width {
+			if line != "" {
+				out = append(out, line)
+				line = ""
+			}
+			runes := []rune(word)
+			out = append(out, string(runes[:width]))
+			word = string(runes[width:])
+		}
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+			line += " " + word
+		default:
+			out = append(out, line)
+			line = word
+		}
+	}
+	if line != "" {
+		out = append(out, line)
+	}
+	return out
+}
+
+// columnsPair sets a label on the left and an amount on the right of a line, putting
+// the amount on a line of its own when both do not fit
+func columnsPair(label, amount string, width int) []string {
+	gap := width - utf8.RuneCountInString(label) - utf8.RuneCountInString(amount)
+	if gap >= 1 {
+		return []string{label + strings.Repeat(" ", gap) + amount}
+	}
+	out := wrap(label, width)
+	return append(out, strings.Repeat(" ", max(width-utf8.RuneCountInString(amount), 0))+amount)
+}
+
+// money formats an amount with thousands separators, e.g. 12,500.00
+func money(v float64) string {
+	s := fmt.Sprintf("%.2f", math.Abs(v))
+	whole, frac, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	for i, d := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	sign := ""
+	if v < 0 && s != "0.00" {
+		sign = "-"
+	}
+	return sign + b.String() + "." + frac
+}
+
+// quantity prints an item quantity without trailing zeros: 2, or 0.75 for a weighed item
+func quantity(q float64) string {
+	return strconv.FormatFloat(math.Round(q*1000)/1000, 'f', -1, 64)
+}
+
+// ascii replaces characters receipt printers and the PDF fonts cannot show
+func ascii(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 32 || r > 126 {
+			return '?'
+		}
+		return r
+	}, s)
+}
+
+func pdfEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(ascii(s))
+}
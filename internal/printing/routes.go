@@ -1,6 +1,8 @@
 package printing
 
 import (
+	"pos-fiber-app/internal/middleware"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 	"gorm.io/gorm"
@@ -17,4 +19,8 @@ func RegisterRoutes(r fiber.Router, db *gorm.DB) {
 
 	// API to trigger print jobs (Protected by usual business/outlet middleware)
 	r.Post("/print/test", TestPrintHandler(db))
+
+	// Receipt layout
+	r.Get("/receipt-template", GetReceiptTemplateHandler(db))
+	r.Put("/receipt-template", middleware.RequireRoles("OWNER", "MANAGER"), SaveReceiptTemplateHandler(db))
 }
@@ -2,11 +2,31 @@ package printing
 
 import (
 	"fmt"
+	"strings"
+
 	"pos-fiber-app/internal/terminal"
 
 	"gorm.io/gorm"
 )
 
+// KitchenTicket is the kitchen-facing view of an order
+type KitchenTicket struct {
+	OrderID      uint                `json:"order_id"`
+	TableNumber  string              `json:"table_number,omitempty"`
+	OrderType    string              `json:"order_type,omitempty"`
+	CustomerName string              `json:"customer_name,omitempty"`
+	Course       int                 `json:"course,omitempty"` // set when the ticket fires a course
+	Items        []KitchenTicketItem `json:"items"`
+}
+
+// KitchenTicketItem is a single line on a kitchen ticket
+type KitchenTicketItem struct {
+	Name       string   `json:"name"`
+	Quantity   float64  `json:"quantity"`
+	SeatNumber int      `json:"seat_number,omitempty"`
+	Modifiers  []string `json:"modifiers,omitempty"` // e.g. "Large", "No onions"
+}
+
 // PrintKitchenOrder formats and sends an order to kitchen printers in an outlet
 func PrintKitchenOrder(db *gorm.DB, tenantID string, outletID uint, orderData interface{}) error {
 	// 1. Find all kitchen printers for this outlet
@@ -45,13 +65,58 @@ func formatOrderForKitchen(data interface{}) string {
 		"\x1b\x21\x00" + // Reset font
 		"--------------------------------\n"
 
-	body := fmt.Sprintf("Order ID: %v\n", data) // In reality, cast and loop
+	var body string
+	switch ticket := data.(type) {
+	case KitchenTicket:
+		body = formatKitchenTicket(ticket)
+	case *KitchenTicket:
+		body = formatKitchenTicket(*ticket)
+	default:
+		body = fmt.Sprintf("Order ID: %v\n", data)
+	}
 
 	footer := "\n\n\n\x1dV\x00" // Form feed and cut
 
 	return header + body + footer
 }
 
+func formatKitchenTicket(t KitchenTicket) string {
+	var b strings.Builder
+
+	b.WriteString("\x1b\x61\x00") // Left alignment
+	fmt.Fprintf(&b, "Order #%d\n", t.OrderID)
+	if t.TableNumber != "" {
+		fmt.Fprintf(&b, "Table: %s\n", t.TableNumber)
+	}
+	if t.OrderType != "" {
+		fmt.Fprintf(&b, "Type: %s\n", strings.ToUpper(t.OrderType))
+	}
+	if t.CustomerName != "" {
+		fmt.Fprintf(&b, "Customer: %s\n", t.CustomerName)
+	}
+	if t.Course > 0 {
+		b.WriteString("\x1b\x45\x01") // Bold on
+		fmt.Fprintf(&b, "FIRE COURSE %d\n", t.Course)
+		b.WriteString("\x1b\x45\x00") // Bold off
+	}
+	b.WriteString("--------------------------------\n")
+
+	for _, item := range t.Items {
+		b.WriteString("\x1b\x45\x01") // Bold on
+		fmt.Fprintf(&b, "%sx %s", quantity(item.Quantity), item.Name)
+		b.WriteString("\x1b\x45\x00") // Bold off
+		if item.SeatNumber > 0 {
+			fmt.Fprintf(&b, " (Seat %d)", item.SeatNumber)
+		}
+		b.WriteString("\n")
+		for _, m := range item.Modifiers {
+			fmt.Fprintf(&b, "   > %s\n", m)
+		}
+	}
+
+	return b.String()
+}
+
 // Helper to check if printing is enabled for a business/terminal
 // (This could be expanded to check specific database settings)
 func IsSilentPrintingEnabled(db *gorm.DB, bizID uint) bool {
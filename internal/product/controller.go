@@ -4,7 +4,9 @@ package product
 import (
 	"fmt"
 	"pos-fiber-app/internal/common"
+	"pos-fiber-app/internal/inventory"
 	"pos-fiber-app/internal/subscription"
+	"pos-fiber-app/internal/types"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
@@ -110,11 +112,17 @@ func CreateHandler(db *gorm.DB) fiber.Handler {
 		if trackStr := c.FormValue("track_by_round"); trackStr != "" {
 			req.TrackByRound = trackStr == "true"
 		}
+		if giftStr := c.FormValue("is_gift_card"); giftStr != "" {
+			req.IsGiftCard = giftStr == "true"
+		}
+		if req.TaxClass != "" && !req.TaxClass.Valid() {
+			return fiber.NewError(fiber.StatusBadRequest, "tax_class must be STANDARD, ZERO_RATED or EXEMPT")
+		}
 		if uom := c.FormValue("unit_of_measure"); uom != "" {
 			req.UnitOfMeasure = uom
 		}
 
-		product, err := Create(db, bizID, req)
+		product, err := Create(stockBy(c, db), bizID, req)
 		if err != nil {
 			return fiber.ErrInternalServerError
 		}
@@ -192,11 +200,18 @@ func UpdateHandler(db *gorm.DB) fiber.Handler {
 			val := trackStr == "true"
 			req.TrackByRound = &val
 		}
+		if giftStr := c.FormValue("is_gift_card"); giftStr != "" {
+			val := giftStr == "true"
+			req.IsGiftCard = &val
+		}
+		if req.TaxClass != nil && *req.TaxClass != "" && !req.TaxClass.Valid() {
+			return fiber.NewError(fiber.StatusBadRequest, "tax_class must be STANDARD, ZERO_RATED or EXEMPT")
+		}
 		if uom := c.FormValue("unit_of_measure"); uom != "" {
 			req.UnitOfMeasure = uom
 		}
 
-		product, err := Update(db, uint(id), bizID, req)
+		product, err := Update(stockBy(c, db), uint(id), bizID, req)
 		if err != nil {
 			if err.Error() == "product not found" {
 				return fiber.NewError(fiber.StatusNotFound, "product not found")
@@ -252,3 +267,12 @@ func LowStockHandler(db *gorm.DB) fiber.Handler {
 		return c.JSON(products)
 	}
 }
+
+// stockBy returns a session whose stock changes are recorded as made by the request's user
+func stockBy(c *fiber.Ctx, db *gorm.DB) *gorm.DB {
+	claims, ok := c.Locals("user").(*types.UserClaims)
+	if !ok || claims == nil {
+		return db
+	}
+	return inventory.WithMovement(db, inventory.Movement{UserID: &claims.UserID, OutletID: claims.OutletID})
+}
@@ -1,5 +1,7 @@
 package product
 
+import "pos-fiber-app/internal/common"
+
 // type CreateProductRequest struct {
 // 	Name        string  `json:"name" validate:"required,min=2"`
 // 	SKU         string  `json:"sku" validate:"omitempty,alphanum"`
@@ -31,11 +33,17 @@ type CreateProductRequest struct {
 	Cost        float64 `json:"cost" form:"cost" validate:"gte=0"`
 	CategoryID  uint    `json:"category_id" form:"category_id" validate:"required"`
 	ImageURL    string  `json:"image_url" form:"image_url" validate:"omitempty,url"`
-	Stock       int     `json:"stock" form:"stock"`
-	MinStock    int     `json:"min_stock" form:"min_stock"`
+	Stock       float64 `json:"stock" form:"stock"`
+	MinStock    float64 `json:"min_stock" form:"min_stock"`
 	Barcode     string  `json:"barcode,omitempty" form:"barcode"`
 	TrackByRound bool   `json:"track_by_round" form:"track_by_round"`
 	UnitOfMeasure string `json:"unit_of_measure,omitempty" form:"unit_of_measure"`
+	QuantityPrecision int `json:"quantity_precision" form:"quantity_precision" validate:"gte=0,lte=3"`
+	PLU         string  `json:"plu,omitempty" form:"plu" validate:"omitempty,numeric,len=5"`
+	ParentID    *uint   `json:"parent_id,omitempty" form:"parent_id"`
+	VariantName string  `json:"variant_name,omitempty" form:"variant_name"`
+	IsGiftCard  bool    `json:"is_gift_card" form:"is_gift_card"`
+	TaxClass    common.TaxClass `json:"tax_class,omitempty" form:"tax_class"` // STANDARD, ZERO_RATED or EXEMPT; empty = the category's
 }
 
 // UpdateProductRequest (all fields optional)
@@ -47,10 +55,26 @@ type UpdateProductRequest struct {
 	Cost        *float64 `json:"cost,omitempty" form:"cost" validate:"omitempty,gte=0"`
 	CategoryID  *uint    `json:"category_id,omitempty" form:"category_id"`
 	ImageURL    string   `json:"image_url,omitempty" form:"image_url" validate:"omitempty,url"`
-	Stock       *int     `json:"stock,omitempty" form:"stock"`
-	MinStock    *int     `json:"min_stock,omitempty" form:"min_stock"`
+	Stock       *float64 `json:"stock,omitempty" form:"stock"`
+	MinStock    *float64 `json:"min_stock,omitempty" form:"min_stock"`
 	Barcode     string   `json:"barcode,omitempty" form:"barcode"`
 	Active      *bool    `json:"active,omitempty" form:"active"`
 	TrackByRound *bool   `json:"track_by_round,omitempty" form:"track_by_round"`
 	UnitOfMeasure string `json:"unit_of_measure,omitempty" form:"unit_of_measure"`
+	QuantityPrecision *int `json:"quantity_precision,omitempty" form:"quantity_precision" validate:"omitempty,gte=0,lte=3"`
+	PLU         *string  `json:"plu,omitempty" form:"plu" validate:"omitempty,numeric,len=5"`
+	IsGiftCard  *bool    `json:"is_gift_card,omitempty" form:"is_gift_card"`
+	TaxClass    *common.TaxClass `json:"tax_class,omitempty" form:"tax_class"` // "" = back to the category's
+}
+
+// CreateVariantRequest describes a variant of an existing product.
+// Price and Cost default to the parent's when omitted.
+type CreateVariantRequest struct {
+	VariantName string   `json:"variant_name" validate:"required"`
+	SKU         string   `json:"sku" validate:"required,alphanum"`
+	Price       *float64 `json:"price,omitempty" validate:"omitempty,gt=0"`
+	Cost        *float64 `json:"cost,omitempty" validate:"omitempty,gte=0"`
+	Stock       float64  `json:"stock"`
+	MinStock    float64  `json:"min_stock"`
+	Barcode     string   `json:"barcode,omitempty"`
 }
@@ -4,6 +4,8 @@ package product
 import (
 	"time"
 
+	"pos-fiber-app/internal/common"
+
 	"gorm.io/gorm"
 )
 
@@ -17,12 +19,18 @@ type Product struct {
 	Price       float64        `gorm:"type:decimal(10,2)" json:"price"`
 	Cost        float64        `gorm:"type:decimal(10,2)" json:"cost,omitempty"`
 	ImageURL    string         `json:"image_url,omitempty"`
-	Stock       int            `json:"stock"`
-	MinStock    int            `json:"min_stock"`
+	Stock       float64        `gorm:"type:decimal(14,3)" json:"stock"`
+	MinStock    float64        `gorm:"type:decimal(14,3)" json:"min_stock"`
 	Barcode     string         `gorm:"size:100" json:"barcode,omitempty"`
 	TrackByRound bool          `gorm:"default:false" json:"track_by_round"`
 	UnitOfMeasure string        `gorm:"size:20" json:"unit_of_measure,omitempty"` // e.g., Liters, Tons
+	QuantityPrecision int       `gorm:"default:0" json:"quantity_precision"`     // decimal places it sells in: 0 = whole units, 3 = e.g. 0.125 kg
+	PLU           string        `gorm:"size:5;index" json:"plu,omitempty"`       // item code a weighing scale prints into its barcodes
 	Active      bool           `json:"active" default:"true"`
+	ParentID    *uint          `gorm:"index" json:"parent_id,omitempty"`      // Set on variants (e.g. a size) of another product
+	VariantName string         `gorm:"size:100" json:"variant_name,omitempty"` // e.g. "Large"
+	IsGiftCard  bool           `gorm:"default:false" json:"is_gift_card"`      // Selling it issues a gift card worth its price; carries no stock
+	TaxClass    common.TaxClass `gorm:"type:varchar(20)" json:"tax_class,omitempty"` // empty = the category's tax class
 	CreatedAt   time.Time      `json:"-"`
 	UpdatedAt   time.Time      `json:"-"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
@@ -0,0 +1,306 @@
+// internal/product/modifier.go
+package product
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ModifierGroup is a set of choices offered on a product, e.g. "Size", "Add-ons" or "Remove".
+// Required groups need at least one selection (or MinSelect, if higher); MaxSelect 0 means no limit.
+type ModifierGroup struct {
+	ID         uint             `gorm:"primaryKey" json:"id"`
+	BusinessID uint             `gorm:"index" json:"business_id"`
+	Name       string           `gorm:"size:100;not null" json:"name"`
+	Required   bool             `gorm:"default:false" json:"required"`
+	MinSelect  int              `gorm:"default:0" json:"min_select"`
+	MaxSelect  int              `gorm:"default:0" json:"max_select"`
+	Options    []ModifierOption `gorm:"foreignKey:GroupID;constraint:OnDelete:CASCADE" json:"options"`
+	CreatedAt  time.Time        `json:"-"`
+	UpdatedAt  time.Time        `json:"-"`
+}
+
+// ModifierOption is a single choice within a group, e.g. "Extra cheese" (+500) or "No onions" (0)
+type ModifierOption struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	GroupID    uint      `gorm:"index" json:"group_id"`
+	BusinessID uint      `gorm:"index" json:"business_id"`
+	Name       string    `gorm:"size:100;not null" json:"name"`
+	PriceDelta float64   `gorm:"type:decimal(10,2);default:0" json:"price_delta"`
+	Active     bool      `gorm:"default:true" json:"active"`
+	CreatedAt  time.Time `json:"-"`
+	UpdatedAt  time.Time `json:"-"`
+}
+
+// ProductModifierGroup attaches a modifier group to a product.
+// Groups attached to a parent product also apply to all of its variants.
+type ProductModifierGroup struct {
+	ID         uint `gorm:"primaryKey" json:"id"`
+	BusinessID uint `gorm:"index" json:"business_id"`
+	ProductID  uint `gorm:"uniqueIndex:idx_product_modifier_group" json:"product_id"`
+	GroupID    uint `gorm:"uniqueIndex:idx_product_modifier_group" json:"group_id"`
+}
+
+// SelectedModifier is the snapshot of a chosen option stored on a sale item
+type SelectedModifier struct {
+	OptionID   uint    `json:"option_id"`
+	GroupID    uint    `json:"group_id"`
+	GroupName  string  `json:"group_name"`
+	Name       string  `json:"name"`
+	PriceDelta float64 `json:"price_delta"`
+}
+
+type CreateModifierGroupRequest struct {
+	Name      string                        `json:"name" validate:"required"`
+	Required  bool                          `json:"required"`
+	MinSelect int                           `json:"min_select" validate:"gte=0"`
+	MaxSelect int                           `json:"max_select" validate:"gte=0"`
+	Options   []CreateModifierOptionRequest `json:"options"`
+}
+
+type CreateModifierOptionRequest struct {
+	Name       string  `json:"name" validate:"required"`
+	PriceDelta float64 `json:"price_delta"`
+}
+
+type AttachModifierGroupRequest struct {
+	GroupID uint `json:"group_id" validate:"required"`
+}
+
+// CreateModifierGroup creates a modifier group together with its initial options
+func CreateModifierGroup(db *gorm.DB, businessID uint, req CreateModifierGroupRequest) (*ModifierGroup, error) {
+	if req.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if req.MinSelect < 0 || req.MaxSelect < 0 {
+		return nil, errors.New("min_select and max_select cannot be negative")
+	}
+	if req.MaxSelect > 0 && req.MinSelect > req.MaxSelect {
+		return nil, errors.New("min_select cannot exceed max_select")
+	}
+
+	group := &ModifierGroup{
+		BusinessID: businessID,
+		Name:       req.Name,
+		Required:   req.Required,
+		MinSelect:  req.MinSelect,
+		MaxSelect:  req.MaxSelect,
+	}
+	for _, o := range req.Options {
+		if o.Name == "" {
+			return nil, errors.New("option name is required")
+		}
+		group.Options = append(group.Options, ModifierOption{
+			BusinessID: businessID,
+			Name:       o.Name,
+			PriceDelta: o.PriceDelta,
+			Active:     true,
+		})
+	}
+
+	if err := db.Create(group).Error; err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// ListModifierGroups returns all modifier groups of a business with their active options
+func ListModifierGroups(db *gorm.DB, businessID uint) ([]ModifierGroup, error) {
+	groups := []ModifierGroup{}
+	err := db.Preload("Options", "active = ?", true).
+		Where("business_id = ?", businessID).
+		Order("name ASC").
+		Find(&groups).Error
+	return groups, err
+}
+
+// DeleteModifierGroup removes a group, its options and its product attachments.
+// Sale items keep their snapshot of any options already sold.
+func DeleteModifierGroup(db *gorm.DB, groupID, businessID uint) error {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	result := tx.Where("id = ? AND business_id = ?", groupID, businessID).Delete(&ModifierGroup{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("modifier group not found")
+	}
+	if err := tx.Where("group_id = ?", groupID).Delete(&ModifierOption{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("group_id = ?", groupID).Delete(&ProductModifierGroup{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// AddModifierOption adds an option to an existing group
+func AddModifierOption(db *gorm.DB, groupID, businessID uint, req CreateModifierOptionRequest) (*ModifierOption, error) {
+	if req.Name == "" {
+		return nil, errors.New("name is required")
+	}
+
+	var group ModifierGroup
+	if err := db.First(&group, "id = ? AND business_id = ?", groupID, businessID).Error; err != nil {
+		return nil, errors.New("modifier group not found")
+	}
+
+	option := &ModifierOption{
+		GroupID:    group.ID,
+		BusinessID: businessID,
+		Name:       req.Name,
+		PriceDelta: req.PriceDelta,
+		Active:     true,
+	}
+	if err := db.Create(option).Error; err != nil {
+		return nil, err
+	}
+	return option, nil
+}
+
+// DeactivateModifierOption hides an option from sale without touching past sales
+func DeactivateModifierOption(db *gorm.DB, groupID, optionID, businessID uint) error {
+	result := db.Model(&ModifierOption{}).
+		Where("id = ? AND group_id = ? AND business_id = ?", optionID, groupID, businessID).
+		Update("active", false)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("modifier option not found")
+	}
+	return nil
+}
+
+// AttachModifierGroup offers a modifier group on a product (and its variants)
+func AttachModifierGroup(db *gorm.DB, productID, businessID, groupID uint) error {
+	if _, err := Get(db, productID, businessID); err != nil {
+		return err
+	}
+
+	var group ModifierGroup
+	if err := db.First(&group, "id = ? AND business_id = ?", groupID, businessID).Error; err != nil {
+		return errors.New("modifier group not found")
+	}
+
+	link := ProductModifierGroup{BusinessID: businessID, ProductID: productID, GroupID: groupID}
+	return db.Where(map[string]interface{}{"product_id": productID, "group_id": groupID}).FirstOrCreate(&link).Error
+}
+
+// DetachModifierGroup stops offering a modifier group on a product
+func DetachModifierGroup(db *gorm.DB, productID, businessID, groupID uint) error {
+	result := db.Where("product_id = ? AND group_id = ? AND business_id = ?", productID, groupID, businessID).
+		Delete(&ProductModifierGroup{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("modifier group not attached to product")
+	}
+	return nil
+}
+
+// GetProductModifierGroups returns the groups offered on a product, including
+// those inherited from its parent when the product is a variant
+func GetProductModifierGroups(db *gorm.DB, productID, businessID uint) ([]ModifierGroup, error) {
+	prod, err := Get(db, productID, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	productIDs := []uint{prod.ID}
+	if prod.ParentID != nil {
+		productIDs = append(productIDs, *prod.ParentID)
+	}
+
+	groups := []ModifierGroup{}
+	err = db.Preload("Options", "active = ?", true).
+		Where("business_id = ? AND id IN (?)", businessID,
+			db.Model(&ProductModifierGroup{}).Select("group_id").Where("product_id IN ?", productIDs)).
+		Order("id ASC").
+		Find(&groups).Error
+	return groups, err
+}
+
+// ResolveModifiers validates the chosen options against the product's modifier groups
+// (availability, required groups and min/max selections) and returns their snapshot.
+func ResolveModifiers(db *gorm.DB, businessID, productID uint, optionIDs []uint) ([]SelectedModifier, error) {
+	groups, err := GetProductModifierGroups(db, productID, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	type offered struct {
+		group  *ModifierGroup
+		option ModifierOption
+	}
+	available := map[uint]offered{}
+	for i := range groups {
+		for _, o := range groups[i].Options {
+			available[o.ID] = offered{group: &groups[i], option: o}
+		}
+	}
+
+	chosen := map[uint]bool{}
+	counts := map[uint]int{}
+	selected := []SelectedModifier{}
+	for _, id := range optionIDs {
+		if chosen[id] {
+			continue
+		}
+		entry, ok := available[id]
+		if !ok {
+			return nil, fmt.Errorf("modifier option %d is not available for this product", id)
+		}
+		chosen[id] = true
+		counts[entry.group.ID]++
+		selected = append(selected, SelectedModifier{
+			OptionID:   entry.option.ID,
+			GroupID:    entry.group.ID,
+			GroupName:  entry.group.Name,
+			Name:       entry.option.Name,
+			PriceDelta: entry.option.PriceDelta,
+		})
+	}
+
+	for _, g := range groups {
+		minimum := g.MinSelect
+		if g.Required && minimum < 1 {
+			minimum = 1
+		}
+		if counts[g.ID] < minimum {
+			return nil, fmt.Errorf("modifier group %s requires at least %d selection(s)", g.Name, minimum)
+		}
+		if g.MaxSelect > 0 && counts[g.ID] > g.MaxSelect {
+			return nil, fmt.Errorf("modifier group %s allows at most %d selection(s)", g.Name, g.MaxSelect)
+		}
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].GroupID != selected[j].GroupID {
+			return selected[i].GroupID < selected[j].GroupID
+		}
+		return selected[i].OptionID < selected[j].OptionID
+	})
+
+	return selected, nil
+}
+
+// ModifierKey builds a stable key for a set of selected modifiers, so identical
+// choices of the same product can share a sale line
+func ModifierKey(modifiers []SelectedModifier) string {
+	ids := make([]string, len(modifiers))
+	for i, m := range modifiers {
+		ids[i] = strconv.FormatUint(uint64(m.OptionID), 10)
+	}
+	return strings.Join(ids, ",")
+}
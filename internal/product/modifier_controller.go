@@ -0,0 +1,317 @@
+// internal/product/modifier_controller.go
+package product
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func handleModifierError(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "not found") || strings.Contains(msg, "not attached") {
+		return fiber.NewError(fiber.StatusNotFound, msg)
+	}
+	if strings.HasPrefix(msg, "PRODUCT_LIMIT_REACHED") {
+		return fiber.NewError(fiber.StatusForbidden, msg)
+	}
+	if strings.Contains(msg, "required") || strings.Contains(msg, "cannot") {
+		return fiber.NewError(fiber.StatusBadRequest, msg)
+	}
+	return fiber.ErrInternalServerError
+}
+
+// ListVariants godoc
+// @Summary List variants of a product
+// @Description Retrieve the active variants (sizes, flavours, ...) of a product, each with its own SKU and stock
+// @Tags Product
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Parent Product ID"
+// @Success 200 {array} Product
+// @Failure 404 {object} map[string]string
+// @Router /products/{id}/variants [get]
+func ListVariantsHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid product ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		variants, err := ListVariants(db, uint(id), bizID)
+		if err != nil {
+			return handleModifierError(err)
+		}
+
+		return c.JSON(variants)
+	}
+}
+
+// CreateVariant godoc
+// @Summary Add a variant to a product
+// @Description Create a variant with its own SKU, price and stock. Price and cost default to the parent's.
+// @Tags Product
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Parent Product ID"
+// @Param body body CreateVariantRequest true "Variant details"
+// @Success 201 {object} Product
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Product limit reached"
+// @Failure 404 {object} map[string]string
+// @Router /products/{id}/variants [post]
+func CreateVariantHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid product ID")
+		}
+
+		var req CreateVariantRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		variant, err := CreateVariant(stockBy(c, db), uint(id), bizID, req)
+		if err != nil {
+			return handleModifierError(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(variant)
+	}
+}
+
+// GetProductModifiers godoc
+// @Summary List modifier groups offered on a product
+// @Description Includes groups inherited from the parent product when the product is a variant
+// @Tags Product
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Product ID"
+// @Success 200 {array} ModifierGroup
+// @Failure 404 {object} map[string]string
+// @Router /products/{id}/modifiers [get]
+func GetProductModifiersHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid product ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		groups, err := GetProductModifierGroups(db, uint(id), bizID)
+		if err != nil {
+			return handleModifierError(err)
+		}
+
+		return c.JSON(groups)
+	}
+}
+
+// AttachModifierGroup godoc
+// @Summary Offer a modifier group on a product
+// @Tags Product
+// @Security BearerAuth
+// @Accept json
+// @Param id path uint true "Product ID"
+// @Param body body AttachModifierGroupRequest true "Group to attach"
+// @Success 204 "Attached"
+// @Failure 404 {object} map[string]string
+// @Router /products/{id}/modifiers [post]
+func AttachModifierGroupHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid product ID")
+		}
+
+		var req AttachModifierGroupRequest
+		if err := c.BodyParser(&req); err != nil || req.GroupID == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "group_id is required")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		if err := AttachModifierGroup(db, uint(id), bizID, req.GroupID); err != nil {
+			return handleModifierError(err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// DetachModifierGroup godoc
+// @Summary Stop offering a modifier group on a product
+// @Tags Product
+// @Security BearerAuth
+// @Param id path uint true "Product ID"
+// @Param group_id path uint true "Modifier Group ID"
+// @Success 204 "Detached"
+// @Failure 404 {object} map[string]string
+// @Router /products/{id}/modifiers/{group_id} [delete]
+func DetachModifierGroupHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid product ID")
+		}
+		groupID, err := c.ParamsInt("group_id")
+		if err != nil || groupID <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid group ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		if err := DetachModifierGroup(db, uint(id), bizID, uint(groupID)); err != nil {
+			return handleModifierError(err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// ListModifierGroups godoc
+// @Summary List modifier groups
+// @Tags Product
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} ModifierGroup
+// @Router /modifier-groups [get]
+func ListModifierGroupsHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		groups, err := ListModifierGroups(db, bizID)
+		if err != nil {
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(groups)
+	}
+}
+
+// CreateModifierGroup godoc
+// @Summary Create a modifier group
+// @Description Create a group such as "Size" or "Add-ons" with its options and selection rules
+// @Tags Product
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body CreateModifierGroupRequest true "Group and options"
+// @Success 201 {object} ModifierGroup
+// @Failure 400 {object} map[string]string
+// @Router /modifier-groups [post]
+func CreateModifierGroupHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req CreateModifierGroupRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		group, err := CreateModifierGroup(db, bizID, req)
+		if err != nil {
+			return handleModifierError(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(group)
+	}
+}
+
+// DeleteModifierGroup godoc
+// @Summary Delete a modifier group
+// @Tags Product
+// @Security BearerAuth
+// @Param id path uint true "Modifier Group ID"
+// @Success 204 "Deleted"
+// @Failure 404 {object} map[string]string
+// @Router /modifier-groups/{id} [delete]
+func DeleteModifierGroupHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid group ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		if err := DeleteModifierGroup(db, uint(id), bizID); err != nil {
+			return handleModifierError(err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// AddModifierOption godoc
+// @Summary Add an option to a modifier group
+// @Tags Product
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Modifier Group ID"
+// @Param body body CreateModifierOptionRequest true "Option"
+// @Success 201 {object} ModifierOption
+// @Failure 404 {object} map[string]string
+// @Router /modifier-groups/{id}/options [post]
+func AddModifierOptionHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid group ID")
+		}
+
+		var req CreateModifierOptionRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		option, err := AddModifierOption(db, uint(id), bizID, req)
+		if err != nil {
+			return handleModifierError(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(option)
+	}
+}
+
+// DeactivateModifierOption godoc
+// @Summary Remove an option from a modifier group
+// @Description The option is deactivated so past sales keep their snapshot
+// @Tags Product
+// @Security BearerAuth
+// @Param id path uint true "Modifier Group ID"
+// @Param option_id path uint true "Modifier Option ID"
+// @Success 204 "Removed"
+// @Failure 404 {object} map[string]string
+// @Router /modifier-groups/{id}/options/{option_id} [delete]
+func DeactivateModifierOptionHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid group ID")
+		}
+		optionID, err := c.ParamsInt("option_id")
+		if err != nil || optionID <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid option ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		if err := DeactivateModifierOption(db, uint(id), uint(optionID), bizID); err != nil {
+			return handleModifierError(err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
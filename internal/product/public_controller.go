@@ -23,9 +23,11 @@ func GetPublicMenuBySlugHandler(db *gorm.DB) fiber.Handler {
 			return fiber.NewError(fiber.StatusBadRequest, "slug is required")
 		}
 
-		// 1. Find Business
+		// 1. Find Business (only what a customer may see; the row also holds credentials)
 		var biz map[string]interface{}
-		if err := db.Table("businesses").Where("slug = ?", slug).Take(&biz).Error; err != nil {
+		if err := db.Table("businesses").
+			Select("id, name, type, address, city, currency, slug, vat_rate, prices_include_tax, service_charge_percent, paystack_public_key").
+			Where("slug = ? AND deleted_at IS NULL", slug).Take(&biz).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				return fiber.NewError(fiber.StatusNotFound, "business not found")
 			}
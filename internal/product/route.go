@@ -17,4 +17,17 @@ func RegisterProductRoutes(r fiber.Router, db *gorm.DB) {
 	r.Get("/products/:id", GetHandler(db))
 	r.Put("/products/:id", UpdateHandler(db))
 	r.Delete("/products/:id", DeleteHandler(db))
+
+	// Variants and modifiers
+	r.Get("/products/:id/variants", ListVariantsHandler(db))
+	r.Post("/products/:id/variants", CreateVariantHandler(db))
+	r.Get("/products/:id/modifiers", GetProductModifiersHandler(db))
+	r.Post("/products/:id/modifiers", AttachModifierGroupHandler(db))
+	r.Delete("/products/:id/modifiers/:group_id", DetachModifierGroupHandler(db))
+
+	r.Get("/modifier-groups", ListModifierGroupsHandler(db))
+	r.Post("/modifier-groups", CreateModifierGroupHandler(db))
+	r.Delete("/modifier-groups/:id", DeleteModifierGroupHandler(db))
+	r.Post("/modifier-groups/:id/options", AddModifierOptionHandler(db))
+	r.Delete("/modifier-groups/:id/options/:option_id", DeactivateModifierOptionHandler(db))
 }
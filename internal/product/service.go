@@ -4,6 +4,7 @@ package product
 import (
 	"errors"
 
+	"pos-fiber-app/internal/inventory"
 	"pos-fiber-app/internal/subscription"
 	"gorm.io/gorm"
 )
@@ -33,6 +34,12 @@ func Create(db *gorm.DB, businessID uint, req CreateProductRequest) (*Product, e
 		Barcode:     req.Barcode,
 		TrackByRound: req.TrackByRound,
 		UnitOfMeasure: req.UnitOfMeasure,
+		QuantityPrecision: req.QuantityPrecision,
+		PLU:         req.PLU,
+		ParentID:    req.ParentID,
+		VariantName: req.VariantName,
+		IsGiftCard:  req.IsGiftCard,
+		TaxClass:    req.TaxClass,
 		Active:      true, // default
 	}
 
@@ -44,7 +51,7 @@ func Create(db *gorm.DB, businessID uint, req CreateProductRequest) (*Product, e
 	inv := struct {
 		ProductID    uint `gorm:"column:product_id"`
 		BusinessID   uint `gorm:"column:business_id"`
-		CurrentStock int  `gorm:"column:current_stock"`
+		CurrentStock float64 `gorm:"column:current_stock"`
 	}{
 		ProductID:    product.ID,
 		BusinessID:   businessID,
@@ -52,6 +59,11 @@ func Create(db *gorm.DB, businessID uint, req CreateProductRequest) (*Product, e
 	}
 	db.Table("inventories").Create(&inv)
 
+	// Opening stock is the first entry on the product's stock card
+	if err := inventory.RecordMovement(inventory.WithReason(db, inventory.ReasonRestock), product.ID, businessID, product.Stock, product.Stock); err != nil {
+		return nil, err
+	}
+
 	return product, nil
 }
 
@@ -60,7 +72,7 @@ func ListByBusiness(db *gorm.DB, businessID uint, filters ...func(*gorm.DB) *gor
 	var products []Product
 
 	query := db.Table("products").
-		Select("products.id, products.business_id, products.category_id, products.name, products.sku, products.description, products.price, products.cost, products.image_url, products.min_stock, products.barcode, products.active, products.track_by_round, products.unit_of_measure, COALESCE(inventories.current_stock, products.stock) as stock").
+		Select("products.id, products.business_id, products.category_id, products.name, products.sku, products.description, products.price, products.cost, products.image_url, products.min_stock, products.barcode, products.active, products.track_by_round, products.unit_of_measure, products.quantity_precision, products.plu, products.parent_id, products.variant_name, products.is_gift_card, products.tax_class, COALESCE(inventories.current_stock, products.stock) as stock").
 		Joins("LEFT JOIN inventories ON inventories.product_id = products.id AND inventories.business_id = products.business_id").
 		Where("products.business_id = ? AND products.active = ?", businessID, true)
 
@@ -94,7 +106,7 @@ func Get(db *gorm.DB, id, businessID uint) (*Product, error) {
 	var product Product
 
 	err := db.Table("products").
-		Select("products.id, products.business_id, products.category_id, products.name, products.sku, products.description, products.price, products.cost, products.image_url, products.min_stock, products.barcode, products.active, products.track_by_round, products.unit_of_measure, COALESCE(inventories.current_stock, products.stock) as stock").
+		Select("products.id, products.business_id, products.category_id, products.name, products.sku, products.description, products.price, products.cost, products.image_url, products.min_stock, products.barcode, products.active, products.track_by_round, products.unit_of_measure, products.quantity_precision, products.plu, products.parent_id, products.variant_name, products.is_gift_card, products.tax_class, COALESCE(inventories.current_stock, products.stock) as stock").
 		Joins("LEFT JOIN inventories ON inventories.product_id = products.id AND inventories.business_id = products.business_id").
 		Where("products.id = ? AND products.business_id = ?", id, businessID).
 		First(&product).Error
@@ -108,49 +120,93 @@ func Get(db *gorm.DB, id, businessID uint) (*Product, error) {
 	return &product, nil
 }
 
-// Update modifies an existing product (partial updates allowed)
+// Update modifies an existing product (partial updates allowed). The product, its
+// inventory row and any stock movement are written together or not at all.
 func Update(db *gorm.DB, id, businessID uint, req UpdateProductRequest) (*Product, error) {
+	var product *Product
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		product, err = updateProduct(tx, id, businessID, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+func updateProduct(db *gorm.DB, id, businessID uint, req UpdateProductRequest) (*Product, error) {
 	product, err := Get(db, id, businessID)
 	if err != nil {
 		return nil, err
 	}
+	previousStock := product.Stock
 
-	// Apply updates only if fields are provided
+	// Apply updates only if fields are provided, writing just the columns that change
+	changes := map[string]interface{}{}
 	if req.Name != "" {
 		product.Name = req.Name
+		changes["name"] = req.Name
 	}
 	if req.SKU != "" {
 		product.SKU = req.SKU
+		changes["sku"] = req.SKU
 	}
 	if req.Description != "" {
 		product.Description = req.Description
+		changes["description"] = req.Description
 	}
 	if req.Price != nil {
 		product.Price = *req.Price
+		changes["price"] = *req.Price
 	}
 	if req.Cost != nil {
 		product.Cost = *req.Cost
+		changes["cost"] = *req.Cost
 	}
 	if req.CategoryID != nil {
 		product.CategoryID = *req.CategoryID
+		changes["category_id"] = *req.CategoryID
 	}
 	if req.ImageURL != "" {
 		product.ImageURL = req.ImageURL
+		changes["image_url"] = req.ImageURL
 	}
 	if req.Stock != nil {
 		product.Stock = *req.Stock
+		changes["stock"] = *req.Stock
 	}
 	if req.MinStock != nil {
 		product.MinStock = *req.MinStock
+		changes["min_stock"] = *req.MinStock
 	}
 	if req.Barcode != "" {
 		product.Barcode = req.Barcode
+		changes["barcode"] = req.Barcode
 	}
 	if req.TrackByRound != nil {
 		product.TrackByRound = *req.TrackByRound
+		changes["track_by_round"] = *req.TrackByRound
 	}
 	if req.UnitOfMeasure != "" {
 		product.UnitOfMeasure = req.UnitOfMeasure
+		changes["unit_of_measure"] = req.UnitOfMeasure
+	}
+	if req.QuantityPrecision != nil {
+		product.QuantityPrecision = *req.QuantityPrecision
+		changes["quantity_precision"] = *req.QuantityPrecision
+	}
+	if req.PLU != nil {
+		product.PLU = *req.PLU
+		changes["plu"] = *req.PLU
+	}
+	if req.IsGiftCard != nil {
+		product.IsGiftCard = *req.IsGiftCard
+		changes["is_gift_card"] = *req.IsGiftCard
+	}
+	if req.TaxClass != nil {
+		product.TaxClass = *req.TaxClass
+		changes["tax_class"] = *req.TaxClass
 	}
 	if req.Active != nil {
 		if *req.Active && !product.Active {
@@ -163,10 +219,13 @@ func Update(db *gorm.DB, id, businessID uint, req UpdateProductRequest) (*Produc
 			}
 		}
 		product.Active = *req.Active
+		changes["active"] = *req.Active
 	}
 
-	if err := db.Save(product).Error; err != nil {
-		return nil, err
+	if len(changes) > 0 {
+		if err := db.Model(&Product{}).Where("id = ? AND business_id = ?", id, businessID).Updates(changes).Error; err != nil {
+			return nil, err
+		}
 	}
 
 	// Sync to inventory table
@@ -174,18 +233,30 @@ func Update(db *gorm.DB, id, businessID uint, req UpdateProductRequest) (*Produc
 		var count int64
 		db.Table("inventories").Where("product_id = ? AND business_id = ?", id, businessID).Count(&count)
 		if count > 0 {
-			db.Table("inventories").Where("product_id = ? AND business_id = ?", id, businessID).Update("current_stock", *req.Stock)
+			if err := db.Table("inventories").Where("product_id = ? AND business_id = ?", id, businessID).Update("current_stock", *req.Stock).Error; err != nil {
+				return nil, err
+			}
 		} else {
 			inv := struct {
 				ProductID    uint `gorm:"column:product_id"`
 				BusinessID   uint `gorm:"column:business_id"`
-				CurrentStock int  `gorm:"column:current_stock"`
+				CurrentStock float64 `gorm:"column:current_stock"`
 			}{
 				ProductID:    id,
 				BusinessID:   businessID,
 				CurrentStock: *req.Stock,
 			}
-			db.Table("inventories").Create(&inv)
+			if err := db.Table("inventories").Create(&inv).Error; err != nil {
+				return nil, err
+			}
+		}
+
+		// Setting the stock outright is recorded as an adjustment by the difference;
+		// saving the same stock again moves nothing
+		if delta := inventory.RoundQuantity(*req.Stock - previousStock); delta != 0 {
+			if err := inventory.RecordMovement(inventory.WithReason(db, inventory.ReasonAdjustment), id, businessID, delta, *req.Stock); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -200,9 +271,7 @@ func Delete(db *gorm.DB, id, businessID uint) error {
 		return err
 	}
 
-	product.Active = false
-
-	return db.Save(product).Error
+	return db.Model(&Product{}).Where("id = ? AND business_id = ?", product.ID, businessID).Update("active", false).Error
 }
 
 // ListLowStock returns products where stock is below min_stock
@@ -210,14 +279,74 @@ func ListLowStock(db *gorm.DB, businessID uint) ([]Product, error) {
 	var products []Product
 
 	err := db.Table("products").
-		Select("products.id, products.business_id, products.category_id, products.name, products.sku, products.description, products.price, products.cost, products.image_url, products.min_stock, products.barcode, products.active, products.track_by_round, products.unit_of_measure, COALESCE(inventories.current_stock, products.stock) as stock").
+		Select("products.id, products.business_id, products.category_id, products.name, products.sku, products.description, products.price, products.cost, products.image_url, products.min_stock, products.barcode, products.active, products.track_by_round, products.unit_of_measure, products.quantity_precision, products.plu, products.parent_id, products.variant_name, products.is_gift_card, products.tax_class, COALESCE(inventories.current_stock, products.stock) as stock").
 		Joins("LEFT JOIN inventories ON inventories.product_id = products.id AND inventories.business_id = products.business_id").
-		Where("products.business_id = ? AND products.active = ? AND COALESCE(inventories.current_stock, products.stock) <= products.min_stock", businessID, true).
+		Where("products.business_id = ? AND products.active = ? AND products.is_gift_card = false AND COALESCE(inventories.current_stock, products.stock) <= products.min_stock", businessID, true).
 		Find(&products).Error
 
 	return products, err
 }
 
+// CreateVariant adds a variant (e.g. a size) under a parent product. Variants are full
+// products with their own SKU, price and stock; unset fields are copied from the parent.
+func CreateVariant(db *gorm.DB, parentID, businessID uint, req CreateVariantRequest) (*Product, error) {
+	if req.VariantName == "" || req.SKU == "" {
+		return nil, errors.New("variant_name and sku are required")
+	}
+
+	parent, err := Get(db, parentID, businessID)
+	if err != nil {
+		return nil, err
+	}
+	if parent.ParentID != nil {
+		return nil, errors.New("variants cannot have variants of their own")
+	}
+
+	create := CreateProductRequest{
+		Name:          parent.Name + " - " + req.VariantName,
+		SKU:           req.SKU,
+		Description:   parent.Description,
+		Price:         parent.Price,
+		Cost:          parent.Cost,
+		CategoryID:    parent.CategoryID,
+		ImageURL:      parent.ImageURL,
+		Stock:         req.Stock,
+		MinStock:      req.MinStock,
+		Barcode:       req.Barcode,
+		TrackByRound:  parent.TrackByRound,
+		UnitOfMeasure: parent.UnitOfMeasure,
+		QuantityPrecision: parent.QuantityPrecision,
+		TaxClass:      parent.TaxClass,
+		ParentID:      &parent.ID,
+		VariantName:   req.VariantName,
+	}
+	if req.Price != nil {
+		create.Price = *req.Price
+	}
+	if req.Cost != nil {
+		create.Cost = *req.Cost
+	}
+
+	return Create(db, businessID, create)
+}
+
+// ListVariants returns the active variants of a product
+func ListVariants(db *gorm.DB, parentID, businessID uint) ([]Product, error) {
+	if _, err := Get(db, parentID, businessID); err != nil {
+		return nil, err
+	}
+	return ListByBusiness(db, businessID, func(q *gorm.DB) *gorm.DB {
+		return q.Where("products.parent_id = ?", parentID)
+	})
+}
+
+// HasVariants reports whether a product is sold through its variants rather than directly
+func HasVariants(db *gorm.DB, productID uint) bool {
+	var count int64
+	db.Table("products").Where("parent_id = ? AND active = ? AND deleted_at IS NULL", productID, true).Count(&count)
+	return count > 0
+}
+
 // Optional: HardDelete if needed (use cautiously)
 // func HardDelete(db *gorm.DB, id, businessID uint) error {
 //     return db.Where("id = ? AND business_id = ?", id, businessID).Delete(&Product{}).Error
@@ -0,0 +1,159 @@
+// internal/promotion/controller.go
+package promotion
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func handlePromotionError(err error) error {
+	msg := err.Error()
+	if strings.Contains(msg, "not found") {
+		return fiber.NewError(fiber.StatusNotFound, msg)
+	}
+	if strings.Contains(msg, "required") || strings.Contains(msg, "must") ||
+		strings.Contains(msg, "invalid") || strings.Contains(msg, "cannot") {
+		return fiber.NewError(fiber.StatusBadRequest, msg)
+	}
+	return fiber.NewError(fiber.StatusInternalServerError, msg)
+}
+
+// ListPromotions godoc
+// @Summary List promotions
+// @Description Promotion rules in the order they are evaluated (highest priority first)
+// @Tags Promotions
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} Promotion
+// @Router /promotions [get]
+func ListHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		promos, err := List(db, bizID)
+		if err != nil {
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(promos)
+	}
+}
+
+// CreatePromotion godoc
+// @Summary Create a promotion
+// @Description Add an automatic discount: percent or amount off, buy-X-get-Y or a fixed-price combo, optionally limited to weekdays and hours
+// @Tags Promotions
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body PromotionRequest true "Promotion rule"
+// @Success 201 {object} Promotion
+// @Failure 400 {object} map[string]string
+// @Router /promotions [post]
+func CreateHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		var req PromotionRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		promo, err := Create(db, bizID, req)
+		if err != nil {
+			return handlePromotionError(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(promo)
+	}
+}
+
+// GetPromotion godoc
+// @Summary Get a promotion
+// @Tags Promotions
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Promotion ID"
+// @Success 200 {object} Promotion
+// @Failure 404 {object} map[string]string
+// @Router /promotions/{id} [get]
+func GetHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid promotion ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		promo, err := Get(db, uint(id), bizID)
+		if err != nil {
+			return handlePromotionError(err)
+		}
+
+		return c.JSON(promo)
+	}
+}
+
+// UpdatePromotion godoc
+// @Summary Update a promotion
+// @Description Replace a promotion's rule. Completed sales keep the discounts they were given.
+// @Tags Promotions
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Promotion ID"
+// @Param body body PromotionRequest true "Promotion rule"
+// @Success 200 {object} Promotion
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /promotions/{id} [put]
+func UpdateHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid promotion ID")
+		}
+
+		var req PromotionRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		promo, err := Update(db, uint(id), bizID, req)
+		if err != nil {
+			return handlePromotionError(err)
+		}
+
+		return c.JSON(promo)
+	}
+}
+
+// DeletePromotion godoc
+// @Summary Delete a promotion
+// @Tags Promotions
+// @Security BearerAuth
+// @Param id path uint true "Promotion ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /promotions/{id} [delete]
+func DeleteHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid promotion ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		if err := Delete(db, uint(id), bizID); err != nil {
+			return handlePromotionError(err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
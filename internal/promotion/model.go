@@ -0,0 +1,93 @@
+// internal/promotion/model.go
+package promotion
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Type string
+
+const (
+	TypePercentOff Type = "PERCENT_OFF" // Value percent off each matching line
+	TypeAmountOff  Type = "AMOUNT_OFF"  // Value off each matching unit
+	TypeBuyXGetY   Type = "BUY_X_GET_Y" // for every BuyQty+GetQty matching units, the GetQty cheapest are GetPercent off
+	TypeCombo      Type = "COMBO"       // ComboItems sold together for a fixed price of Value
+)
+
+// Promotion is an automatic discount rule. Rules are tried in Priority order (highest
+// first). A line discounted by a non-stackable rule gets no further discounts, and a
+// non-stackable rule skips lines another rule has already discounted.
+// ProductIDs and CategoryIDs narrow which lines a rule applies to; with neither set
+// it applies to every product. Weekdays and StartTime/EndTime limit it to certain
+// days and hours (a happy hour); a window may run past midnight, e.g. 22:00-02:00.
+type Promotion struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	BusinessID  uint           `gorm:"index" json:"business_id"`
+	Name        string         `gorm:"size:150;not null" json:"name"`
+	Type        Type           `gorm:"type:varchar(20)" json:"type"`
+	Value       float64        `gorm:"type:decimal(12,2);default:0" json:"value"`
+	ProductIDs  []uint         `gorm:"serializer:json;type:text" json:"product_ids"`
+	CategoryIDs []uint         `gorm:"serializer:json;type:text" json:"category_ids"`
+	BuyQty      int            `gorm:"default:0" json:"buy_qty,omitempty"`
+	GetQty      int            `gorm:"default:0" json:"get_qty,omitempty"`
+	GetPercent  float64        `gorm:"type:decimal(5,2);default:100" json:"get_percent,omitempty"` // 100 = free
+	ComboItems  []ComboItem    `gorm:"serializer:json;type:text" json:"combo_items,omitempty"`
+	Weekdays    []int          `gorm:"serializer:json;type:text" json:"weekdays"` // 0 = Sunday; empty = every day
+	StartTime   string         `gorm:"size:5" json:"start_time,omitempty"`        // HH:MM
+	EndTime     string         `gorm:"size:5" json:"end_time,omitempty"`          // HH:MM
+	StartsAt    *time.Time     `json:"starts_at,omitempty"`
+	EndsAt      *time.Time     `json:"ends_at,omitempty"`
+	Priority    int            `gorm:"default:0" json:"priority"`
+	Stackable   bool           `gorm:"default:false" json:"stackable"`
+	Active      bool           `gorm:"default:true" json:"active"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+type ComboItem struct {
+	ProductID uint `json:"product_id"`
+	Quantity  int  `json:"quantity"`
+}
+
+// Applied is a discount a promotion gave a sale line, snapshotted on the line
+type Applied struct {
+	PromotionID uint    `json:"promotion_id"`
+	Name        string  `json:"name"`
+	Amount      float64 `json:"amount"`
+}
+
+// Line is a sale line to be priced
+type Line struct {
+	ProductID uint
+	Quantity  float64 // may be fractional for weighed products; unit deals only count whole units
+	UnitPrice float64
+}
+
+// LineResult is the discount the promotions give one line
+type LineResult struct {
+	Discount float64
+	Applied  []Applied
+}
+
+type PromotionRequest struct {
+	Name        string      `json:"name" validate:"required"`
+	Type        Type        `json:"type" validate:"required"`
+	Value       float64     `json:"value"`
+	ProductIDs  []uint      `json:"product_ids"`
+	CategoryIDs []uint      `json:"category_ids"`
+	BuyQty      int         `json:"buy_qty"`
+	GetQty      int         `json:"get_qty"`
+	GetPercent  float64     `json:"get_percent"` // defaults to 100 (free)
+	ComboItems  []ComboItem `json:"combo_items"`
+	Weekdays    []int       `json:"weekdays"`
+	StartTime   string      `json:"start_time"`
+	EndTime     string      `json:"end_time"`
+	StartsAt    string      `json:"starts_at"` // YYYY-MM-DD
+	EndsAt      string      `json:"ends_at"`   // YYYY-MM-DD, inclusive
+	Priority    int         `json:"priority"`
+	Stackable   bool        `json:"stackable"`
+	Active      *bool       `json:"active"`
+}
@@ -0,0 +1,16 @@
+// internal/promotion/route.go
+package promotion
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+func RegisterPromotionRoutes(r fiber.Router, db *gorm.DB) {
+	group := r.Group("/promotions")
+	group.Get("/", ListHandler(db))
+	group.Post("/", CreateHandler(db))
+	group.Get("/:id", GetHandler(db))
+	group.Put("/:id", UpdateHandler(db))
+	group.Delete("/:id", DeleteHandler(db))
+}
@@ -0,0 +1,449 @@
+// internal/promotion/service.go
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"pos-fiber-app/internal/product"
+
+	"gorm.io/gorm"
+)
+
+// Create adds a promotion rule
+func Create(db *gorm.DB, businessID uint, req PromotionRequest) (*Promotion, error) {
+	promo := &Promotion{BusinessID: businessID, Active: true}
+	if err := applyRequest(promo, req); err != nil {
+		return nil, err
+	}
+	if err := db.Create(promo).Error; err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+// List returns a business's promotions in the order they are evaluated
+func List(db *gorm.DB, businessID uint) ([]Promotion, error) {
+	promos := []Promotion{}
+	err := db.Where("business_id = ?", businessID).
+		Order("priority DESC, id ASC").
+		Find(&promos).Error
+	return promos, err
+}
+
+// Get retrieves a promotion, ensuring it belongs to the business
+func Get(db *gorm.DB, id, businessID uint) (*Promotion, error) {
+	var promo Promotion
+	if err := db.First(&promo, "id = ? AND business_id = ?", id, businessID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("promotion not found")
+		}
+		return nil, err
+	}
+	return &promo, nil
+}
+
+// Update replaces a promotion's rule. Sales already completed keep the discounts
+// they were given.
+func Update(db *gorm.DB, id, businessID uint, req PromotionRequest) (*Promotion, error) {
+	promo, err := Get(db, id, businessID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyRequest(promo, req); err != nil {
+		return nil, err
+	}
+	if err := db.Save(promo).Error; err != nil {
+		return nil, err
+	}
+	return promo, nil
+}
+
+// Delete removes a promotion
+func Delete(db *gorm.DB, id, businessID uint) error {
+	result := db.Where("id = ? AND business_id = ?", id, businessID).Delete(&Promotion{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("promotion not found")
+	}
+	return nil
+}
+
+// Evaluate prices lines against the business's promotions running at the given time
+// and returns the discount for each line, in the same order as lines
+func Evaluate(db *gorm.DB, businessID uint, lines []Line, at time.Time) ([]LineResult, error) {
+	results := make([]LineResult, len(lines))
+	if len(lines) == 0 {
+		return results, nil
+	}
+
+	var promos []Promotion
+	if err := db.Where("business_id = ? AND active = ?", businessID, true).
+		Order("priority DESC, id ASC").
+		Find(&promos).Error; err != nil {
+		return nil, err
+	}
+	if len(promos) == 0 {
+		return results, nil
+	}
+
+	categories, err := productCategories(db, businessID, lines)
+	if err != nil {
+		return nil, err
+	}
+	return evaluate(promos, lines, categories, at), nil
+}
+
+// evaluate gives lines the discounts of promos, which are in priority order.
+// categories maps each line's product to its category.
+func evaluate(promos []Promotion, lines []Line, categories map[uint]uint, at time.Time) []LineResult {
+	results := make([]LineResult, len(lines))
+	locked := make([]bool, len(lines)) // discounted by a non-stackable rule
+	for _, promo := range promos {
+		if !promo.runningAt(at) {
+			continue
+		}
+
+		eligible := make([]int, 0, len(lines))
+		for i, l := range lines {
+			if locked[i] || l.Quantity <= 0 {
+				continue
+			}
+			if !promo.Stackable && len(results[i].Applied) > 0 {
+				continue
+			}
+			if promo.matches(l.ProductID, categories[l.ProductID]) {
+				eligible = append(eligible, i)
+			}
+		}
+		if len(eligible) == 0 {
+			continue
+		}
+
+		for i, amount := range promo.discounts(lines, eligible) {
+			gross := lines[i].Quantity * lines[i].UnitPrice
+			amount = roundMoney(math.Min(amount, gross-results[i].Discount))
+			if amount <= 0 {
+				continue
+			}
+			results[i].Discount = roundMoney(results[i].Discount + amount)
+			results[i].Applied = append(results[i].Applied, Applied{PromotionID: promo.ID, Name: promo.Name, Amount: amount})
+			if !promo.Stackable {
+				locked[i] = true
+			}
+		}
+	}
+	return results
+}
+
+// discounts works out the rule's discount on each of the eligible lines
+func (p *Promotion) discounts(lines []Line, eligible []int) map[int]float64 {
+	out := make(map[int]float64)
+	switch p.Type {
+	case TypePercentOff:
+		for _, i := range eligible {
+			out[i] = lines[i].Quantity * lines[i].UnitPrice * p.Value / 100
+		}
+
+	case TypeAmountOff:
+		for _, i := range eligible {
+			out[i] = lines[i].Quantity * p.Value
+		}
+
+	case TypeBuyXGetY:
+		// Pool the matching units; in every group of BuyQty+GetQty the cheapest GetQty are discounted
+		type unit struct {
+			line  int
+			price float64
+		}
+		var units []unit
+		for _, i := range eligible {
+			for q := 0; q < wholeUnits(lines[i].Quantity); q++ {
+				units = append(units, unit{line: i, price: lines[i].UnitPrice})
+			}
+		}
+		group := p.BuyQty + p.GetQty
+		if group <= 0 {
+			return out
+		}
+		free := len(units) / group * p.GetQty
+		sort.SliceStable(units, func(a, b int) bool { return units[a].price > units[b].price })
+		for _, u := range units[len(units)-free:] {
+			out[u.line] += u.price * p.GetPercent / 100
+		}
+
+	case TypeCombo:
+		// How many complete combos the matching lines make up
+		combos := math.MaxInt32
+		normal := 0.0
+		for _, ci := range p.ComboItems {
+			qty, price := 0, 0.0
+			for _, i := range eligible {
+				if lines[i].ProductID == ci.ProductID {
+					if qty == 0 {
+						price = lines[i].UnitPrice
+					}
+					qty += wholeUnits(lines[i].Quantity)
+				}
+			}
+			if ci.Quantity <= 0 || qty < ci.Quantity {
+				return out
+			}
+			if n := qty / ci.Quantity; n < combos {
+				combos = n
+			}
+			normal += price * float64(ci.Quantity)
+		}
+		if normal <= p.Value {
+			return out
+		}
+
+		// Each unit in a combo gives up the same share of its price
+		share := 1 - p.Value/normal
+		for _, ci := range p.ComboItems {
+			need := combos * ci.Quantity
+			for _, i := range eligible {
+				if need == 0 {
+					break
+				}
+				if lines[i].ProductID != ci.ProductID {
+					continue
+				}
+				take := wholeUnits(lines[i].Quantity)
+				if take > need {
+					take = need
+				}
+				out[i] += float64(take) * lines[i].UnitPrice * share
+				need -= take
+			}
+		}
+	}
+	return out
+}
+
+// wholeUnits is how many whole units a line quantity holds, for unit-based deals
+func wholeUnits(q float64) int {
+	return int(math.Floor(q + 1e-9))
+}
+
+func (p *Promotion) matches(productID, categoryID uint) bool {
+	if p.Type == TypeCombo {
+		for _, ci := range p.ComboItems {
+			if ci.ProductID == productID {
+				return true
+			}
+		}
+		return false
+	}
+	if len(p.ProductIDs) == 0 && len(p.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range p.ProductIDs {
+		if id == productID {
+			return true
+		}
+	}
+	for _, id := range p.CategoryIDs {
+		if id == categoryID {
+			return true
+		}
+	}
+	return false
+}
+
+// runningAt reports whether the rule is in force at t
+func (p *Promotion) runningAt(t time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.StartsAt != nil && t.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !t.Before(*p.EndsAt) {
+		return false
+	}
+
+	windowed := p.StartTime != "" && p.EndTime != ""
+	start, _ := parseClock(p.StartTime)
+	end, _ := parseClock(p.EndTime)
+	now := minuteOfDay(t)
+
+	if len(p.Weekdays) > 0 {
+		day := int(t.Weekday())
+		// The small hours of an overnight window belong to the day it started
+		if windowed && start > end && now < end {
+			day = (day + 6) % 7
+		}
+		found := false
+		for _, d := range p.Weekdays {
+			if d == day {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if !windowed {
+		return true
+	}
+	if start <= end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// applyRequest validates a rule and copies it onto the promotion
+func applyRequest(p *Promotion, req PromotionRequest) error {
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+
+	switch req.Type {
+	case TypePercentOff:
+		if req.Value <= 0 || req.Value > 100 {
+			return errors.New("percent off must be between 0 and 100")
+		}
+	case TypeAmountOff:
+		if req.Value <= 0 {
+			return errors.New("amount off must be greater than zero")
+		}
+	case TypeBuyXGetY:
+		if req.BuyQty < 1 || req.GetQty < 1 {
+			return errors.New("buy and get quantities must be at least 1")
+		}
+		if req.GetPercent == 0 {
+			req.GetPercent = 100
+		}
+		if req.GetPercent < 0 || req.GetPercent > 100 {
+			return errors.New("get percent must be between 0 and 100")
+		}
+	case TypeCombo:
+		if len(req.ComboItems) == 0 {
+			return errors.New("a combo must list its items")
+		}
+		units := 0
+		for _, ci := range req.ComboItems {
+			if ci.ProductID == 0 || ci.Quantity < 1 {
+				return errors.New("combo items need a product and a quantity of at least 1")
+			}
+			units += ci.Quantity
+		}
+		if units < 2 {
+			return errors.New("a combo must contain at least two items")
+		}
+		if req.Value <= 0 {
+			return errors.New("combo price must be greater than zero")
+		}
+	default:
+		return fmt.Errorf("invalid promotion type %q", req.Type)
+	}
+
+	for _, d := range req.Weekdays {
+		if d < 0 || d > 6 {
+			return errors.New("weekdays must be between 0 (Sunday) and 6 (Saturday)")
+		}
+	}
+	if (req.StartTime == "") != (req.EndTime == "") {
+		return errors.New("start time and end time must be set together")
+	}
+	if req.StartTime != "" {
+		start, err := parseClock(req.StartTime)
+		if err != nil {
+			return err
+		}
+		end, err := parseClock(req.EndTime)
+		if err != nil {
+			return err
+		}
+		if start == end {
+			return errors.New("start time and end time cannot be the same")
+		}
+	}
+
+	var startsAt, endsAt *time.Time
+	if req.StartsAt != "" {
+		t, err := time.ParseInLocation("2006-01-02", req.StartsAt, time.Local)
+		if err != nil {
+			return errors.New("invalid start date, use YYYY-MM-DD")
+		}
+		startsAt = &t
+	}
+	if req.EndsAt != "" {
+		t, err := time.ParseInLocation("2006-01-02", req.EndsAt, time.Local)
+		if err != nil {
+			return errors.New("invalid end date, use YYYY-MM-DD")
+		}
+		t = t.AddDate(0, 0, 1) // the end date is inclusive
+		endsAt = &t
+	}
+
+	p.Name = req.Name
+	p.Type = req.Type
+	p.Value = req.Value
+	p.ProductIDs = req.ProductIDs
+	p.CategoryIDs = req.CategoryIDs
+	p.BuyQty = req.BuyQty
+	p.GetQty = req.GetQty
+	p.GetPercent = req.GetPercent
+	p.ComboItems = req.ComboItems
+	p.Weekdays = req.Weekdays
+	p.StartTime = req.StartTime
+	p.EndTime = req.EndTime
+	p.StartsAt = startsAt
+	p.EndsAt = endsAt
+	p.Priority = req.Priority
+	p.Stackable = req.Stackable
+	if req.Active != nil {
+		p.Active = *req.Active
+	}
+	return nil
+}
+
+func productCategories(db *gorm.DB, businessID uint, lines []Line) (map[uint]uint, error) {
+	ids := make([]uint, 0, len(lines))
+	for _, l := range lines {
+		ids = append(ids, l.ProductID)
+	}
+
+	var rows []struct {
+		ID         uint
+		CategoryID uint
+	}
+	if err := db.Model(&product.Product{}).
+		Select("id, category_id").
+		Where("business_id = ? AND id IN ?", businessID, ids).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	categories := make(map[uint]uint, len(rows))
+	for _, r := range rows {
+		categories[r.ID] = r.CategoryID
+	}
+	return categories, nil
+}
+
+// parseClock turns "HH:MM" into minutes after midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
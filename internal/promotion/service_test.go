@@ -0,0 +1,136 @@
+// internal/promotion/service_test.go
+package promotion
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	categories := map[uint]uint{1: 5, 2: 6, 3: 6}
+	at := time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		promos []Promotion
+		lines  []Line
+		want   []float64
+	}{
+		{
+			name:   "percent off a category",
+			promos: []Promotion{{ID: 1, Type: TypePercentOff, Value: 10, CategoryIDs: []uint{5}, Active: true}},
+			lines:  []Line{{ProductID: 1, Quantity: 2, UnitPrice: 50}, {ProductID: 2, Quantity: 1, UnitPrice: 30}},
+			want:   []float64{10, 0},
+		},
+		{
+			name:   "amount off each unit, weighed",
+			promos: []Promotion{{ID: 1, Type: TypeAmountOff, Value: 3, ProductIDs: []uint{1}, Active: true}},
+			lines:  []Line{{ProductID: 1, Quantity: 2.5, UnitPrice: 12}},
+			want:   []float64{7.5},
+		},
+		{
+			name:   "amount off never goes below nothing",
+			promos: []Promotion{{ID: 1, Type: TypeAmountOff, Value: 20, Active: true}},
+			lines:  []Line{{ProductID: 1, Quantity: 1, UnitPrice: 15}},
+			want:   []float64{15},
+		},
+		{
+			name:   "buy two get the cheapest free",
+			promos: []Promotion{{ID: 1, Type: TypeBuyXGetY, BuyQty: 2, GetQty: 1, GetPercent: 100, Active: true}},
+			lines:  []Line{{ProductID: 1, Quantity: 3, UnitPrice: 10}, {ProductID: 2, Quantity: 3, UnitPrice: 4}},
+			want:   []float64{0, 8},
+		},
+		{
+			name:   "buy one get one half price, whole units only",
+			promos: []Promotion{{ID: 1, Type: TypeBuyXGetY, BuyQty: 1, GetQty: 1, GetPercent: 50, Active: true}},
+			lines:  []Line{{ProductID: 1, Quantity: 3.7, UnitPrice: 10}},
+			want:   []float64{5},
+		},
+		{
+			name: "combo for a fixed price",
+			promos: []Promotion{{ID: 1, Type: TypeCombo, Value: 10, Active: true,
+				ComboItems: []ComboItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 1}}}},
+			lines: []Line{{ProductID: 1, Quantity: 2, UnitPrice: 8}, {ProductID: 2, Quantity: 1, UnitPrice: 3}, {ProductID: 3, Quantity: 1, UnitPrice: 5}},
+			want:  []float64{0.73, 0.27, 0},
+		},
+		{
+			name: "incomplete combo",
+			promos: []Promotion{{ID: 1, Type: TypeCombo, Value: 10, Active: true,
+				ComboItems: []ComboItem{{ProductID: 1, Quantity: 1}, {ProductID: 2, Quantity: 2}}}},
+			lines: []Line{{ProductID: 1, Quantity: 1, UnitPrice: 8}, {ProductID: 2, Quantity: 1, UnitPrice: 3}},
+			want:  []float64{0, 0},
+		},
+		{
+			name: "higher priority non-stackable rule wins",
+			promos: []Promotion{
+				{ID: 1, Type: TypePercentOff, Value: 20, Priority: 2, Active: true},
+				{ID: 2, Type: TypePercentOff, Value: 10, Priority: 1, Active: true},
+			},
+			lines: []Line{{ProductID: 1, Quantity: 1, UnitPrice: 100}},
+			want:  []float64{20},
+		},
+		{
+			name: "stackable rules add up",
+			promos: []Promotion{
+				{ID: 1, Type: TypePercentOff, Value: 20, Priority: 2, Stackable: true, Active: true},
+				{ID: 2, Type: TypeAmountOff, Value: 5, Priority: 1, Stackable: true, Active: true},
+			},
+			lines: []Line{{ProductID: 1, Quantity: 1, UnitPrice: 100}},
+			want:  []float64{25},
+		},
+		{
+			name: "rules not running are skipped",
+			promos: []Promotion{
+				{ID: 1, Type: TypePercentOff, Value: 50, StartTime: "17:00", EndTime: "19:00", Active: true},
+				{ID: 2, Type: TypePercentOff, Value: 50},
+			},
+			lines: []Line{{ProductID: 1, Quantity: 1, UnitPrice: 100}},
+			want:  []float64{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := evaluate(tt.promos, tt.lines, categories, at)
+			for i, r := range results {
+				if r.Discount != tt.want[i] {
+					t.Errorf("line %d: discount %.2f, want %.2f", i, r.Discount, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunningAt(t *testing.T) {
+	// A Friday night happy hour running past midnight, during March 2025
+	starts := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	ends := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	happyHour := Promotion{Weekdays: []int{5}, StartTime: "22:00", EndTime: "02:00", StartsAt: &starts, EndsAt: &ends, Active: true}
+	lunch := Promotion{StartTime: "12:00", EndTime: "14:00", Active: true}
+
+	tests := []struct {
+		name  string
+		promo Promotion
+		at    time.Time
+		want  bool
+	}{
+		{"friday night", happyHour, time.Date(2025, 3, 14, 23, 0, 0, 0, time.UTC), true},
+		{"small hours of saturday", happyHour, time.Date(2025, 3, 15, 1, 59, 0, 0, time.UTC), true},
+		{"window closed", happyHour, time.Date(2025, 3, 15, 2, 0, 0, 0, time.UTC), false},
+		{"saturday night", happyHour, time.Date(2025, 3, 15, 23, 0, 0, 0, time.UTC), false},
+		{"small hours of friday", happyHour, time.Date(2025, 3, 14, 1, 0, 0, 0, time.UTC), false},
+		{"before the window", happyHour, time.Date(2025, 3, 14, 21, 59, 0, 0, time.UTC), false},
+		{"after the end date", happyHour, time.Date(2025, 4, 4, 23, 0, 0, 0, time.UTC), false},
+		{"lunch", lunch, time.Date(2025, 3, 12, 12, 30, 0, 0, time.UTC), true},
+		{"after lunch", lunch, time.Date(2025, 3, 12, 14, 0, 0, 0, time.UTC), false},
+		{"inactive", Promotion{}, time.Date(2025, 3, 12, 12, 30, 0, 0, time.UTC), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.promo.runningAt(tt.at); got != tt.want {
+				t.Errorf("running at %s: %v, want %v", tt.at.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
@@ -41,6 +41,21 @@ func GetRecipeHandler(db *gorm.DB) fiber.Handler {
 	}
 }
 
+func GetModifierRecipeHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+		optionID, _ := strconv.Atoi(c.Params("option_id"))
+
+		service := NewRecipeService(db)
+		ingredients, err := service.GetModifierRecipe(uint(optionID), bizID)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		return c.JSON(ingredients)
+	}
+}
+
 func RemoveIngredientHandler(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		bizID := c.Locals("current_business_id").(uint)
@@ -8,6 +8,7 @@ import (
 func RegisterRecipeRoutes(router fiber.Router, db *gorm.DB) {
 	group := router.Group("/recipes")
 
+	group.Get("/modifiers/:option_id", GetModifierRecipeHandler(db))
 	group.Get("/:product_id", GetRecipeHandler(db))
 	group.Post("/", AddIngredientHandler(db))
 	group.Delete("/:id", RemoveIngredientHandler(db))
@@ -1,6 +1,7 @@
 package recipe
 
 import (
+	"errors"
 	"fmt"
 	"pos-fiber-app/internal/inventory"
 	"pos-fiber-app/internal/subscription"
@@ -16,22 +17,45 @@ func NewRecipeService(db *gorm.DB) *RecipeService {
 	return &RecipeService{db: db}
 }
 
+// AddIngredientRequest adds an ingredient either to a product's recipe or,
+// when ModifierOptionID is set, to a modifier option's recipe.
 type AddIngredientRequest struct {
-	ProductID    uint    `json:"product_id" validate:"required"`
-	IngredientID uint    `json:"ingredient_id" validate:"required"`
-	Quantity     float64 `json:"quantity" validate:"required,gt=0"`
+	ProductID        uint    `json:"product_id" validate:"required_without=ModifierOptionID"`
+	ModifierOptionID *uint   `json:"modifier_option_id,omitempty"`
+	IngredientID     uint    `json:"ingredient_id" validate:"required"`
+	Quantity         float64 `json:"quantity" validate:"required,gt=0"`
 }
 
 func (s *RecipeService) GetRecipe(productID, businessID uint) ([]RecipeIngredient, error) {
 	var ingredients []RecipeIngredient
-	err := s.db.Where("product_id = ? AND business_id = ?", productID, businessID).Find(&ingredients).Error
+	err := s.db.Where("product_id = ? AND business_id = ? AND modifier_option_id IS NULL", productID, businessID).Find(&ingredients).Error
+	return ingredients, err
+}
+
+// GetModifierRecipe returns the ingredients used by a modifier option
+func (s *RecipeService) GetModifierRecipe(optionID, businessID uint) ([]RecipeIngredient, error) {
+	var ingredients []RecipeIngredient
+	err := s.db.Where("modifier_option_id = ? AND business_id = ?", optionID, businessID).Find(&ingredients).Error
 	return ingredients, err
 }
 
 func (s *RecipeService) AddIngredient(businessID uint, req AddIngredientRequest) (*RecipeIngredient, error) {
+	if req.ModifierOptionID != nil {
+		// Modifier recipes are not tied to a product
+		req.ProductID = 0
+	} else if req.ProductID == 0 {
+		return nil, errors.New("product_id or modifier_option_id is required")
+	}
+
 	var ing RecipeIngredient
 	// Check if already exists
-	err := s.db.Where("product_id = ? AND ingredient_id = ? AND business_id = ?", req.ProductID, req.IngredientID, businessID).First(&ing).Error
+	query := s.db.Where("ingredient_id = ? AND business_id = ?", req.IngredientID, businessID)
+	if req.ModifierOptionID != nil {
+		query = query.Where("modifier_option_id = ?", *req.ModifierOptionID)
+	} else {
+		query = query.Where("product_id = ? AND modifier_option_id IS NULL", req.ProductID)
+	}
+	err := query.First(&ing).Error
 
 	if err == nil {
 		// Update existing
@@ -44,10 +68,11 @@ func (s *RecipeService) AddIngredient(businessID uint, req AddIngredientRequest)
 
 	// Create new
 	ing = RecipeIngredient{
-		BusinessID:   businessID,
-		ProductID:    req.ProductID,
-		IngredientID: req.IngredientID,
-		Quantity:     req.Quantity,
+		BusinessID:       businessID,
+		ProductID:        req.ProductID,
+		IngredientID:     req.IngredientID,
+		Quantity:         req.Quantity,
+		ModifierOptionID: req.ModifierOptionID,
 	}
 	if err := s.db.Create(&ing).Error; err != nil {
 		return nil, err
@@ -62,7 +87,7 @@ func (s *RecipeService) RemoveIngredient(id, businessID uint) error {
 // AdjustStockWithRecipe handles stock deduction for a product, taking its recipe into account if applicable.
 // If the business has the RECIPE_MANAGEMENT module and a recipe exists for the product, it deducts ingredients.
 // Otherwise, it falls back to standard single-product stock adjustment.
-func (s *RecipeService) AdjustStockWithRecipe(tx *gorm.DB, productID, businessID uint, sellQuantity int) error {
+func (s *RecipeService) AdjustStockWithRecipe(tx *gorm.DB, productID, businessID uint, sellQuantity float64) error {
 	// 1. Check if the business has the Recipe Management module enabled
 	// Use the transaction tx to avoid potential connection state issues
 	hasRecipeModule, err := subscription.HasModuleWithError(tx, businessID, subscription.ModuleRecipe)
@@ -75,7 +100,7 @@ func (s *RecipeService) AdjustStockWithRecipe(tx *gorm.DB, productID, businessID
 
 	// 2. Check if this product has a recipe
 	var ingredients []RecipeIngredient
-	if err := tx.Where("product_id = ? AND business_id = ?", productID, businessID).Find(&ingredients).Error; err != nil {
+	if err := tx.Where("product_id = ? AND business_id = ? AND modifier_option_id IS NULL", productID, businessID).Find(&ingredients).Error; err != nil {
 		return err
 	}
 
@@ -84,25 +109,71 @@ func (s *RecipeService) AdjustStockWithRecipe(tx *gorm.DB, productID, businessID
 		return inventory.AdjustStock(tx, productID, businessID, -sellQuantity)
 	}
 
-	// 4. If a recipe exists, deduct each ingredient quantity
+	// 4. If a recipe exists, deduct each ingredient quantity. Ingredients used up are
+	// recorded as consumed; ingredients put back keep the reason of the return (void, refund).
+	stockTx := tx
+	if sellQuantity > 0 {
+		stockTx = inventory.WithReason(tx, inventory.ReasonRecipeConsumption)
+	}
 	for _, ing := range ingredients {
 		// Calculate total quantity to deduct for this ingredient
 		// sellQuantity is the number of finished products sold
 		// ing.Quantity is the amount of ingredient per 1 finished product
-		deductQty := float64(sellQuantity) * ing.Quantity
-
-		// Note: We cast to int for standard logic, but in specialized industries like bars,
-		// ingredients might be measured in fractional units (ml, grams) which standard inventory might not support yet.
-		// For now, we adjust by integer if the inventory system expects it, but we should ideally support fractional stock.
-		// Given current inventory system uses int, we'll use a ceiling or just round.
-		// Better yet, we should probably update inventory to support float quantities for ingredients.
-		// For the sake of this implementation, we will assume integer deduction or round to nearest.
+		// Stock is kept to the gram/ml, so fractional usage (e.g. 0.05 L a drink) is deducted as is
+		deductQty := inventory.RoundQuantity(sellQuantity * ing.Quantity)
 
 		// If the ingredient is tracked as a standard product, we deduct it.
-		if err := inventory.AdjustStock(tx, ing.IngredientID, businessID, -int(deductQty)); err != nil {
+		if err := inventory.AdjustStock(stockTx, ing.IngredientID, businessID, -deductQty); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// RestockWithRecipe puts returned products back into stock (e.g. refunds), reversing
+// exactly what AdjustStockWithRecipe deducted when they were sold.
+func (s *RecipeService) RestockWithRecipe(tx *gorm.DB, productID, businessID uint, returnQuantity float64) error {
+	return s.AdjustStockWithRecipe(tx, productID, businessID, -returnQuantity)
+}
+
+// AdjustStockForModifiers deducts the ingredients of the chosen modifier options for
+// sellQuantity items. Options without a recipe (e.g. "No onions") deduct nothing, and
+// nothing is deducted unless the business has the Recipe Management module.
+// A negative sellQuantity puts the ingredients back.
+func (s *RecipeService) AdjustStockForModifiers(tx *gorm.DB, businessID uint, optionIDs []uint, sellQuantity float64) error {
+	if len(optionIDs) == 0 {
+		return nil
+	}
+
+	hasRecipeModule, err := subscription.HasModuleWithError(tx, businessID, subscription.ModuleRecipe)
+	if err != nil {
+		return fmt.Errorf("module check failed: %w", err)
+	}
+	if !hasRecipeModule {
+		return nil
+	}
+
+	var ingredients []RecipeIngredient
+	if err := tx.Where("modifier_option_id IN ? AND business_id = ?", optionIDs, businessID).Find(&ingredients).Error; err != nil {
+		return err
+	}
+
+	stockTx := tx
+	if sellQuantity > 0 {
+		stockTx = inventory.WithReason(tx, inventory.ReasonRecipeConsumption)
+	}
+	for _, ing := range ingredients {
+		deductQty := inventory.RoundQuantity(sellQuantity * ing.Quantity)
+		if err := inventory.AdjustStock(stockTx, ing.IngredientID, businessID, -deductQty); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RestockForModifiers reverses AdjustStockForModifiers for returned items
+func (s *RecipeService) RestockForModifiers(tx *gorm.DB, businessID uint, optionIDs []uint, returnQuantity float64) error {
+	return s.AdjustStockForModifiers(tx, businessID, optionIDs, -returnQuantity)
+}
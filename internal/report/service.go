@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"pos-fiber-app/internal/email"
 	"pos-fiber-app/internal/inventory"
+	"pos-fiber-app/internal/loyalty"
 	"pos-fiber-app/internal/notification"
 	"pos-fiber-app/internal/sale"
 	"pos-fiber-app/internal/shift"
@@ -127,8 +128,30 @@ func (s *ReportService) TriggerWeeklyAuditReminders() error {
 		}
 
 		// Prepare data for audit reminder
-		s.notifier.SendSecurityAlert(b.ID, "Physical Stock Audit Reminder",
-			fmt.Sprintf("Hello %s, it's time for the weekly headcount of your stock at %s. Please perform a physical audit to ensure inventory accuracy.", owner.FirstName, b.Name))
+		message := fmt.Sprintf("Hello %s, it's time for the weekly headcount of your stock at %s. Please perform a physical audit to ensure inventory accuracy.", owner.FirstName, b.Name)
+
+		// List the week's discounts and price overrides for review
+		now := time.Now()
+		overrides, _ := sale.ListOverrides(s.db, b.ID, now.AddDate(0, 0, -7), now)
+		if len(overrides) > 0 {
+			message += fmt.Sprintf("\n\nDiscounts and price overrides this week (%d):", len(overrides))
+			for _, o := range overrides {
+				line := fmt.Sprintf("\n- %s Sale #%d", o.CreatedAt.Format("Mon 02 Jan 15:04"), o.SaleID)
+				if o.ProductName != "" {
+					line += " " + o.ProductName
+				}
+				line += fmt.Sprintf(": %s -> %s by %s", o.OldValue, o.NewValue, o.PerformedBy)
+				if o.ApprovedBy != "" {
+					line += ", approved by " + o.ApprovedBy
+				}
+				if o.Reason != "" {
+					line += " (" + o.Reason + ")"
+				}
+				message += line
+			}
+		}
+
+		s.notifier.SendSecurityAlert(b.ID, "Physical Stock Audit Reminder", message)
 	}
 	return nil
 }
@@ -189,6 +212,9 @@ func (s *ReportService) TriggerMonthlyFinancialReports() error {
 			TransactionCount: monthlyStats.TotalTransactions,
 			Message:          fmt.Sprintf("%.2f", monthlyStats.AverageSale), // Using message as placeholder for Avg Sale
 		}
+		if liability, err := loyalty.GetLiability(s.db, b.ID); err == nil && liability.Points > 0 {
+			data.LoyaltyLiability = fmt.Sprintf("%.2f", liability.Value)
+		}
 
 		// 3. Send Email
 		emailCfg := email.LoadConfig()
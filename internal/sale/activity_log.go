@@ -11,15 +11,23 @@ import (
 type ActionType string
 
 const (
-	ActionCreated     ActionType = "created"
-	ActionUpdated     ActionType = "updated"
-	ActionCompleted   ActionType = "completed"
-	ActionVoided      ActionType = "voided"
-	ActionTransferred ActionType = "transferred"
-	ActionMerged      ActionType = "merged"
-	ActionResumed     ActionType = "resumed"
-	ActionItemAdded   ActionType = "item_added"
-	ActionItemRemoved ActionType = "item_removed"
+	ActionCreated       ActionType = "created"
+	ActionUpdated       ActionType = "updated"
+	ActionCompleted     ActionType = "completed"
+	ActionVoided        ActionType = "voided"
+	ActionRefunded      ActionType = "refunded"
+	ActionTransferred   ActionType = "transferred"
+	ActionMerged        ActionType = "merged"
+	ActionSplit         ActionType = "split"
+	ActionResumed       ActionType = "resumed"
+	ActionItemAdded     ActionType = "item_added"
+	ActionItemRemoved   ActionType = "item_removed"
+	ActionPriceOverride ActionType = "price_override"    // line discount or unit price override
+	ActionDiscount      ActionType = "discount"          // discount on the whole sale
+	ActionLayaway       ActionType = "layaway"           // sale put on layaway with a first deposit
+	ActionDeposit       ActionType = "deposit"           // instalment paid towards a layaway
+	ActionLayawayCancel ActionType = "layaway_cancelled" // layaway cancelled, deposits refunded less the fee
+	ActionCourseFired   ActionType = "course_fired"      // a held course released to the kitchen
 )
 
 // SaleActivityLog tracks all actions performed on a sale for audit purposes
@@ -38,14 +46,19 @@ type ActivityDetails struct {
 	FromTable     string      `json:"from_table,omitempty"`
 	ToTable       string      `json:"to_table,omitempty"`
 	MergedFrom    []uint      `json:"merged_from,omitempty"`
+	SplitFrom     uint        `json:"split_from,omitempty"` // parent sale of a split bill
+	SplitInto     []uint      `json:"split_into,omitempty"` // child sales created by a split
 	ProductID     uint        `json:"product_id,omitempty"`
 	ProductName   string      `json:"product_name,omitempty"`
-	Quantity      int         `json:"quantity,omitempty"`
+	Quantity      float64     `json:"quantity,omitempty"`
 	Reason        string      `json:"reason,omitempty"`
 	OldValue      interface{} `json:"old_value,omitempty"`
 	NewValue      interface{} `json:"new_value,omitempty"`
 	AmountPaid    float64     `json:"amount_paid,omitempty"`
 	PaymentMethod string      `json:"payment_method,omitempty"`
+	ApprovedBy    *uint       `json:"approved_by,omitempty"` // manager or owner who approved an override
+	Course        int         `json:"course,omitempty"`      // course fired
+	ItemIDs       []uint      `json:"item_ids,omitempty"`    // lines the action applied to
 }
 
 // LogActivity creates an activity log entry
@@ -4,6 +4,9 @@ package sale
 import (
 	"errors"
 	"fmt"
+	"time"
+
+	"pos-fiber-app/internal/inventory"
 
 	"gorm.io/gorm"
 )
@@ -21,6 +24,41 @@ type MergeBillsRequest struct {
 	TargetTableNumber string `json:"target_table_number"`
 }
 
+// Split modes accepted by SplitBill
+const (
+	SplitByItems = "items"
+	SplitBySeats = "seats"
+	SplitEvenly  = "even"
+)
+
+// SplitBillRequest contains the data needed to split a bill
+type SplitBillRequest struct {
+	Mode   string          `json:"mode" validate:"required,oneof=items seats even"`
+	Splits []SplitBillPart `json:"splits,omitempty"` // items / seats modes
+	Ways   int             `json:"ways,omitempty"`   // even mode
+}
+
+// SplitBillPart describes one new bill carved off the parent
+type SplitBillPart struct {
+	Items        []SplitItemRequest `json:"items,omitempty"`
+	Seats        []int              `json:"seats,omitempty"`
+	TableID      *uint              `json:"table_id,omitempty"`
+	TableNumber  string             `json:"table_number,omitempty"`
+	CustomerName string             `json:"customer_name,omitempty"`
+}
+
+// SplitItemRequest moves a quantity of one sale item; a zero quantity moves the whole line
+type SplitItemRequest struct {
+	SaleItemID uint    `json:"sale_item_id" validate:"required"`
+	Quantity   float64 `json:"quantity"`
+}
+
+// SplitBillResult is returned after a split. Parent is nil when every item moved off it.
+type SplitBillResult struct {
+	Parent *Sale  `json:"parent,omitempty"`
+	Splits []Sale `json:"splits"`
+}
+
 // TransferBill moves a sale from one table to another
 func TransferBill(db *gorm.DB, saleID, businessID, userID uint, req TransferBillRequest) (*Sale, error) {
 	tx := db.Begin()
@@ -160,14 +198,393 @@ func MergeBills(db *gorm.DB, primarySaleID, businessID, userID uint, req MergeBi
 	return &primarySale, nil
 }
 
-// SplitBill splits a sale into multiple sales (future enhancement)
-// This is a placeholder for future implementation
-func SplitBill(db *gorm.DB, saleID, businessID, userID uint, splitConfig interface{}) error {
-	// TODO: Implement bill splitting logic
-	// This would involve:
-	// 1. Creating new sales for each split
-	// 2. Distributing items across the new sales
-	// 3. Handling stock reservations
-	// 4. Logging the split activity
-	return errors.New("bill splitting not yet implemented")
+// SplitBill splits a draft or held sale into several child sales.
+//
+// Modes:
+//   - "items": each split lists sale item IDs (optionally with a partial quantity) to move off the parent
+//   - "seats": each split lists seat numbers; every line on those seats moves
+//   - "even":  the parent keeps all items and N-1 item-less children carry an equal share of the amount
+//
+// Stock reservations follow the items they belong to. Whatever is not moved stays on the parent,
+// and a parent left with no items is removed once the split has been logged.
+func SplitBill(db *gorm.DB, saleID, businessID, userID uint, req SplitBillRequest) (*SplitBillResult, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var parent Sale
+	if err := tx.Preload("SaleItems").First(&parent, "id = ? AND business_id = ?", saleID, businessID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("sale not found")
+		}
+		return nil, err
+	}
+
+	if parent.Status != StatusDraft && parent.Status != StatusHeld {
+		return nil, errors.New("can only split draft or held sales")
+	}
+	if parent.SplitWays > 0 {
+		return nil, errors.New("bill is already split evenly")
+	}
+
+	// Detach the loaded items so saving the parent doesn't write stale lines back
+	items := parent.SaleItems
+	parent.SaleItems = nil
+
+	var children []Sale
+	var err error
+	switch req.Mode {
+	case SplitByItems, SplitBySeats:
+		children, err = splitByLines(tx, &parent, items, req)
+	case SplitEvenly:
+		children, err = splitEvenly(tx, &parent, req.Ways)
+	default:
+		return nil, fmt.Errorf("unknown split mode %q", req.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Log the split on every child (pointing back to the parent) and on the parent
+	childIDs := make([]uint, 0, len(children))
+	for _, child := range children {
+		childIDs = append(childIDs, child.ID)
+		if err := LogActivity(tx, child.ID, businessID, userID, ActionSplit, ActivityDetails{SplitFrom: parent.ID, NewValue: req.Mode}); err != nil {
+			return nil, err
+		}
+	}
+	if err := LogActivity(tx, parent.ID, businessID, userID, ActionSplit, ActivityDetails{SplitInto: childIDs, NewValue: req.Mode}); err != nil {
+		return nil, err
+	}
+
+	var remaining int64
+	tx.Model(&SaleItem{}).Where("sale_id = ?", parent.ID).Count(&remaining)
+	parentRemoved := remaining == 0 && req.Mode != SplitEvenly
+	if parentRemoved {
+		if err := tx.Delete(&parent).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	result := &SplitBillResult{Splits: children}
+	if !parentRemoved {
+		if err := db.Preload("SaleItems").First(&parent, parent.ID).Error; err != nil {
+			return nil, err
+		}
+		result.Parent = &parent
+	}
+	for i := range result.Splits {
+		db.Where("sale_id = ?", result.Splits[i].ID).Find(&result.Splits[i].SaleItems)
+	}
+
+	return result, nil
+}
+
+// splitByLines handles the "items" and "seats" modes
+func splitByLines(tx *gorm.DB, parent *Sale, items []SaleItem, req SplitBillRequest) ([]Sale, error) {
+	if len(req.Splits) == 0 {
+		return nil, errors.New("at least one split is required")
+	}
+
+	lines := make(map[uint]*SaleItem, len(items))
+	for i := range items {
+		lines[items[i].ID] = &items[i]
+	}
+	if req.Mode == SplitByItems {
+		if err := checkSplitItems(parent, lines, req.Splits); err != nil {
+			return nil, err
+		}
+	}
+
+	reservationService := inventory.NewReservationService(tx)
+	children := make([]Sale, 0, len(req.Splits))
+
+	for n, part := range req.Splits {
+		child := newSplitChild(parent, part)
+		if err := tx.Create(&child).Error; err != nil {
+			return nil, err
+		}
+
+		moved := 0
+		if req.Mode == SplitBySeats {
+			seats := make(map[int]bool, len(part.Seats))
+			for _, seat := range part.Seats {
+				seats[seat] = true
+			}
+			for i := range items {
+				item := &items[i]
+				if item.Quantity == 0 || !seats[item.SeatNumber] {
+					continue
+				}
+				if err := moveSaleItem(tx, reservationService, item, child.ID, item.Quantity); err != nil {
+					return nil, err
+				}
+				moved++
+			}
+		} else {
+			for _, itemReq := range part.Items {
+				item := lines[itemReq.SaleItemID]
+				qty := itemReq.Quantity
+				if qty == 0 {
+					qty = item.Quantity
+				}
+				if err := checkItemQuantity(tx, item.ProductID, qty); err != nil {
+					return nil, fmt.Errorf("%s: %w", item.ProductName, err)
+				}
+				if err := moveSaleItem(tx, reservationService, item, child.ID, qty); err != nil {
+					return nil, err
+				}
+				moved++
+			}
+		}
+
+		if moved == 0 {
+			return nil, fmt.Errorf("split %d has no items", n+1)
+		}
+
+		if err := recalculateSaleTotals(tx, &child); err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	if err := recalculateSaleTotals(tx, parent); err != nil {
+		return nil, err
+	}
+
+	return children, nil
+}
+
+// checkSplitItems validates an "items" split before anything moves: every line is on
+// the bill, appears in one split only, and no more of it moves than is on the bill
+func checkSplitItems(parent *Sale, lines map[uint]*SaleItem, splits []SplitBillPart) error {
+	seen := make(map[uint]bool)
+	for _, part := range splits {
+		for _, itemReq := range part.Items {
+			item, ok := lines[itemReq.SaleItemID]
+			if !ok {
+				return fmt.Errorf("item %d not found on sale %d", itemReq.SaleItemID, parent.ID)
+			}
+			if seen[item.ID] {
+				return fmt.Errorf("item %d (%s) is in more than one split", item.ID, item.ProductName)
+			}
+			seen[item.ID] = true
+
+			if itemReq.Quantity < 0 || itemReq.Quantity > item.Quantity {
+				return fmt.Errorf("cannot move %s of %s: only %s left on the bill", inventory.FormatQuantity(itemReq.Quantity), item.ProductName, inventory.FormatQuantity(item.Quantity))
+			}
+		}
+	}
+	return nil
+}
+
+// splitEvenly leaves the items (and their stock) on the parent and creates ways-1
+// item-less shares. The parent and every share carry SplitWays, so computeTotals prices
+// each as its part of the whole bill, tax, service charge and fees included, and the
+// shares complete to the bill's total. Any rounding remainder stays on the parent.
+func splitEvenly(tx *gorm.DB, parent *Sale, ways int) ([]Sale, error) {
+	if ways < 2 {
+		return nil, errors.New("ways must be at least 2")
+	}
+	if parent.Subtotal <= 0 {
+		return nil, errors.New("cannot split an empty bill")
+	}
+
+	parent.SplitWays = ways
+	if err := recalculateSaleTotals(tx, parent); err != nil {
+		return nil, err
+	}
+
+	children := make([]Sale, 0, ways-1)
+	for i := 1; i < ways; i++ {
+		child := newSplitChild(parent, SplitBillPart{})
+		child.SplitWays = ways
+		if err := tx.Create(&child).Error; err != nil {
+			return nil, err
+		}
+		if err := recalculateSaleTotals(tx, &child); err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	return children, nil
+}
+
+// applySplitShare prices a sale as its share of the bill it was split evenly from: the
+// parent's items are totalled as one bill, which is then divided between the shares
+func applySplitShare(db *gorm.DB, sale *Sale, discount float64) error {
+	billID := sale.ID
+	if sale.ParentSaleID != nil {
+		billID = *sale.ParentSaleID
+	}
+
+	var bill Sale
+	if err := db.First(&bill, billID).Error; err != nil {
+		return err
+	}
+	var items []SaleItem
+	if err := db.Where("sale_id = ?", billID).Find(&items).Error; err != nil {
+		return err
+	}
+
+	bill.SplitWays = 0
+	bill.Subtotal, bill.PromoDiscount = 0, 0
+	for _, item := range items {
+		bill.Subtotal += item.TotalPrice
+		bill.PromoDiscount += item.PromoDiscount
+	}
+	bill.Subtotal = roundMoney(bill.Subtotal)
+	bill.PromoDiscount = roundMoney(bill.PromoDiscount)
+	if err := computeTotals(db, &bill, items, 0); err != nil {
+		return err
+	}
+
+	applyShare(sale, &bill, discount)
+	return nil
+}
+
+// applyShare sets a sale's totals to its share of bill. Each share is the bill divided
+// by SplitWays and rounded; the parent takes whatever rounding leaves over, so the
+// shares always add back up to the bill. A discount given on a share comes off that
+// share alone, taking its tax and service charge down in proportion.
+func applyShare(sale, bill *Sale, discount float64) {
+	ways := float64(sale.SplitWays)
+	owner := sale.ParentSaleID == nil
+	part := func(amount float64) float64 {
+		share := roundMoney(amount / ways)
+		if owner {
+			return roundMoney(amount - share*(ways-1))
+		}
+		return share
+	}
+
+	sale.Subtotal = part(bill.Subtotal)
+	sale.PromoDiscount = part(bill.PromoDiscount)
+	sale.Tax = part(bill.Tax)
+	sale.ServiceCharge = part(bill.ServiceCharge)
+	sale.DeliveryFee = part(bill.DeliveryFee)
+	sale.TaxInclusive = bill.TaxInclusive
+
+	if discount > 0 && sale.Subtotal > 0 {
+		left := (sale.Subtotal - discount) / sale.Subtotal
+		sale.Tax = roundMoney(sale.Tax * left)
+		sale.ServiceCharge = roundMoney(sale.ServiceCharge * left)
+	}
+
+	sale.Total = sale.Subtotal - discount + sale.ServiceCharge + sale.DeliveryFee
+	if !sale.TaxInclusive {
+		sale.Total += sale.Tax
+	}
+	sale.Total = roundMoney(sale.Total)
+}
+
+// checkSplitUnpaid stops the items of an evenly split bill changing once one of its
+// shares has been paid, as the paid share could no longer add up with the others
+func checkSplitUnpaid(db *gorm.DB, sale *Sale) error {
+	if sale.SplitWays < 2 {
+		return nil
+	}
+	billID := sale.ID
+	if sale.ParentSaleID != nil {
+		billID = *sale.ParentSaleID
+	}
+	var paid int64
+	db.Model(&Sale{}).
+		Where("(id = ? OR parent_sale_id = ?) AND split_ways > 0 AND status = ?", billID, billID, StatusCompleted).
+		Count(&paid)
+	if paid > 0 {
+		return errors.New("bill is split evenly and a share is already paid; its items cannot change")
+	}
+	return nil
+}
+
+// newSplitChild builds a draft that inherits the parent's header
+func newSplitChild(parent *Sale, part SplitBillPart) Sale {
+	child := Sale{
+		BusinessID:    parent.BusinessID,
+		TenantID:      parent.TenantID,
+		OutletID:      parent.OutletID,
+		TerminalID:    parent.TerminalID,
+		CashierID:     parent.CashierID,
+		ShiftID:       parent.ShiftID,
+		Status:        StatusDraft,
+		TableID:       parent.TableID,
+		TableNumber:   parent.TableNumber,
+		CustomerName:  parent.CustomerName,
+		CustomerPhone: parent.CustomerPhone,
+		OrderType:     parent.OrderType,
+		SaleDate:      time.Now(),
+		ParentSaleID:  &parent.ID,
+	}
+	if part.TableID != nil || part.TableNumber != "" {
+		child.TableID = part.TableID
+		child.TableNumber = part.TableNumber
+	}
+	if part.CustomerName != "" {
+		child.CustomerName = part.CustomerName
+	}
+	return child
+}
+
+// splitLine takes qty units off a line as a new line for another sale, leaving the rest
+// on item. Amounts are shared by quantity, the moved part rounded and the remainder
+// left behind, so the two parts add up to the line they came from.
+func splitLine(item *SaleItem, toSaleID uint, qty float64) SaleItem {
+	ratio := qty / item.Quantity
+	moved := *item
+	moved.ID = 0
+	moved.SaleID = toSaleID
+	moved.Quantity = qty
+	moved.TotalPrice = roundMoney(item.TotalPrice * ratio)
+	moved.Profit = roundMoney(item.Profit * ratio)
+	moved.ManualDiscount = roundMoney(item.ManualDiscount * ratio)
+	moved.PromoDiscount = roundMoney(item.PromoDiscount * ratio)
+	if item.LabelPrice != nil {
+		// Each part of a scale-labelled line carries its share of the printed price
+		movedLabel := roundMoney(*item.LabelPrice * ratio)
+		leftLabel := roundMoney(*item.LabelPrice - movedLabel)
+		moved.LabelPrice = &movedLabel
+		item.LabelPrice = &leftLabel
+	}
+
+	item.Quantity = inventory.RoundQuantity(item.Quantity - qty)
+	item.TotalPrice = roundMoney(item.TotalPrice - moved.TotalPrice)
+	item.Profit = roundMoney(item.Profit - moved.Profit)
+	item.ManualDiscount = roundMoney(item.ManualDiscount - moved.ManualDiscount)
+	item.PromoDiscount = roundMoney(item.PromoDiscount - moved.PromoDiscount)
+	return moved
+}
+
+// moveSaleItem moves qty units of a line onto another sale, splitting the line when
+// only part of it moves. The in-memory item is updated to what is left on the parent.
+func moveSaleItem(tx *gorm.DB, reservationService *inventory.ReservationService, item *SaleItem, toSaleID uint, qty float64) error {
+	fromSaleID := item.SaleID
+	wholeLine := qty == item.Quantity
+
+	if wholeLine {
+		if err := tx.Model(&SaleItem{}).Where("id = ?", item.ID).Update("sale_id", toSaleID).Error; err != nil {
+			return err
+		}
+	} else {
+		moved := splitLine(item, toSaleID, qty)
+		if err := tx.Create(&moved).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(item).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := reservationService.TransferReservation(fromSaleID, toSaleID, item.ProductID, qty); err != nil {
+		return fmt.Errorf("failed to move reservation for %s: %w", item.ProductName, err)
+	}
+
+	if wholeLine {
+		item.Quantity = 0
+	}
+	return nil
 }
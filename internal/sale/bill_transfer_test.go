@@ -0,0 +1,105 @@
+// internal/sale/bill_transfer_test.go
+package sale
+
+import "testing"
+
+// The shares of a bill split evenly must complete to exactly the bill's total, tax,
+// service charge and delivery fee included, whatever the rounding
+func TestApplyShareAddsUpToBill(t *testing.T) {
+	parentID := uint(1)
+	bills := []Sale{
+		{Subtotal: 100, Tax: 7.5, ServiceCharge: 10, Total: 117.5},
+		{Subtotal: 250.01, Tax: 18.75, ServiceCharge: 25, DeliveryFee: 3.33, Total: 297.09},
+		{Subtotal: 99.99, Tax: 6.98, ServiceCharge: 9.99, Total: 109.98, TaxInclusive: true},
+	}
+
+	for _, bill := range bills {
+		for ways := 2; ways <= 7; ways++ {
+			var total, tax float64
+			for i := 0; i < ways; i++ {
+				share := Sale{SplitWays: ways}
+				if i > 0 {
+					share.ParentSaleID = &parentID
+				}
+				applyShare(&share, &bill, 0)
+				total += share.Total
+				tax += share.Tax
+			}
+			if roundMoney(total) != bill.Total {
+				t.Errorf("%d ways of %.2f: shares total %.2f", ways, bill.Total, roundMoney(total))
+			}
+			if roundMoney(tax) != bill.Tax {
+				t.Errorf("%d ways of %.2f: shares carry %.2f tax, bill has %.2f", ways, bill.Total, roundMoney(tax), bill.Tax)
+			}
+		}
+	}
+}
+
+// A discount on one share comes off that share alone
+func TestApplyShareDiscount(t *testing.T) {
+	parentID := uint(1)
+	bill := Sale{Subtotal: 100, Tax: 7.5, ServiceCharge: 10, Total: 117.5}
+
+	share := Sale{SplitWays: 2, ParentSaleID: &parentID}
+	applyShare(&share, &bill, 10)
+
+	// 50 - 10 discount, then 7.5% tax and 10% service charge on the 40 left
+	if share.Tax != 3 || share.ServiceCharge != 4 || share.Total != 47 {
+		t.Errorf("discounted share: tax %.2f, service charge %.2f, total %.2f; want 3, 4, 47", share.Tax, share.ServiceCharge, share.Total)
+	}
+}
+
+// Moving part of a line splits its amounts and discounts between the two parts, so
+// nothing is counted twice and the parts add up to the line they came from
+func TestSplitLine(t *testing.T) {
+	label := 12.35
+	tests := []struct {
+		name string
+		item SaleItem
+		qty  float64
+	}{
+		{"discounted", SaleItem{Quantity: 4, UnitPrice: 10, ManualDiscount: 6, TotalPrice: 34, Profit: 14}, 1},
+		{"promotion", SaleItem{Quantity: 3, UnitPrice: 5, PromoDiscount: 5, TotalPrice: 10, Profit: 4}, 2},
+		{"odd cents", SaleItem{Quantity: 3, UnitPrice: 3.33, ManualDiscount: 1, TotalPrice: 8.99, Profit: 2.99}, 1},
+		{"scale label", SaleItem{Quantity: 0.95, UnitPrice: 13, LabelPrice: &label, TotalPrice: 12.35, Profit: 3}, 0.4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := tt.item
+			if item.LabelPrice != nil {
+				l := *item.LabelPrice
+				item.LabelPrice = &l
+			}
+			moved := splitLine(&item, 2, tt.qty)
+
+			if moved.SaleID != 2 || moved.ID != 0 {
+				t.Errorf("moved part is not a new line on the other sale")
+			}
+			if got := roundMoney(moved.Quantity + item.Quantity); got != tt.item.Quantity {
+				t.Errorf("quantities add up to %v, want %v", got, tt.item.Quantity)
+			}
+			if got := roundMoney(moved.ManualDiscount + item.ManualDiscount); got != tt.item.ManualDiscount {
+				t.Errorf("manual discounts add up to %.2f, want %.2f", got, tt.item.ManualDiscount)
+			}
+			if got := roundMoney(moved.PromoDiscount + item.PromoDiscount); got != tt.item.PromoDiscount {
+				t.Errorf("promotion discounts add up to %.2f, want %.2f", got, tt.item.PromoDiscount)
+			}
+			if got := roundMoney(moved.TotalPrice + item.TotalPrice); got != tt.item.TotalPrice {
+				t.Errorf("totals add up to %.2f, want %.2f", got, tt.item.TotalPrice)
+			}
+			if tt.item.LabelPrice != nil {
+				if got := roundMoney(*moved.LabelPrice + *item.LabelPrice); got != *tt.item.LabelPrice {
+					t.Errorf("label prices add up to %.2f, want %.2f", got, *tt.item.LabelPrice)
+				}
+			}
+
+			// Repriced, each part is charged for its own units less its own share of the discounts
+			priceSaleItem(&moved)
+			priceSaleItem(&item)
+			if got := roundMoney(moved.TotalPrice + item.TotalPrice); got != tt.item.TotalPrice {
+				t.Errorf("repriced parts total %.2f, want %.2f", got, tt.item.TotalPrice)
+			}
+		})
+	}
+}
@@ -4,39 +4,48 @@ import (
 	"bytes"
 	"encoding/csv"
 	"fmt"
+	"sort"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-// TaxReportItem represents a row in the tax report CSV
+// TaxReportItem represents a row in the tax report CSV: one sale's lines at one tax rate
 type TaxReportItem struct {
 	Date          string
 	InvoiceNumber string
 	Customer      string
 	TaxID         string
-	Subtotal      float64
+	TaxClass      string
+	TaxRate       float64
+	Net           float64
 	VAT           float64
-	Total         float64
+	Gross         float64
 	PaymentMethod string
 	Status        string
 }
 
-// GenerateTaxReport generates a CSV report for VAT/FIRS filings
+// taxRateKey groups report totals by class and rate
+type taxRateKey struct {
+	Class string
+	Rate  float64
+}
+
+// GenerateTaxReport generates a CSV report for VAT/FIRS filings from the VAT stored on
+// each sale line, one row per sale and rate, followed by totals for each rate. Refunds
+// made in the period are credit rows taking their share of the refunded lines' VAT back
+// off the totals; the sales they refund stay in the periods they were made in.
 func GenerateTaxReport(db *gorm.DB, businessID uint, startDate, endDate string) ([]byte, error) {
-	// 0. Fetch Business for VAT settings
+	// 0. Fetch Business for the tax number
 	var biz struct {
-		VATRate   float64
 		TaxNumber string
 	}
-	db.Table("businesses").Select("vat_rate, tax_number").Where("id = ?", businessID).Scan(&biz)
-	if biz.VATRate <= 0 {
-		biz.VATRate = 7.5 // Default fallback
-	}
+	db.Table("businesses").Select("tax_number").Where("id = ?", businessID).Scan(&biz)
 
-	// 1. Fetch sales within range
+	// 1. Fetch sales and refunds within range
 	var sales []Sale
-	query := db.Where("business_id = ? AND status = ?", businessID, StatusCompleted)
+	query := db.Where("business_id = ? AND status IN ?", businessID, revenueStatuses)
+	refundQuery := db.Where("business_id = ?", businessID)
 
 	if startDate != "" && endDate != "" {
 		start, _ := time.Parse("2006-01-02", startDate)
@@ -44,47 +53,107 @@ func GenerateTaxReport(db *gorm.DB, businessID uint, startDate, endDate string)
 		// Set end to end of day
 		end = end.Add(24 * time.Hour).Add(-1 * time.Second)
 		query = query.Where("created_at BETWEEN ? AND ?", start, end)
+		refundQuery = refundQuery.Where("created_at BETWEEN ? AND ?", start, end)
 	}
 
-	if err := query.Find(&sales).Error; err != nil {
+	if err := query.Order("created_at ASC").Find(&sales).Error; err != nil {
+		return nil, err
+	}
+	var refunds []Refund
+	if err := refundQuery.Order("created_at ASC").Find(&refunds).Error; err != nil {
 		return nil, err
 	}
 
-	// 2. Prepare CSV Data
+	// 2. Sum each sale's lines by tax rate
+	saleIDs := make([]uint, 0, len(sales))
+	for _, s := range sales {
+		saleIDs = append(saleIDs, s.ID)
+	}
+	var lines []struct {
+		SaleID   uint
+		TaxClass string
+		TaxRate  float64
+		Net      float64
+		Tax      float64
+	}
+	if len(saleIDs) > 0 {
+		if err := db.Model(&SaleItem{}).
+			Select("sale_id, tax_class, tax_rate, SUM(net_amount) AS net, SUM(tax) AS tax").
+			Where("sale_id IN ? AND tax_class <> ''", saleIDs).
+			Group("sale_id, tax_class, tax_rate").
+			Order("sale_id, tax_rate DESC").
+			Scan(&lines).Error; err != nil {
+			return nil, err
+		}
+	}
+	bySale := make(map[uint][]int)
+	for i, l := range lines {
+		bySale[l.SaleID] = append(bySale[l.SaleID], i)
+	}
+
+	// 3. Prepare CSV Data
 	var reportData []TaxReportItem
+	totals := make(map[taxRateKey]*TaxReportItem)
+	var order []taxRateKey
+	add := func(item TaxReportItem) {
+		reportData = append(reportData, item)
+		key := taxRateKey{Class: item.TaxClass, Rate: item.TaxRate}
+		t, ok := totals[key]
+		if !ok {
+			t = &TaxReportItem{TaxClass: item.TaxClass, TaxRate: item.TaxRate}
+			totals[key] = t
+			order = append(order, key)
+		}
+		t.Net += item.Net
+		t.VAT += item.VAT
+		t.Gross += item.Gross
+	}
+
 	for _, s := range sales {
-		// Calculate VAT (Assuming inclusive or exclusive logic, here we simplify)
-		// For this example, let's assume specific tax logic based on business settings or fixed rate
-		// In a real app, you'd fetch tax settings. Here we'll show raw totals and calculate example 7.5% VAT from Total if needed,
-		// or just list the tax amount if stored.
-		// For now, let's use the stored Total and assume a standard extraction or 0 if not recorded.
-
-		// If tax amount isn't explicitly stored, we might calculate it.
-		// Let's assume 7.5% VAT is included in Total for reporting purposes if not separated.
-		// vatRate = 12.0 -> 1.12 divisor
-		divisor := 1 + (biz.VATRate / 100)
-		vatAmount := s.Total - (s.Total / divisor)
-		subtotal := s.Total - vatAmount
-
-		reportData = append(reportData, TaxReportItem{
+		row := TaxReportItem{
 			Date:          s.CreatedAt.Format("2006-01-02 15:04:05"),
 			InvoiceNumber: fmt.Sprintf("INV-%d", s.ID),
 			Customer:      s.CustomerName,
 			TaxID:         biz.TaxNumber,
-			Subtotal:      subtotal,
-			VAT:           vatAmount,
-			Total:         s.Total,
 			PaymentMethod: s.PaymentMethod,
 			Status:        string(s.Status),
-		})
+		}
+
+		idx, ok := bySale[s.ID]
+		if !ok {
+			// Sales from before VAT was worked out per line only have the sale's Tax
+			row.TaxClass = "UNCLASSIFIED"
+			row.VAT = s.Tax
+			row.Gross = s.Total
+			row.Net = s.Total - s.Tax
+			add(row)
+			continue
+		}
+		for _, i := range idx {
+			l := lines[i]
+			row.TaxClass = l.TaxClass
+			row.TaxRate = l.TaxRate
+			row.Net = l.Net
+			row.VAT = l.Tax
+			row.Gross = l.Net + l.Tax
+			add(row)
+		}
+	}
+
+	creditRows, err := refundTaxRows(db, refunds, biz.TaxNumber)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range creditRows {
+		add(row)
 	}
 
-	// 3. Write to CSV
+	// 4. Write to CSV
 	b := &bytes.Buffer{}
 	w := csv.NewWriter(b)
 
 	// Header
-	if err := w.Write([]string{"Date", "Invoice No", "Customer", "Business Tax ID", "Subtotal", fmt.Sprintf("VAT (%.1f%%)", biz.VATRate), "Total", "Payment Method", "Status"}); err != nil {
+	if err := w.Write([]string{"Date", "Invoice No", "Customer", "Business Tax ID", "Tax Class", "VAT Rate (%)", "Net", "VAT", "Gross", "Payment Method", "Status"}); err != nil {
 		return nil, err
 	}
 
@@ -95,9 +164,11 @@ func GenerateTaxReport(db *gorm.DB, businessID uint, startDate, endDate string)
 			r.InvoiceNumber,
 			r.Customer,
 			r.TaxID,
-			fmt.Sprintf("%.2f", r.Subtotal),
+			r.TaxClass,
+			fmt.Sprintf("%.2f", r.TaxRate),
+			fmt.Sprintf("%.2f", r.Net),
 			fmt.Sprintf("%.2f", r.VAT),
-			fmt.Sprintf("%.2f", r.Total),
+			fmt.Sprintf("%.2f", r.Gross),
 			r.PaymentMethod,
 			r.Status,
 		}
@@ -106,8 +177,126 @@ func GenerateTaxReport(db *gorm.DB, businessID uint, startDate, endDate string)
 		}
 	}
 
+	// Totals by rate
+	if len(order) > 0 {
+		if err := w.Write([]string{}); err != nil {
+			return nil, err
+		}
+		if err := w.Write([]string{"Totals by rate", "", "", "", "Tax Class", "VAT Rate (%)", "Net", "VAT", "Gross"}); err != nil {
+			return nil, err
+		}
+		sort.Slice(order, func(i, j int) bool {
+			if order[i].Rate != order[j].Rate {
+				return order[i].Rate > order[j].Rate
+			}
+			return order[i].Class < order[j].Class
+		})
+		for _, key := range order {
+			t := totals[key]
+			record := []string{"", "", "", "", t.TaxClass,
+				fmt.Sprintf("%.2f", t.TaxRate),
+				fmt.Sprintf("%.2f", t.Net),
+				fmt.Sprintf("%.2f", t.VAT),
+				fmt.Sprintf("%.2f", t.Gross),
+			}
+			if err := w.Write(record); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	w.Flush()
-	return b.Bytes(), nil
+	return b.Bytes(), w.Error()
+}
+
+// refundTaxRows are the credit rows for refunds: each refunded line gives back its
+// share of the line's net and VAT, by quantity. Refunds of sales from before VAT was
+// worked out per line give back their share of the sale's Tax. Refunded service
+// charges and delivery fees are outside VAT.
+func refundTaxRows(db *gorm.DB, refunds []Refund, taxNumber string) ([]TaxReportItem, error) {
+	if len(refunds) == 0 {
+		return nil, nil
+	}
+
+	refundIDs := make([]uint, 0, len(refunds))
+	saleIDs := make([]uint, 0, len(refunds))
+	for _, r := range refunds {
+		refundIDs = append(refundIDs, r.ID)
+		saleIDs = append(saleIDs, r.SaleID)
+	}
+
+	var sales []Sale
+	if err := db.Where("id IN ?", saleIDs).Find(&sales).Error; err != nil {
+		return nil, err
+	}
+	salesByID := make(map[uint]Sale, len(sales))
+	for _, s := range sales {
+		salesByID[s.ID] = s
+	}
+
+	var lines []struct {
+		RefundID uint
+		TaxClass string
+		TaxRate  float64
+		Net      float64
+		Tax      float64
+	}
+	if err := db.Table("refund_items").
+		Select("refund_items.refund_id, sale_items.tax_class, sale_items.tax_rate, "+
+			"SUM(sale_items.net_amount * refund_items.quantity / sale_items.quantity) AS net, "+
+			"SUM(sale_items.tax * refund_items.quantity / sale_items.quantity) AS tax").
+		Joins("JOIN sale_items ON sale_items.id = refund_items.sale_item_id").
+		Where("refund_items.refund_id IN ? AND sale_items.tax_class <> '' AND sale_items.quantity > 0", refundIDs).
+		Group("refund_items.refund_id, sale_items.tax_class, sale_items.tax_rate").
+		Order("refund_items.refund_id, sale_items.tax_rate DESC").
+		Scan(&lines).Error; err != nil {
+		return nil, err
+	}
+	byRefund := make(map[uint][]int)
+	for i, l := range lines {
+		byRefund[l.RefundID] = append(byRefund[l.RefundID], i)
+	}
+
+	var rows []TaxReportItem
+	for _, r := range refunds {
+		s := salesByID[r.SaleID]
+		row := TaxReportItem{
+			Date:          r.CreatedAt.Format("2006-01-02 15:04:05"),
+			InvoiceNumber: fmt.Sprintf("INV-%d", r.SaleID),
+			Customer:      s.CustomerName,
+			TaxID:         taxNumber,
+			PaymentMethod: s.PaymentMethod,
+			Status:        "REFUND",
+		}
+
+		idx, ok := byRefund[r.ID]
+		if !ok {
+			goods := r.Amount - r.FeesAmount
+			if goods <= 0 {
+				continue
+			}
+			share := 0.0
+			if itemsTotal := s.Total - s.ServiceCharge - s.DeliveryFee; itemsTotal > 0 {
+				share = goods / itemsTotal
+			}
+			row.TaxClass = "UNCLASSIFIED"
+			row.VAT = -roundMoney(s.Tax * share)
+			row.Gross = -roundMoney(goods)
+			row.Net = row.Gross - row.VAT
+			rows = append(rows, row)
+			continue
+		}
+		for _, i := range idx {
+			l := lines[i]
+			row.TaxClass = l.TaxClass
+			row.TaxRate = l.TaxRate
+			row.Net = -roundMoney(l.Net)
+			row.VAT = -roundMoney(l.Tax)
+			row.Gross = row.Net + row.VAT
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
 }
 
 // GetAuditTrail fetches rich activity logs with optional filtering
@@ -2,6 +2,7 @@
 package sale
 
 import (
+	"strconv"
 	"strings"
 
 	"fmt"
@@ -25,9 +26,62 @@ func handleSaleError(err error) error {
 	if strings.Contains(msg, "insufficient payment") {
 		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
 	}
+	if strings.Contains(msg, "gift card") || strings.Contains(msg, "GIFT_CARD payment") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
+	if strings.Contains(msg, "prices have changed") {
+		return fiber.NewError(fiber.StatusConflict, msg)
+	}
+	if strings.Contains(msg, "too many wrong manager PINs") {
+		return fiber.NewError(fiber.StatusTooManyRequests, msg)
+	}
+	if strings.Contains(msg, "manager approval") || strings.Contains(msg, "manager PIN") || strings.Contains(msg, "approver token") {
+		return fiber.NewError(fiber.StatusForbidden, msg)
+	}
+	if strings.Contains(msg, "discount") || strings.Contains(msg, "price override") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
 	if strings.Contains(msg, "not found") {
 		return fiber.NewError(fiber.StatusNotFound, msg)
 	}
+	if strings.Contains(msg, "credit limit") || strings.Contains(msg, "CREDIT payment") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
+	if strings.Contains(msg, "modifier") || strings.Contains(msg, "variant") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
+	if strings.Contains(msg, "loyalty") || strings.Contains(msg, "LOYALTY payment") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
+	if strings.Contains(msg, "tip cannot") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
+	if strings.Contains(msg, "receipt") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
+	if strings.Contains(msg, "quotation") || strings.Contains(msg, "valid_until") || strings.Contains(msg, "reservation") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
+	if strings.Contains(msg, "layaway") || strings.Contains(msg, "deposit") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
+	if strings.Contains(msg, "online order") || strings.Contains(msg, "QR code") || strings.Contains(msg, "not on the menu") ||
+		strings.Contains(msg, "payment reference") || strings.Contains(msg, "payment provider") || strings.Contains(msg, "payment could not") || strings.Contains(msg, "does not cover") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
+	if strings.Contains(msg, "station") || strings.Contains(msg, "preparation status") || strings.Contains(msg, "course") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
+	if strings.Contains(msg, "whole number") || strings.Contains(msg, "decimal places") || strings.Contains(msg, "quantity") ||
+		strings.Contains(msg, "PLU") || strings.Contains(msg, "scale label") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
+	if strings.Contains(msg, "another rider") {
+		return fiber.NewError(fiber.StatusForbidden, msg)
+	}
+	if strings.Contains(msg, "deliver") || strings.Contains(msg, "rider") || strings.Contains(msg, "cash") {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, msg)
+	}
 
 	return fiber.NewError(fiber.StatusInternalServerError, msg)
 }
@@ -62,7 +116,7 @@ func CreateDraftHandler(db *gorm.DB) fiber.Handler {
 
 		sale, err := CreateDraft(db, bizID, claims.TenantID, outletID, claims.UserID, req)
 		if err != nil {
-			return fiber.ErrInternalServerError
+			return handleSaleError(err)
 		}
 
 		// Broadcast to KDS if business has the module
@@ -72,7 +126,7 @@ func CreateDraftHandler(db *gorm.DB) fiber.Handler {
 
 		// Trigger Silent Printing if there are items and an agent is connected
 		if len(sale.SaleItems) > 0 {
-			printing.PrintKitchenOrder(db, claims.TenantID, outletID, sale)
+			printing.PrintKitchenOrder(db, claims.TenantID, outletID, kitchenTicketFor(sale))
 		}
 
 		return c.Status(fiber.StatusCreated).JSON(sale)
@@ -148,6 +202,60 @@ func RemoveItemHandler(db *gorm.DB) fiber.Handler {
 			return handleSaleError(err)
 		}
 
+		GlobalDisplayHub.PushCart(result.Sale, result.Items)
+
+		return c.JSON(map[string]any{
+			"sale":  result.Sale,
+			"items": result.Items,
+		})
+	}
+}
+
+// AdjustItemHandler godoc
+// @Summary Discount or override the price of a sale item
+// @Description Set a line discount or unit price override on a draft/held sale. Adjustments above the business's override threshold need a manager PIN or approver token unless made by a manager or owner.
+// @Tags Sales
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Param item_id path uint true "Sale Item ID"
+// @Param body body AdjustItemRequest true "Discount, price override and approval"
+// @Success 200 {object} map[string]any{sale=Sale,items=[]SaleItem}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string "Manager approval required"
+// @Failure 404 {object} map[string]string
+// @Router /sales/{sale_id}/items/{item_id} [put]
+func AdjustItemHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil || saleID <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		itemID, err := c.ParamsInt("item_id")
+		if err != nil || itemID <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid item ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req AdjustItemRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+		if req.Discount == nil && req.PriceOverride == nil {
+			return fiber.NewError(fiber.StatusBadRequest, "discount or price_override is required")
+		}
+
+		result, err := AdjustSaleItem(db, uint(saleID), uint(itemID), bizID, claims.UserID, req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		GlobalDisplayHub.PushCart(result.Sale, result.Items)
+
 		return c.JSON(map[string]any{
 			"sale":  result.Sale,
 			"items": result.Items,
@@ -198,17 +306,20 @@ func AddItemHandler(db *gorm.DB) fiber.Handler {
 		}
 
 		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
 
 		var req AddItemRequest
 		if err := c.BodyParser(&req); err != nil {
 			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
 		}
 
-		result, err := AddItemToSale(db, uint(saleID), bizID, req.ProductID, req.Quantity)
+		result, err := AddItemToSale(db, uint(saleID), bizID, claims.UserID, req)
 		if err != nil {
 			return handleSaleError(err)
 		}
 
+		GlobalDisplayHub.PushCart(result.Sale, result.Items)
+
 		// Broadcast update to KDS
 		if subscription.HasModule(db, bizID, subscription.ModuleKDS) {
 			GlobalKDSHub.BroadcastOrder(bizID, EventOrderUpdated, result)
@@ -251,6 +362,8 @@ func CompleteSaleHandler(db *gorm.DB) fiber.Handler {
 			return handleSaleError(err)
 		}
 
+		GlobalDisplayHub.PushPaid(receipt)
+
 		// Broadcast to KDS that order is paid (remove from screen)
 		if subscription.HasModule(db, bizID, subscription.ModuleKDS) {
 			GlobalKDSHub.BroadcastOrder(bizID, EventOrderPaid, saleID)
@@ -316,6 +429,8 @@ func VoidSaleHandler(db *gorm.DB) fiber.Handler {
 			return handleSaleError(err)
 		}
 
+		GlobalDisplayHub.PushIdle(sale.TerminalID)
+
 		// Broadcast to KDS that order is voided
 		if subscription.HasModule(db, bizID, subscription.ModuleKDS) {
 			GlobalKDSHub.BroadcastOrder(bizID, EventOrderVoided, saleID)
@@ -325,6 +440,170 @@ func VoidSaleHandler(db *gorm.DB) fiber.Handler {
 	}
 }
 
+// RefundSale godoc
+// @Summary Refund all or part of a completed sale
+// @Description Returns items (restocking them unless told otherwise) and pays the money back per tender
+// @Tags Sales
+// @Security BearerAuth
+// @Param sale_id path uint true "Sale ID"
+// @Param body body RefundSaleRequest true "Lines, reason code and optional tenders"
+// @Success 201 {object} Refund
+// @Failure 400 {object} map[string]string
+// @Router /sales/{sale_id}/refund [post]
+func RefundSaleHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req RefundSaleRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+		if req.ReasonCode == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "reason_code is required")
+		}
+
+		var shiftID *uint
+		if sid, ok := c.Locals("shift_id").(uint); ok {
+			shiftID = &sid
+		}
+
+		refund, err := RefundSale(db, uint(saleID), bizID, claims.UserID, shiftID, req)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				return fiber.NewError(fiber.StatusNotFound, err.Error())
+			}
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(refund)
+	}
+}
+
+// ListRefunds godoc
+// @Summary List refunds recorded against a sale
+// @Tags Sales
+// @Security BearerAuth
+// @Param sale_id path uint true "Sale ID"
+// @Success 200 {array} Refund
+// @Router /sales/{sale_id}/refunds [get]
+func ListRefundsHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		refunds, err := ListRefunds(db, uint(saleID), bizID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(refunds)
+	}
+}
+
+// SyncSalesHandler godoc
+// @Summary Upload sales made offline
+// @Description Records a batch of sales a device made while offline, in order. Each comes back CREATED, DUPLICATE (already uploaded) or REJECTED with a reason. Stock a sale took that is no longer on hand is flagged for review rather than refused.
+// @Tags Sales
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body SyncRequest true "Queued sales"
+// @Success 200 {array} SyncResult
+// @Failure 400 {object} map[string]string
+// @Router /sales/sync [post]
+func SyncSalesHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req SyncRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+		if len(req.Sales) == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "no sales to sync")
+		}
+		if len(req.Sales) > 200 {
+			return fiber.NewError(fiber.StatusBadRequest, "at most 200 sales can be synced at a time")
+		}
+
+		outletID := uint(0)
+		if claims.OutletID != nil {
+			outletID = *claims.OutletID
+		}
+
+		return c.JSON(SyncSales(db, bizID, claims.TenantID, outletID, claims.UserID, req))
+	}
+}
+
+// ListShortfallsHandler godoc
+// @Summary List stock shortfalls from offline sales
+// @Tags Sales
+// @Security BearerAuth
+// @Param all query bool false "Include resolved shortfalls"
+// @Success 200 {array} StockShortfall
+// @Router /sales/sync/shortfalls [get]
+func ListShortfallsHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		shortfalls, err := ListShortfalls(db, bizID, c.QueryBool("all", false))
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(shortfalls)
+	}
+}
+
+// ResolveShortfallHandler godoc
+// @Summary Mark a stock shortfall as reviewed
+// @Tags Sales
+// @Security BearerAuth
+// @Accept json
+// @Param id path uint true "Shortfall ID"
+// @Param body body ResolveShortfallRequest false "Review note"
+// @Success 200 {object} StockShortfall
+// @Failure 404 {object} map[string]string
+// @Router /sales/sync/shortfalls/{id}/resolve [post]
+func ResolveShortfallHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid shortfall ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req ResolveShortfallRequest
+		_ = c.BodyParser(&req)
+
+		flag, err := ResolveShortfall(db, uint(id), bizID, claims.UserID, req.Note)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				return fiber.NewError(fiber.StatusNotFound, err.Error())
+			}
+			if strings.Contains(err.Error(), "already resolved") {
+				return fiber.NewError(fiber.StatusConflict, err.Error())
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(flag)
+	}
+}
+
 // ListSales godoc
 // @Summary List sales with filters
 // @Tags Sales
@@ -450,8 +729,20 @@ func KDSWebsocketHandler(db *gorm.DB) fiber.Handler {
 			return
 		}
 
+		// ?station_id= subscribes a station screen to only the items routed to it
+		var stationID uint
+		if id, err := strconv.Atoi(conn.Query("station_id", "0")); err == nil && id > 0 {
+			if _, err := GetStation(db, uint(id), bizID); err != nil {
+				conn.WriteJSON(fiber.Map{"error": err.Error()})
+				conn.Close()
+				return
+			}
+			stationID = uint(id)
+		}
+
 		kdsConn := &KDSConn{
 			BusinessID: bizID,
+			StationID:  stationID,
 			Conn:       conn,
 		}
 
@@ -476,11 +767,12 @@ func UpdateSalePrepStatusHandler(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		saleID, _ := c.ParamsInt("sale_id")
 		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
 
 		var req struct {
 			Status PrepStatus `json:"status"`
 		}
-		if err := c.BodyParser(&req); err != nil {
+		if err := c.BodyParser(&req); err != nil || !validPrepStatus(req.Status) {
 			return fiber.NewError(fiber.StatusBadRequest, "invalid status")
 		}
 
@@ -494,8 +786,8 @@ func UpdateSalePrepStatusHandler(db *gorm.DB) fiber.Handler {
 			return err
 		}
 
-		// Update all items in this sale as well for consistency
-		db.Model(&SaleItem{}).Where("sale_id = ?", sale.ID).Update("preparation_status", req.Status)
+		// Update all items in this sale as well for consistency, stamping when each got there
+		setItemsPrepStatus(db.Where("sale_id = ?", sale.ID), req.Status, claims.UserID)
 
 		// Broadcast update to KDS
 		GlobalKDSHub.BroadcastOrder(bizID, "ORDER_PREP_UPDATE", fiber.Map{
@@ -513,11 +805,12 @@ func UpdateItemPrepStatusHandler(db *gorm.DB) fiber.Handler {
 		saleID, _ := c.ParamsInt("sale_id")
 		itemID, _ := c.ParamsInt("item_id")
 		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
 
 		var req struct {
 			Status PrepStatus `json:"status"`
 		}
-		if err := c.BodyParser(&req); err != nil {
+		if err := c.BodyParser(&req); err != nil || !validPrepStatus(req.Status) {
 			return fiber.NewError(fiber.StatusBadRequest, "invalid status")
 		}
 
@@ -526,18 +819,30 @@ func UpdateItemPrepStatusHandler(db *gorm.DB) fiber.Handler {
 			return fiber.NewError(fiber.StatusNotFound, "item not found")
 		}
 
-		if err := db.Model(&item).Update("preparation_status", req.Status).Error; err != nil {
+		if _, err := setItemsPrepStatus(db.Where("id = ?", item.ID), req.Status, claims.UserID); err != nil {
 			return err
 		}
 
+		// The sale is ready once all of its items are
+		var sale Sale
+		saleStatus := PrepStatus("")
+		if err := db.Where("id = ? AND business_id = ?", uint(saleID), bizID).First(&sale).Error; err == nil {
+			saleStatus, _ = syncSalePrepStatus(db, &sale)
+		}
+
 		// Broadcast update
-		GlobalKDSHub.BroadcastOrder(bizID, "ITEM_PREP_UPDATE", fiber.Map{
-			"sale_id": saleID,
-			"item_id": itemID,
-			"status":  req.Status,
-		})
+		update := fiber.Map{
+			"sale_id":     saleID,
+			"item_id":     itemID,
+			"status":      req.Status,
+			"sale_status": saleStatus,
+		}
+		if item.StationID != nil {
+			update["station_id"] = *item.StationID
+		}
+		GlobalKDSHub.BroadcastOrder(bizID, "ITEM_PREP_UPDATE", update)
 
-		return c.JSON(fiber.Map{"status": "success", "preparation_status": req.Status})
+		return c.JSON(fiber.Map{"status": "success", "preparation_status": req.Status, "sale_status": saleStatus})
 	}
 }
 
@@ -614,6 +919,31 @@ func ProductProfitReportHandler(db *gorm.DB) fiber.Handler {
 	}
 }
 
+// GratuityReportHandler godoc
+// @Summary Get service charges and tips by staff member
+// @Description Totals the service charges and tips on each staff member's sales, for paying out tips
+// @Tags Sales
+// @Security BearerAuth
+// @Param from query string false "From date (YYYY-MM-DD)"
+// @Param to query string false "To date (YYYY-MM-DD)"
+// @Success 200 {array} StaffGratuity
+// @Router /sales/reports/gratuities [get]
+func GratuityReportHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		report, err := GetStaffGratuityReport(db, bizID, c.Query("from"), c.Query("to"))
+		if err != nil {
+			if strings.Contains(err.Error(), "invalid") {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			return fiber.ErrInternalServerError
+		}
+
+		return c.JSON(report)
+	}
+}
+
 // MonthlyReportHandler godoc
 // @Summary Get monthly financial summary
 // @Description Retrieve revenue, cost, and profit grouped by month
@@ -636,3 +966,186 @@ func MonthlyReportHandler(db *gorm.DB) fiber.Handler {
 		return c.JSON(report)
 	}
 }
+
+// GetReceiptHandler godoc
+// @Summary Get a sale's receipt
+// @Description Renders the receipt of a completed sale with the business's receipt template: HTML for the web, PDF to download or email, or ESC/POS bytes for a print agent. Marked COPY once the receipt has been printed.
+// @Tags Sales
+// @Security BearerAuth
+// @Produce html
+// @Produce application/pdf
+// @Produce application/octet-stream
+// @Param sale_id path uint true "Sale ID"
+// @Param format query string false "html (default), pdf or escpos"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /sales/{sale_id}/receipt [get]
+func GetReceiptHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil || saleID <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		format := c.Query("format", ReceiptFormatHTML)
+		var contentType string
+		switch format {
+		case ReceiptFormatHTML:
+			contentType = fiber.MIMETextHTMLCharsetUTF8
+		case ReceiptFormatPDF:
+			contentType = "application/pdf"
+			c.Set("Content-Disposition", fmt.Sprintf("inline; filename=receipt_%d.pdf", saleID))
+		case ReceiptFormatESCPOS:
+			contentType = fiber.MIMEOctetStream
+		default:
+			return fiber.NewError(fiber.StatusBadRequest, "format must be html, pdf or escpos")
+		}
+
+		content, err := RenderSaleReceipt(db, uint(saleID), bizID, format)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		c.Set("Content-Type", contentType)
+		return c.Send(content)
+	}
+}
+
+// PrintReceiptHandler godoc
+// @Summary Print a sale's receipt
+// @Description Sends the receipt to the receipt printers of the sale's outlet through the print agent. The first print is the original; reprints are marked COPY.
+// @Tags Sales
+// @Security BearerAuth
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Success 200 {object} printing.Receipt
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /sales/{sale_id}/receipt/print [post]
+func PrintReceiptHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil || saleID <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		receipt, err := PrintSaleReceipt(db, uint(saleID), bizID, claims.TenantID)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.JSON(receipt)
+	}
+}
+
+// ResendReceiptHandler godoc
+// @Summary Send a sale's receipt to the customer
+// @Description Emails the receipt link, and sends it on WhatsApp when the WHATSAPP_ALERTS module is active. Without an email or phone in the body, the customer's on the sale are used. Delivery happens in the background; follow it on the deliveries endpoint.
+// @Tags Sales
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Param body body ResendReceiptRequest false "Where to send it"
+// @Success 202 {array} ReceiptDelivery
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /sales/{sale_id}/receipt/send [post]
+func ResendReceiptHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil || saleID <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req ResendReceiptRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+			}
+		}
+
+		deliveries, err := ResendReceipt(db, uint(saleID), bizID, claims.UserID, req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.Status(fiber.StatusAccepted).JSON(deliveries)
+	}
+}
+
+// ListReceiptDeliveriesHandler godoc
+// @Summary List a sale's e-receipt deliveries
+// @Description Every email and WhatsApp receipt sent for the sale, newest first, with its delivery status.
+// @Tags Sales
+// @Security BearerAuth
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Success 200 {array} ReceiptDelivery
+// @Router /sales/{sale_id}/receipt/deliveries [get]
+func ListReceiptDeliveriesHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil || saleID <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		deliveries, err := ListReceiptDeliveries(db, uint(saleID), bizID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch receipt deliveries")
+		}
+
+		return c.JSON(deliveries)
+	}
+}
+
+// PublicReceiptHandler godoc
+// @Summary View a receipt from its public link
+// @Description Renders the receipt behind a signed e-receipt link. No login is needed; the link's signature is the proof.
+// @Tags Public
+// @Produce html
+// @Produce application/pdf
+// @Param token path string true "Signed receipt token"
+// @Param format query string false "html (default) or pdf"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Router /public/receipts/{token} [get]
+func PublicReceiptHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, bizID, err := ParseReceiptLinkToken(c.Params("token"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "receipt not found")
+		}
+
+		format := c.Query("format", ReceiptFormatHTML)
+		var contentType string
+		switch format {
+		case ReceiptFormatHTML:
+			contentType = fiber.MIMETextHTMLCharsetUTF8
+		case ReceiptFormatPDF:
+			contentType = "application/pdf"
+			c.Set("Content-Disposition", fmt.Sprintf("inline; filename=receipt_%d.pdf", saleID))
+		default:
+			return fiber.NewError(fiber.StatusBadRequest, "format must be html or pdf")
+		}
+
+		content, err := RenderCustomerReceipt(db, saleID, bizID, format)
+		if err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "receipt not found")
+		}
+
+		c.Set("Content-Type", contentType)
+		return c.Send(content)
+	}
+}
@@ -55,11 +55,13 @@ func AddItemWithReservationHandler(db *gorm.DB) fiber.Handler {
 		businessID := c.Locals("business_id").(uint)
 		cashierID := c.Locals("user_id").(uint)
 
-		result, err := AddItemToSaleWithReservation(db, uint(saleID), businessID, cashierID, req.ProductID, req.Quantity)
+		result, err := AddItemToSaleWithReservation(db, uint(saleID), businessID, cashierID, req)
 		if err != nil {
 			return fiber.NewError(fiber.StatusBadRequest, err.Error())
 		}
 
+		GlobalDisplayHub.PushCart(result.Sale, result.Items)
+
 		return c.JSON(fiber.Map{
 			"success": true,
 			"message": "item added to sale",
@@ -89,6 +91,8 @@ func CompleteSaleWithReservationHandler(db *gorm.DB) fiber.Handler {
 			return fiber.NewError(fiber.StatusBadRequest, err.Error())
 		}
 
+		GlobalDisplayHub.PushPaid(receipt)
+
 		return c.JSON(fiber.Map{
 			"success": true,
 			"message": "sale completed successfully",
@@ -200,6 +204,35 @@ func MergeBillsHandler(db *gorm.DB) fiber.Handler {
 	}
 }
 
+// SplitBillHandler splits a bill into several bills
+func SplitBillHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := strconv.Atoi(c.Params("sale_id"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		var req SplitBillRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid request body")
+		}
+
+		businessID := c.Locals("business_id").(uint)
+		userID := c.Locals("user_id").(uint)
+
+		result, err := SplitBill(db, uint(saleID), businessID, userID, req)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "bill split successfully",
+			"data":    result,
+		})
+	}
+}
+
 // VoidSaleWithReservationHandler voids a sale and handles reservations
 func VoidSaleWithReservationHandler(db *gorm.DB) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -0,0 +1,213 @@
+// internal/sale/courses.go
+package sale
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CourseStatus says whether a course is still held or has gone to the kitchen
+type CourseStatus string
+
+const (
+	CourseHeld  CourseStatus = "HELD"
+	CourseFired CourseStatus = "FIRED"
+)
+
+// CourseState is one course of a sale as the kitchen sees it
+type CourseState struct {
+	Course  int          `json:"course"`
+	Status  CourseStatus `json:"status"`
+	Items   int          `json:"items"`              // lines in the course
+	Held    int          `json:"held"`               // lines not fired yet
+	FiredAt *time.Time   `json:"fired_at,omitempty"` // latest fire of the course
+}
+
+// FireCourseResult is the sale after a course was fired, with the lines it released
+type FireCourseResult struct {
+	Sale  *Sale      `json:"sale"`
+	Fired []SaleItem `json:"fired"`
+}
+
+// CourseTimes is how long, on average, courses took to be fired
+type CourseTimes struct {
+	Course          int     `json:"course"`
+	Fired           int     `json:"fired"`                       // times the course was fired
+	AvgFromOpen     float64 `json:"avg_minutes_from_open"`       // sale opened to the course fired
+	AvgFromPrevious float64 `json:"avg_minutes_from_previous"`   // previous course fired to this one
+	FromPrevious    int     `json:"fires_after_previous_course"` // fires AvgFromPrevious is over
+}
+
+// held reports whether a line is waiting for its course to be fired
+func (i SaleItem) held() bool {
+	return i.Course > 0 && i.FiredAt == nil
+}
+
+// saleCourses summarises the courses of a sale's lines, in course order
+func saleCourses(items []SaleItem) []CourseState {
+	byCourse := map[int]*CourseState{}
+	for _, it := range items {
+		if it.Course <= 0 {
+			continue
+		}
+		cs, ok := byCourse[it.Course]
+		if !ok {
+			cs = &CourseState{Course: it.Course}
+			byCourse[it.Course] = cs
+		}
+		cs.Items++
+		if it.held() {
+			cs.Held++
+		} else if cs.FiredAt == nil || it.FiredAt.After(*cs.FiredAt) {
+			cs.FiredAt = it.FiredAt
+		}
+	}
+
+	courses := make([]CourseState, 0, len(byCourse))
+	for _, cs := range byCourse {
+		cs.Status = CourseFired
+		if cs.Held > 0 {
+			cs.Status = CourseHeld
+		}
+		courses = append(courses, *cs)
+	}
+	sort.Slice(courses, func(i, j int) bool { return courses[i].Course < courses[j].Course })
+	return courses
+}
+
+// FireCourse releases a course's held lines to the kitchen. Lines added to the course
+// after it was fired are held again until the next fire.
+func FireCourse(db *gorm.DB, saleID, businessID, userID uint, course int) (*FireCourseResult, error) {
+	if course <= 0 {
+		return nil, errors.New("course must be 1 or more")
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var sale Sale
+	if err := tx.First(&sale, "id = ? AND business_id = ? AND status IN ?", saleID, businessID, []SaleStatus{StatusDraft, StatusHeld}).Error; err != nil {
+		return nil, errors.New("sale not found or not open")
+	}
+
+	var held []SaleItem
+	tx.Where("sale_id = ? AND course = ? AND fired_at IS NULL", saleID, course).Find(&held)
+	if len(held) == 0 {
+		return nil, fmt.Errorf("course %d has nothing held to fire", course)
+	}
+
+	now := time.Now()
+	ids := make([]uint, len(held))
+	for i := range held {
+		ids[i] = held[i].ID
+		held[i].FiredAt = &now
+		if held[i].StationID != nil {
+			held[i].QueuedAt = &now
+		}
+	}
+	if err := tx.Model(&SaleItem{}).Where("id IN ?", ids).Update("fired_at", now).Error; err != nil {
+		return nil, err
+	}
+	// The kitchen's ticket clock starts when the course is fired
+	if err := tx.Model(&SaleItem{}).Where("id IN ? AND station_id IS NOT NULL", ids).Update("queued_at", now).Error; err != nil {
+		return nil, err
+	}
+
+	if err := LogActivity(tx, saleID, businessID, userID, ActionCourseFired, ActivityDetails{
+		Course:  course,
+		ItemIDs: ids,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	db.Where("sale_id = ?", saleID).Find(&sale.SaleItems)
+	sale.Courses = saleCourses(sale.SaleItems)
+
+	return &FireCourseResult{Sale: &sale, Fired: held}, nil
+}
+
+// GetCourseTimes reports, per course, how long after the sale was opened and after the
+// previous course it was fired, from the fires logged between from and to (YYYY-MM-DD)
+func GetCourseTimes(db *gorm.DB, businessID uint, from, to string) ([]CourseTimes, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, errors.New("invalid from date, use YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return nil, errors.New("invalid to date, use YYYY-MM-DD")
+	}
+
+	var logs []SaleActivityLog
+	if err := db.Where("business_id = ? AND action_type = ? AND created_at >= ? AND created_at < ?",
+		businessID, ActionCourseFired, start, end.AddDate(0, 0, 1)).
+		Order("sale_id ASC, created_at ASC").
+		Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return []CourseTimes{}, nil
+	}
+
+	saleIDs := []uint{}
+	for _, l := range logs {
+		if len(saleIDs) == 0 || saleIDs[len(saleIDs)-1] != l.SaleID {
+			saleIDs = append(saleIDs, l.SaleID)
+		}
+	}
+	var sales []Sale
+	db.Unscoped().Select("id, created_at").Where("id IN ?", saleIDs).Find(&sales)
+	opened := make(map[uint]time.Time, len(sales))
+	for _, s := range sales {
+		opened[s.ID] = s.CreatedAt
+	}
+
+	type totals struct {
+		fired, fromPrev  int
+		sumOpen, sumPrev float64
+	}
+	byCourse := map[int]*totals{}
+	var prevSale uint
+	var prevFire time.Time
+	for _, l := range logs {
+		var d ActivityDetails
+		if json.Unmarshal([]byte(l.Details), &d) != nil || d.Course <= 0 {
+			continue
+		}
+		t, ok := byCourse[d.Course]
+		if !ok {
+			t = &totals{}
+			byCourse[d.Course] = t
+		}
+		t.fired++
+		if at, ok := opened[l.SaleID]; ok {
+			t.sumOpen += l.CreatedAt.Sub(at).Minutes()
+		}
+		if prevSale == l.SaleID {
+			t.fromPrev++
+			t.sumPrev += l.CreatedAt.Sub(prevFire).Minutes()
+		}
+		prevSale, prevFire = l.SaleID, l.CreatedAt
+	}
+
+	report := make([]CourseTimes, 0, len(byCourse))
+	for course, t := range byCourse {
+		row := CourseTimes{Course: course, Fired: t.fired, FromPrevious: t.fromPrev}
+		row.AvgFromOpen = roundMoney(t.sumOpen / float64(t.fired))
+		if t.fromPrev > 0 {
+			row.AvgFromPrevious = roundMoney(t.sumPrev / float64(t.fromPrev))
+		}
+		report = append(report, row)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Course < report[j].Course })
+	return report, nil
+}
@@ -0,0 +1,65 @@
+// internal/sale/credit.go
+package sale
+
+import (
+	"errors"
+	"strings"
+
+	"pos-fiber-app/internal/customer"
+
+	"gorm.io/gorm"
+)
+
+// PaymentMethodCredit is the tender for sales put on a customer's credit (tab) account
+const PaymentMethodCredit = "CREDIT"
+
+func isCreditTender(method string) bool {
+	return strings.EqualFold(method, PaymentMethodCredit)
+}
+
+// attachCustomer links a sale to a customer of the business and snapshots their
+// name, phone and email onto the sale when none were typed in
+func attachCustomer(tx *gorm.DB, sale *Sale, customerID *uint) error {
+	if customerID == nil {
+		return nil
+	}
+	cust, err := customer.Get(tx, *customerID, sale.BusinessID)
+	if err != nil {
+		return err
+	}
+	sale.CustomerID = &cust.ID
+	if sale.CustomerName == "" {
+		sale.CustomerName = cust.Name
+	}
+	if sale.CustomerPhone == "" {
+		sale.CustomerPhone = cust.Phone
+	}
+	if sale.CustomerEmail == "" {
+		sale.CustomerEmail = cust.Email
+	}
+	return nil
+}
+
+// chargeCustomerCredit debits the CREDIT part of a sale's payments to the customer's
+// account. The customer's credit limit is enforced by the ledger, inside the sale's transaction.
+func chargeCustomerCredit(tx *gorm.DB, sale *Sale, payments []PaymentInfoRequest, userID uint) error {
+	var credit float64
+	for _, p := range payments {
+		if isCreditTender(p.Method) {
+			credit += p.Amount
+		}
+	}
+	if credit <= 0 {
+		return nil
+	}
+
+	if sale.CustomerID == nil {
+		return errors.New("a customer is required for CREDIT payments")
+	}
+	if roundMoney(credit) > roundMoney(sale.Total) {
+		return errors.New("CREDIT payment cannot exceed the sale total")
+	}
+
+	_, err := customer.ChargeCredit(tx, sale.BusinessID, *sale.CustomerID, sale.ID, credit, userID)
+	return err
+}
@@ -0,0 +1,290 @@
+// internal/sale/customer_display.go
+package sale
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"pos-fiber-app/internal/advert"
+
+	"github.com/gofiber/websocket/v2"
+	"gorm.io/gorm"
+)
+
+// DisplayEventType defines the messages sent to customer-facing displays
+type DisplayEventType string
+
+const (
+	DisplayCartUpdated DisplayEventType = "CART_UPDATED" // items scanned or removed
+	DisplaySalePaid    DisplayEventType = "SALE_PAID"    // totals and change due
+	DisplayIdle        DisplayEventType = "IDLE"         // cart cleared, adverts follow
+	DisplayAdvert      DisplayEventType = "ADVERT"       // next advert to show while idle
+)
+
+const (
+	displayAdvertInterval = 10 * time.Second // how long each advert stays up
+	displayAdvertRefresh  = 5 * time.Minute  // how long a business's adverts are cached
+)
+
+// DisplayEvent is the payload sent to a terminal's customer display
+type DisplayEvent struct {
+	Type       DisplayEventType `json:"type"`
+	TerminalID uint             `json:"terminal_id"`
+	Data       interface{}      `json:"data,omitempty"`
+}
+
+// DisplayLine is a cart line as the customer sees it
+type DisplayLine struct {
+	Name      string  `json:"name"`
+	Quantity  float64 `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	Total     float64 `json:"total"`
+}
+
+// DisplayCart is the running cart of a sale, and once paid the change due
+type DisplayCart struct {
+	SaleID        uint          `json:"sale_id"`
+	Lines         []DisplayLine `json:"lines"`
+	Items         int           `json:"items"` // lines in the cart
+	Subtotal      float64       `json:"subtotal"`
+	Discount      float64       `json:"discount"`
+	Tax           float64       `json:"tax"`
+	ServiceCharge float64       `json:"service_charge,omitempty"`
+	Total         float64       `json:"total"`
+	Paid          float64       `json:"paid,omitempty"`
+	Change        float64       `json:"change,omitempty"`
+}
+
+// CustomerDisplayHub manages the customer-facing screens of the tills, and rotates the
+// business's adverts on the ones with no sale going on
+type CustomerDisplayHub struct {
+	// Map TerminalID -> map of connections
+	Clients    map[uint]map[*websocket.Conn]*DisplayConn
+	Broadcast  chan DisplayEvent
+	Register   chan *DisplayConn
+	Unregister chan *DisplayConn
+	mu         sync.RWMutex
+
+	db      *gorm.DB
+	busy    map[uint]bool // terminals with a cart on screen
+	next    map[uint]int  // next advert to show, per terminal
+	adverts map[uint]cachedAdverts
+}
+
+type DisplayConn struct {
+	BusinessID uint
+	TerminalID uint
+	Conn       *websocket.Conn
+}
+
+type cachedAdverts struct {
+	adverts  []advert.Advert
+	loadedAt time.Time
+}
+
+// GlobalDisplayHub is nil until StartCustomerDisplayHub runs
+var GlobalDisplayHub *CustomerDisplayHub
+
+// StartCustomerDisplayHub starts serving customer displays and rotating adverts on idle ones
+func StartCustomerDisplayHub(db *gorm.DB) {
+	GlobalDisplayHub = &CustomerDisplayHub{
+		Clients:    make(map[uint]map[*websocket.Conn]*DisplayConn),
+		Broadcast:  make(chan DisplayEvent, 64),
+		Register:   make(chan *DisplayConn),
+		Unregister: make(chan *DisplayConn),
+		db:         db,
+		busy:       make(map[uint]bool),
+		next:       make(map[uint]int),
+		adverts:    make(map[uint]cachedAdverts),
+	}
+	go GlobalDisplayHub.Run()
+}
+
+func (h *CustomerDisplayHub) Run() {
+	ticker := time.NewTicker(displayAdvertInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case client := <-h.Register:
+			h.mu.Lock()
+			if h.Clients[client.TerminalID] == nil {
+				h.Clients[client.TerminalID] = make(map[*websocket.Conn]*DisplayConn)
+			}
+			h.Clients[client.TerminalID][client.Conn] = client
+			h.mu.Unlock()
+			log.Printf("[DISPLAY] Client registered for terminal %d", client.TerminalID)
+
+		case client := <-h.Unregister:
+			h.mu.Lock()
+			if connections, ok := h.Clients[client.TerminalID]; ok {
+				if _, ok := connections[client.Conn]; ok {
+					delete(connections, client.Conn)
+					client.Conn.Close()
+					if len(connections) == 0 {
+						delete(h.Clients, client.TerminalID)
+						delete(h.busy, client.TerminalID)
+						delete(h.next, client.TerminalID)
+					}
+				}
+			}
+			h.mu.Unlock()
+			log.Printf("[DISPLAY] Client unregistered for terminal %d", client.TerminalID)
+
+		case event := <-h.Broadcast:
+			switch event.Type {
+			case DisplayCartUpdated:
+				cart, _ := event.Data.(DisplayCart)
+				h.busy[event.TerminalID] = len(cart.Lines) > 0
+			case DisplaySalePaid, DisplayIdle:
+				h.busy[event.TerminalID] = false
+			}
+			h.send(event)
+
+		case <-ticker.C:
+			h.rotateAdverts()
+		}
+	}
+}
+
+// send writes an event to every display of its terminal
+func (h *CustomerDisplayHub) send(event DisplayEvent) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	connections := h.Clients[event.TerminalID]
+	if len(connections) == 0 {
+		return
+	}
+	message, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	for conn := range connections {
+		if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			log.Printf("[DISPLAY ERROR] Broadcast error: %v", err)
+			conn.Close()
+		}
+	}
+}
+
+// rotateAdverts moves every idle display on to its business's next active advert. A
+// display showing a cart, or just paid (until the next tick), is left alone.
+func (h *CustomerDisplayHub) rotateAdverts() {
+	h.mu.RLock()
+	idle := map[uint]uint{} // terminal -> business
+	for terminalID, connections := range h.Clients {
+		if h.busy[terminalID] {
+			continue
+		}
+		for _, client := range connections {
+			idle[terminalID] = client.BusinessID
+			break
+		}
+	}
+	h.mu.RUnlock()
+
+	for terminalID, businessID := range idle {
+		adverts := h.businessAdverts(businessID)
+		if len(adverts) == 0 {
+			continue
+		}
+		i := h.next[terminalID] % len(adverts)
+		h.next[terminalID] = i + 1
+		h.send(DisplayEvent{Type: DisplayAdvert, TerminalID: terminalID, Data: adverts[i]})
+	}
+}
+
+// businessAdverts returns the business's and the global active adverts, cached for a while
+func (h *CustomerDisplayHub) businessAdverts(businessID uint) []advert.Advert {
+	cached, ok := h.adverts[businessID]
+	if ok && time.Since(cached.loadedAt) < displayAdvertRefresh {
+		return cached.adverts
+	}
+	adverts, err := advert.NewService(h.db).GetAdverts(&businessID)
+	if err != nil {
+		log.Printf("[DISPLAY ERROR] loading adverts for business %d: %v", businessID, err)
+		return cached.adverts
+	}
+	h.adverts[businessID] = cachedAdverts{adverts: adverts, loadedAt: time.Now()}
+	return adverts
+}
+
+// displayCart builds what the customer sees of a sale
+func displayCart(sale *Sale, items []SaleItem) DisplayCart {
+	cart := DisplayCart{
+		SaleID:        sale.ID,
+		Lines:         make([]DisplayLine, 0, len(items)),
+		Subtotal:      sale.Subtotal,
+		Discount:      sale.Discount,
+		Tax:           sale.Tax,
+		ServiceCharge: sale.ServiceCharge,
+		Total:         sale.Total,
+	}
+	cart.Items = len(items)
+	for _, it := range items {
+		cart.Lines = append(cart.Lines, DisplayLine{
+			Name:      it.ProductName,
+			Quantity:  it.Quantity,
+			UnitPrice: it.UnitPrice,
+			Total:     it.TotalPrice,
+		})
+	}
+	return cart
+}
+
+// PushCart sends a sale's cart to the customer display of the terminal it is rung up on
+func (h *CustomerDisplayHub) PushCart(sale *Sale, items []SaleItem) {
+	if h == nil || sale == nil || sale.TerminalID == 0 {
+		return
+	}
+	h.Broadcast <- DisplayEvent{Type: DisplayCartUpdated, TerminalID: sale.TerminalID, Data: displayCart(sale, items)}
+}
+
+// PushPaid sends the paid total and change due of a completed sale to its terminal's display
+func (h *CustomerDisplayHub) PushPaid(receipt *SaleReceipt) {
+	if h == nil || receipt == nil || receipt.Sale == nil || receipt.Sale.TerminalID == 0 {
+		return
+	}
+	cart := displayCart(receipt.Sale, receipt.Items)
+	cart.Change = receipt.Change
+	cart.Paid = roundMoney(receipt.Sale.Total + receipt.Sale.Tip + receipt.Change)
+	h.Broadcast <- DisplayEvent{Type: DisplaySalePaid, TerminalID: receipt.Sale.TerminalID, Data: cart}
+}
+
+// PushIdle clears a terminal's display, e.g. when its sale is voided or held
+func (h *CustomerDisplayHub) PushIdle(terminalID uint) {
+	if h == nil || terminalID == 0 {
+		return
+	}
+	h.Broadcast <- DisplayEvent{Type: DisplayIdle, TerminalID: terminalID}
+}
+
+// checkTerminal makes sure a terminal a sale is rung up on is one of the tenant's
+func checkTerminal(db *gorm.DB, tenantID string, terminalID uint) error {
+	if terminalID == 0 {
+		return nil
+	}
+	var count int64
+	db.Table("terminals").Where("id = ? AND tenant_id = ? AND active = ?", terminalID, tenantID, true).Count(&count)
+	if count == 0 {
+		return errors.New("terminal not found")
+	}
+	return nil
+}
+
+// openTerminalCart returns the open draft last worked on at a terminal, if any
+func openTerminalCart(db *gorm.DB, businessID, terminalID uint) *Sale {
+	var sale Sale
+	err := db.Preload("SaleItems").
+		Where("business_id = ? AND terminal_id = ? AND status = ?", businessID, terminalID, StatusDraft).
+		Order("updated_at DESC").
+		First(&sale).Error
+	if err != nil {
+		return nil
+	}
+	return &sale
+}
@@ -0,0 +1,71 @@
+// internal/sale/customer_display_controller.go
+package sale
+
+import (
+	"strconv"
+
+	"pos-fiber-app/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"gorm.io/gorm"
+)
+
+// CustomerDisplayWebsocketHandler handles the connection for a till's customer-facing
+// screen. It follows the drafts rung up with that terminal_id: CART_UPDATED as items are
+// added or removed, SALE_PAID with the change due, then ADVERT every few seconds until
+// the next sale starts.
+func CustomerDisplayWebsocketHandler(db *gorm.DB) fiber.Handler {
+	return websocket.New(func(conn *websocket.Conn) {
+		bizIDVal := conn.Locals("current_business_id")
+		claims, _ := conn.Locals("user").(*types.UserClaims)
+		if bizIDVal == nil || claims == nil {
+			conn.WriteJSON(fiber.Map{"error": "missing business id"})
+			conn.Close()
+			return
+		}
+		if GlobalDisplayHub == nil {
+			conn.WriteJSON(fiber.Map{"error": "customer displays are not available"})
+			conn.Close()
+			return
+		}
+
+		bizID := bizIDVal.(uint)
+
+		terminalID, err := strconv.Atoi(conn.Query("terminal_id"))
+		if err != nil || terminalID <= 0 {
+			conn.WriteJSON(fiber.Map{"error": "terminal_id is required"})
+			conn.Close()
+			return
+		}
+		if err := checkTerminal(db, claims.TenantID, uint(terminalID)); err != nil {
+			conn.WriteJSON(fiber.Map{"error": err.Error()})
+			conn.Close()
+			return
+		}
+
+		displayConn := &DisplayConn{
+			BusinessID: bizID,
+			TerminalID: uint(terminalID),
+			Conn:       conn,
+		}
+
+		GlobalDisplayHub.Register <- displayConn
+
+		defer func() {
+			GlobalDisplayHub.Unregister <- displayConn
+		}()
+
+		// A display switched on mid-sale picks up the cart in progress
+		if sale := openTerminalCart(db, bizID, uint(terminalID)); sale != nil {
+			GlobalDisplayHub.PushCart(sale, sale.SaleItems)
+		}
+
+		for {
+			_, _, err := conn.ReadMessage()
+			if err != nil {
+				break
+			}
+		}
+	})
+}
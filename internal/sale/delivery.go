@@ -0,0 +1,558 @@
+// internal/sale/delivery.go
+package sale
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"pos-fiber-app/internal/shift"
+
+	"gorm.io/gorm"
+)
+
+// PaymentMethodCashOnDelivery is the tender for delivery orders the rider collects
+// payment for at the door. The cash reaches the till when the rider hands it in.
+const PaymentMethodCashOnDelivery = "CASH_ON_DELIVERY"
+
+// RoleRider is the user role of delivery riders
+const RoleRider = "RIDER"
+
+type DispatchStatus string
+
+const (
+	DispatchPending   DispatchStatus = "PENDING"   // waiting for a rider
+	DispatchAssigned  DispatchStatus = "ASSIGNED"  // rider assigned, not yet collected
+	DispatchPickedUp  DispatchStatus = "PICKED_UP" // on the way
+	DispatchDelivered DispatchStatus = "DELIVERED"
+	DispatchFailed    DispatchStatus = "FAILED" // could not be delivered; can be assigned again
+)
+
+// Delivery is the delivery details of a sale with order type "delivery"
+type Delivery struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	BusinessID    uint           `gorm:"index" json:"business_id"`
+	OutletID      uint           `gorm:"index" json:"outlet_id"`
+	SaleID        uint           `gorm:"uniqueIndex" json:"sale_id"`
+	Address       string         `gorm:"type:text" json:"address"`
+	Landmark      string         `gorm:"size:200" json:"landmark,omitempty"`
+	CustomerName  string         `gorm:"size:150" json:"customer_name,omitempty"`
+	CustomerPhone string         `gorm:"size:30" json:"customer_phone"`
+	Fee           float64        `gorm:"type:decimal(12,2);default:0" json:"fee"`
+	Notes         string         `gorm:"type:text" json:"notes,omitempty"`
+	Status        DispatchStatus `gorm:"type:varchar(20);default:'PENDING';index" json:"status"`
+	RiderID       *uint          `gorm:"index" json:"rider_id,omitempty"`
+	RiderName     string         `json:"rider_name,omitempty"`
+	FailureReason string         `gorm:"type:text" json:"failure_reason,omitempty"`
+	Attempts      int            `gorm:"default:0" json:"attempts"` // times a rider was sent out
+
+	// Cash on delivery: what the rider should have collected, what they say they did
+	// and the hand-in that settled it
+	CashDue       float64 `gorm:"type:decimal(12,2);default:0" json:"cash_due"`
+	CashCollected float64 `gorm:"type:decimal(12,2);default:0" json:"cash_collected"`
+	RemittanceID  *uint   `gorm:"index" json:"remittance_id,omitempty"`
+
+	AssignedAt  *time.Time `json:"assigned_at,omitempty"`
+	PickedUpAt  *time.Time `json:"picked_up_at,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	FailedAt    *time.Time `json:"failed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// RiderRemittance is cash on delivery a rider handed in at the till
+type RiderRemittance struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	BusinessID uint      `gorm:"index" json:"business_id"`
+	RiderID    uint      `gorm:"index" json:"rider_id"`
+	ShiftID    *uint     `gorm:"index" json:"shift_id,omitempty"` // the drawer the cash went into
+	Expected   float64   `gorm:"type:decimal(12,2)" json:"expected"`
+	Amount     float64   `gorm:"type:decimal(12,2)" json:"amount"`
+	Variance   float64   `gorm:"type:decimal(12,2)" json:"variance"` // Amount - Expected
+	Deliveries int       `json:"deliveries"`
+	ReceivedBy uint      `json:"received_by"`
+	Notes      string    `gorm:"type:text" json:"notes,omitempty"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
+
+type DeliveryRequest struct {
+	Address       string  `json:"address" validate:"required"`
+	Landmark      string  `json:"landmark,omitempty"`
+	CustomerName  string  `json:"customer_name,omitempty"`
+	CustomerPhone string  `json:"customer_phone" validate:"required"`
+	Fee           float64 `json:"fee" validate:"gte=0"`
+	Notes         string  `json:"notes,omitempty"`
+}
+
+type AssignRiderRequest struct {
+	RiderID uint `json:"rider_id" validate:"required"`
+}
+
+// DeliveryStatusRequest moves a delivery along. CashCollected is what the rider took at
+// the door; it defaults to the cash on delivery due.
+type DeliveryStatusRequest struct {
+	Status        DispatchStatus `json:"status" validate:"required,oneof=PICKED_UP DELIVERED FAILED"`
+	Reason        string         `json:"reason,omitempty"` // required when FAILED
+	CashCollected *float64       `json:"cash_collected,omitempty"`
+}
+
+type RemitCashRequest struct {
+	Amount float64 `json:"amount" validate:"gte=0"`
+	Notes  string  `json:"notes,omitempty"`
+}
+
+type DeliveryFilters struct {
+	Status  DispatchStatus
+	RiderID *uint
+	Date    string // YYYY-MM-DD, by when the delivery was created
+}
+
+// RiderCashSummary is one rider's deliveries and cash on delivery over a period
+type RiderCashSummary struct {
+	RiderID       uint    `json:"rider_id"`
+	RiderName     string  `json:"rider_name"`
+	Deliveries    int     `json:"deliveries"`
+	Delivered     int     `json:"delivered"`
+	Failed        int     `json:"failed"`
+	CashDue       float64 `json:"cash_due"`
+	CashCollected float64 `json:"cash_collected"`
+	Remitted      float64 `json:"remitted"`
+	Variance      float64 `json:"variance"`    // over (+) or short (-) on hand-ins
+	Outstanding   float64 `json:"outstanding"` // collected and not yet handed in, at any time
+}
+
+// deliveryEditable are the sale states a delivery can be set up on
+var deliveryEditable = []SaleStatus{StatusDraft, StatusHeld, StatusPendingPayment, StatusCompleted}
+
+// CreateDelivery sets up the delivery of a sale and makes it a delivery order. The fee
+// goes on the sale's total, so it can only be charged before the sale is paid.
+func CreateDelivery(db *gorm.DB, saleID, businessID uint, req DeliveryRequest) (*Delivery, error) {
+	if strings.TrimSpace(req.Address) == "" || strings.TrimSpace(req.CustomerPhone) == "" {
+		return nil, errors.New("delivery address and customer phone are required")
+	}
+	if req.Fee < 0 {
+		return nil, errors.New("delivery fee cannot be negative")
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var sale Sale
+	if err := tx.First(&sale, "id = ? AND business_id = ? AND status IN ?", saleID, businessID, deliveryEditable).Error; err != nil {
+		return nil, errors.New("sale not found or cannot be delivered")
+	}
+
+	var count int64
+	tx.Model(&Delivery{}).Where("sale_id = ?", saleID).Count(&count)
+	if count > 0 {
+		return nil, errors.New("sale already has a delivery")
+	}
+
+	d := &Delivery{
+		BusinessID:    businessID,
+		OutletID:      sale.OutletID,
+		SaleID:        saleID,
+		Status:        DispatchPending,
+		CustomerName:  strings.TrimSpace(req.CustomerName),
+		CustomerPhone: strings.TrimSpace(req.CustomerPhone),
+	}
+	if d.CustomerName == "" {
+		d.CustomerName = sale.CustomerName
+	}
+	applyDeliveryRequest(d, req)
+
+	if err := setDeliveryFee(tx, &sale, req.Fee); err != nil {
+		return nil, err
+	}
+	if err := tx.Create(d).Error; err != nil {
+		return nil, err
+	}
+
+	return d, tx.Commit().Error
+}
+
+// UpdateDelivery changes where an undelivered order goes, and its fee while the sale is unpaid
+func UpdateDelivery(db *gorm.DB, saleID, businessID uint, req DeliveryRequest) (*Delivery, error) {
+	if strings.TrimSpace(req.Address) == "" || strings.TrimSpace(req.CustomerPhone) == "" {
+		return nil, errors.New("delivery address and customer phone are required")
+	}
+	if req.Fee < 0 {
+		return nil, errors.New("delivery fee cannot be negative")
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var d Delivery
+	if err := tx.First(&d, "sale_id = ? AND business_id = ?", saleID, businessID).Error; err != nil {
+		return nil, errors.New("delivery not found")
+	}
+	if d.Status == DispatchDelivered {
+		return nil, errors.New("delivery has already been delivered")
+	}
+
+	var sale Sale
+	if err := tx.First(&sale, "id = ?", saleID).Error; err != nil {
+		return nil, errors.New("sale not found")
+	}
+
+	applyDeliveryRequest(&d, req)
+	if req.CustomerName != "" {
+		d.CustomerName = strings.TrimSpace(req.CustomerName)
+	}
+	d.CustomerPhone = strings.TrimSpace(req.CustomerPhone)
+
+	if err := setDeliveryFee(tx, &sale, req.Fee); err != nil {
+		return nil, err
+	}
+	if err := tx.Save(&d).Error; err != nil {
+		return nil, err
+	}
+
+	return &d, tx.Commit().Error
+}
+
+func applyDeliveryRequest(d *Delivery, req DeliveryRequest) {
+	d.Address = strings.TrimSpace(req.Address)
+	d.Landmark = strings.TrimSpace(req.Landmark)
+	d.Notes = req.Notes
+	d.Fee = req.Fee
+}
+
+// setDeliveryFee makes the sale a delivery order charging fee, repricing it while it is open
+func setDeliveryFee(tx *gorm.DB, sale *Sale, fee float64) error {
+	fee = roundMoney(fee)
+	sale.OrderType = "delivery"
+
+	if sale.Status != StatusDraft && sale.Status != StatusHeld {
+		if fee != sale.DeliveryFee {
+			return errors.New("delivery fee can only be changed before the sale is paid")
+		}
+		return tx.Model(sale).Update("order_type", sale.OrderType).Error
+	}
+
+	sale.DeliveryFee = fee
+	return recalculateSaleTotals(tx, sale)
+}
+
+// GetDelivery returns the delivery of a sale
+func GetDelivery(db *gorm.DB, saleID, businessID uint) (*Delivery, error) {
+	var d Delivery
+	if err := db.First(&d, "sale_id = ? AND business_id = ?", saleID, businessID).Error; err != nil {
+		return nil, errors.New("delivery not found")
+	}
+	return &d, nil
+}
+
+// ListDeliveries returns deliveries, newest first
+func ListDeliveries(db *gorm.DB, businessID uint, filters DeliveryFilters) ([]Delivery, error) {
+	query := db.Where("business_id = ?", businessID)
+	if filters.Status != "" {
+		query = query.Where("status = ?", filters.Status)
+	}
+	if filters.RiderID != nil {
+		query = query.Where("rider_id = ?", *filters.RiderID)
+	}
+	if filters.Date != "" {
+		day, err := time.Parse("2006-01-02", filters.Date)
+		if err != nil {
+			return nil, errors.New("invalid date format, use YYYY-MM-DD")
+		}
+		query = query.Where("created_at >= ? AND created_at < ?", day, day.AddDate(0, 0, 1))
+	}
+
+	deliveries := []Delivery{}
+	err := query.Order("created_at DESC").Find(&deliveries).Error
+	return deliveries, err
+}
+
+// ListRiders returns the business's active riders
+func ListRiders(db *gorm.DB, businessID uint) ([]DeliveryRider, error) {
+	riders := []DeliveryRider{}
+	err := db.Table("users").
+		Select("users.id, users.first_name, users.last_name, users.phone").
+		Joins("JOIN businesses ON businesses.tenant_id = users.tenant_id").
+		Where("businesses.id = ? AND users.role = ? AND users.active = ?", businessID, RoleRider, true).
+		Order("users.first_name ASC").
+		Scan(&riders).Error
+	return riders, err
+}
+
+// DeliveryRider is a user who can be sent out with deliveries
+type DeliveryRider struct {
+	ID        uint   `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Phone     string `json:"phone"`
+}
+
+// AssignRider sends a rider out with a delivery. A failed delivery can be given to a
+// rider again; one already picked up or delivered cannot change hands.
+func AssignRider(db *gorm.DB, saleID, businessID, userID uint, req AssignRiderRequest) (*Delivery, error) {
+	var rider struct {
+		ID        uint
+		FirstName string
+		LastName  string
+	}
+	db.Table("users").
+		Select("users.id, users.first_name, users.last_name").
+		Joins("JOIN businesses ON businesses.tenant_id = users.tenant_id").
+		Where("businesses.id = ? AND users.id = ? AND users.role = ? AND users.active = ?", businessID, req.RiderID, RoleRider, true).
+		Scan(&rider)
+	if rider.ID == 0 {
+		return nil, errors.New("rider not found")
+	}
+
+	var d Delivery
+	if err := db.First(&d, "sale_id = ? AND business_id = ?", saleID, businessID).Error; err != nil {
+		return nil, errors.New("delivery not found")
+	}
+	switch d.Status {
+	case DispatchPending, DispatchAssigned, DispatchFailed:
+	default:
+		return nil, fmt.Errorf("cannot assign a rider to a %s delivery", d.Status)
+	}
+
+	now := time.Now()
+	if d.Status != DispatchAssigned {
+		d.Attempts++
+	}
+	d.RiderID = &rider.ID
+	d.RiderName = strings.TrimSpace(rider.FirstName + " " + rider.LastName)
+	d.Status = DispatchAssigned
+	d.AssignedAt = &now
+	d.PickedUpAt = nil
+	d.FailedAt = nil
+	d.FailureReason = ""
+	if err := db.Save(&d).Error; err != nil {
+		return nil, err
+	}
+
+	_ = LogActivity(db, saleID, businessID, userID, ActionUpdated, ActivityDetails{
+		Reason:   "rider assigned",
+		NewValue: d.RiderName,
+	})
+	return &d, nil
+}
+
+// UpdateDeliveryStatus records a delivery being picked up, delivered or failing.
+// Riders may only move their own deliveries.
+func UpdateDeliveryStatus(db *gorm.DB, saleID, businessID, userID uint, role string, req DeliveryStatusRequest) (*Delivery, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var d Delivery
+	if err := tx.First(&d, "sale_id = ? AND business_id = ?", saleID, businessID).Error; err != nil {
+		return nil, errors.New("delivery not found")
+	}
+	if role == RoleRider && (d.RiderID == nil || *d.RiderID != userID) {
+		return nil, errors.New("delivery is assigned to another rider")
+	}
+
+	now := time.Now()
+	switch req.Status {
+	case DispatchPickedUp:
+		if d.Status != DispatchAssigned {
+			return nil, fmt.Errorf("cannot pick up a %s delivery", d.Status)
+		}
+		d.PickedUpAt = &now
+
+	case DispatchDelivered:
+		if d.Status != DispatchPickedUp {
+			return nil, fmt.Errorf("cannot deliver a %s delivery", d.Status)
+		}
+		var due float64
+		tx.Model(&Payment{}).
+			Where("sale_id = ? AND provider = ?", saleID, PaymentMethodCashOnDelivery).
+			Select("COALESCE(SUM(amount), 0)").
+			Scan(&due)
+		d.CashDue = roundMoney(due)
+		d.CashCollected = d.CashDue
+		if req.CashCollected != nil {
+			if *req.CashCollected < 0 {
+				return nil, errors.New("cash collected cannot be negative")
+			}
+			d.CashCollected = roundMoney(*req.CashCollected)
+		}
+		d.DeliveredAt = &now
+
+	case DispatchFailed:
+		if d.Status != DispatchAssigned && d.Status != DispatchPickedUp {
+			return nil, fmt.Errorf("cannot fail a %s delivery", d.Status)
+		}
+		if strings.TrimSpace(req.Reason) == "" {
+			return nil, errors.New("a reason is required when a delivery fails")
+		}
+		d.FailureReason = strings.TrimSpace(req.Reason)
+		d.FailedAt = &now
+
+	default:
+		return nil, fmt.Errorf("invalid delivery status %s", req.Status)
+	}
+
+	d.Status = req.Status
+	if err := tx.Save(&d).Error; err != nil {
+		return nil, err
+	}
+	if err := LogActivity(tx, saleID, businessID, userID, ActionUpdated, ActivityDetails{
+		Reason:     "delivery " + strings.ToLower(string(req.Status)),
+		NewValue:   req.Status,
+		AmountPaid: d.CashCollected,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &d, tx.Commit().Error
+}
+
+// RemitRiderCash records a rider handing in the cash on delivery they have collected.
+// Every delivered order they have not yet settled is settled by it; the cash goes
+// into the receiving shift's drawer and any difference is kept as the variance.
+func RemitRiderCash(db *gorm.DB, businessID, riderID, receivedBy uint, shiftID *uint, req RemitCashRequest) (*RiderRemittance, error) {
+	if req.Amount < 0 {
+		return nil, errors.New("amount cannot be negative")
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var deliveries []Delivery
+	if err := tx.Where("business_id = ? AND rider_id = ? AND status = ? AND cash_collected > 0 AND remittance_id IS NULL",
+		businessID, riderID, DispatchDelivered).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 && req.Amount == 0 {
+		return nil, errors.New("rider has no cash on delivery to hand in")
+	}
+
+	var expected float64
+	ids := make([]uint, len(deliveries))
+	for i, d := range deliveries {
+		expected += d.CashCollected
+		ids[i] = d.ID
+	}
+	expected = roundMoney(expected)
+
+	remittance := &RiderRemittance{
+		BusinessID: businessID,
+		RiderID:    riderID,
+		ShiftID:    shiftID,
+		Expected:   expected,
+		Amount:     roundMoney(req.Amount),
+		Variance:   roundMoney(req.Amount - expected),
+		Deliveries: len(deliveries),
+		ReceivedBy: receivedBy,
+		Notes:      req.Notes,
+	}
+	if err := tx.Create(remittance).Error; err != nil {
+		return nil, err
+	}
+	if len(ids) > 0 {
+		if err := tx.Model(&Delivery{}).Where("id IN ?", ids).Update("remittance_id", remittance.ID).Error; err != nil {
+			return nil, err
+		}
+	}
+	if shiftID != nil {
+		if err := shift.NewShiftService(tx).AddRiderCash(*shiftID, remittance.Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	return remittance, tx.Commit().Error
+}
+
+// GetRiderCashReport sums each rider's deliveries and cash on delivery between from and
+// to (YYYY-MM-DD, inclusive). With a shift, only the hand-ins into that shift's drawer
+// count as remitted, so the report lines up with the shift's cash at close.
+func GetRiderCashReport(db *gorm.DB, businessID uint, from, to string, shiftID *uint) ([]RiderCashSummary, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, errors.New("invalid from date, use YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return nil, errors.New("invalid to date, use YYYY-MM-DD")
+	}
+	end = end.AddDate(0, 0, 1)
+
+	var rows []RiderCashSummary
+	if err := db.Model(&Delivery{}).
+		Select(`rider_id, MAX(rider_name) AS rider_name, COUNT(*) AS deliveries,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS delivered,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS failed,
+			COALESCE(SUM(cash_due), 0) AS cash_due,
+			COALESCE(SUM(cash_collected), 0) AS cash_collected`, DispatchDelivered, DispatchFailed).
+		Where("business_id = ? AND rider_id IS NOT NULL AND assigned_at >= ? AND assigned_at < ?", businessID, start, end).
+		Group("rider_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	remitQuery := db.Model(&RiderRemittance{}).
+		Select("rider_id, COALESCE(SUM(amount), 0) AS remitted, COALESCE(SUM(variance), 0) AS variance").
+		Where("business_id = ?", businessID)
+	if shiftID != nil {
+		remitQuery = remitQuery.Where("shift_id = ?", *shiftID)
+	} else {
+		remitQuery = remitQuery.Where("created_at >= ? AND created_at < ?", start, end)
+	}
+	var remitted []struct {
+		RiderID  uint
+		Remitted float64
+		Variance float64
+	}
+	if err := remitQuery.Group("rider_id").Scan(&remitted).Error; err != nil {
+		return nil, err
+	}
+
+	var outstanding []struct {
+		RiderID     uint
+		Outstanding float64
+	}
+	if err := db.Model(&Delivery{}).
+		Select("rider_id, COALESCE(SUM(cash_collected), 0) AS outstanding").
+		Where("business_id = ? AND status = ? AND remittance_id IS NULL", businessID, DispatchDelivered).
+		Group("rider_id").
+		Scan(&outstanding).Error; err != nil {
+		return nil, err
+	}
+
+	var order []uint
+	byRider := make(map[uint]*RiderCashSummary, len(rows))
+	row := func(riderID uint) *RiderCashSummary {
+		if _, ok := byRider[riderID]; !ok {
+			byRider[riderID] = &RiderCashSummary{RiderID: riderID}
+			order = append(order, riderID)
+		}
+		return byRider[riderID]
+	}
+	for _, r := range rows {
+		*row(r.RiderID) = r
+	}
+	for _, r := range remitted {
+		s := row(r.RiderID)
+		s.Remitted = roundMoney(r.Remitted)
+		s.Variance = roundMoney(r.Variance)
+	}
+	for _, o := range outstanding {
+		row(o.RiderID).Outstanding = roundMoney(o.Outstanding)
+	}
+
+	out := make([]RiderCashSummary, 0, len(order))
+	for _, id := range order {
+		s := byRider[id]
+		if s.RiderName == "" {
+			var u struct {
+				FirstName string
+				LastName  string
+			}
+			db.Table("users").Select("first_name, last_name").Where("id = ?", id).Scan(&u)
+			s.RiderName = strings.TrimSpace(u.FirstName + " " + u.LastName)
+		}
+		s.CashDue = roundMoney(s.CashDue)
+		s.CashCollected = roundMoney(s.CashCollected)
+		out = append(out, *s)
+	}
+	return out, nil
+}
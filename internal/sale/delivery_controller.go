@@ -0,0 +1,317 @@
+// internal/sale/delivery_controller.go
+package sale
+
+import (
+	"time"
+
+	"pos-fiber-app/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// CreateDeliveryHandler godoc
+// @Summary Set up the delivery of a sale
+// @Description Makes the sale a delivery order with the address, customer phone and fee. The fee is added to the sale total, so it can only be charged before the sale is paid.
+// @Tags Deliveries
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Param body body DeliveryRequest true "Delivery details"
+// @Success 201 {object} Delivery
+// @Failure 400 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /sales/{sale_id}/delivery [post]
+func CreateDeliveryHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		var req DeliveryRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		d, err := CreateDelivery(db, uint(saleID), bizID, req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		GlobalKDSHub.BroadcastOrder(bizID, EventDeliveryUpdated, d)
+
+		return c.Status(fiber.StatusCreated).JSON(d)
+	}
+}
+
+// GetDeliveryHandler godoc
+// @Summary Get the delivery of a sale
+// @Tags Deliveries
+// @Security BearerAuth
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Success 200 {object} Delivery
+// @Failure 404 {object} map[string]string
+// @Router /sales/{sale_id}/delivery [get]
+func GetDeliveryHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		d, err := GetDelivery(db, uint(saleID), bizID)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.JSON(d)
+	}
+}
+
+// UpdateDeliveryHandler godoc
+// @Summary Change the delivery details of a sale
+// @Tags Deliveries
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Param body body DeliveryRequest true "Delivery details"
+// @Success 200 {object} Delivery
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /sales/{sale_id}/delivery [put]
+func UpdateDeliveryHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		var req DeliveryRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		d, err := UpdateDelivery(db, uint(saleID), bizID, req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		GlobalKDSHub.BroadcastOrder(bizID, EventDeliveryUpdated, d)
+
+		return c.JSON(d)
+	}
+}
+
+// AssignRiderHandler godoc
+// @Summary Assign a rider to a delivery
+// @Description The rider must be an active user with the RIDER role. Failed deliveries can be assigned again.
+// @Tags Deliveries
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Param body body AssignRiderRequest true "Rider"
+// @Success 200 {object} Delivery
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /sales/{sale_id}/delivery/assign [post]
+func AssignRiderHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req AssignRiderRequest
+		if err := c.BodyParser(&req); err != nil || req.RiderID == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "rider_id is required")
+		}
+
+		d, err := AssignRider(db, uint(saleID), bizID, claims.UserID, req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		GlobalKDSHub.BroadcastOrder(bizID, EventDeliveryUpdated, d)
+
+		return c.JSON(d)
+	}
+}
+
+// UpdateDeliveryStatusHandler godoc
+// @Summary Mark a delivery picked up, delivered or failed
+// @Description Riders can only move deliveries assigned to them. On DELIVERED, cash_collected defaults to the sale's cash on delivery; on FAILED a reason is required.
+// @Tags Deliveries
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Param body body DeliveryStatusRequest true "New status"
+// @Success 200 {object} Delivery
+// @Failure 403 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /sales/{sale_id}/delivery/status [patch]
+func UpdateDeliveryStatusHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req DeliveryStatusRequest
+		if err := c.BodyParser(&req); err != nil || req.Status == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "status is required")
+		}
+
+		d, err := UpdateDeliveryStatus(db, uint(saleID), bizID, claims.UserID, claims.Role, req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		GlobalKDSHub.BroadcastOrder(bizID, EventDeliveryUpdated, d)
+
+		return c.JSON(d)
+	}
+}
+
+// ListDeliveriesHandler godoc
+// @Summary List deliveries
+// @Description Riders only see their own deliveries
+// @Tags Deliveries
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "PENDING, ASSIGNED, PICKED_UP, DELIVERED or FAILED"
+// @Param rider_id query uint false "Rider user ID"
+// @Param date query string false "YYYY-MM-DD"
+// @Success 200 {array} Delivery
+// @Router /deliveries [get]
+func ListDeliveriesHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		filters := DeliveryFilters{
+			Status: DispatchStatus(c.Query("status")),
+			Date:   c.Query("date"),
+		}
+		if id := c.QueryInt("rider_id"); id > 0 {
+			rid := uint(id)
+			filters.RiderID = &rid
+		}
+		if claims.Role == RoleRider {
+			filters.RiderID = &claims.UserID
+		}
+
+		deliveries, err := ListDeliveries(db, bizID, filters)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		return c.JSON(deliveries)
+	}
+}
+
+// ListRidersHandler godoc
+// @Summary List the business's active riders
+// @Tags Deliveries
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} DeliveryRider
+// @Router /deliveries/riders [get]
+func ListRidersHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		riders, err := ListRiders(db, bizID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch riders")
+		}
+
+		return c.JSON(riders)
+	}
+}
+
+// RemitRiderCashHandler godoc
+// @Summary Take in a rider's cash on delivery
+// @Description Settles every delivered order the rider has not handed the cash in for. The cash goes into the current shift's drawer and counts towards its expected cash at close.
+// @Tags Deliveries
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param rider_id path uint true "Rider user ID"
+// @Param body body RemitCashRequest true "Cash handed in"
+// @Success 201 {object} RiderRemittance
+// @Failure 422 {object} map[string]string
+// @Router /deliveries/riders/{rider_id}/remit [post]
+func RemitRiderCashHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		riderID, err := c.ParamsInt("rider_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid rider ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req RemitCashRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		remittance, err := RemitRiderCash(db, bizID, uint(riderID), claims.UserID, currentShiftID(c), req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(remittance)
+	}
+}
+
+// RiderCashReportHandler godoc
+// @Summary Rider cash-collection report
+// @Description Per rider: deliveries, cash on delivery due and collected, cash handed in with its variance and what is still outstanding. With shift_id, only hand-ins into that shift's drawer count, to reconcile it at close.
+// @Tags Deliveries
+// @Security BearerAuth
+// @Produce json
+// @Param from query string false "YYYY-MM-DD (defaults to today)"
+// @Param to query string false "YYYY-MM-DD (defaults to from)"
+// @Param shift_id query uint false "Shift ID"
+// @Success 200 {array} RiderCashSummary
+// @Router /deliveries/riders/cash [get]
+func RiderCashReportHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		from := c.Query("from", time.Now().Format("2006-01-02"))
+		to := c.Query("to", from)
+
+		var shiftID *uint
+		if id := c.QueryInt("shift_id"); id > 0 {
+			sid := uint(id)
+			shiftID = &sid
+		}
+
+		report, err := GetRiderCashReport(db, bizID, from, to, shiftID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		return c.JSON(report)
+	}
+}
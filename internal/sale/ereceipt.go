@@ -0,0 +1,236 @@
+// internal/sale/ereceipt.go
+package sale
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"pos-fiber-app/internal/common"
+	"pos-fiber-app/internal/email"
+	"pos-fiber-app/internal/notification"
+
+	"gorm.io/gorm"
+)
+
+type DeliveryChannel string
+
+const (
+	ChannelEmail    DeliveryChannel = "EMAIL"
+	ChannelWhatsApp DeliveryChannel = "WHATSAPP"
+)
+
+type DeliveryStatus string
+
+const (
+	DeliveryQueued DeliveryStatus = "QUEUED"
+	DeliverySent   DeliveryStatus = "SENT"
+	DeliveryFailed DeliveryStatus = "FAILED"
+)
+
+// ReceiptDelivery is one e-receipt sent, or being sent, to a customer
+type ReceiptDelivery struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	BusinessID  uint            `gorm:"index" json:"business_id"`
+	SaleID      uint            `gorm:"index" json:"sale_id"`
+	Channel     DeliveryChannel `gorm:"type:varchar(20)" json:"channel"`
+	Recipient   string          `gorm:"size:150" json:"recipient"`
+	Status      DeliveryStatus  `gorm:"type:varchar(20);default:'QUEUED';index" json:"status"`
+	Error       string          `gorm:"type:text" json:"error,omitempty"`
+	RequestedBy *uint           `json:"requested_by,omitempty"` // set on resends; automatic deliveries have none
+	SentAt      *time.Time      `json:"sent_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// ResendReceiptRequest sends a sale's receipt again. Without an email or phone the
+// ones on the sale are used.
+type ResendReceiptRequest struct {
+	Email string `json:"email,omitempty" validate:"omitempty,email"`
+	Phone string `json:"phone,omitempty"`
+}
+
+// receiptLinkSecret signs public receipt links
+func receiptLinkSecret() []byte {
+	if secret := os.Getenv("RECEIPT_LINK_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+func signReceiptLink(payload string) string {
+	h := hmac.New(sha256.New, receiptLinkSecret())
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// ReceiptLinkToken is the signed token in a sale's public receipt link
+func ReceiptLinkToken(saleID, businessID uint) string {
+	payload := fmt.Sprintf("%d.%d", saleID, businessID)
+	return payload + "." + signReceiptLink(payload)
+}
+
+// ParseReceiptLinkToken checks a public receipt link's signature and returns the sale it is for
+func ParseReceiptLinkToken(token string) (saleID, businessID uint, err error) {
+	return parseSaleToken(token, "")
+}
+
+// parseSaleToken checks a signed "sale.business.signature" token. purpose is what the
+// signature was made for, so a token minted for one public page cannot open another.
+func parseSaleToken(token, purpose string) (saleID, businessID uint, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return 0, 0, errors.New("invalid receipt link")
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(parts[2]), []byte(signReceiptLink(purpose+payload))) {
+		return 0, 0, errors.New("invalid receipt link")
+	}
+	sid, err1 := strconv.ParseUint(parts[0], 10, 64)
+	bid, err2 := strconv.ParseUint(parts[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, errors.New("invalid receipt link")
+	}
+	return uint(sid), uint(bid), nil
+}
+
+// ReceiptLink is the public URL where a customer views a sale's receipt
+func ReceiptLink(saleID, businessID uint) string {
+	base := os.Getenv("PUBLIC_API_URL")
+	if base == "" {
+		base = email.LoadConfig().AppURL
+	}
+	return strings.TrimRight(base, "/") + "/api/v1/public/receipts/" + ReceiptLinkToken(saleID, businessID)
+}
+
+// queueEReceipts sends the receipt of a just-finished sale to the customer's email and
+// WhatsApp, when the business has e-receipts on. Failures are recorded on the delivery,
+// never on the sale.
+func queueEReceipts(db *gorm.DB, sale *Sale) {
+	var biz struct {
+		EReceiptsEnabled bool
+	}
+	if err := db.Table("businesses").Select("e_receipts_enabled").Where("id = ?", sale.BusinessID).Scan(&biz).Error; err != nil || !biz.EReceiptsEnabled {
+		return
+	}
+	if _, err := sendEReceipts(db, sale, sale.CustomerEmail, sale.CustomerPhone, nil); err != nil {
+		log.Printf("[E-RECEIPT] sale %d: %v", sale.ID, err)
+	}
+}
+
+// ResendReceipt sends a finished sale's receipt to the given email or phone, or else to
+// the customer's on the sale
+func ResendReceipt(db *gorm.DB, saleID, businessID, userID uint, req ResendReceiptRequest) ([]ReceiptDelivery, error) {
+	var sale Sale
+	if err := db.First(&sale, "id = ? AND business_id = ?", saleID, businessID).Error; err != nil {
+		return nil, errors.New("sale not found")
+	}
+	switch sale.Status {
+	case StatusCompleted, StatusRefunded, StatusPendingPayment:
+	default:
+		return nil, fmt.Errorf("no receipt for a %s sale", sale.Status)
+	}
+
+	to, phone := strings.TrimSpace(req.Email), strings.TrimSpace(req.Phone)
+	if to == "" && phone == "" {
+		to, phone = sale.CustomerEmail, sale.CustomerPhone
+	}
+	return sendEReceipts(db, &sale, to, phone, &userID)
+}
+
+// sendEReceipts records a delivery per channel and sends them in the background
+func sendEReceipts(db *gorm.DB, sale *Sale, to, phone string, requestedBy *uint) ([]ReceiptDelivery, error) {
+	var deliveries []ReceiptDelivery
+	if to != "" {
+		deliveries = append(deliveries, ReceiptDelivery{Channel: ChannelEmail, Recipient: to})
+	}
+	if phone != "" {
+		var active int64
+		db.Table("business_modules").
+			Where("business_id = ? AND module = ? AND is_active = ?", sale.BusinessID, common.ModuleWhatsApp, true).
+			Count(&active)
+		if active > 0 {
+			deliveries = append(deliveries, ReceiptDelivery{Channel: ChannelWhatsApp, Recipient: phone})
+		} else if to == "" {
+			return nil, errors.New("WhatsApp receipts need the WHATSAPP_ALERTS module; no email to send the receipt to")
+		}
+	}
+	if len(deliveries) == 0 {
+		return nil, errors.New("no email or phone to send the receipt to")
+	}
+
+	for i := range deliveries {
+		deliveries[i].BusinessID = sale.BusinessID
+		deliveries[i].SaleID = sale.ID
+		deliveries[i].Status = DeliveryQueued
+		deliveries[i].RequestedBy = requestedBy
+	}
+	if err := db.Create(&deliveries).Error; err != nil {
+		return nil, err
+	}
+
+	for _, d := range deliveries {
+		go deliverReceipt(db, d)
+	}
+	return deliveries, nil
+}
+
+// deliverReceipt sends one e-receipt and records how it went
+func deliverReceipt(db *gorm.DB, d ReceiptDelivery) {
+	err := sendReceiptTo(db, d)
+
+	updates := map[string]interface{}{"status": DeliverySent, "sent_at": time.Now(), "error": ""}
+	if err != nil {
+		log.Printf("[E-RECEIPT] delivery %d (%s) failed: %v", d.ID, d.Channel, err)
+		updates = map[string]interface{}{"status": DeliveryFailed, "error": err.Error()}
+	}
+	db.Model(&ReceiptDelivery{}).Where("id = ?", d.ID).Updates(updates)
+}
+
+func sendReceiptTo(db *gorm.DB, d ReceiptDelivery) error {
+	r, err := loadSaleReceipt(db, d.SaleID, d.BusinessID)
+	if err != nil {
+		return err
+	}
+	var biz struct {
+		Name     string
+		Currency string
+	}
+	if err := db.Table("businesses").Select("name, currency").Where("id = ?", d.BusinessID).Scan(&biz).Error; err != nil {
+		return err
+	}
+	link := ReceiptLink(d.SaleID, d.BusinessID)
+
+	switch d.Channel {
+	case ChannelEmail:
+		sender := email.NewSender(email.LoadConfig())
+		return sender.SendEReceipt(d.Recipient, email.EmailData{
+			Name:         r.Sale.CustomerName,
+			BusinessName: biz.Name,
+			Currency:     biz.Currency,
+			Date:         r.Sale.SaleDate.Format("02 Jan 2006 15:04"),
+			ReceiptNo:    r.ReceiptNo,
+			ReceiptURL:   link,
+			Total:        fmt.Sprintf("%.2f", r.Sale.Total),
+		})
+	case ChannelWhatsApp:
+		body := fmt.Sprintf("*Your receipt from %s*\n\nReceipt No: %s\nTotal: %s %.2f\n\nView it here: %s",
+			biz.Name, r.ReceiptNo, biz.Currency, r.Sale.Total, link)
+		return notification.GetDefaultService(db).SendWhatsApp(d.Recipient, body)
+	}
+	return fmt.Errorf("unknown receipt channel %s", d.Channel)
+}
+
+// ListReceiptDeliveries returns the e-receipts sent for a sale, newest first
+func ListReceiptDeliveries(db *gorm.DB, saleID, businessID uint) ([]ReceiptDelivery, error) {
+	var deliveries []ReceiptDelivery
+	err := db.Where("sale_id = ? AND business_id = ?", saleID, businessID).Order("id DESC").Find(&deliveries).Error
+	return deliveries, err
+}
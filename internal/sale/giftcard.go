@@ -0,0 +1,93 @@
+// internal/sale/giftcard.go
+package sale
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"pos-fiber-app/internal/giftcard"
+	"pos-fiber-app/internal/product"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// PaymentMethodGiftCard is the tender for value redeemed from a gift card
+	PaymentMethodGiftCard = "GIFT_CARD"
+	// PaymentMethodStoreCredit is a refund tender that pays out on a new store-credit card
+	PaymentMethodStoreCredit = "STORE_CREDIT"
+)
+
+func isGiftCardTender(method string) bool {
+	return strings.EqualFold(method, PaymentMethodGiftCard)
+}
+
+func isStoreCreditTender(method string) bool {
+	return strings.EqualFold(method, PaymentMethodStoreCredit)
+}
+
+// setGiftCardLine marks a line selling a gift card product. A code loads that card
+// (a top-up, or a pre-printed card being sold) instead of issuing a new one, so
+// such a line is always for a single card.
+func setGiftCardLine(item *SaleItem, prod *product.Product, code string) error {
+	code = strings.TrimSpace(code)
+	if code != "" && !prod.IsGiftCard {
+		return fmt.Errorf("%s is not a gift card product", prod.Name)
+	}
+	item.IsGiftCard = prod.IsGiftCard
+	item.GiftCardCode = code
+	if code != "" && item.Quantity != 1 {
+		return errors.New("a line loading a gift card code must have a quantity of 1")
+	}
+	return nil
+}
+
+// redeemGiftCards takes the GIFT_CARD payments of a sale off their cards
+func redeemGiftCards(tx *gorm.DB, sale *Sale, payments []PaymentInfoRequest, userID uint) error {
+	var total float64
+	for _, p := range payments {
+		if !isGiftCardTender(p.Method) {
+			continue
+		}
+		if p.GiftCardCode == "" {
+			return errors.New("a gift card code is required for GIFT_CARD payments")
+		}
+		total += p.Amount
+		if _, err := giftcard.Redeem(tx, sale.BusinessID, p.GiftCardCode, p.Amount, sale.ID, userID); err != nil {
+			return err
+		}
+	}
+
+	// Gift cards pay for goods, they are not cashed out as change
+	if roundMoney(total) > roundMoney(sale.Total) {
+		return errors.New("GIFT_CARD payment cannot exceed the sale total")
+	}
+	return nil
+}
+
+// issueGiftCards issues (or tops up) the cards sold on a completed sale, one per unit
+func issueGiftCards(tx *gorm.DB, sale *Sale, items []SaleItem, userID uint) ([]giftcard.Card, error) {
+	var cards []giftcard.Card
+	for _, item := range items {
+		if !item.IsGiftCard {
+			continue
+		}
+		itemID := item.ID
+		for i := 0; i < int(item.Quantity); i++ { // gift cards sell in whole units
+			card, err := giftcard.LoadValue(tx, sale.BusinessID, giftcard.Load{
+				Code:       item.GiftCardCode,
+				Amount:     item.UnitPrice + item.ModifierTotal,
+				CustomerID: sale.CustomerID,
+				SaleID:     &sale.ID,
+				SaleItemID: &itemID,
+				RecordedBy: userID,
+			})
+			if err != nil {
+				return nil, err
+			}
+			cards = append(cards, *card)
+		}
+	}
+	return cards, nil
+}
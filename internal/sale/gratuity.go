@@ -0,0 +1,165 @@
+// internal/sale/gratuity.go
+package sale
+
+import (
+	"errors"
+	"time"
+
+	"pos-fiber-app/internal/shift"
+
+	"gorm.io/gorm"
+)
+
+// StaffGratuity is what a staff member's sales collected in service charges and tips,
+// for paying them out
+type StaffGratuity struct {
+	UserID         uint    `json:"user_id"`
+	UserName       string  `json:"user_name"`
+	Sales          int     `json:"sales"`
+	ServiceCharges float64 `json:"service_charges"`
+	Tips           float64 `json:"tips"`
+	Total          float64 `json:"total"`
+}
+
+// computeTotals works out a sale's VAT, service charge and Total. A delivery fee is
+// added on top, outside VAT and the service charge. A share of a bill split evenly is
+// priced as its part of the whole bill instead.
+func computeTotals(db *gorm.DB, sale *Sale, items []SaleItem, discount float64) error {
+	if sale.SplitWays > 1 {
+		return applySplitShare(db, sale, discount)
+	}
+	if err := applyTax(db, sale, items, discount); err != nil {
+		return err
+	}
+	if err := applyServiceCharge(db, sale, discount); err != nil {
+		return err
+	}
+	sale.Total = roundMoney(sale.Total + sale.DeliveryFee)
+	return nil
+}
+
+// applyServiceCharge adds the business's automatic service charge to a sale. It is a
+// percentage of the subtotal after discount, charged on eat-in orders; with a minimum
+// party size set, only at tables seating at least that many.
+func applyServiceCharge(db *gorm.DB, sale *Sale, discount float64) error {
+	sale.ServiceCharge = 0
+
+	var biz struct {
+		ServiceChargePercent      float64
+		ServiceChargeMinPartySize int
+	}
+	if err := db.Table("businesses").
+		Select("service_charge_percent, service_charge_min_party_size").
+		Where("id = ?", sale.BusinessID).
+		Scan(&biz).Error; err != nil {
+		return err
+	}
+	if biz.ServiceChargePercent <= 0 || sale.OrderType == "takeaway" || sale.OrderType == "delivery" {
+		return nil
+	}
+
+	if biz.ServiceChargeMinPartySize > 0 {
+		if sale.TableID == nil {
+			return nil
+		}
+		var capacity int
+		if err := db.Table("tables").Select("capacity").Where("id = ?", *sale.TableID).Scan(&capacity).Error; err != nil {
+			return err
+		}
+		if capacity < biz.ServiceChargeMinPartySize {
+			return nil
+		}
+	}
+
+	sale.ServiceCharge = roundMoney((sale.Subtotal - discount) * biz.ServiceChargePercent / 100)
+	sale.Total = roundMoney(sale.Total + sale.ServiceCharge)
+	return nil
+}
+
+// collectTips totals the tips paid with a sale's tenders onto the sale
+func collectTips(sale *Sale, payments []PaymentInfoRequest) error {
+	var tips float64
+	for _, p := range payments {
+		if p.Tip < 0 {
+			return errors.New("tip cannot be negative")
+		}
+		tips += p.Tip
+	}
+	sale.Tip = roundMoney(tips)
+	return nil
+}
+
+// recordShiftGratuities adds a sale's service charge and tips to its shift, or takes
+// them back when sign is -1
+func recordShiftGratuities(db *gorm.DB, sale *Sale, sign float64) error {
+	if sale.ShiftID == nil || (sale.ServiceCharge == 0 && sale.Tip == 0) {
+		return nil
+	}
+	var cashTips float64
+	if sale.Tip != 0 {
+		if err := db.Model(&Payment{}).
+			Where("sale_id = ? AND provider = ?", sale.ID, "CASH").
+			Select("COALESCE(SUM(tip), 0)").
+			Scan(&cashTips).Error; err != nil {
+			return err
+		}
+	}
+	return shift.NewShiftService(db).AddGratuities(*sale.ShiftID, sign*sale.ServiceCharge, sign*sale.Tip, sign*cashTips)
+}
+
+// sumGratuities totals the service charges and tips on sales made between start and end
+func sumGratuities(db *gorm.DB, businessID uint, start, end time.Time) (serviceCharges, tips float64) {
+	var totals struct {
+		ServiceCharges float64
+		Tips           float64
+	}
+	db.Model(&Sale{}).
+		Where("business_id = ? AND sale_date >= ? AND sale_date < ? AND status IN ?", businessID, start, end, revenueStatuses).
+		Select("COALESCE(SUM(service_charge), 0) AS service_charges, COALESCE(SUM(tip), 0) AS tips").
+		Scan(&totals)
+	return totals.ServiceCharges, totals.Tips
+}
+
+// GetStaffGratuityReport totals service charges and tips by the staff member who made each sale
+func GetStaffGratuityReport(db *gorm.DB, businessID uint, from, to string) ([]StaffGratuity, error) {
+	query := db.Table("sales").
+		Joins("LEFT JOIN users ON users.id = sales.cashier_id").
+		Where("sales.business_id = ? AND sales.status IN ? AND (sales.service_charge <> 0 OR sales.tip <> 0)", businessID, revenueStatuses)
+
+	if from != "" {
+		t, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return nil, errors.New("invalid from date, use YYYY-MM-DD")
+		}
+		query = query.Where("sales.sale_date >= ?", t)
+	}
+	if to != "" {
+		t, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return nil, errors.New("invalid to date, use YYYY-MM-DD")
+		}
+		query = query.Where("sales.sale_date < ?", t.AddDate(0, 0, 1))
+	}
+
+	results := []StaffGratuity{}
+	err := query.Select(`
+		sales.cashier_id AS user_id,
+		COALESCE(users.first_name || ' ' || users.last_name, '') AS user_name,
+		COUNT(*) AS sales,
+		SUM(sales.service_charge) AS service_charges,
+		SUM(sales.tip) AS tips
+	`).
+		Group("sales.cashier_id, users.first_name, users.last_name").
+		Order("tips DESC").
+		Scan(&results).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].ServiceCharges = roundMoney(results[i].ServiceCharges)
+		results[i].Tips = roundMoney(results[i].Tips)
+		results[i].Total = roundMoney(results[i].ServiceCharges + results[i].Tips)
+	}
+	return results, nil
+}
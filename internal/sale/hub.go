@@ -5,6 +5,7 @@ import (
 	"log"
 	"sync"
 	
+	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 )
 
@@ -17,6 +18,10 @@ const (
 	EventOrderVoided  SaleEventType = "ORDER_VOIDED"
 	EventOrderPaid    SaleEventType = "ORDER_PAID"
 	EventPaymentVerified SaleEventType = "PAYMENT_VERIFIED"
+	EventDeliveryUpdated SaleEventType = "DELIVERY_UPDATED"
+	EventStationBumped   SaleEventType = "STATION_BUMPED"
+	EventCourseFired     SaleEventType = "COURSE_FIRED"
+	EventOrderOverdue    SaleEventType = "ORDER_OVERDUE"
 )
 
 // SaleEvent represents the payload sent over WebSockets
@@ -29,7 +34,7 @@ type SaleEvent struct {
 // KDSWebsocketHub manages all active KDS connections
 type KDSWebsocketHub struct {
 	// Map BusinessID -> map of connections
-	Clients    map[uint]map[*websocket.Conn]bool
+	Clients    map[uint]map[*websocket.Conn]*KDSConn
 	Broadcast  chan SaleEvent
 	Register   chan *KDSConn
 	Unregister chan *KDSConn
@@ -38,6 +43,7 @@ type KDSWebsocketHub struct {
 
 type KDSConn struct {
 	BusinessID uint
+	StationID  uint // 0 = every station (expo screen)
 	Conn       *websocket.Conn
 }
 
@@ -45,7 +51,7 @@ var GlobalKDSHub *KDSWebsocketHub
 
 func init() {
 	GlobalKDSHub = &KDSWebsocketHub{
-		Clients:    make(map[uint]map[*websocket.Conn]bool),
+		Clients:    make(map[uint]map[*websocket.Conn]*KDSConn),
 		Broadcast:  make(chan SaleEvent),
 		Register:   make(chan *KDSConn),
 		Unregister: make(chan *KDSConn),
@@ -59,9 +65,9 @@ func (h *KDSWebsocketHub) Run() {
 		case client := <-h.Register:
 			h.mu.Lock()
 			if h.Clients[client.BusinessID] == nil {
-				h.Clients[client.BusinessID] = make(map[*websocket.Conn]bool)
+				h.Clients[client.BusinessID] = make(map[*websocket.Conn]*KDSConn)
 			}
-			h.Clients[client.BusinessID][client.Conn] = true
+			h.Clients[client.BusinessID][client.Conn] = client
 			h.mu.Unlock()
 			log.Printf("[KDS] Client registered for business %d", client.BusinessID)
 
@@ -83,8 +89,18 @@ func (h *KDSWebsocketHub) Run() {
 			h.mu.RLock()
 			connections := h.Clients[event.BusinessID]
 			if connections != nil {
-				message, _ := json.Marshal(event)
-				for conn := range connections {
+				messages := map[uint][]byte{}
+				for conn, client := range connections {
+					message, ok := messages[client.StationID]
+					if !ok {
+						if view, send := stationView(event, client.StationID); send {
+							message, _ = json.Marshal(view)
+						}
+						messages[client.StationID] = message
+					}
+					if message == nil {
+						continue
+					}
 					if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
 						log.Printf("[KDS ERROR] Broadcast error: %v", err)
 						conn.Close()
@@ -106,3 +122,66 @@ func (h *KDSWebsocketHub) BroadcastOrder(bizID uint, eventType SaleEventType, da
 		Data:       data,
 	}
 }
+
+// stationView is the event as a station screen sees it: a sale carries only the items
+// routed to the station, less held courses, and station-specific updates only reach that
+// station. New orders with nothing for the station are not sent to it at all. Every
+// screen gets the sale's held and fired courses.
+func stationView(event SaleEvent, stationID uint) (SaleEvent, bool) {
+	var sale *Sale
+	switch data := event.Data.(type) {
+	case *Sale:
+		sale = data
+	case Sale:
+		sale = &data
+	case *SaleResult:
+		return stationResultView(event, data, stationID)
+	case fiber.Map:
+		if id, ok := data["station_id"].(uint); ok && stationID != 0 && id != stationID {
+			return event, false
+		}
+		return event, true
+	default:
+		return event, true
+	}
+	if sale == nil || len(sale.SaleItems) == 0 {
+		return event, true
+	}
+
+	view := *sale
+	view.Courses = saleCourses(sale.SaleItems)
+	if stationID == 0 {
+		event.Data = &view
+		return event, true
+	}
+	view.SaleItems = nil
+	for _, item := range sale.SaleItems {
+		if item.StationID != nil && *item.StationID == stationID && !item.held() {
+			view.SaleItems = append(view.SaleItems, item)
+		}
+	}
+	if len(view.SaleItems) == 0 && event.Type == EventOrderCreated {
+		return event, false
+	}
+	event.Data = &view
+	return event, true
+}
+
+// stationResultView filters a sale sent with its items beside it (as items are added)
+// the same way as a sale carrying its own
+func stationResultView(event SaleEvent, result *SaleResult, stationID uint) (SaleEvent, bool) {
+	if result == nil || result.Sale == nil {
+		return event, true
+	}
+	sale := *result.Sale
+	sale.SaleItems = result.Items
+	filtered, send := stationView(SaleEvent{Type: event.Type, BusinessID: event.BusinessID, Data: &sale}, stationID)
+	if !send {
+		return event, false
+	}
+
+	view := *filtered.Data.(*Sale)
+	event.Data = &SaleResult{Sale: &view, Items: view.SaleItems}
+	view.SaleItems = nil
+	return event, true
+}
@@ -0,0 +1,363 @@
+// internal/sale/kds_station.go
+package sale
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// KitchenStation is a prep station (grill, bar, pastry...) in an outlet. Items are routed
+// to the station whose CategoryIDs hold their product's category, or else to the outlet's
+// default station; items no station takes only show on the all-stations (expo) screen.
+type KitchenStation struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	BusinessID  uint      `gorm:"index" json:"business_id"`
+	OutletID    uint      `gorm:"index" json:"outlet_id"`
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	CategoryIDs []uint    `gorm:"serializer:json;type:text" json:"category_ids"`
+	IsDefault   bool      `gorm:"default:false" json:"is_default"` // takes the outlet's unmapped categories
+	Active      bool      `gorm:"default:true" json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type KitchenStationRequest struct {
+	OutletID    uint   `json:"outlet_id" validate:"required"`
+	Name        string `json:"name" validate:"required"`
+	CategoryIDs []uint `json:"category_ids"`
+	IsDefault   bool   `json:"is_default"`
+	Active      *bool  `json:"active,omitempty"`
+}
+
+type StationBumpRequest struct {
+	Status PrepStatus `json:"status" validate:"required,oneof=PENDING PREPARING READY SERVED"`
+}
+
+// StationBump is the result of a station moving its items of a sale along
+type StationBump struct {
+	SaleID     uint       `json:"sale_id"`
+	StationID  uint       `json:"station_id"`
+	Status     PrepStatus `json:"status"`
+	SaleStatus PrepStatus `json:"sale_status"` // READY once every station has bumped the sale
+	Items      []SaleItem `json:"items"`
+}
+
+// StationTicket is a sale as a station sees it: only the items routed to it
+type StationTicket struct {
+	SaleID      uint       `json:"sale_id"`
+	OrderType   string     `json:"order_type"`
+	TableNumber string     `json:"table_number,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Items       []SaleItem `json:"items"`
+}
+
+// kitchenSaleStates are the sale states whose items are still on kitchen screens
+var kitchenSaleStates = []SaleStatus{StatusPending, StatusDraft, StatusHeld, StatusPendingPayment, StatusCompleted}
+
+func validPrepStatus(s PrepStatus) bool {
+	switch s {
+	case PrepPending, PrepPreparing, PrepReady, PrepServed:
+		return true
+	}
+	return false
+}
+
+// BeforeCreate routes a new line to its kitchen station and starts its ticket clock, so
+// every way items reach a sale (till, tables, online orders, quotations) is covered.
+// Lines no station makes never start a clock.
+func (i *SaleItem) BeforeCreate(tx *gorm.DB) error {
+	if i.StationID == nil && !i.IsGiftCard {
+		i.StationID = stationForItem(tx, i.SaleID, i.ProductID)
+	}
+	if i.QueuedAt == nil && i.StationID != nil && !i.IsGiftCard && !i.held() {
+		now := time.Now()
+		i.QueuedAt = &now
+	}
+	return nil
+}
+
+// stationForItem picks the station of the sale's outlet for a product's category, falling
+// back to the outlet's default station. A sale with no outlet yet (an online order) is
+// only routed when all of the business's stations are at one outlet; otherwise its lines
+// wait for the outlet that takes the order on (see routeToKitchen).
+func stationForItem(tx *gorm.DB, saleID, productID uint) *uint {
+	var sale struct {
+		BusinessID uint
+		OutletID   uint
+	}
+	tx.Session(&gorm.Session{NewDB: true}).Table("sales").Select("business_id, COALESCE(outlet_id, 0) AS outlet_id").Where("id = ?", saleID).Scan(&sale)
+	if sale.BusinessID == 0 {
+		return nil
+	}
+	return stationFor(tx, sale.BusinessID, sale.OutletID, productID)
+}
+
+func stationFor(tx *gorm.DB, businessID, outletID, productID uint) *uint {
+	var stations []KitchenStation
+	q := tx.Session(&gorm.Session{NewDB: true}).Where("business_id = ? AND active = ?", businessID, true)
+	if outletID != 0 {
+		q = q.Where("outlet_id = ?", outletID)
+	}
+	if q.Order("id ASC").Find(&stations).Error != nil || len(stations) == 0 {
+		return nil
+	}
+	if outletID == 0 {
+		for i := range stations {
+			if stations[i].OutletID != stations[0].OutletID {
+				return nil
+			}
+		}
+	}
+
+	var categoryID uint
+	tx.Session(&gorm.Session{NewDB: true}).Table("products").Select("category_id").Where("id = ?", productID).Scan(&categoryID)
+
+	var fallback *uint
+	for i := range stations {
+		for _, id := range stations[i].CategoryIDs {
+			if id == categoryID {
+				return &stations[i].ID
+			}
+		}
+		if stations[i].IsDefault && fallback == nil {
+			fallback = &stations[i].ID
+		}
+	}
+	return fallback
+}
+
+// routeToKitchen routes the lines of a sale not yet at a station, now that its outlet is
+// known, and starts the ticket clock of those the kitchen can start on
+func routeToKitchen(tx *gorm.DB, sale *Sale) error {
+	now := time.Now()
+	for i := range sale.SaleItems {
+		item := &sale.SaleItems[i]
+		if item.StationID != nil || item.IsGiftCard {
+			continue
+		}
+		if item.StationID = stationFor(tx, sale.BusinessID, sale.OutletID, item.ProductID); item.StationID == nil {
+			continue
+		}
+		updates := map[string]interface{}{"station_id": *item.StationID}
+		if item.QueuedAt == nil && !item.held() {
+			item.QueuedAt = &now
+			updates["queued_at"] = now
+		}
+		if err := tx.Model(&SaleItem{}).Where("id = ?", item.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListStations returns the business's kitchen stations, optionally for one outlet
+func ListStations(db *gorm.DB, businessID, outletID uint) ([]KitchenStation, error) {
+	stations := []KitchenStation{}
+	q := db.Where("business_id = ?", businessID)
+	if outletID != 0 {
+		q = q.Where("outlet_id = ?", outletID)
+	}
+	err := q.Order("outlet_id ASC, name ASC").Find(&stations).Error
+	return stations, err
+}
+
+// GetStation returns one of the business's kitchen stations
+func GetStation(db *gorm.DB, stationID, businessID uint) (*KitchenStation, error) {
+	var st KitchenStation
+	if err := db.First(&st, "id = ? AND business_id = ?", stationID, businessID).Error; err != nil {
+		return nil, errors.New("station not found")
+	}
+	return &st, nil
+}
+
+// SaveStation creates a station, or updates it when stationID is set. A category can only
+// be routed to one station per outlet, and an outlet has at most one default station.
+func SaveStation(db *gorm.DB, stationID, businessID uint, tenantID string, req KitchenStationRequest) (*KitchenStation, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, errors.New("station name is required")
+	}
+
+	var outlets int64
+	db.Table("outlets").Where("id = ? AND tenant_id = ?", req.OutletID, tenantID).Count(&outlets)
+	if outlets == 0 {
+		return nil, errors.New("outlet not found")
+	}
+
+	st := &KitchenStation{BusinessID: businessID, Active: true}
+	if stationID != 0 {
+		existing, err := GetStation(db, stationID, businessID)
+		if err != nil {
+			return nil, err
+		}
+		st = existing
+	}
+
+	var others []KitchenStation
+	db.Where("business_id = ? AND outlet_id = ? AND id <> ?", businessID, req.OutletID, st.ID).Find(&others)
+	for _, o := range others {
+		if req.IsDefault && o.IsDefault {
+			return nil, errors.New("the outlet already has a default station: " + o.Name)
+		}
+		for _, taken := range o.CategoryIDs {
+			for _, id := range req.CategoryIDs {
+				if id == taken {
+					return nil, errors.New("a category is already routed to station " + o.Name)
+				}
+			}
+		}
+	}
+
+	st.OutletID = req.OutletID
+	st.Name = req.Name
+	st.CategoryIDs = req.CategoryIDs
+	st.IsDefault = req.IsDefault
+	if req.Active != nil {
+		st.Active = *req.Active
+	}
+
+	if err := db.Save(st).Error; err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// DeleteStation removes a station; its items stay on the expo screen
+func DeleteStation(db *gorm.DB, stationID, businessID uint) error {
+	result := db.Where("id = ? AND business_id = ?", stationID, businessID).Delete(&KitchenStation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("station not found")
+	}
+	return db.Model(&SaleItem{}).Where("station_id = ?", stationID).Update("station_id", nil).Error
+}
+
+// StationQueue returns the open tickets of a station: sales with items routed to it that
+// are not ready yet, oldest first
+func StationQueue(db *gorm.DB, stationID, businessID uint) ([]StationTicket, error) {
+	if _, err := GetStation(db, stationID, businessID); err != nil {
+		return nil, err
+	}
+
+	var items []SaleItem
+	err := db.Joins("JOIN sales ON sales.id = sale_items.sale_id").
+		Where("sales.business_id = ? AND sales.deleted_at IS NULL AND sales.status IN ?", businessID, kitchenSaleStates).
+		Where("sale_items.station_id = ? AND sale_items.preparation_status IN ?", stationID, []PrepStatus{PrepPending, PrepPreparing}).
+		Where("sale_items.course = 0 OR sale_items.fired_at IS NOT NULL"). // held courses wait for their fire
+		Order("sale_items.sale_id ASC, sale_items.id ASC").
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	tickets := []StationTicket{}
+	bySale := map[uint]int{}
+	for _, it := range items {
+		idx, ok := bySale[it.SaleID]
+		if !ok {
+			var s Sale
+			if db.Select("id, order_type, table_number, created_at").First(&s, it.SaleID).Error != nil {
+				continue
+			}
+			tickets = append(tickets, StationTicket{SaleID: s.ID, OrderType: s.OrderType, TableNumber: s.TableNumber, CreatedAt: s.CreatedAt})
+			idx = len(tickets) - 1
+			bySale[it.SaleID] = idx
+		}
+		tickets[idx].Items = append(tickets[idx].Items, it)
+	}
+	return tickets, nil
+}
+
+// BumpStation moves a station's items of a sale to a new prep status and updates the
+// sale's own status from all of its items
+func BumpStation(db *gorm.DB, stationID, saleID, businessID, userID uint, status PrepStatus) (*StationBump, error) {
+	if !validPrepStatus(status) {
+		return nil, errors.New("invalid preparation status")
+	}
+	if _, err := GetStation(db, stationID, businessID); err != nil {
+		return nil, err
+	}
+
+	var sale Sale
+	if err := db.First(&sale, "id = ? AND business_id = ?", saleID, businessID).Error; err != nil {
+		return nil, errors.New("sale not found")
+	}
+
+	// Held courses stay put until they are fired
+	bumped, err := setItemsPrepStatus(db.Where("sale_id = ? AND station_id = ? AND (course = 0 OR fired_at IS NOT NULL)", saleID, stationID), status, userID)
+	if err != nil {
+		return nil, err
+	}
+	if bumped == 0 {
+		return nil, errors.New("sale has no items for this station")
+	}
+
+	saleStatus, err := syncSalePrepStatus(db, &sale)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []SaleItem
+	db.Where("sale_id = ? AND station_id = ?", saleID, stationID).Find(&items)
+
+	return &StationBump{
+		SaleID:     saleID,
+		StationID:  stationID,
+		Status:     status,
+		SaleStatus: saleStatus,
+		Items:      items,
+	}, nil
+}
+
+// syncSalePrepStatus works a sale's prep status out from its items: READY once every
+// station has bumped its items ready, SERVED once all are served. When stations are in
+// use only routed items count, since the rest are not made in the kitchen.
+func syncSalePrepStatus(db *gorm.DB, sale *Sale) (PrepStatus, error) {
+	var items []SaleItem
+	if err := db.Select("id, station_id, preparation_status, is_gift_card").Where("sale_id = ?", sale.ID).Find(&items).Error; err != nil {
+		return sale.PreparationStatus, err
+	}
+
+	routed := false
+	for _, it := range items {
+		if it.StationID != nil {
+			routed = true
+			break
+		}
+	}
+
+	counts := map[PrepStatus]int{}
+	total := 0
+	for _, it := range items {
+		if it.IsGiftCard || (routed && it.StationID == nil) {
+			continue
+		}
+		counts[it.PreparationStatus]++
+		total++
+	}
+	if total == 0 {
+		return sale.PreparationStatus, nil
+	}
+
+	status := PrepPending
+	switch {
+	case counts[PrepServed] == total:
+		status = PrepServed
+	case counts[PrepReady]+counts[PrepServed] == total:
+		status = PrepReady
+	case counts[PrepPending] < total:
+		status = PrepPreparing
+	}
+
+	if status != sale.PreparationStatus {
+		if err := db.Model(sale).Update("preparation_status", status).Error; err != nil {
+			return sale.PreparationStatus, err
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,321 @@
+// internal/sale/kds_station_controller.go
+package sale
+
+import (
+	"time"
+
+	"pos-fiber-app/internal/printing"
+	"pos-fiber-app/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ListStationsHandler godoc
+// @Summary List kitchen stations
+// @Tags KDS
+// @Security BearerAuth
+// @Produce json
+// @Param outlet_id query uint false "Outlet ID"
+// @Success 200 {array} KitchenStation
+// @Router /kds/stations [get]
+func ListStationsHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		stations, err := ListStations(db, bizID, uint(c.QueryInt("outlet_id")))
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch stations")
+		}
+
+		return c.JSON(stations)
+	}
+}
+
+// CreateStationHandler godoc
+// @Summary Create a kitchen station
+// @Description Items whose product category is in category_ids are routed to the station; a default station takes the outlet's other categories
+// @Tags KDS
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body KitchenStationRequest true "Station"
+// @Success 201 {object} KitchenStation
+// @Failure 422 {object} map[string]string
+// @Router /kds/stations [post]
+func CreateStationHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req KitchenStationRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		st, err := SaveStation(db, 0, bizID, claims.TenantID, req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(st)
+	}
+}
+
+// UpdateStationHandler godoc
+// @Summary Update a kitchen station
+// @Description Routing changes apply to items added from now on
+// @Tags KDS
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Station ID"
+// @Param body body KitchenStationRequest true "Station"
+// @Success 200 {object} KitchenStation
+// @Failure 404 {object} map[string]string
+// @Router /kds/stations/{id} [put]
+func UpdateStationHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid station ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req KitchenStationRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		st, err := SaveStation(db, uint(id), bizID, claims.TenantID, req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.JSON(st)
+	}
+}
+
+// DeleteStationHandler godoc
+// @Summary Delete a kitchen station
+// @Tags KDS
+// @Security BearerAuth
+// @Param id path uint true "Station ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /kds/stations/{id} [delete]
+func DeleteStationHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid station ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		if err := DeleteStation(db, uint(id), bizID); err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// StationQueueHandler godoc
+// @Summary A station's open tickets
+// @Description Sales with items routed to the station that are not ready yet, oldest first. Station screens load this, then follow /ws/kds?station_id=
+// @Tags KDS
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Station ID"
+// @Success 200 {array} StationTicket
+// @Failure 404 {object} map[string]string
+// @Router /kds/stations/{id}/queue [get]
+func StationQueueHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid station ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		tickets, err := StationQueue(db, uint(id), bizID)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.JSON(tickets)
+	}
+}
+
+// BumpStationHandler godoc
+// @Summary Bump a sale at a station
+// @Description Moves only the station's items of the sale along. The sale itself becomes READY once every station has bumped its items ready.
+// @Tags KDS
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Station ID"
+// @Param sale_id path uint true "Sale ID"
+// @Param body body StationBumpRequest true "New status"
+// @Success 200 {object} StationBump
+// @Failure 404 {object} map[string]string
+// @Router /kds/stations/{id}/sales/{sale_id} [patch]
+func BumpStationHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid station ID")
+		}
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req StationBumpRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid status")
+		}
+
+		bump, err := BumpStation(db, uint(id), uint(saleID), bizID, claims.UserID, req.Status)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		GlobalKDSHub.BroadcastOrder(bizID, EventStationBumped, fiber.Map{
+			"sale_id":     bump.SaleID,
+			"station_id":  bump.StationID,
+			"status":      bump.Status,
+			"sale_status": bump.SaleStatus,
+		})
+
+		return c.JSON(bump)
+	}
+}
+
+// FireCourseHandler godoc
+// @Summary Fire a course
+// @Description Releases the course's held lines to the kitchen stations and kitchen printers. Lines with a course number are held until it is fired; the fire is logged against the sale for ticket-time reporting.
+// @Tags KDS
+// @Security BearerAuth
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Param course path int true "Course number"
+// @Success 200 {object} FireCourseResult
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /sales/{sale_id}/courses/{course}/fire [post]
+func FireCourseHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+		course, err := c.ParamsInt("course")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid course")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		result, err := FireCourse(db, uint(saleID), bizID, claims.UserID, course)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		GlobalKDSHub.BroadcastOrder(bizID, EventCourseFired, result.Sale)
+
+		ticket := kitchenTicketFor(&Sale{
+			ID:           result.Sale.ID,
+			TableNumber:  result.Sale.TableNumber,
+			OrderType:    result.Sale.OrderType,
+			CustomerName: result.Sale.CustomerName,
+			SaleItems:    result.Fired,
+		})
+		ticket.Course = course
+		go printing.PrintKitchenOrder(db, claims.TenantID, result.Sale.OutletID, ticket)
+
+		return c.JSON(result)
+	}
+}
+
+// CourseTimesHandler godoc
+// @Summary Course ticket times
+// @Description Per course: how often it was fired and the average minutes from the sale being opened, and from the previous course, to the fire
+// @Tags KDS
+// @Security BearerAuth
+// @Produce json
+// @Param from query string false "YYYY-MM-DD (defaults to today)"
+// @Param to query string false "YYYY-MM-DD (defaults to from)"
+// @Success 200 {array} CourseTimes
+// @Router /sales/reports/courses [get]
+func CourseTimesHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		from := c.Query("from", time.Now().Format("2006-01-02"))
+		to := c.Query("to", from)
+
+		report, err := GetCourseTimes(db, bizID, from, to)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		return c.JSON(report)
+	}
+}
+
+// PrepTimesHandler godoc
+// @Summary Kitchen prep times
+// @Description Average and 90th-percentile minutes from the kitchen getting a line (added, or its course fired) to it being bumped ready, for lines bumped ready in the period
+// @Tags KDS
+// @Security BearerAuth
+// @Produce json
+// @Param from query string false "YYYY-MM-DD (defaults to today)"
+// @Param to query string false "YYYY-MM-DD (defaults to from)"
+// @Param group_by query string false "product (default), station, hour or staff"
+// @Success 200 {array} PrepTimeRow
+// @Router /kds/reports/prep-times [get]
+func PrepTimesHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		from := c.Query("from", time.Now().Format("2006-01-02"))
+		to := c.Query("to", from)
+
+		report, err := GetPrepTimes(db, bizID, from, to, c.Query("group_by", "product"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		return c.JSON(report)
+	}
+}
+
+// OverdueOrdersHandler godoc
+// @Summary Kitchen orders past the prep SLA
+// @Description Orders with lines waiting longer than the business's prep_sla_minutes, longest wait first. KDS screens are also sent an ORDER_OVERDUE event once per order.
+// @Tags KDS
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} OverdueOrder
+// @Router /kds/overdue [get]
+func OverdueOrdersHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		orders, err := ListOverdueOrders(db, bizID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch overdue orders")
+		}
+
+		return c.JSON(orders)
+	}
+}
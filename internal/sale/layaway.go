@@ -0,0 +1,502 @@
+// internal/sale/layaway.go
+package sale
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"pos-fiber-app/internal/inventory"
+	"pos-fiber-app/internal/recipe"
+	"pos-fiber-app/internal/shift"
+
+	"gorm.io/gorm"
+)
+
+// LayawayRequest puts a draft or held sale on layaway. The stock is held until the
+// layaway is paid off or cancelled.
+type LayawayRequest struct {
+	Deposits   []PaymentInfoRequest `json:"deposits" validate:"required,min=1"`
+	Discount   float64              `json:"discount" validate:"gte=0"`
+	CustomerID *uint                `json:"customer_id,omitempty"` // needed unless the sale already has a customer's phone
+	Approval   *OverrideApproval    `json:"approval,omitempty"`    // for a discount above the override threshold
+}
+
+// LayawayPaymentRequest pays an instalment towards a layaway
+type LayawayPaymentRequest struct {
+	Payments []PaymentInfoRequest `json:"payments" validate:"required,min=1"`
+}
+
+// CancelLayawayRequest cancels a layaway. The deposits go back less the business's
+// forfeiture fee, on the tenders they were paid with unless others are given.
+type CancelLayawayRequest struct {
+	Reason  string                `json:"reason,omitempty"`
+	Tenders []RefundTenderRequest `json:"tenders,omitempty"`
+}
+
+// LayawayResult is a layaway with what has been paid towards it
+type LayawayResult struct {
+	Sale      *Sale        `json:"sale"`
+	Items     []SaleItem   `json:"items"`
+	Payments  []Payment    `json:"payments"`
+	Paid      float64      `json:"paid"`
+	Balance   float64      `json:"balance"`
+	Change    float64      `json:"change,omitempty"`    // paid over the balance on the final instalment
+	Refunded  float64      `json:"refunded,omitempty"`  // given back on cancellation
+	Forfeited float64      `json:"forfeited,omitempty"` // kept on cancellation
+	Receipt   *SaleReceipt `json:"receipt,omitempty"`   // set once the layaway is paid off
+}
+
+// LayawayBalance is an open layaway in a list
+type LayawayBalance struct {
+	Sale
+	Paid    float64 `json:"paid"`
+	Balance float64 `json:"balance"`
+}
+
+// checkLayawayTenders refuses tenders that cannot be held as a deposit
+func checkLayawayTenders(payments []PaymentInfoRequest) error {
+	for _, p := range payments {
+		if isCreditTender(p.Method) || isLoyaltyTender(p.Method) || isGiftCardTender(p.Method) || isStoreCreditTender(p.Method) {
+			return fmt.Errorf("layaway deposits cannot be paid with %s", strings.ToUpper(p.Method))
+		}
+		if p.Tip != 0 {
+			return errors.New("tip cannot be taken on a layaway deposit")
+		}
+	}
+	return nil
+}
+
+// salePaid is what has been paid on a sale so far (layaway deposits, online payments),
+// net of anything refunded
+func salePaid(db *gorm.DB, saleID uint) float64 {
+	var paid float64
+	db.Model(&Payment{}).Where("sale_id = ?", saleID).Select("COALESCE(SUM(amount), 0)").Scan(&paid)
+	return roundMoney(paid)
+}
+
+// recordDeposits writes the instalments as payments on the sale and adds them to the
+// shift taking them. Anything paid over the balance is returned as change.
+func recordDeposits(tx *gorm.DB, sale *Sale, shiftID *uint, payments []PaymentInfoRequest, balance float64) (recorded, change float64, err error) {
+	var count int64
+	tx.Model(&Payment{}).Where("sale_id = ?", sale.ID).Count(&count)
+
+	if shiftID == nil {
+		shiftID = sale.ShiftID
+	}
+
+	now := time.Now()
+	for _, p := range payments {
+		if p.Amount <= 0 {
+			return 0, 0, errors.New("deposit amounts must be positive")
+		}
+		amount := roundMoney(math.Min(p.Amount, balance-recorded))
+		change += p.Amount - amount
+		if amount <= 0 {
+			continue
+		}
+
+		count++
+		payment := Payment{
+			SaleID:            sale.ID,
+			BusinessID:        sale.BusinessID,
+			Amount:            amount,
+			NetAmount:         amount,
+			Provider:          p.Method,
+			InternalReference: fmt.Sprintf("LAYAWAY-%d-%d", sale.ID, count),
+			Status:            ReconSuccess,
+			ReconciledAt:      now,
+			CreatedAt:         now,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return 0, 0, err
+		}
+		if shiftID != nil {
+			if err := shift.NewShiftService(tx).UpdateShiftMetrics(*shiftID, amount, p.Method); err != nil {
+				return 0, 0, err
+			}
+		}
+		recorded += amount
+	}
+	return roundMoney(recorded), roundMoney(change), nil
+}
+
+// StartLayaway prices a draft or held sale, holds its stock with no expiry and takes
+// the first deposit. A deposit covering the whole total completes the sale at once.
+func StartLayaway(db *gorm.DB, saleID, businessID, userID uint, shiftID *uint, req LayawayRequest) (*LayawayResult, error) {
+	if len(req.Deposits) == 0 {
+		return nil, errors.New("a deposit is required to start a layaway")
+	}
+	if err := checkLayawayTenders(req.Deposits); err != nil {
+		return nil, err
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var sale Sale
+	if err := tx.Preload("SaleItems").First(&sale, "id = ? AND business_id = ? AND status IN ?", saleID, businessID, []SaleStatus{StatusDraft, StatusHeld}).Error; err != nil {
+		return nil, errors.New("sale not found or cannot be put on layaway")
+	}
+	if len(sale.SaleItems) == 0 {
+		return nil, errors.New("cannot put an empty sale on layaway")
+	}
+
+	if err := authorizeSaleDiscount(tx, &sale, req.Discount, req.Approval, userID); err != nil {
+		return nil, err
+	}
+	if err := computeTotals(tx, &sale, sale.SaleItems, req.Discount); err != nil {
+		return nil, err
+	}
+	if err := attachCustomer(tx, &sale, req.CustomerID); err != nil {
+		return nil, err
+	}
+	if sale.CustomerID == nil && sale.CustomerPhone == "" {
+		return nil, errors.New("a customer is required for a layaway")
+	}
+
+	// Swap any draft reservations for ones that last until the layaway ends
+	reservationService := inventory.NewReservationService(tx)
+	if err := reservationService.ReleaseAllReservations(sale.ID); err != nil {
+		return nil, err
+	}
+	quantities := make(map[uint]float64)
+	var products []uint
+	for _, item := range sale.SaleItems {
+		if item.IsGiftCard {
+			return nil, errors.New("gift cards cannot be sold on layaway")
+		}
+		if _, seen := quantities[item.ProductID]; !seen {
+			products = append(products, item.ProductID)
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+	for _, productID := range products {
+		if err := reservationService.ReserveUntilReleased(sale.ID, productID, businessID, userID, quantities[productID]); err != nil {
+			return nil, err
+		}
+	}
+
+	sale.Status = StatusLayaway
+	sale.Discount = req.Discount
+	if sale.ShiftID == nil {
+		sale.ShiftID = shiftID
+	}
+	if err := tx.Save(&sale).Error; err != nil {
+		return nil, err
+	}
+
+	paid, change, err := recordDeposits(tx, &sale, shiftID, req.Deposits, sale.Total)
+	if err != nil {
+		return nil, err
+	}
+	if err := LogActivity(tx, sale.ID, businessID, userID, ActionLayaway, ActivityDetails{
+		AmountPaid:    paid,
+		PaymentMethod: joinMethods(req.Deposits),
+	}); err != nil {
+		return nil, err
+	}
+
+	return settleLayaway(db, tx, &sale, userID, change)
+}
+
+// PayLayaway takes an instalment towards a layaway, completing the sale once it is paid off
+func PayLayaway(db *gorm.DB, saleID, businessID, userID uint, shiftID *uint, req LayawayPaymentRequest) (*LayawayResult, error) {
+	if len(req.Payments) == 0 {
+		return nil, errors.New("a payment is required")
+	}
+	if err := checkLayawayTenders(req.Payments); err != nil {
+		return nil, err
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var sale Sale
+	if err := tx.Preload("SaleItems").First(&sale, "id = ? AND business_id = ? AND status = ?", saleID, businessID, StatusLayaway).Error; err != nil {
+		return nil, errors.New("layaway not found")
+	}
+
+	balance := roundMoney(sale.Total - salePaid(tx, sale.ID))
+	paid, change, err := recordDeposits(tx, &sale, shiftID, req.Payments, balance)
+	if err != nil {
+		return nil, err
+	}
+	if err := LogActivity(tx, sale.ID, businessID, userID, ActionDeposit, ActivityDetails{
+		AmountPaid:    paid,
+		PaymentMethod: joinMethods(req.Payments),
+	}); err != nil {
+		return nil, err
+	}
+
+	return settleLayaway(db, tx, &sale, userID, change)
+}
+
+// settleLayaway completes a layaway that has been paid off, commits and returns where it stands
+func settleLayaway(db, tx *gorm.DB, sale *Sale, userID uint, change float64) (*LayawayResult, error) {
+	paid := salePaid(tx, sale.ID)
+
+	var receipt *SaleReceipt
+	if paid >= roundMoney(sale.Total) {
+		var err error
+		if receipt, err = completePrepaidSale(db, tx, sale, userID, change); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	if receipt != nil {
+		queueEReceipts(db, sale)
+	}
+
+	result, err := GetLayaway(db, sale.ID, sale.BusinessID)
+	if err != nil {
+		return nil, err
+	}
+	result.Change = change
+	result.Receipt = receipt
+	return result, nil
+}
+
+// completePrepaidSale completes a sale whose payments are already recorded, such as a
+// paid-off layaway: any held stock is released and deducted, loyalty points are earned
+// and the sale gets its receipt number
+func completePrepaidSale(db, tx *gorm.DB, sale *Sale, userID uint, change float64) (*SaleReceipt, error) {
+	if err := inventory.NewReservationService(tx).ReleaseAllReservations(sale.ID); err != nil {
+		return nil, err
+	}
+
+	recipeSvc := recipe.NewRecipeService(db)
+	stockTx := saleStock(tx, sale, inventory.ReasonSale, userID)
+	for _, item := range sale.SaleItems {
+		if err := recipeSvc.AdjustStockWithRecipe(stockTx, item.ProductID, sale.BusinessID, item.Quantity); err != nil {
+			return nil, errors.New("failed to update inventory: " + err.Error())
+		}
+		if err := deductModifierStock(stockTx, recipeSvc, sale.BusinessID, item, item.Quantity); err != nil {
+			return nil, errors.New("failed to update inventory: " + err.Error())
+		}
+	}
+
+	if err := earnLoyaltyPoints(tx, sale, sale.SaleItems, 0); err != nil {
+		return nil, err
+	}
+
+	var methods []string
+	tx.Model(&Payment{}).Where("sale_id = ?", sale.ID).Distinct().Pluck("provider", &methods)
+	sale.PaymentMethod = "SPLIT"
+	if len(methods) == 1 {
+		sale.PaymentMethod = methods[0]
+	}
+
+	seq, err := getNextDailySequence(tx, sale.BusinessID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sale.Status = StatusCompleted
+	sale.SaleDate = now // counted in the reports on the day it is completed
+	sale.SyncedAt = &now
+	sale.DailySequence = seq
+	if err := tx.Save(sale).Error; err != nil {
+		return nil, err
+	}
+	if err := recordShiftGratuities(tx, sale, 1); err != nil {
+		return nil, err
+	}
+
+	if err := LogActivity(tx, sale.ID, sale.BusinessID, userID, ActionCompleted, ActivityDetails{
+		AmountPaid:    sale.Total,
+		PaymentMethod: sale.PaymentMethod,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &SaleReceipt{
+		Sale:        sale,
+		Items:       sale.SaleItems,
+		Change:      change,
+		ReceiptNo:   generateReceiptNo(sale.DailySequence),
+		GeneratedAt: now,
+	}, nil
+}
+
+// CancelLayaway releases a layaway's stock and refunds the deposits, keeping the
+// business's forfeiture fee (a percentage of the total, never more than was paid)
+func CancelLayaway(db *gorm.DB, saleID, businessID, userID uint, shiftID *uint, req CancelLayawayRequest) (*LayawayResult, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var sale Sale
+	if err := tx.First(&sale, "id = ? AND business_id = ? AND status = ?", saleID, businessID, StatusLayaway).Error; err != nil {
+		return nil, errors.New("layaway not found")
+	}
+
+	var biz struct {
+		LayawayForfeitPercent float64
+	}
+	tx.Table("businesses").Select("layaway_forfeit_percent").Where("id = ?", businessID).Scan(&biz)
+
+	paid := salePaid(tx, sale.ID)
+	fee := roundMoney(math.Min(paid, sale.Total*biz.LayawayForfeitPercent/100))
+	refundAmount := roundMoney(paid - fee)
+
+	if refundAmount > 0 {
+		tenders, err := resolveRefundTenders(tx, sale.ID, refundAmount, req.Tenders)
+		if err != nil {
+			return nil, err
+		}
+		if shiftID == nil {
+			shiftID = sale.ShiftID
+		}
+
+		now := time.Now()
+		for i, t := range tenders {
+			if isCreditTender(t.Method) || isLoyaltyTender(t.Method) || isGiftCardTender(t.Method) || isStoreCreditTender(t.Method) {
+				return nil, fmt.Errorf("layaway deposits cannot be refunded to %s", strings.ToUpper(t.Method))
+			}
+			payment := Payment{
+				SaleID:            sale.ID,
+				BusinessID:        businessID,
+				Amount:            -t.Amount,
+				NetAmount:         -t.Amount,
+				Provider:          t.Method,
+				InternalReference: fmt.Sprintf("LAYAWAY-REFUND-%d-%d", sale.ID, i+1),
+				Status:            ReconSuccess,
+				ReconciledAt:      now,
+				CreatedAt:         now,
+			}
+			if err := tx.Create(&payment).Error; err != nil {
+				return nil, err
+			}
+			if shiftID != nil {
+				if err := shift.NewShiftService(tx).UpdateShiftMetrics(*shiftID, -t.Amount, t.Method); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if err := inventory.NewReservationService(tx).ReleaseAllReservations(sale.ID); err != nil {
+		return nil, err
+	}
+
+	sale.Status = StatusLayawayCancelled
+	sale.LayawayForfeit = fee
+	sale.SaleDate = time.Now() // the fee kept is reported on the day of the cancellation
+	if err := tx.Save(&sale).Error; err != nil {
+		return nil, err
+	}
+
+	if err := LogActivity(tx, sale.ID, businessID, userID, ActionLayawayCancel, ActivityDetails{
+		Reason:     req.Reason,
+		AmountPaid: refundAmount,
+		NewValue:   fee,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	result, err := GetLayaway(db, sale.ID, businessID)
+	if err != nil {
+		return nil, err
+	}
+	result.Refunded = refundAmount
+	result.Forfeited = fee
+	return result, nil
+}
+
+// sumLayawayForfeits totals the deposits kept on layaways cancelled in [start, end). The
+// money stays in the tills (only the rest is refunded) but no sale counts it as takings.
+func sumLayawayForfeits(db *gorm.DB, businessID uint, start, end time.Time) float64 {
+	var total float64
+	db.Model(&Sale{}).
+		Where("business_id = ? AND status = ? AND sale_date >= ? AND sale_date < ?", businessID, StatusLayawayCancelled, start, end).
+		Select("COALESCE(SUM(layaway_forfeit), 0)").
+		Scan(&total)
+	return roundMoney(total)
+}
+
+// GetLayaway returns a layaway (open, paid off or cancelled) with its payments and balance
+func GetLayaway(db *gorm.DB, saleID, businessID uint) (*LayawayResult, error) {
+	var sale Sale
+	if err := db.Preload("SaleItems").Preload("Payments", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&sale, "id = ? AND business_id = ?", saleID, businessID).Error; err != nil {
+		return nil, errors.New("layaway not found")
+	}
+
+	var started int64
+	db.Model(&SaleActivityLog{}).Where("sale_id = ? AND action_type = ?", saleID, ActionLayaway).Count(&started)
+	if started == 0 {
+		return nil, errors.New("sale was not sold on layaway")
+	}
+
+	result := &LayawayResult{
+		Sale:     &sale,
+		Items:    sale.SaleItems,
+		Payments: sale.Payments,
+		Paid:     salePaid(db, sale.ID),
+	}
+	if sale.Status == StatusLayaway {
+		result.Balance = roundMoney(sale.Total - result.Paid)
+	}
+	return result, nil
+}
+
+// ListLayaways returns the open layaways, oldest first, with what is left to pay on each
+func ListLayaways(db *gorm.DB, businessID uint, customerID *uint) ([]LayawayBalance, error) {
+	query := db.Where("business_id = ? AND status = ?", businessID, StatusLayaway)
+	if customerID != nil {
+		query = query.Where("customer_id = ?", *customerID)
+	}
+
+	var sales []Sale
+	if err := query.Order("created_at ASC").Find(&sales).Error; err != nil {
+		return nil, err
+	}
+	if len(sales) == 0 {
+		return []LayawayBalance{}, nil
+	}
+
+	ids := make([]uint, len(sales))
+	for i, s := range sales {
+		ids[i] = s.ID
+	}
+	var rows []struct {
+		SaleID uint
+		Paid   float64
+	}
+	if err := db.Model(&Payment{}).
+		Select("sale_id, COALESCE(SUM(amount), 0) AS paid").
+		Where("sale_id IN ?", ids).
+		Group("sale_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	paid := make(map[uint]float64, len(rows))
+	for _, r := range rows {
+		paid[r.SaleID] = roundMoney(r.Paid)
+	}
+
+	out := make([]LayawayBalance, len(sales))
+	for i, s := range sales {
+		out[i] = LayawayBalance{Sale: s, Paid: paid[s.ID], Balance: roundMoney(s.Total - paid[s.ID])}
+	}
+	return out, nil
+}
+
+func joinMethods(payments []PaymentInfoRequest) string {
+	methods := make([]string, 0, len(payments))
+	for _, p := range payments {
+		methods = append(methods, p.Method)
+	}
+	return strings.Join(methods, ",")
+}
@@ -0,0 +1,192 @@
+// internal/sale/layaway_controller.go
+package sale
+
+import (
+	"pos-fiber-app/internal/subscription"
+	"pos-fiber-app/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// currentShiftID is the shift ShiftGuard found for the request, if any
+func currentShiftID(c *fiber.Ctx) *uint {
+	if sid, ok := c.Locals("shift_id").(uint); ok {
+		return &sid
+	}
+	return nil
+}
+
+// StartLayawayHandler godoc
+// @Summary Put a sale on layaway
+// @Description Prices a draft or held sale, holds its stock until it is paid off or cancelled and takes the first deposit. Deposits are cash, card or transfer; a deposit covering the total completes the sale.
+// @Tags Layaways
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Param body body LayawayRequest true "Deposit, discount and customer"
+// @Success 201 {object} LayawayResult
+// @Failure 400 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /sales/{sale_id}/layaway [post]
+func StartLayawayHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req LayawayRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		result, err := StartLayaway(db, uint(saleID), bizID, claims.UserID, currentShiftID(c), req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		if result.Receipt != nil {
+			go subscription.EvaluateTrialActivation(db, bizID)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(result)
+	}
+}
+
+// PayLayawayHandler godoc
+// @Summary Pay an instalment towards a layaway
+// @Description Records the payment against the layaway. Once the balance is paid the sale is completed, its stock deducted and the receipt issued; anything paid over the balance comes back as change.
+// @Tags Layaways
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Param body body LayawayPaymentRequest true "Payments"
+// @Success 200 {object} LayawayResult
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /sales/{sale_id}/layaway/payments [post]
+func PayLayawayHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req LayawayPaymentRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		result, err := PayLayaway(db, uint(saleID), bizID, claims.UserID, currentShiftID(c), req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		if result.Receipt != nil {
+			go subscription.EvaluateTrialActivation(db, bizID)
+		}
+
+		return c.JSON(result)
+	}
+}
+
+// CancelLayawayHandler godoc
+// @Summary Cancel a layaway
+// @Description Releases the held stock and refunds the deposits, keeping the business's layaway forfeiture fee
+// @Tags Layaways
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Param body body CancelLayawayRequest false "Reason and optional refund tenders"
+// @Success 200 {object} LayawayResult
+// @Failure 404 {object} map[string]string
+// @Router /sales/{sale_id}/layaway/cancel [post]
+func CancelLayawayHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req CancelLayawayRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+			}
+		}
+
+		result, err := CancelLayaway(db, uint(saleID), bizID, claims.UserID, currentShiftID(c), req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.JSON(result)
+	}
+}
+
+// GetLayawayHandler godoc
+// @Summary Get a layaway with its payments and balance
+// @Tags Layaways
+// @Security BearerAuth
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Success 200 {object} LayawayResult
+// @Failure 404 {object} map[string]string
+// @Router /sales/{sale_id}/layaway [get]
+func GetLayawayHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		result, err := GetLayaway(db, uint(saleID), bizID)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.JSON(result)
+	}
+}
+
+// ListLayawaysHandler godoc
+// @Summary List open layaways
+// @Tags Layaways
+// @Security BearerAuth
+// @Produce json
+// @Param customer_id query uint false "Customer ID"
+// @Success 200 {array} LayawayBalance
+// @Router /sales/layaways [get]
+func ListLayawaysHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		var customerID *uint
+		if id := c.QueryInt("customer_id"); id > 0 {
+			cid := uint(id)
+			customerID = &cid
+		}
+
+		layaways, err := ListLayaways(db, bizID, customerID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch layaways")
+		}
+
+		return c.JSON(layaways)
+	}
+}
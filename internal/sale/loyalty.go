@@ -0,0 +1,69 @@
+// internal/sale/loyalty.go
+package sale
+
+import (
+	"errors"
+	"strings"
+
+	"pos-fiber-app/internal/loyalty"
+
+	"gorm.io/gorm"
+)
+
+// PaymentMethodLoyalty is the tender for the part of a sale paid with loyalty points
+const PaymentMethodLoyalty = "LOYALTY"
+
+func isLoyaltyTender(method string) bool {
+	return strings.EqualFold(method, PaymentMethodLoyalty)
+}
+
+// redeemLoyaltyPoints spends the customer's points for the LOYALTY part of a sale's
+// payments and returns the amount paid with points
+func redeemLoyaltyPoints(tx *gorm.DB, sale *Sale, payments []PaymentInfoRequest) (float64, error) {
+	var amount float64
+	for _, p := range payments {
+		if isLoyaltyTender(p.Method) {
+			amount += p.Amount
+		}
+	}
+	if amount <= 0 {
+		return 0, nil
+	}
+
+	if sale.CustomerID == nil {
+		return 0, errors.New("a customer is required for LOYALTY payments")
+	}
+	if roundMoney(amount) > roundMoney(sale.Total) {
+		return 0, errors.New("LOYALTY payment cannot exceed the sale total")
+	}
+
+	if _, err := loyalty.Redeem(tx, sale.BusinessID, *sale.CustomerID, sale.ID, amount); err != nil {
+		return 0, err
+	}
+	return roundMoney(amount), nil
+}
+
+// earnLoyaltyPoints credits the sale's customer with points on what they paid for the
+// goods. Line amounts carry their share of the sale-level discount and tax; the service
+// charge and delivery fee earn nothing. Gift cards earn nothing when bought; points are
+// earned when the card is spent.
+func earnLoyaltyPoints(tx *gorm.DB, sale *Sale, items []SaleItem, paidWithPoints float64) error {
+	if sale.CustomerID == nil {
+		return nil
+	}
+
+	ratio := 1.0
+	if sale.Subtotal > 0 {
+		ratio = (sale.Total - sale.ServiceCharge - sale.DeliveryFee) / sale.Subtotal
+	}
+	lines := make([]loyalty.EarnLine, 0, len(items))
+	for _, item := range items {
+		if item.IsGiftCard {
+			continue
+		}
+		lines = append(lines, loyalty.EarnLine{ProductID: item.ProductID, Amount: item.TotalPrice * ratio})
+	}
+
+	_, err := loyalty.Earn(tx, sale.BusinessID, *sale.CustomerID, sale.ID, lines, paidWithPoints)
+	return err
+}
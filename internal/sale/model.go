@@ -4,9 +4,12 @@ package sale
 import (
 	"time"
 
+	"pos-fiber-app/internal/common"
+	"pos-fiber-app/internal/product"
+	"pos-fiber-app/internal/promotion"
+
 	"gorm.io/gorm"
 	// "pos-fiber-app/internal/business"
-	// "pos-fiber-app/internal/common"
 )
 
 type SaleStatus string
@@ -17,8 +20,18 @@ const (
 	StatusVoided         SaleStatus = "VOIDED"
 	StatusHeld           SaleStatus = "HELD"            // parked for later
 	StatusPendingPayment SaleStatus = "PENDING_PAYMENT" // awaiting external verification
+	StatusRefunded       SaleStatus = "REFUNDED"        // every item refunded
+	StatusLayaway        SaleStatus = "LAYAWAY"         // stock held while the customer pays in instalments
+	StatusPending        SaleStatus = "PENDING"         // online order waiting for staff to accept it
+	StatusRejected       SaleStatus = "REJECTED"        // online order staff turned down
+
+	StatusLayawayCancelled SaleStatus = "LAYAWAY_CANCELLED" // deposits refunded less the forfeiture fee
 )
 
+// revenueStatuses are the sale states that count towards sales reports.
+// Fully refunded sales stay in so their refunds can be netted off.
+var revenueStatuses = []SaleStatus{StatusCompleted, StatusRefunded}
+
 type ReconciliationStatus string
 
 const (
@@ -40,13 +53,21 @@ const (
 
 type Sale struct {
 	ID                uint       `gorm:"primaryKey" json:"id"`
-	BusinessID        uint       `gorm:"index;index:idx_business_saledate" json:"business_id"`
+	BusinessID        uint       `gorm:"index;index:idx_business_saledate;uniqueIndex:idx_sale_business_client_uuid,priority:1;uniqueIndex:idx_sale_business_idempotency,priority:1" json:"business_id"`
 	TenantID          string     `gorm:"index;size:8" json:"tenant_id"`
 	CustomerName      string     `json:"customer_name,omitempty"`
 	CustomerPhone     string     `json:"customer_phone,omitempty"`
+	CustomerEmail     string     `gorm:"size:150" json:"customer_email,omitempty"`
+	CustomerID        *uint      `gorm:"index" json:"customer_id,omitempty"` // Set when sold to a customer from the directory
 	Subtotal          float64    `gorm:"type:decimal(12,2)" json:"subtotal"`
-	Tax               float64    `gorm:"type:decimal(12,2)" json:"tax"`
+	Tax               float64    `gorm:"type:decimal(12,2)" json:"tax"`          // VAT worked out from the lines
+	TaxInclusive      bool       `gorm:"default:false" json:"tax_inclusive"` // Tax is inside Subtotal rather than added to Total
 	Discount          float64    `gorm:"type:decimal(12,2)" json:"discount"`
+	DiscountApprovedBy *uint     `json:"discount_approved_by,omitempty"` // set when the discount needed a manager
+	PromoDiscount     float64    `gorm:"type:decimal(12,2);default:0" json:"promo_discount"` // sum of the lines' promotion discounts, already out of Subtotal
+	ServiceCharge     float64    `gorm:"type:decimal(12,2);default:0" json:"service_charge"` // automatic service charge, included in Total
+	DeliveryFee       float64    `gorm:"type:decimal(12,2);default:0" json:"delivery_fee"`   // charged on delivery orders, included in Total
+	Tip               float64    `gorm:"type:decimal(12,2);default:0" json:"tip"`            // tips paid on top of Total
 	Total             float64    `gorm:"type:decimal(12,2)" json:"total"`
 	PaymentMethod     string     `json:"payment_method"` // CASH, CARD, TRANSFER, etc.
 	Status            SaleStatus `gorm:"type:varchar(20);default:'DRAFT'" json:"status"`
@@ -58,19 +79,30 @@ type Sale struct {
 	DailySequence     int        `gorm:"type:int;default:0" json:"daily_sequence"` // resets daily
 	SaleDate          time.Time  `gorm:"index:idx_business_saledate" json:"sale_date"`
 	SyncedAt          *time.Time `json:"synced_at,omitempty"` // for offline sync
+	// Offline sale keys are unique per business, as findSyncedSale looks them up
+	ClientUUID        *string    `gorm:"size:36;uniqueIndex:idx_sale_business_client_uuid,priority:2" json:"client_uuid,omitempty"`      // the device's ID for a sale made offline
+	IdempotencyKey    *string    `gorm:"size:100;uniqueIndex:idx_sale_business_idempotency,priority:2" json:"idempotency_key,omitempty"` // the upload that created it
 	// New fields for table management and shift tracking
 	TableID           *uint          `gorm:"index" json:"table_id,omitempty"`
 	TableNumber       string         `json:"table_number,omitempty"`                               // Snapshot for history
 	OrderType         string         `gorm:"type:varchar(20);default:'dine-in'" json:"order_type"` // dine-in, takeaway, delivery
+	Channel           string         `gorm:"type:varchar(20);default:'POS'" json:"channel"`        // POS, or QR_MENU for orders customers placed themselves
 	ShiftID           *uint          `gorm:"index" json:"shift_id,omitempty"`                      // Link to cashier's shift
 	PreparationStatus PrepStatus     `gorm:"type:varchar(20);default:'PENDING'" json:"preparation_status"`
+	PrepAlertedAt     *time.Time     `json:"prep_alerted_at,omitempty"` // when the order was flagged past the kitchen SLA
+	ParentSaleID      *uint          `gorm:"index" json:"parent_sale_id,omitempty"` // Set on bills split off another sale
+	SplitWays         int            `gorm:"default:0" json:"split_ways,omitempty"` // Set on a bill split evenly and each of its shares
+	QuotationID       *uint          `gorm:"index" json:"quotation_id,omitempty"`   // Set on sales made from a quotation; they keep its prices
+	ReceiptPrintedAt  *time.Time     `json:"receipt_printed_at,omitempty"`              // first receipt print; later prints are marked COPY
+	LayawayForfeit    float64        `gorm:"type:decimal(12,2);default:0" json:"layaway_forfeit,omitempty"` // deposit kept when a layaway is cancelled
 	CreatedAt         time.Time      `json:"created_at"`
 	UpdatedAt         time.Time      `json:"updated_at"`
 	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
 
-	CashierName string     `gorm:"-" json:"cashier_name"` // Populated manually or via join
-	SaleItems   []SaleItem `gorm:"foreignKey:SaleID;constraint:OnDelete:CASCADE" json:"items"`
-	Payments    []Payment  `gorm:"foreignKey:SaleID;constraint:OnDelete:CASCADE" json:"payments"`
+	CashierName string        `gorm:"-" json:"cashier_name"` // Populated manually or via join
+	SaleItems   []SaleItem    `gorm:"foreignKey:SaleID;constraint:OnDelete:CASCADE" json:"items"`
+	Payments    []Payment     `gorm:"foreignKey:SaleID;constraint:OnDelete:CASCADE" json:"payments"`
+	Courses     []CourseState `gorm:"-" json:"courses,omitempty"` // held and fired courses, filled in for the kitchen
 }
 
 type Payment struct {
@@ -78,6 +110,7 @@ type Payment struct {
 	SaleID             uint                 `gorm:"index" json:"sale_id"`
 	BusinessID         uint                 `gorm:"index" json:"business_id"`
 	Amount             float64              `gorm:"type:decimal(12,2)" json:"amount"`
+	Tip                float64              `gorm:"type:decimal(12,2);default:0" json:"tip"` // paid on top of Amount
 	CommissionFee      float64              `gorm:"type:decimal(12,2)" json:"commission_fee"`
 	NetAmount          float64              `gorm:"type:decimal(12,2)" json:"net_amount"`
 	Provider           string               `json:"provider"`
@@ -109,18 +142,71 @@ type SaleItem struct {
 	SaleID            uint       `gorm:"index" json:"sale_id"`
 	ProductID         uint       `json:"product_id"`
 	ProductName       string     `json:"product_name"` // snapshot
-	Quantity          int        `json:"quantity"`
+	Quantity          float64    `gorm:"type:decimal(12,3)" json:"quantity"`
 	UnitPrice         float64    `gorm:"type:decimal(12,2)" json:"unit_price"` // snapshot
 	CostPrice         float64    `gorm:"type:decimal(12,2)" json:"cost_price"` // snapshot at time of sale
 	TotalPrice        float64    `gorm:"type:decimal(12,2)" json:"total_price"`
 	Profit            float64    `gorm:"type:decimal(12,2)" json:"profit"`
+	SeatNumber        int        `gorm:"default:0" json:"seat_number,omitempty"` // 0 = shared / unassigned
 	PreparationStatus PrepStatus `gorm:"type:varchar(20);default:'PENDING'" json:"preparation_status"`
+	StationID         *uint      `gorm:"index" json:"station_id,omitempty"` // kitchen station the line is routed to
+
+	// Coursed lines (Course > 0) are held back from the kitchen until their course is fired;
+	// course 0 goes to the kitchen straight away
+	Course  int        `gorm:"default:0" json:"course,omitempty"`
+	FiredAt *time.Time `json:"fired_at,omitempty"`
+
+	// When the line reached each prep status. QueuedAt is when the kitchen got it (added, or
+	// its course fired), set only on lines routed to a station; ReadyBy is who bumped it ready.
+	QueuedAt    *time.Time `gorm:"index" json:"queued_at,omitempty"`
+	PreparingAt *time.Time `json:"preparing_at,omitempty"`
+	ReadyAt     *time.Time `gorm:"index" json:"ready_at,omitempty"`
+	ServedAt    *time.Time `json:"served_at,omitempty"`
+	ReadyBy     *uint      `json:"ready_by,omitempty"`
+
+	// Chosen modifiers, snapshotted at time of sale. ModifierTotal is the per-unit sum of their
+	// price deltas and is included in TotalPrice; ModifierKey keeps differently-modified lines apart.
+	Modifiers     []product.SelectedModifier `gorm:"serializer:json;type:text" json:"modifiers,omitempty"`
+	ModifierTotal float64                    `gorm:"type:decimal(12,2);default:0" json:"modifier_total,omitempty"`
+	ModifierKey   string                     `gorm:"size:255;default:''" json:"-"`
+
+	// Gift card lines carry no stock; completing the sale issues one card per unit,
+	// or loads GiftCardCode when the line tops up (or sells a pre-printed) card
+	IsGiftCard   bool   `gorm:"default:false" json:"is_gift_card,omitempty"`
+	GiftCardCode string `gorm:"size:100;default:''" json:"gift_card_code,omitempty"`
+
+	// Automatic discounts from promotions, already taken off TotalPrice and Profit
+	PromoDiscount float64             `gorm:"type:decimal(12,2);default:0" json:"promo_discount,omitempty"`
+	Promotions    []promotion.Applied `gorm:"serializer:json;type:text" json:"promotions,omitempty"`
+
+	// Cashier adjustments, already taken off TotalPrice and Profit. ManualDiscount is an amount
+	// off the line; OriginalUnitPrice is the catalogue price when UnitPrice was overridden.
+	ManualDiscount     float64  `gorm:"type:decimal(12,2);default:0" json:"manual_discount,omitempty"`
+	OriginalUnitPrice  *float64 `gorm:"type:decimal(12,2)" json:"original_unit_price,omitempty"`
+	OverrideApprovedBy *uint    `json:"override_approved_by,omitempty"` // set when the adjustment needed a manager
+
+	// The price printed on a scale label. A labelled line is charged exactly this, whatever
+	// its quantity works out to, and is never merged with another line.
+	LabelPrice *float64 `gorm:"type:decimal(12,2)" json:"label_price,omitempty"`
+
+	// VAT on the line at its tax class's rate, after its share of any sale discount.
+	// NetAmount is the taxable amount excluding VAT.
+	TaxClass  common.TaxClass `gorm:"type:varchar(20)" json:"tax_class,omitempty"`
+	TaxRate   float64         `gorm:"type:decimal(5,2);default:0" json:"tax_rate"`
+	Tax       float64         `gorm:"type:decimal(12,2);default:0" json:"tax"`
+	NetAmount float64         `gorm:"type:decimal(12,2);default:0" json:"net_amount"`
 }
 
 type SalesReport struct {
 	FromDate                     string  `json:"from_date"`
 	ToDate                       string  `json:"to_date"`
-	TotalSales                   float64 `json:"total_sales"`
+	GrossSales                   float64 `json:"gross_sales"`
+	TotalRefunds                 float64 `json:"total_refunds"`
+	RefundTransactions           int     `json:"refund_transactions"`
+	TotalSales                   float64 `json:"total_sales"` // net of refunds, excluding service charges and tips
+	ServiceCharges               float64 `json:"service_charges"`
+	Tips                         float64 `json:"tips"`
+	LayawayForfeits              float64 `json:"layaway_forfeits"` // deposits kept on cancelled layaways, in TotalProfit
 	TotalCost                    float64 `json:"total_cost"`
 	TotalProfit                  float64 `json:"total_profit"`
 	TotalTransactions            int     `json:"total_transactions"`
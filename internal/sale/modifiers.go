@@ -0,0 +1,99 @@
+// internal/sale/modifiers.go
+package sale
+
+import (
+	"fmt"
+	"math"
+
+	"pos-fiber-app/internal/printing"
+	"pos-fiber-app/internal/product"
+	"pos-fiber-app/internal/recipe"
+
+	"gorm.io/gorm"
+)
+
+// resolveLineModifiers validates the modifiers requested for a new sale line.
+// Products that have variants must be sold through one of their variants.
+func resolveLineModifiers(db *gorm.DB, businessID uint, prod *product.Product, optionIDs []uint) ([]product.SelectedModifier, error) {
+	if product.HasVariants(db, prod.ID) {
+		return nil, fmt.Errorf("%s has variants; select a variant to sell", prod.Name)
+	}
+	return product.ResolveModifiers(db, businessID, prod.ID, optionIDs)
+}
+
+// applyModifiers snapshots the chosen modifiers onto a line
+func applyModifiers(item *SaleItem, modifiers []product.SelectedModifier) {
+	item.Modifiers = modifiers
+	item.ModifierKey = product.ModifierKey(modifiers)
+	item.ModifierTotal = 0
+	for _, m := range modifiers {
+		item.ModifierTotal += m.PriceDelta
+	}
+}
+
+// priceSaleItem sets a line's total and profit. Modifier deltas are charged per unit
+// and the line's promotion discount comes off the total.
+func priceSaleItem(item *SaleItem) {
+	unitPrice := item.UnitPrice + item.ModifierTotal
+	gross := item.Quantity*unitPrice - item.PromoDiscount
+	if item.LabelPrice != nil {
+		// A scale label is charged what it says, not the quantity worked back out of it
+		gross = *item.LabelPrice - item.PromoDiscount
+	}
+	// A cashier's discount never takes a line below zero, e.g. once a promotion also applies
+	if item.ManualDiscount > gross {
+		item.ManualDiscount = math.Max(roundMoney(gross), 0)
+	}
+	item.TotalPrice = gross - item.ManualDiscount
+	item.Profit = item.TotalPrice - item.CostPrice*item.Quantity
+}
+
+func modifierOptionIDs(item SaleItem) []uint {
+	ids := make([]uint, 0, len(item.Modifiers))
+	for _, m := range item.Modifiers {
+		ids = append(ids, m.OptionID)
+	}
+	return ids
+}
+
+// deductModifierStock deducts the recipe ingredients of a line's modifiers
+func deductModifierStock(tx *gorm.DB, recipeSvc *recipe.RecipeService, businessID uint, item SaleItem, quantity float64) error {
+	if len(item.Modifiers) == 0 {
+		return nil
+	}
+	return recipeSvc.AdjustStockForModifiers(tx, businessID, modifierOptionIDs(item), quantity)
+}
+
+// restockModifierStock puts a line's modifier ingredients back (voids and refunds)
+func restockModifierStock(tx *gorm.DB, recipeSvc *recipe.RecipeService, businessID uint, item SaleItem, quantity float64) error {
+	if len(item.Modifiers) == 0 {
+		return nil
+	}
+	return recipeSvc.RestockForModifiers(tx, businessID, modifierOptionIDs(item), quantity)
+}
+
+// kitchenTicketFor builds the kitchen printer ticket for a sale; held courses are left off
+// until they are fired
+func kitchenTicketFor(sale *Sale) printing.KitchenTicket {
+	ticket := printing.KitchenTicket{
+		OrderID:      sale.ID,
+		TableNumber:  sale.TableNumber,
+		OrderType:    sale.OrderType,
+		CustomerName: sale.CustomerName,
+	}
+	for _, item := range sale.SaleItems {
+		if item.held() {
+			continue
+		}
+		line := printing.KitchenTicketItem{
+			Name:       item.ProductName,
+			Quantity:   item.Quantity,
+			SeatNumber: item.SeatNumber,
+		}
+		for _, m := range item.Modifiers {
+			line.Modifiers = append(line.Modifiers, m.Name)
+		}
+		ticket.Items = append(ticket.Items, line)
+	}
+	return ticket
+}
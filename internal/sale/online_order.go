@@ -0,0 +1,576 @@
+// internal/sale/online_order.go
+package sale
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"pos-fiber-app/internal/inventory"
+	"pos-fiber-app/internal/product"
+	"pos-fiber-app/internal/shift"
+	"pos-fiber-app/internal/subscription"
+	"pos-fiber-app/internal/table"
+	"pos-fiber-app/pkg/paystack"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChannelQRMenu marks sales customers ordered themselves from the public QR menu
+const ChannelQRMenu = "QR_MENU"
+
+const (
+	maxOnlineOrderLines    = 50
+	maxOnlineOrderQuantity = 99
+)
+
+// PublicOrderRequest is an order a customer places from the QR menu. Prices are always
+// the catalogue's; ExpectedTotal, when sent, must match what the server works out.
+// Paying online comes after, against the order placed (see PayOnlineOrder).
+type PublicOrderRequest struct {
+	TableToken    string            `json:"table_token,omitempty"` // from a table's QR code; makes the order dine-in at that table
+	OrderType     string            `json:"order_type,omitempty" validate:"omitempty,oneof=dine-in takeaway delivery"`
+	CustomerName  string            `json:"customer_name,omitempty"`
+	CustomerPhone string            `json:"customer_phone,omitempty"`
+	CustomerEmail string            `json:"customer_email,omitempty" validate:"omitempty,email"`
+	Address       string            `json:"address,omitempty"` // required for delivery
+	Notes         string            `json:"notes,omitempty"`
+	Items         []PublicOrderItem `json:"items" validate:"required,min=1"`
+	ExpectedTotal *float64          `json:"expected_total,omitempty"`
+}
+
+type PublicOrderItem struct {
+	ProductID         uint    `json:"product_id" validate:"required"`
+	Quantity          float64 `json:"quantity" validate:"required,gt=0"`
+	ModifierOptionIDs []uint  `json:"modifier_option_ids,omitempty"`
+}
+
+// PublicOrderPaymentInfo is a payment the customer made with a provider for an order
+// already placed, to be verified server-side before it is recorded against the order.
+// The transaction must be started on the business's own account for the exact order
+// total, with the order's sale_id and business_id in its metadata.
+type PublicOrderPaymentInfo struct {
+	Provider  string `json:"provider" validate:"required,oneof=PAYSTACK"`
+	Reference string `json:"reference" validate:"required"`
+}
+
+// PublicOrderResult is what the customer gets back: the order and a token to follow it with
+type PublicOrderResult struct {
+	Sale       *Sale      `json:"sale"`
+	Items      []SaleItem `json:"items"`
+	Paid       float64    `json:"paid"`
+	OrderToken string     `json:"order_token"`
+}
+
+// OnlineOrderDecision is the outcome of staff accepting or rejecting an online order
+type OnlineOrderDecision struct {
+	Sale      *Sale        `json:"sale"`
+	Items     []SaleItem   `json:"items"`
+	Receipt   *SaleReceipt `json:"receipt,omitempty"`    // set when a prepaid order is accepted
+	RefundDue float64      `json:"refund_due,omitempty"` // paid online for an order that was rejected
+}
+
+type RejectOrderRequest struct {
+	Reason string `json:"reason" validate:"required"`
+}
+
+// PlaceOnlineOrder records an order from the public QR menu as a PENDING sale for staff
+// to accept. Items, prices and stock are checked server-side.
+func PlaceOnlineOrder(db *gorm.DB, slug string, req PublicOrderRequest) (*PublicOrderResult, error) {
+	var biz struct {
+		ID       uint
+		TenantID string
+	}
+	if err := db.Table("businesses").Select("id, tenant_id").Where("slug = ?", slug).Scan(&biz).Error; err != nil || biz.ID == 0 {
+		return nil, errors.New("business not found")
+	}
+	if !subscription.HasModule(db, biz.ID, subscription.ModuleQRMenu) {
+		return nil, errors.New("online orders are not available for this business")
+	}
+
+	if len(req.Items) == 0 {
+		return nil, errors.New("online order has no items")
+	}
+	if len(req.Items) > maxOnlineOrderLines {
+		return nil, fmt.Errorf("an online order can have at most %d lines", maxOnlineOrderLines)
+	}
+
+	sale := &Sale{
+		BusinessID:    biz.ID,
+		TenantID:      biz.TenantID,
+		Status:        StatusPending,
+		Channel:       ChannelQRMenu,
+		OrderType:     req.OrderType,
+		CustomerName:  strings.TrimSpace(req.CustomerName),
+		CustomerPhone: strings.TrimSpace(req.CustomerPhone),
+		CustomerEmail: strings.TrimSpace(req.CustomerEmail),
+		SaleDate:      time.Now(),
+	}
+
+	if req.TableToken != "" {
+		t, err := table.ResolveQRToken(db, biz.ID, req.TableToken)
+		if err != nil {
+			return nil, err
+		}
+		sale.TableID = &t.ID
+		sale.TableNumber = t.TableNumber
+		sale.OrderType = "dine-in"
+	}
+	switch sale.OrderType {
+	case "":
+		sale.OrderType = "takeaway"
+	case "dine-in":
+		if sale.TableID == nil {
+			return nil, errors.New("scan the table's QR code to order to a table")
+		}
+	case "takeaway":
+	case "delivery":
+		if strings.TrimSpace(req.Address) == "" || sale.CustomerPhone == "" {
+			return nil, errors.New("delivery orders need an address and a phone number")
+		}
+	default:
+		return nil, fmt.Errorf("invalid online order type %s", sale.OrderType)
+	}
+	if sale.OrderType != "dine-in" && sale.CustomerName == "" && sale.CustomerPhone == "" {
+		return nil, errors.New("a name or phone number is required to collect an online order")
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	if err := tx.Create(sale).Error; err != nil {
+		return nil, err
+	}
+	if sale.TableID != nil {
+		if err := table.NewTableService(tx).UpdateTableStatus(*sale.TableID, biz.ID, table.StatusOccupied); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, line := range req.Items {
+		if line.Quantity <= 0 || line.Quantity > maxOnlineOrderQuantity {
+			return nil, fmt.Errorf("online order quantities must be above 0 and at most %d", maxOnlineOrderQuantity)
+		}
+
+		var prod product.Product
+		if err := tx.First(&prod, "id = ? AND business_id = ? AND active = ?", line.ProductID, biz.ID, true).Error; err != nil {
+			return nil, fmt.Errorf("product %d is not on the menu", line.ProductID)
+		}
+		if prod.IsGiftCard {
+			return nil, errors.New("gift cards cannot be ordered online")
+		}
+		if err := inventory.CheckQuantity(line.Quantity, prod.QuantityPrecision); err != nil {
+			return nil, fmt.Errorf("%s: %w", prod.Name, err)
+		}
+
+		// Held like a till draft's, so the order cannot be oversold while it waits for staff
+		if err := inventory.NewReservationService(tx).ReserveStock(sale.ID, prod.ID, biz.ID, 0, line.Quantity); err != nil {
+			return nil, fmt.Errorf("insufficient stock for %s", prod.Name)
+		}
+
+		modifiers, err := resolveLineModifiers(tx, biz.ID, &prod, line.ModifierOptionIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		item := SaleItem{
+			SaleID:      sale.ID,
+			ProductID:   prod.ID,
+			ProductName: prod.Name,
+			Quantity:    line.Quantity,
+			UnitPrice:   prod.Price,
+			CostPrice:   prod.Cost,
+		}
+		applyModifiers(&item, modifiers)
+		priceSaleItem(&item)
+		if err := tx.Create(&item).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	items, err := repriceSale(tx, sale)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpectedTotal != nil && math.Abs(*req.ExpectedTotal-sale.Total) > 0.01 {
+		return nil, fmt.Errorf("prices have changed: the order total is now %.2f", sale.Total)
+	}
+
+	if sale.OrderType == "delivery" {
+		// The fee is left to staff, who can charge it once the order is accepted as a draft
+		d := &Delivery{
+			BusinessID:    biz.ID,
+			SaleID:        sale.ID,
+			Status:        DispatchPending,
+			CustomerName:  sale.CustomerName,
+			CustomerPhone: sale.CustomerPhone,
+		}
+		applyDeliveryRequest(d, DeliveryRequest{Address: req.Address, Notes: req.Notes})
+		if err := tx.Create(d).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := LogActivity(tx, sale.ID, biz.ID, 0, ActionCreated, ActivityDetails{
+		Reason:   "online order",
+		NewValue: req.Notes,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &PublicOrderResult{
+		Sale:       sale,
+		Items:      items,
+		OrderToken: OrderToken(sale.ID, sale.BusinessID),
+	}, nil
+}
+
+// PayOnlineOrder records the customer's online payment for an order still waiting for
+// staff, found by its order token. The order is paid once, in full.
+func PayOnlineOrder(db *gorm.DB, token string, info PublicOrderPaymentInfo) (*PublicOrderResult, error) {
+	saleID, businessID, err := parseSaleToken(token, orderTokenPurpose)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var sale Sale
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("SaleItems").
+		First(&sale, "id = ? AND business_id = ? AND channel = ?", saleID, businessID, ChannelQRMenu).Error; err != nil {
+		return nil, errors.New("order not found")
+	}
+	if sale.Status != StatusPending {
+		return nil, errors.New("payment could not be accepted: the order has already been taken on or turned down")
+	}
+	if salePaid(tx, sale.ID) > 0 {
+		return nil, errors.New("payment could not be accepted: the order is already paid")
+	}
+
+	paid, err := recordOnlinePayment(tx, &sale, info)
+	if err != nil {
+		return nil, err
+	}
+	if err := LogActivity(tx, sale.ID, businessID, 0, ActionUpdated, ActivityDetails{
+		Reason:     "paid online",
+		AmountPaid: paid,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &PublicOrderResult{
+		Sale:       &sale,
+		Items:      sale.SaleItems,
+		Paid:       paid,
+		OrderToken: token,
+	}, nil
+}
+
+// recordOnlinePayment verifies a customer's provider payment and records it on the order.
+// It is checked with the business's own Paystack account, must be for this order and
+// exactly its total, and each provider reference can only pay once.
+func recordOnlinePayment(tx *gorm.DB, sale *Sale, info PublicOrderPaymentInfo) (float64, error) {
+	provider := strings.ToUpper(strings.TrimSpace(info.Provider))
+	reference := strings.TrimSpace(info.Reference)
+	if provider != "PAYSTACK" {
+		return 0, fmt.Errorf("unsupported payment provider %s", info.Provider)
+	}
+	if reference == "" {
+		return 0, errors.New("payment reference is required")
+	}
+
+	var used int64
+	tx.Model(&Payment{}).Where("provider = ? AND external_reference = ?", provider, reference).Count(&used)
+	if used > 0 {
+		return 0, errors.New("payment reference has already been used")
+	}
+
+	var biz struct {
+		Currency          string
+		PaystackSecretKey string
+	}
+	tx.Table("businesses").Select("currency, paystack_secret_key").Where("id = ?", sale.BusinessID).Scan(&biz)
+	if biz.PaystackSecretKey == "" {
+		return 0, errors.New("payment could not be accepted: online payments are not set up for this business")
+	}
+
+	verification, err := paystack.NewClientWithKey(biz.PaystackSecretKey).VerifyTransaction(reference)
+	if err != nil {
+		return 0, fmt.Errorf("payment could not be verified: %w", err)
+	}
+	if !paymentIsFor(verification.Data.Metadata, sale.ID, sale.BusinessID) {
+		return 0, errors.New("payment could not be accepted: it was not made for this order")
+	}
+	if !strings.EqualFold(verification.Data.Currency, biz.Currency) {
+		return 0, fmt.Errorf("payment could not be accepted: it was made in %s but the business charges in %s", verification.Data.Currency, biz.Currency)
+	}
+	// Paystack amounts are in kobo; compared whole so rounding cannot let a short payment through
+	if int64(math.Round(verification.Data.Amount)) != int64(math.Round(sale.Total*100)) {
+		return 0, fmt.Errorf("payment could not be accepted: %.2f was paid but the order total is %.2f", verification.Data.Amount/100, sale.Total)
+	}
+	amount := roundMoney(verification.Data.Amount / 100)
+
+	internalRef := "QR-" + reference
+	if len(internalRef) > 50 {
+		internalRef = internalRef[:50]
+	}
+	now := time.Now()
+	payment := Payment{
+		SaleID:            sale.ID,
+		BusinessID:        sale.BusinessID,
+		Amount:            amount,
+		NetAmount:         amount,
+		Provider:          provider,
+		InternalReference: internalRef,
+		ExternalReference: reference,
+		Status:            ReconSuccess,
+		ReconciledAt:      now,
+		CreatedAt:         now,
+	}
+	if err := tx.Create(&payment).Error; err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
+
+// paymentIsFor reports whether a Paystack transaction's metadata names the given order.
+// The metadata may come back as an object or as the JSON string it was sent as, and
+// its IDs as numbers or strings.
+func paymentIsFor(metadata json.RawMessage, saleID, businessID uint) bool {
+	var encoded string
+	if json.Unmarshal(metadata, &encoded) == nil {
+		metadata = json.RawMessage(encoded)
+	}
+	var meta map[string]interface{}
+	if json.Unmarshal(metadata, &meta) != nil {
+		return false
+	}
+	return metadataID(meta["sale_id"]) == saleID && metadataID(meta["business_id"]) == businessID
+}
+
+func metadataID(v interface{}) uint {
+	switch id := v.(type) {
+	case float64:
+		if id > 0 && id == math.Trunc(id) {
+			return uint(id)
+		}
+	case string:
+		if n, err := strconv.ParseUint(strings.TrimSpace(id), 10, 64); err == nil {
+			return uint(n)
+		}
+	}
+	return 0
+}
+
+// orderTokenPurpose keeps order tokens apart from receipt links signed with the same secret
+const orderTokenPurpose = "order:"
+
+// OrderToken is the signed token a customer follows a QR menu order with. It is only
+// good for following the order; it does not open the sale's public receipt.
+func OrderToken(saleID, businessID uint) string {
+	payload := fmt.Sprintf("%d.%d", saleID, businessID)
+	return payload + "." + signReceiptLink(orderTokenPurpose+payload)
+}
+
+// GetPublicOrder returns an online order for the customer following it
+func GetPublicOrder(db *gorm.DB, token string) (*PublicOrderResult, error) {
+	saleID, businessID, err := parseSaleToken(token, orderTokenPurpose)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	var sale Sale
+	if err := db.Unscoped().Preload("SaleItems").First(&sale, "id = ? AND business_id = ? AND channel = ?", saleID, businessID, ChannelQRMenu).Error; err != nil {
+		return nil, errors.New("order not found")
+	}
+	return &PublicOrderResult{
+		Sale:       &sale,
+		Items:      sale.SaleItems,
+		Paid:       salePaid(db, sale.ID),
+		OrderToken: token,
+	}, nil
+}
+
+// ListOnlineOrders returns the online orders waiting for staff, oldest first
+func ListOnlineOrders(db *gorm.DB, businessID uint) ([]Sale, error) {
+	sales := []Sale{}
+	err := db.Preload("SaleItems").Preload("Payments").
+		Where("business_id = ? AND status = ?", businessID, StatusPending).
+		Order("created_at ASC").
+		Find(&sales).Error
+	return sales, err
+}
+
+// AcceptOnlineOrder takes an online order on. One already paid for in full is completed
+// at once; otherwise it joins the bill already open on its table or, failing that, becomes
+// a draft on the accepting cashier's till, to be paid there.
+func AcceptOnlineOrder(db *gorm.DB, saleID, businessID, userID uint, shiftID *uint) (*OnlineOrderDecision, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var sale Sale
+	if err := tx.Preload("SaleItems").First(&sale, "id = ? AND business_id = ? AND status = ?", saleID, businessID, StatusPending).Error; err != nil {
+		return nil, errors.New("online order not found or already handled")
+	}
+
+	var outletID uint
+	tx.Table("users").Select("COALESCE(outlet_id, 0)").Where("id = ?", userID).Scan(&outletID)
+
+	sale.CashierID = userID
+	sale.OutletID = outletID
+	sale.ShiftID = shiftID
+	sale.Status = StatusDraft
+	// Lines left unrouted while the order had no outlet go to this outlet's kitchen
+	if err := routeToKitchen(tx, &sale); err != nil {
+		return nil, err
+	}
+
+	decision := &OnlineOrderDecision{Sale: &sale, Items: sale.SaleItems}
+	paid := salePaid(tx, sale.ID)
+	if paid > 0 && paid >= roundMoney(sale.Total) {
+		receipt, err := completePrepaidSale(db, tx, &sale, userID, 0)
+		if err != nil {
+			return nil, err
+		}
+		// Paid before any shift took it, so it counts towards the shift accepting it
+		if shiftID != nil {
+			if err := shift.NewShiftService(tx).UpdateShiftMetrics(*shiftID, paid, sale.PaymentMethod); err != nil {
+				return nil, err
+			}
+		}
+		decision.Receipt = receipt
+	} else if draft, err := openTableDraft(tx, &sale); err != nil {
+		return nil, err
+	} else if draft != nil {
+		// The table already has a tab open: the order goes onto it, like a merged bill
+		if decision, err = attachToTableDraft(tx, &sale, draft, userID); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return nil, err
+		}
+		return decision, nil
+	} else if err := tx.Save(&sale).Error; err != nil {
+		return nil, err
+	}
+
+	if err := LogActivity(tx, sale.ID, businessID, userID, ActionUpdated, ActivityDetails{
+		Reason:   "online order accepted",
+		NewValue: sale.Status,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	if decision.Receipt != nil {
+		queueEReceipts(db, &sale)
+	}
+	return decision, nil
+}
+
+// openTableDraft is the draft or held bill already open on an online order's table, if any
+func openTableDraft(tx *gorm.DB, order *Sale) (*Sale, error) {
+	if order.TableID == nil {
+		return nil, nil
+	}
+	var draft Sale
+	err := tx.Where("business_id = ? AND table_id = ? AND status IN ? AND id <> ?",
+		order.BusinessID, *order.TableID, []SaleStatus{StatusDraft, StatusHeld}, order.ID).
+		Order("created_at ASC").
+		First(&draft).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// attachToTableDraft moves an accepted online order's items and held stock onto the
+// table's open bill and removes the order, the same way bills are merged
+func attachToTableDraft(tx *gorm.DB, order, draft *Sale, userID uint) (*OnlineOrderDecision, error) {
+	if err := tx.Model(&SaleItem{}).Where("sale_id = ?", order.ID).Update("sale_id", draft.ID).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Exec("UPDATE stock_reservations SET sale_id = ? WHERE sale_id = ?", draft.ID, order.ID).Error; err != nil {
+		return nil, err
+	}
+
+	// Kept, deleted, as the record behind the customer's order link
+	order.Status = StatusDraft
+	if err := tx.Save(order).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Delete(&Sale{}, order.ID).Error; err != nil {
+		return nil, err
+	}
+
+	items, err := repriceSale(tx, draft)
+	if err != nil {
+		return nil, err
+	}
+	if err := LogActivity(tx, draft.ID, draft.BusinessID, userID, ActionMerged, ActivityDetails{
+		Reason:     "online order accepted",
+		MergedFrom: []uint{order.ID},
+	}); err != nil {
+		return nil, err
+	}
+
+	return &OnlineOrderDecision{Sale: draft, Items: items}, nil
+}
+
+// RejectOnlineOrder turns an online order down. Money paid online for it is reported as
+// due back to the customer; it is refunded through the provider, not the till.
+func RejectOnlineOrder(db *gorm.DB, saleID, businessID, userID uint, req RejectOrderRequest) (*OnlineOrderDecision, error) {
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, errors.New("a reason is required to reject an online order")
+	}
+
+	var sale Sale
+	if err := db.Preload("SaleItems").First(&sale, "id = ? AND business_id = ? AND status = ?", saleID, businessID, StatusPending).Error; err != nil {
+		return nil, errors.New("online order not found or already handled")
+	}
+
+	sale.Status = StatusRejected
+	if err := db.Save(&sale).Error; err != nil {
+		return nil, err
+	}
+	_ = inventory.NewReservationService(db).ReleaseAllReservations(sale.ID)
+	if sale.TableID != nil {
+		freeTableIfIdle(db, *sale.TableID, businessID)
+	}
+	_ = LogActivity(db, sale.ID, businessID, userID, ActionVoided, ActivityDetails{Reason: req.Reason})
+
+	return &OnlineOrderDecision{
+		Sale:      &sale,
+		Items:     sale.SaleItems,
+		RefundDue: salePaid(db, sale.ID),
+	}, nil
+}
+
+// freeTableIfIdle makes a table available again once no open bill or online order is left on it
+func freeTableIfIdle(db *gorm.DB, tableID, businessID uint) {
+	var open int64
+	db.Model(&Sale{}).
+		Where("business_id = ? AND table_id = ? AND status IN ?", businessID, tableID, []SaleStatus{StatusPending, StatusDraft, StatusHeld}).
+		Count(&open)
+	if open == 0 {
+		_ = table.NewTableService(db).UpdateTableStatus(tableID, businessID, table.StatusAvailable)
+	}
+}
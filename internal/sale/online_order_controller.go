@@ -0,0 +1,185 @@
+// internal/sale/online_order_controller.go
+package sale
+
+import (
+	"pos-fiber-app/internal/subscription"
+	"pos-fiber-app/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// PlaceOnlineOrderHandler godoc
+// @Summary Order from the public QR menu
+// @Description No login is needed. Prices come from the catalogue; if expected_total is sent and no longer matches, the order is refused with 409 so the menu can refresh. A table_token from a table's QR code makes it a dine-in order at that table. The order waits as PENDING until staff accept or reject it, and can be paid online meanwhile with its order_token.
+// @Tags Public
+// @Accept json
+// @Produce json
+// @Param slug path string true "Business slug"
+// @Param body body PublicOrderRequest true "Order"
+// @Success 201 {object} PublicOrderResult
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /public/menu/{slug}/orders [post]
+func PlaceOnlineOrderHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req PublicOrderRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		result, err := PlaceOnlineOrder(db, c.Params("slug"), req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		if subscription.HasModule(db, result.Sale.BusinessID, subscription.ModuleKDS) {
+			// Stations filter orders by their items, which come back beside the sale
+			order := *result.Sale
+			order.SaleItems = result.Items
+			GlobalKDSHub.BroadcastOrder(order.BusinessID, EventOrderCreated, &order)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(result)
+	}
+}
+
+// GetPublicOrderHandler godoc
+// @Summary Follow a QR menu order
+// @Description Uses the order_token returned when the order was placed
+// @Tags Public
+// @Produce json
+// @Param token path string true "Order token"
+// @Success 200 {object} PublicOrderResult
+// @Failure 404 {object} map[string]string
+// @Router /public/orders/{token} [get]
+func GetPublicOrderHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		result, err := GetPublicOrder(db, c.Params("token"))
+		if err != nil {
+			return handleSaleError(err)
+		}
+		return c.JSON(result)
+	}
+}
+
+// PayOnlineOrderHandler godoc
+// @Summary Pay for a QR menu order online
+// @Description Records a Paystack payment made on the business's own account for the order's exact total, with the order's sale_id and business_id in the transaction metadata. The payment is verified with Paystack before it is recorded; an order is paid once.
+// @Tags Public
+// @Accept json
+// @Produce json
+// @Param token path string true "Order token"
+// @Param body body PublicOrderPaymentInfo true "Payment"
+// @Success 200 {object} PublicOrderResult
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /public/orders/{token}/payment [post]
+func PayOnlineOrderHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var info PublicOrderPaymentInfo
+		if err := c.BodyParser(&info); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		result, err := PayOnlineOrder(db, c.Params("token"), info)
+		if err != nil {
+			return handleSaleError(err)
+		}
+		return c.JSON(result)
+	}
+}
+
+// ListOnlineOrdersHandler godoc
+// @Summary List online orders waiting to be accepted
+// @Tags Online Orders
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} Sale
+// @Router /online-orders [get]
+func ListOnlineOrdersHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		orders, err := ListOnlineOrders(db, bizID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch online orders")
+		}
+
+		return c.JSON(orders)
+	}
+}
+
+// AcceptOnlineOrderHandler godoc
+// @Summary Accept an online order
+// @Description An order paid for online in full is completed straight away; otherwise it becomes a draft on the accepting cashier's till
+// @Tags Online Orders
+// @Security BearerAuth
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Success 200 {object} OnlineOrderDecision
+// @Failure 404 {object} map[string]string
+// @Router /online-orders/{sale_id}/accept [post]
+func AcceptOnlineOrderHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		decision, err := AcceptOnlineOrder(db, uint(saleID), bizID, claims.UserID, currentShiftID(c))
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		event := EventOrderUpdated
+		if decision.Receipt != nil {
+			event = EventOrderPaid
+		}
+		GlobalKDSHub.BroadcastOrder(bizID, event, decision.Sale)
+
+		return c.JSON(decision)
+	}
+}
+
+// RejectOnlineOrderHandler godoc
+// @Summary Reject an online order
+// @Description Anything the customer paid online is returned as refund_due, to be refunded through the payment provider
+// @Tags Online Orders
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param sale_id path uint true "Sale ID"
+// @Param body body RejectOrderRequest true "Reason"
+// @Success 200 {object} OnlineOrderDecision
+// @Failure 404 {object} map[string]string
+// @Router /online-orders/{sale_id}/reject [post]
+func RejectOnlineOrderHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		saleID, err := c.ParamsInt("sale_id")
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid sale ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req RejectOrderRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		decision, err := RejectOnlineOrder(db, uint(saleID), bizID, claims.UserID, req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		GlobalKDSHub.BroadcastOrder(bizID, EventOrderVoided, decision.Sale)
+
+		return c.JSON(decision)
+	}
+}
@@ -0,0 +1,394 @@
+// internal/sale/overrides.go
+package sale
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"pos-fiber-app/internal/user"
+
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+// OverrideApproval authorizes a discount or price override above the business's
+// threshold: the PIN of a manager or owner, or an access token from their own login
+type OverrideApproval struct {
+	ManagerPIN    string `json:"manager_pin,omitempty"`
+	ApproverToken string `json:"approver_token,omitempty"`
+}
+
+// AdjustItemRequest changes the discount or price of a line already on a sale.
+// Fields left out are unchanged; overriding with the catalogue price clears an override.
+type AdjustItemRequest struct {
+	Discount      *float64          `json:"discount,omitempty"`       // amount off the line
+	PriceOverride *float64          `json:"price_override,omitempty"` // replaces the unit price
+	Reason        string            `json:"reason,omitempty"`
+	Approval      *OverrideApproval `json:"approval,omitempty"`
+}
+
+// linePricing is what an override changes, logged as its old and new value
+type linePricing struct {
+	UnitPrice float64 `json:"unit_price"`
+	Discount  float64 `json:"discount"`
+}
+
+// AdjustSaleItem applies a cashier's discount or price override to a line of an open sale
+func AdjustSaleItem(db *gorm.DB, saleID, itemID, businessID, userID uint, req AdjustItemRequest) (*SaleResult, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var sale Sale
+	if err := tx.First(&sale, "id = ? AND business_id = ? AND status IN ?", saleID, businessID, []SaleStatus{StatusDraft, StatusHeld}).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("sale not found or not editable")
+		}
+		return nil, err
+	}
+
+	var item SaleItem
+	if err := tx.First(&item, "id = ? AND sale_id = ?", itemID, saleID).Error; err != nil {
+		return nil, errors.New("sale item not found")
+	}
+
+	details, err := adjustLine(tx, &sale, &item, req.Discount, req.PriceOverride, req.Approval, userID)
+	if err != nil {
+		return nil, err
+	}
+	if details != nil {
+		priceSaleItem(&item)
+		if err := tx.Save(&item).Error; err != nil {
+			return nil, err
+		}
+		if _, err := repriceSale(tx, &sale); err != nil {
+			return nil, err
+		}
+		details.Reason = req.Reason
+		if err := LogActivity(tx, sale.ID, businessID, userID, ActionPriceOverride, *details); err != nil {
+			return nil, err
+		}
+	}
+
+	var items []SaleItem
+	if err := tx.Where("sale_id = ?", saleID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &SaleResult{Sale: &sale, Items: items}, nil
+}
+
+// adjustLine applies a discount and/or price override to a line, checking them against
+// the business's override threshold. It returns the activity to log once the line is
+// saved, or nil when nothing changed.
+func adjustLine(db *gorm.DB, sale *Sale, item *SaleItem, discount, priceOverride *float64, approval *OverrideApproval, actorID uint) (*ActivityDetails, error) {
+	if discount == nil && priceOverride == nil {
+		return nil, nil
+	}
+
+	catalogPrice := item.UnitPrice
+	if item.OriginalUnitPrice != nil {
+		catalogPrice = *item.OriginalUnitPrice
+	}
+	old := linePricing{UnitPrice: item.UnitPrice, Discount: item.ManualDiscount}
+	next := old
+	if priceOverride != nil {
+		if *priceOverride < 0 {
+			return nil, errors.New("price override cannot be negative")
+		}
+		next.UnitPrice = roundMoney(*priceOverride)
+	}
+	if discount != nil {
+		if *discount < 0 {
+			return nil, errors.New("line discount cannot be negative")
+		}
+		next.Discount = roundMoney(*discount)
+	}
+	if next == old {
+		return nil, nil
+	}
+	if item.IsGiftCard {
+		return nil, errors.New("gift card lines cannot be discounted or have their price overridden")
+	}
+
+	qty := item.Quantity
+	full := qty * (catalogPrice + item.ModifierTotal)
+	charged := qty*(next.UnitPrice+item.ModifierTotal) - next.Discount
+	if charged < 0 {
+		return nil, errors.New("line discount cannot exceed the line total")
+	}
+
+	approvedBy, err := approveReduction(db, sale, full, charged, approval, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	item.UnitPrice = next.UnitPrice
+	item.OriginalUnitPrice = nil
+	if next.UnitPrice != catalogPrice {
+		item.OriginalUnitPrice = &catalogPrice
+	}
+	item.ManualDiscount = next.Discount
+	item.OverrideApprovedBy = approvedBy
+
+	return &ActivityDetails{
+		ProductID:   item.ProductID,
+		ProductName: item.ProductName,
+		Quantity:    item.Quantity,
+		OldValue:    old,
+		NewValue:    next,
+		ApprovedBy:  approvedBy,
+	}, nil
+}
+
+// authorizeSaleDiscount checks a discount on the whole sale against the override
+// threshold and logs it
+func authorizeSaleDiscount(tx *gorm.DB, sale *Sale, discount float64, approval *OverrideApproval, actorID uint) error {
+	sale.DiscountApprovedBy = nil
+	if discount == 0 {
+		return nil
+	}
+	if discount < 0 {
+		return errors.New("discount cannot be negative")
+	}
+	if discount > sale.Subtotal {
+		return errors.New("discount cannot exceed the sale subtotal")
+	}
+
+	approvedBy, err := approveReduction(tx, sale, sale.Subtotal, sale.Subtotal-discount, approval, actorID)
+	if err != nil {
+		return err
+	}
+	sale.DiscountApprovedBy = approvedBy
+
+	return LogActivity(tx, sale.ID, sale.BusinessID, actorID, ActionDiscount, ActivityDetails{
+		OldValue:   sale.Subtotal,
+		NewValue:   roundMoney(sale.Subtotal - discount),
+		ApprovedBy: approvedBy,
+	})
+}
+
+// approveReduction checks a price cut from full to charged against the business's
+// override threshold. Above it the actor must be a manager or owner, or bring one's
+// approval. It returns who approved, or nil when no approval was needed.
+func approveReduction(db *gorm.DB, sale *Sale, full, charged float64, approval *OverrideApproval, actorID uint) (*uint, error) {
+	if full <= 0 || charged >= full {
+		return nil, nil
+	}
+
+	var biz struct {
+		OverrideApprovalPercent float64
+	}
+	if err := db.Table("businesses").Select("override_approval_percent").Where("id = ?", sale.BusinessID).Scan(&biz).Error; err != nil {
+		return nil, err
+	}
+	percent := (full - charged) / full * 100
+	if roundMoney(percent) <= biz.OverrideApprovalPercent {
+		return nil, nil
+	}
+
+	// Managers and owners approve their own overrides
+	var actor user.User
+	if err := db.Select("id, role, active").First(&actor, "id = ? AND tenant_id = ?", actorID, sale.TenantID).Error; err == nil && canApprove(&actor) {
+		return &actor.ID, nil
+	}
+
+	switch {
+	case approval != nil && approval.ApproverToken != "":
+		return approverFromToken(db, sale.TenantID, approval.ApproverToken)
+	case approval != nil && approval.ManagerPIN != "":
+		return approverFromPIN(db, sale.TenantID, approval.ManagerPIN)
+	default:
+		return nil, fmt.Errorf("manager approval required: %.1f%% off is above the %.1f%% override threshold", percent, biz.OverrideApprovalPercent)
+	}
+}
+
+// Wrong manager PINs are limited per business: after pinMaxAttempts misses within
+// pinLockout, PIN approvals are refused until the lockout has passed. Approver tokens
+// still work meanwhile.
+const (
+	pinMaxAttempts = 5
+	pinLockout     = 15 * time.Minute
+)
+
+type pinFailures struct {
+	count int
+	first time.Time
+}
+
+var (
+	pinMu     sync.Mutex
+	pinMisses = map[string]*pinFailures{}
+)
+
+// pinLockedFor reports how long PIN approvals stay locked for a business, or 0
+func pinLockedFor(tenantID string) time.Duration {
+	pinMu.Lock()
+	defer pinMu.Unlock()
+	f, ok := pinMisses[tenantID]
+	if !ok {
+		return 0
+	}
+	left := time.Until(f.first.Add(pinLockout))
+	if left <= 0 {
+		delete(pinMisses, tenantID)
+		return 0
+	}
+	if f.count < pinMaxAttempts {
+		return 0
+	}
+	return left
+}
+
+func pinMissed(tenantID string) {
+	pinMu.Lock()
+	defer pinMu.Unlock()
+	f, ok := pinMisses[tenantID]
+	if !ok || time.Since(f.first) > pinLockout {
+		f = &pinFailures{first: time.Now()}
+		pinMisses[tenantID] = f
+	}
+	f.count++
+}
+
+func approverFromPIN(db *gorm.DB, tenantID, pin string) (*uint, error) {
+	if left := pinLockedFor(tenantID); left > 0 {
+		return nil, fmt.Errorf("too many wrong manager PINs; try again in %d minutes or approve with a manager login", int(left.Minutes())+1)
+	}
+
+	var managers []user.User
+	if err := db.Select("id, role, active, pin").
+		Where("tenant_id = ? AND role IN ? AND active = ? AND pin <> ''", tenantID, []string{"OWNER", "MANAGER"}, true).
+		Find(&managers).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range managers {
+		if user.CheckPassword(m.PIN, pin) {
+			id := m.ID
+			return &id, nil
+		}
+	}
+	pinMissed(tenantID)
+	return nil, errors.New("invalid manager PIN")
+}
+
+func approverFromToken(db *gorm.DB, tenantID, tokenString string) (*uint, error) {
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid approver token")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid approver token")
+	}
+	userID, _ := claims["user_id"].(float64)
+
+	// The token's role may be stale; check the approver as they are now
+	var approver user.User
+	if err := db.Select("id, role, active").First(&approver, "id = ? AND tenant_id = ?", uint(userID), tenantID).Error; err != nil || !canApprove(&approver) {
+		return nil, errors.New("invalid approver token: not a manager or owner of this business")
+	}
+	return &approver.ID, nil
+}
+
+func canApprove(u *user.User) bool {
+	return u.Active && (u.Role == "OWNER" || u.Role == "MANAGER")
+}
+
+// lineDiscount reads a request's line discount, where zero means none was asked for
+func lineDiscount(discount float64) *float64 {
+	if discount == 0 {
+		return nil
+	}
+	return &discount
+}
+
+// OverrideEntry is a discount or price override as listed in audit reports
+type OverrideEntry struct {
+	SaleID      uint      `json:"sale_id"`
+	ActionType  string    `json:"action_type"`
+	ProductName string    `json:"product_name,omitempty"`
+	OldValue    string    `json:"old_value"`
+	NewValue    string    `json:"new_value"`
+	Reason      string    `json:"reason,omitempty"`
+	PerformedBy string    `json:"performed_by"`
+	ApprovedBy  string    `json:"approved_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ListOverrides returns the discounts and price overrides made between from and to
+func ListOverrides(db *gorm.DB, businessID uint, from, to time.Time) ([]OverrideEntry, error) {
+	var logs []SaleActivityLogWithUser
+	if err := db.Table("sale_activity_logs").
+		Select("sale_activity_logs.*, users.first_name || ' ' || users.last_name as user_name").
+		Joins("LEFT JOIN users ON users.id = sale_activity_logs.performed_by").
+		Where("sale_activity_logs.business_id = ? AND sale_activity_logs.action_type IN ?", businessID, []ActionType{ActionPriceOverride, ActionDiscount}).
+		Where("sale_activity_logs.created_at >= ? AND sale_activity_logs.created_at < ?", from, to).
+		Order("sale_activity_logs.created_at ASC").
+		Scan(&logs).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]OverrideEntry, 0, len(logs))
+	approvers := make(map[uint]string)
+	for _, l := range logs {
+		var details struct {
+			ProductName string          `json:"product_name"`
+			Reason      string          `json:"reason"`
+			OldValue    json.RawMessage `json:"old_value"`
+			NewValue    json.RawMessage `json:"new_value"`
+			ApprovedBy  *uint           `json:"approved_by"`
+		}
+		if err := json.Unmarshal([]byte(l.Details), &details); err != nil {
+			continue
+		}
+
+		entry := OverrideEntry{
+			SaleID:      l.SaleID,
+			ActionType:  string(l.ActionType),
+			ProductName: details.ProductName,
+			OldValue:    describePricing(details.OldValue),
+			NewValue:    describePricing(details.NewValue),
+			Reason:      details.Reason,
+			PerformedBy: l.UserName,
+			CreatedAt:   l.CreatedAt,
+		}
+		if details.ApprovedBy != nil {
+			id := *details.ApprovedBy
+			if _, ok := approvers[id]; !ok {
+				var name string
+				db.Table("users").Select("first_name || ' ' || last_name").Where("id = ?", id).Scan(&name)
+				approvers[id] = name
+			}
+			entry.ApprovedBy = approvers[id]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// describePricing renders a logged old or new value: a sale total, or a line's price and discount
+func describePricing(raw json.RawMessage) string {
+	var line linePricing
+	if err := json.Unmarshal(raw, &line); err == nil {
+		if line.Discount == 0 {
+			return fmt.Sprintf("%.2f", line.UnitPrice)
+		}
+		return fmt.Sprintf("%.2f less %.2f", line.UnitPrice, line.Discount)
+	}
+	var total float64
+	if err := json.Unmarshal(raw, &total); err == nil {
+		return fmt.Sprintf("%.2f", total)
+	}
+	return string(raw)
+}
@@ -0,0 +1,269 @@
+// internal/sale/prep_times.go
+package sale
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PrepTimeRow is the prep time of a group of kitchen lines: from the kitchen getting a line
+// to it being bumped ready
+type PrepTimeRow struct {
+	Key        string  `json:"key"`
+	Label      string  `json:"label"`
+	Items      int     `json:"items"`
+	AvgMinutes float64 `json:"avg_minutes"`
+	P90Minutes float64 `json:"p90_minutes"`
+}
+
+// OverdueOrder is a kitchen order that has waited past the business's prep SLA
+type OverdueOrder struct {
+	SaleID       uint      `json:"sale_id"`
+	TableNumber  string    `json:"table_number,omitempty"`
+	OrderType    string    `json:"order_type"`
+	QueuedAt     time.Time `json:"queued_at"`
+	WaitMinutes  float64   `json:"wait_minutes"`
+	SLAMinutes   int       `json:"sla_minutes"`
+	PendingItems int       `json:"pending_items"`
+}
+
+// prepStatusUpdates are the column changes moving lines to a prep status: the status, and
+// the time it was first reached. Moving back clears the times of the later statuses.
+func prepStatusUpdates(status PrepStatus, userID uint) map[string]interface{} {
+	now := time.Now()
+	updates := map[string]interface{}{"preparation_status": status}
+	switch status {
+	case PrepPending:
+		updates["preparing_at"] = nil
+		updates["ready_at"] = nil
+		updates["ready_by"] = nil
+		updates["served_at"] = nil
+	case PrepPreparing:
+		updates["preparing_at"] = gorm.Expr("COALESCE(preparing_at, ?)", now)
+		updates["ready_at"] = nil
+		updates["ready_by"] = nil
+		updates["served_at"] = nil
+	case PrepReady:
+		updates["ready_at"] = gorm.Expr("COALESCE(ready_at, ?)", now)
+		updates["ready_by"] = gorm.Expr("COALESCE(ready_by, ?)", userID)
+		updates["served_at"] = nil
+	case PrepServed:
+		updates["ready_at"] = gorm.Expr("COALESCE(ready_at, ?)", now)
+		updates["ready_by"] = gorm.Expr("COALESCE(ready_by, ?)", userID)
+		updates["served_at"] = gorm.Expr("COALESCE(served_at, ?)", now)
+	}
+	return updates
+}
+
+// setItemsPrepStatus moves the lines a query selects to a prep status, stamping when
+func setItemsPrepStatus(query *gorm.DB, status PrepStatus, userID uint) (int64, error) {
+	if !validPrepStatus(status) {
+		return 0, errors.New("invalid preparation status")
+	}
+	result := query.Model(&SaleItem{}).Updates(prepStatusUpdates(status, userID))
+	return result.RowsAffected, result.Error
+}
+
+// GetPrepTimes reports average and 90th-percentile prep time of the lines bumped ready
+// between from and to (YYYY-MM-DD), grouped by product, station, hour (of the day the
+// line reached the kitchen) or staff (who bumped it ready)
+func GetPrepTimes(db *gorm.DB, businessID uint, from, to, groupBy string) ([]PrepTimeRow, error) {
+	start, err := time.Parse("2006-01-02", from)
+	if err != nil {
+		return nil, errors.New("invalid from date, use YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		return nil, errors.New("invalid to date, use YYYY-MM-DD")
+	}
+
+	var lines []struct {
+		ProductID   uint
+		ProductName string
+		StationID   *uint
+		StationName *string
+		ReadyBy     *uint
+		StaffName   *string
+		QueuedAt    time.Time
+		ReadyAt     time.Time
+	}
+	err = db.Table("sale_items").
+		Select(`sale_items.product_id, sale_items.product_name, sale_items.station_id, kitchen_stations.name AS station_name,
+			sale_items.ready_by, users.first_name || ' ' || users.last_name AS staff_name, sale_items.queued_at, sale_items.ready_at`).
+		Joins("JOIN sales ON sales.id = sale_items.sale_id").
+		Joins("LEFT JOIN kitchen_stations ON kitchen_stations.id = sale_items.station_id").
+		Joins("LEFT JOIN users ON users.id = sale_items.ready_by").
+		Where("sales.business_id = ? AND sale_items.queued_at IS NOT NULL AND sale_items.ready_at >= ? AND sale_items.ready_at < ?",
+			businessID, start, end.AddDate(0, 0, 1)).
+		Scan(&lines).Error
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[string]*PrepTimeRow{}
+	minutes := map[string][]float64{}
+	for _, l := range lines {
+		var key, label string
+		switch groupBy {
+		case "", "product":
+			key, label = fmt.Sprint(l.ProductID), l.ProductName
+		case "station":
+			key, label = "0", "Unrouted"
+			if l.StationID != nil {
+				key = fmt.Sprint(*l.StationID)
+				if l.StationName != nil {
+					label = *l.StationName
+				}
+			}
+		case "hour":
+			key = fmt.Sprintf("%02d", l.QueuedAt.Hour())
+			label = key + ":00"
+		case "staff":
+			key, label = "0", "Unknown"
+			if l.ReadyBy != nil {
+				key = fmt.Sprint(*l.ReadyBy)
+				if l.StaffName != nil {
+					label = *l.StaffName
+				}
+			}
+		default:
+			return nil, errors.New("group_by must be product, station, hour or staff")
+		}
+
+		wait := l.ReadyAt.Sub(l.QueuedAt).Minutes()
+		if wait < 0 {
+			continue
+		}
+		if groups[key] == nil {
+			groups[key] = &PrepTimeRow{Key: key, Label: label}
+		}
+		minutes[key] = append(minutes[key], wait)
+	}
+
+	report := make([]PrepTimeRow, 0, len(groups))
+	for key, row := range groups {
+		waits := minutes[key]
+		sort.Float64s(waits)
+		total := 0.0
+		for _, w := range waits {
+			total += w
+		}
+		row.Items = len(waits)
+		row.AvgMinutes = roundMoney(total / float64(len(waits)))
+		row.P90Minutes = roundMoney(percentile(waits, 0.9))
+		report = append(report, *row)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Key < report[j].Key })
+	return report, nil
+}
+
+// percentile of sorted values, by the nearest-rank method
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+// ListOverdueOrders returns the business's kitchen orders with lines waiting longer than
+// its prep SLA, longest wait first. Without an SLA set nothing is overdue.
+func ListOverdueOrders(db *gorm.DB, businessID uint) ([]OverdueOrder, error) {
+	var sla int
+	db.Table("businesses").Select("prep_sla_minutes").Where("id = ?", businessID).Scan(&sla)
+	if sla <= 0 {
+		return []OverdueOrder{}, nil
+	}
+	return overdueOrders(db, businessID, sla)
+}
+
+// overdueWindow bounds the overdue scan: lines queued longer ago than this are past
+// helping and are left to the prep-time report
+const overdueWindow = 12 * time.Hour
+
+// overdueSaleStates are the open orders still waiting on the kitchen. Completed sales
+// are left out: a paid retail sale never gets bumped, and would stay overdue forever.
+var overdueSaleStates = []SaleStatus{StatusPending, StatusDraft, StatusHeld, StatusPendingPayment}
+
+// overdueOrders lists the open orders with kitchen-routed lines queued past the SLA
+func overdueOrders(db *gorm.DB, businessID uint, sla int) ([]OverdueOrder, error) {
+	now := time.Now()
+	var rows []struct {
+		SaleID       uint
+		TableNumber  string
+		OrderType    string
+		QueuedAt     time.Time
+		PendingItems int
+	}
+	err := db.Table("sale_items").
+		Select("sales.id AS sale_id, sales.table_number, sales.order_type, MIN(sale_items.queued_at) AS queued_at, COUNT(*) AS pending_items").
+		Joins("JOIN sales ON sales.id = sale_items.sale_id").
+		Where("sales.business_id = ? AND sales.deleted_at IS NULL AND sales.status IN ? AND sales.preparation_status <> ?", businessID, overdueSaleStates, PrepServed).
+		Where("sale_items.station_id IS NOT NULL AND sale_items.ready_at IS NULL").
+		Where("sale_items.queued_at > ? AND sale_items.queued_at < ?", now.Add(-overdueWindow), now.Add(-time.Duration(sla)*time.Minute)).
+		Group("sales.id, sales.table_number, sales.order_type").
+		Order("queued_at ASC").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]OverdueOrder, 0, len(rows))
+	for _, r := range rows {
+		orders = append(orders, OverdueOrder{
+			SaleID:       r.SaleID,
+			TableNumber:  r.TableNumber,
+			OrderType:    r.OrderType,
+			QueuedAt:     r.QueuedAt,
+			WaitMinutes:  roundMoney(now.Sub(r.QueuedAt).Minutes()),
+			SLAMinutes:   sla,
+			PendingItems: r.PendingItems,
+		})
+	}
+	return orders, nil
+}
+
+// StartPrepSLAMonitor checks every minute for kitchen orders that have gone past their
+// business's prep SLA and alerts the KDS screens once per order
+func StartPrepSLAMonitor(db *gorm.DB) {
+	ticker := time.NewTicker(1 * time.Minute)
+
+	go func() {
+		for range ticker.C {
+			alertOverdueOrders(db)
+		}
+	}()
+}
+
+func alertOverdueOrders(db *gorm.DB) {
+	var businesses []struct {
+		ID             uint
+		PrepSLAMinutes int
+	}
+	db.Table("businesses").Select("id, prep_sla_minutes").Where("prep_sla_minutes > 0").Scan(&businesses)
+
+	for _, biz := range businesses {
+		orders, err := overdueOrders(db, biz.ID, biz.PrepSLAMinutes)
+		if err != nil {
+			log.Printf("[KDS] overdue check failed for business %d: %v", biz.ID, err)
+			continue
+		}
+		for _, o := range orders {
+			// Claim the alert first so an order is only flagged once
+			result := db.Model(&Sale{}).Where("id = ? AND prep_alerted_at IS NULL", o.SaleID).Update("prep_alerted_at", time.Now())
+			if result.Error != nil || result.RowsAffected == 0 {
+				continue
+			}
+			GlobalKDSHub.BroadcastOrder(biz.ID, EventOrderOverdue, o)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// internal/sale/promotions.go
+package sale
+
+import (
+	"time"
+
+	"pos-fiber-app/internal/promotion"
+
+	"gorm.io/gorm"
+)
+
+// applyPromotions prices a sale's lines against the business's promotions running at
+// the given time, recording each line's discount and the promotions that gave it.
+// Gift cards are never discounted.
+func applyPromotions(db *gorm.DB, businessID uint, items []SaleItem, at time.Time) error {
+	lines := make([]promotion.Line, 0, len(items))
+	index := make([]int, 0, len(items))
+	for i, item := range items {
+		items[i].PromoDiscount = 0
+		items[i].Promotions = nil
+		if item.IsGiftCard {
+			continue
+		}
+		unitPrice := item.UnitPrice + item.ModifierTotal
+		if item.LabelPrice != nil && item.Quantity > 0 {
+			unitPrice = *item.LabelPrice / item.Quantity
+		}
+		lines = append(lines, promotion.Line{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+			UnitPrice: unitPrice,
+		})
+		index = append(index, i)
+	}
+
+	results, err := promotion.Evaluate(db, businessID, lines, at)
+	if err != nil {
+		return err
+	}
+	for n, r := range results {
+		items[index[n]].PromoDiscount = r.Discount
+		items[index[n]].Promotions = r.Applied
+	}
+
+	for i := range items {
+		priceSaleItem(&items[i])
+	}
+	return nil
+}
+
+// repriceSale re-evaluates promotions over all of a sale's lines and refreshes its
+// totals. Called whenever the lines of an open sale change.
+func repriceSale(db *gorm.DB, sale *Sale) ([]SaleItem, error) {
+	if err := checkSplitUnpaid(db, sale); err != nil {
+		return nil, err
+	}
+
+	var items []SaleItem
+	if err := db.Where("sale_id = ?", sale.ID).Order("id ASC").Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	before := make([]float64, len(items))
+	for i, item := range items {
+		before[i] = item.TotalPrice
+	}
+	// An offline sale is priced as it was when it was rung up
+	at := time.Now()
+	if sale.ClientUUID != nil {
+		at = sale.SaleDate
+	}
+	if sale.QuotationID != nil {
+		// A sale made from a quotation keeps the quoted prices
+		for i := range items {
+			priceSaleItem(&items[i])
+		}
+	} else if err := applyPromotions(db, sale.BusinessID, items, at); err != nil {
+		return nil, err
+	}
+
+	subtotal, promoDiscount := 0.0, 0.0
+	for i := range items {
+		if roundMoney(items[i].TotalPrice) != roundMoney(before[i]) || len(items[i].Promotions) > 0 {
+			if err := db.Save(&items[i]).Error; err != nil {
+				return nil, err
+			}
+		}
+		subtotal += items[i].TotalPrice
+		promoDiscount += items[i].PromoDiscount
+	}
+
+	sale.Subtotal = roundMoney(subtotal)
+	sale.PromoDiscount = roundMoney(promoDiscount)
+	if err := computeTotals(db, sale, items, 0); err != nil { // discount applied later
+		return nil, err
+	}
+
+	return items, db.Save(sale).Error
+}
@@ -0,0 +1,543 @@
+// internal/sale/quotation.go
+package sale
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"pos-fiber-app/internal/common"
+	"pos-fiber-app/internal/inventory"
+	"pos-fiber-app/internal/printing"
+	"pos-fiber-app/internal/product"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type QuotationKind string
+
+const (
+	KindQuotation QuotationKind = "QUOTATION"
+	KindProforma  QuotationKind = "PROFORMA" // pro-forma invoice
+)
+
+type QuotationStatus string
+
+const (
+	QuoteDraft    QuotationStatus = "DRAFT"
+	QuoteSent     QuotationStatus = "SENT"
+	QuoteAccepted QuotationStatus = "ACCEPTED"
+	QuoteExpired  QuotationStatus = "EXPIRED"
+)
+
+// Quotation is a priced offer to a customer, numbered per business. Accepting it
+// and converting it makes a draft sale at the quoted prices.
+type Quotation struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	BusinessID    uint            `gorm:"index;uniqueIndex:idx_quotation_number" json:"business_id"`
+	TenantID      string          `gorm:"index;size:8" json:"tenant_id"`
+	OutletID      uint            `gorm:"index" json:"outlet_id"`
+	Sequence      int             `gorm:"uniqueIndex:idx_quotation_number" json:"sequence"`
+	Number        string          `gorm:"size:30" json:"number"` // e.g. QT-000042
+	Kind          QuotationKind   `gorm:"type:varchar(20);default:'QUOTATION'" json:"kind"`
+	Status        QuotationStatus `gorm:"type:varchar(20);default:'DRAFT';index" json:"status"`
+	CustomerID    *uint           `gorm:"index" json:"customer_id,omitempty"`
+	CustomerName  string          `json:"customer_name,omitempty"`
+	CustomerPhone string          `json:"customer_phone,omitempty"`
+	CustomerEmail string          `gorm:"size:150" json:"customer_email,omitempty"`
+	Subtotal      float64         `gorm:"type:decimal(12,2)" json:"subtotal"`
+	Tax           float64         `gorm:"type:decimal(12,2)" json:"tax"`
+	TaxInclusive  bool            `json:"tax_inclusive"`
+	Total         float64         `gorm:"type:decimal(12,2)" json:"total"`
+	ValidUntil    time.Time       `json:"valid_until"`
+	StockReserved bool            `gorm:"default:false" json:"stock_reserved"` // stock is held until ValidUntil
+	Notes         string          `gorm:"type:text" json:"notes,omitempty"`
+	CreatedBy     uint            `json:"created_by"`
+	SentAt        *time.Time      `json:"sent_at,omitempty"`
+	AcceptedAt    *time.Time      `json:"accepted_at,omitempty"`
+	SaleID        *uint           `gorm:"index" json:"sale_id,omitempty"` // the draft sale it was converted into
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+
+	Items []QuotationItem `gorm:"foreignKey:QuotationID;constraint:OnDelete:CASCADE" json:"items"`
+}
+
+// QuotationItem is one quoted line. Its pricing fields mean what they do on a SaleItem.
+type QuotationItem struct {
+	ID                 uint            `gorm:"primaryKey" json:"id"`
+	QuotationID        uint            `gorm:"index" json:"quotation_id"`
+	ProductID          uint            `json:"product_id"`
+	ProductName        string          `json:"product_name"`
+	Quantity           float64         `gorm:"type:decimal(12,3)" json:"quantity"`
+	UnitPrice          float64         `gorm:"type:decimal(12,2)" json:"unit_price"`
+	OriginalUnitPrice  *float64        `gorm:"type:decimal(12,2)" json:"original_unit_price,omitempty"`
+	Discount           float64         `gorm:"type:decimal(12,2);default:0" json:"discount,omitempty"`
+	OverrideApprovedBy *uint           `json:"override_approved_by,omitempty"`
+	TotalPrice         float64         `gorm:"type:decimal(12,2)" json:"total_price"`
+	TaxClass           common.TaxClass `gorm:"type:varchar(20)" json:"tax_class,omitempty"`
+	TaxRate            float64         `gorm:"type:decimal(5,2);default:0" json:"tax_rate"`
+	Tax                float64         `gorm:"type:decimal(12,2);default:0" json:"tax"`
+}
+
+type QuotationItemRequest struct {
+	ProductID uint              `json:"product_id" validate:"required"`
+	Quantity  float64           `json:"quantity" validate:"required,gt=0"`
+	UnitPrice *float64          `json:"unit_price,omitempty"` // defaults to the catalogue price
+	Discount  float64           `json:"discount,omitempty"`   // amount off the line
+	Approval  *OverrideApproval `json:"approval,omitempty"`   // for prices above the override threshold
+}
+
+// QuotationRequest creates a quotation or replaces one still in DRAFT
+type QuotationRequest struct {
+	Kind          QuotationKind          `json:"kind,omitempty" validate:"omitempty,oneof=QUOTATION PROFORMA"`
+	CustomerID    *uint                  `json:"customer_id,omitempty"`
+	CustomerName  string                 `json:"customer_name,omitempty"`
+	CustomerPhone string                 `json:"customer_phone,omitempty"`
+	CustomerEmail string                 `json:"customer_email,omitempty" validate:"omitempty,email"`
+	ValidUntil    time.Time              `json:"valid_until" validate:"required"`
+	ReserveStock  bool                   `json:"reserve_stock,omitempty"`
+	Notes         string                 `json:"notes,omitempty"`
+	Items         []QuotationItemRequest `json:"items" validate:"required,min=1,dive"`
+}
+
+type QuotationFilters struct {
+	Status     QuotationStatus
+	CustomerID *uint
+}
+
+// expired reports whether a quotation not yet accepted has run past its validity
+func (q *Quotation) expired() bool {
+	return (q.Status == QuoteDraft || q.Status == QuoteSent) && time.Now().After(q.ValidUntil)
+}
+
+// expireQuotations marks a business's lapsed quotations EXPIRED. Reservations made
+// for them lapse on their own at ValidUntil.
+func expireQuotations(db *gorm.DB, businessID uint) error {
+	return db.Model(&Quotation{}).
+		Where("business_id = ? AND status IN ? AND valid_until < ?", businessID, []QuotationStatus{QuoteDraft, QuoteSent}, time.Now()).
+		Update("status", QuoteExpired).Error
+}
+
+// nextQuotationSequence returns the business's next quotation number. The business
+// row stays locked until tx ends, so quotations created at the same time are numbered
+// one after the other instead of colliding on the unique (business_id, sequence) index.
+func nextQuotationSequence(tx *gorm.DB, businessID uint) (int, error) {
+	var id uint
+	if err := tx.Table("businesses").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").
+		Where("id = ?", businessID).
+		Scan(&id).Error; err != nil {
+		return 0, err
+	}
+
+	var last int
+	err := tx.Model(&Quotation{}).
+		Where("business_id = ?", businessID).
+		Select("COALESCE(MAX(sequence), 0)").
+		Scan(&last).Error
+	return last + 1, err
+}
+
+func quotationNumber(kind QuotationKind, seq int) string {
+	prefix := "QT"
+	if kind == KindProforma {
+		prefix = "PF"
+	}
+	return fmt.Sprintf("%s-%06d", prefix, seq)
+}
+
+// priceQuotation prices the requested lines into q, checking prices below the
+// catalogue against the override threshold as a cashier's would be
+func priceQuotation(tx *gorm.DB, q *Quotation, reqItems []QuotationItemRequest, userID uint) error {
+	// Lines are priced as sale lines so quotes and sales agree to the kobo
+	draft := &Sale{BusinessID: q.BusinessID, TenantID: q.TenantID}
+	lines := make([]SaleItem, 0, len(reqItems))
+	for _, r := range reqItems {
+		if r.Quantity <= 0 {
+			return errors.New("quantity must be greater than zero")
+		}
+		var prod product.Product
+		if err := tx.First(&prod, "id = ? AND business_id = ?", r.ProductID, q.BusinessID).Error; err != nil {
+			return fmt.Errorf("product %d not found", r.ProductID)
+		}
+		if prod.IsGiftCard {
+			return errors.New("gift cards cannot be quoted")
+		}
+		if err := inventory.CheckQuantity(r.Quantity, prod.QuantityPrecision); err != nil {
+			return fmt.Errorf("%s: %w", prod.Name, err)
+		}
+
+		line := SaleItem{
+			ProductID:   prod.ID,
+			ProductName: prod.Name,
+			Quantity:    r.Quantity,
+			UnitPrice:   prod.Price,
+			CostPrice:   prod.Cost,
+		}
+		if _, err := adjustLine(tx, draft, &line, lineDiscount(r.Discount), r.UnitPrice, r.Approval, userID); err != nil {
+			return err
+		}
+		priceSaleItem(&line)
+		draft.Subtotal += line.TotalPrice
+		lines = append(lines, line)
+	}
+	draft.Subtotal = roundMoney(draft.Subtotal)
+	if err := applyTax(tx, draft, lines, 0); err != nil {
+		return err
+	}
+
+	q.Items = make([]QuotationItem, 0, len(lines))
+	for _, l := range lines {
+		q.Items = append(q.Items, QuotationItem{
+			ProductID:          l.ProductID,
+			ProductName:        l.ProductName,
+			Quantity:           l.Quantity,
+			UnitPrice:          l.UnitPrice,
+			OriginalUnitPrice:  l.OriginalUnitPrice,
+			Discount:           l.ManualDiscount,
+			OverrideApprovedBy: l.OverrideApprovedBy,
+			TotalPrice:         roundMoney(l.TotalPrice),
+			TaxClass:           l.TaxClass,
+			TaxRate:            l.TaxRate,
+			Tax:                l.Tax,
+		})
+	}
+	q.Subtotal, q.Tax, q.TaxInclusive, q.Total = draft.Subtotal, draft.Tax, draft.TaxInclusive, draft.Total
+	return nil
+}
+
+// reserveQuotationStock holds the quoted stock until the quotation expires
+func reserveQuotationStock(tx *gorm.DB, q *Quotation) error {
+	resSvc := inventory.NewReservationService(tx)
+	if err := resSvc.ReleaseQuotationReservations(q.ID); err != nil {
+		return err
+	}
+	if !q.StockReserved {
+		return nil
+	}
+	for _, item := range q.Items {
+		if err := resSvc.ReserveForQuotation(q.ID, item.ProductID, q.BusinessID, q.CreatedBy, item.Quantity, q.ValidUntil); err != nil {
+			return fmt.Errorf("%s: %w", item.ProductName, err)
+		}
+	}
+	return nil
+}
+
+func applyQuotationRequest(tx *gorm.DB, q *Quotation, req QuotationRequest, userID uint) error {
+	if !req.ValidUntil.After(time.Now()) {
+		return errors.New("valid_until must be in the future")
+	}
+	if len(req.Items) == 0 {
+		return errors.New("a quotation needs at least one item")
+	}
+
+	q.Kind = req.Kind
+	if q.Kind == "" {
+		q.Kind = KindQuotation
+	}
+	q.CustomerID = nil
+	q.CustomerName = strings.TrimSpace(req.CustomerName)
+	q.CustomerPhone = strings.TrimSpace(req.CustomerPhone)
+	q.CustomerEmail = strings.TrimSpace(req.CustomerEmail)
+	if req.CustomerID != nil {
+		// Borrow the sale's customer lookup for the snapshot
+		s := Sale{BusinessID: q.BusinessID, CustomerName: q.CustomerName, CustomerPhone: q.CustomerPhone, CustomerEmail: q.CustomerEmail}
+		if err := attachCustomer(tx, &s, req.CustomerID); err != nil {
+			return err
+		}
+		q.CustomerID, q.CustomerName, q.CustomerPhone, q.CustomerEmail = s.CustomerID, s.CustomerName, s.CustomerPhone, s.CustomerEmail
+	}
+	q.ValidUntil = req.ValidUntil
+	q.StockReserved = req.ReserveStock
+	q.Notes = req.Notes
+
+	return priceQuotation(tx, q, req.Items, userID)
+}
+
+// CreateQuotation prices and numbers a new quotation, reserving its stock if asked
+func CreateQuotation(db *gorm.DB, businessID uint, tenantID string, outletID, userID uint, req QuotationRequest) (*Quotation, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	q := &Quotation{
+		BusinessID: businessID,
+		TenantID:   tenantID,
+		OutletID:   outletID,
+		Status:     QuoteDraft,
+		CreatedBy:  userID,
+	}
+	if err := applyQuotationRequest(tx, q, req, userID); err != nil {
+		return nil, err
+	}
+
+	seq, err := nextQuotationSequence(tx, businessID)
+	if err != nil {
+		return nil, err
+	}
+	q.Sequence = seq
+	q.Number = quotationNumber(q.Kind, seq)
+
+	if err := tx.Create(q).Error; err != nil {
+		return nil, err
+	}
+	if err := reserveQuotationStock(tx, q); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// UpdateQuotation replaces the customer, lines and terms of a DRAFT quotation
+func UpdateQuotation(db *gorm.DB, id, businessID, userID uint, req QuotationRequest) (*Quotation, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var q Quotation
+	if err := tx.First(&q, "id = ? AND business_id = ?", id, businessID).Error; err != nil {
+		return nil, errors.New("quotation not found")
+	}
+	if q.Status != QuoteDraft {
+		return nil, fmt.Errorf("a %s quotation cannot be changed", q.Status)
+	}
+
+	kind := q.Kind
+	if err := applyQuotationRequest(tx, &q, req, userID); err != nil {
+		return nil, err
+	}
+	if q.Kind != kind {
+		q.Number = quotationNumber(q.Kind, q.Sequence)
+	}
+
+	if err := tx.Where("quotation_id = ?", q.ID).Delete(&QuotationItem{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&q).Error; err != nil {
+		return nil, err
+	}
+	if err := reserveQuotationStock(tx, &q); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// GetQuotation returns a quotation with its lines
+func GetQuotation(db *gorm.DB, id, businessID uint) (*Quotation, error) {
+	var q Quotation
+	if err := db.Preload("Items").First(&q, "id = ? AND business_id = ?", id, businessID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("quotation not found")
+		}
+		return nil, err
+	}
+	if q.expired() {
+		q.Status = QuoteExpired
+		db.Model(&q).Update("status", QuoteExpired)
+	}
+	return &q, nil
+}
+
+// ListQuotations returns a business's quotations, newest first
+func ListQuotations(db *gorm.DB, businessID uint, filters QuotationFilters) ([]Quotation, error) {
+	if err := expireQuotations(db, businessID); err != nil {
+		return nil, err
+	}
+
+	query := db.Preload("Items").Where("business_id = ?", businessID)
+	if filters.Status != "" {
+		query = query.Where("status = ?", filters.Status)
+	}
+	if filters.CustomerID != nil {
+		query = query.Where("customer_id = ?", *filters.CustomerID)
+	}
+
+	var quotes []Quotation
+	err := query.Order("sequence DESC").Find(&quotes).Error
+	return quotes, err
+}
+
+// SetQuotationStatus moves a quotation to SENT or ACCEPTED. An expired quotation
+// must be re-issued rather than accepted.
+func SetQuotationStatus(db *gorm.DB, id, businessID uint, status QuotationStatus) (*Quotation, error) {
+	q, err := GetQuotation(db, id, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": status}
+	switch {
+	case q.Status == QuoteExpired:
+		return nil, errors.New("quotation has expired")
+	case status == QuoteSent && q.Status == QuoteDraft:
+		updates["sent_at"] = now
+		q.SentAt = &now
+	case status == QuoteAccepted && (q.Status == QuoteDraft || q.Status == QuoteSent):
+		updates["accepted_at"] = now
+		q.AcceptedAt = &now
+	default:
+		return nil, fmt.Errorf("a %s quotation cannot be marked %s", q.Status, status)
+	}
+
+	if err := db.Model(q).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	q.Status = status
+	return q, nil
+}
+
+// ConvertQuotation turns a quotation into a draft sale carrying its customer and
+// quoted prices. Promotions are not applied on top of a quote, and stock held for the
+// quotation moves to the sale. The quotation is marked ACCEPTED.
+func ConvertQuotation(db *gorm.DB, id, businessID uint, tenantID string, outletID, cashierID uint) (*Sale, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	var q Quotation
+	if err := tx.Preload("Items").First(&q, "id = ? AND business_id = ?", id, businessID).Error; err != nil {
+		return nil, errors.New("quotation not found")
+	}
+	if q.SaleID != nil {
+		return nil, fmt.Errorf("quotation already converted into sale %d", *q.SaleID)
+	}
+	if q.Status == QuoteExpired || q.expired() {
+		return nil, errors.New("quotation has expired")
+	}
+
+	if outletID == 0 {
+		outletID = q.OutletID
+	}
+	sale := &Sale{
+		BusinessID:    businessID,
+		TenantID:      tenantID,
+		OutletID:      outletID,
+		Status:        StatusDraft,
+		CashierID:     cashierID,
+		CustomerID:    q.CustomerID,
+		CustomerName:  q.CustomerName,
+		CustomerPhone: q.CustomerPhone,
+		CustomerEmail: q.CustomerEmail,
+		OrderType:     "takeaway",
+		QuotationID:   &q.ID,
+		SaleDate:      time.Now(),
+	}
+	if err := tx.Create(sale).Error; err != nil {
+		return nil, err
+	}
+
+	for _, line := range q.Items {
+		var prod product.Product
+		if err := tx.First(&prod, "id = ? AND business_id = ?", line.ProductID, businessID).Error; err != nil {
+			return nil, fmt.Errorf("product %s not found", line.ProductName)
+		}
+		item := SaleItem{
+			SaleID:             sale.ID,
+			ProductID:          prod.ID,
+			ProductName:        line.ProductName,
+			Quantity:           line.Quantity,
+			UnitPrice:          line.UnitPrice,
+			OriginalUnitPrice:  line.OriginalUnitPrice,
+			CostPrice:          prod.Cost,
+			ManualDiscount:     line.Discount,
+			OverrideApprovedBy: line.OverrideApprovedBy,
+		}
+		priceSaleItem(&item)
+		if err := tx.Create(&item).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	items, err := repriceSale(tx, sale)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.StockReserved {
+		if err := inventory.NewReservationService(tx).MoveQuotationReservations(q.ID, sale.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": QuoteAccepted, "sale_id": sale.ID}
+	if q.AcceptedAt == nil {
+		updates["accepted_at"] = now
+	}
+	if err := tx.Model(&q).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	if err := LogActivity(tx, sale.ID, businessID, cashierID, ActionCreated, ActivityDetails{
+		Reason: "converted from quotation " + q.Number,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	sale.SaleItems = items
+	return sale, nil
+}
+
+// RenderQuotation renders a quotation as HTML or PDF with the business's receipt template
+func RenderQuotation(db *gorm.DB, id, businessID uint, format string) ([]byte, error) {
+	q, err := GetQuotation(db, id, businessID)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := printing.GetReceiptTemplate(db, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	var currency string
+	db.Table("businesses").Select("currency").Where("id = ?", businessID).Scan(&currency)
+
+	title := "QUOTATION"
+	if q.Kind == KindProforma {
+		title = "PRO-FORMA INVOICE"
+	}
+	validUntil := q.ValidUntil
+	doc := printing.Receipt{
+		Title:        title,
+		ReceiptNo:    q.Number,
+		Date:         q.CreatedAt,
+		ValidUntil:   &validUntil,
+		CustomerName: q.CustomerName,
+		Currency:     currency,
+		Subtotal:     q.Subtotal,
+		Tax:          q.Tax,
+		TaxInclusive: q.TaxInclusive,
+		Total:        q.Total,
+	}
+	for _, item := range q.Items {
+		line := printing.ReceiptLine{
+			Name:     item.ProductName,
+			Quantity: item.Quantity,
+			Price:    item.UnitPrice,
+			Total:    item.TotalPrice,
+		}
+		if item.Discount > 0 {
+			line.Details = append(line.Details, fmt.Sprintf("Discount -%.2f", item.Discount))
+		}
+		doc.Lines = append(doc.Lines, line)
+	}
+
+	switch format {
+	case ReceiptFormatHTML:
+		return printing.RenderHTML(doc, tmpl)
+	case ReceiptFormatPDF:
+		return printing.RenderPDF(doc, tmpl), nil
+	}
+	return nil, errors.New("invalid quotation format")
+}
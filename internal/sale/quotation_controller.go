@@ -0,0 +1,263 @@
+// internal/sale/quotation_controller.go
+package sale
+
+import (
+	"fmt"
+
+	"pos-fiber-app/internal/types"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// CreateQuotationHandler godoc
+// @Summary Create a quotation or pro-forma invoice
+// @Description Prices the items (catalogue price unless unit_price is given; cuts above the override threshold need approval) and gives the quotation the business's next number. With reserve_stock, the stock is held until valid_until.
+// @Tags Quotations
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param body body QuotationRequest true "Quotation"
+// @Success 201 {object} Quotation
+// @Failure 400 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /quotations [post]
+func CreateQuotationHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req QuotationRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		outletID := uint(0)
+		if claims.OutletID != nil {
+			outletID = *claims.OutletID
+		}
+
+		q, err := CreateQuotation(db, bizID, claims.TenantID, outletID, claims.UserID, req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(q)
+	}
+}
+
+// ListQuotationsHandler godoc
+// @Summary List quotations
+// @Tags Quotations
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "DRAFT, SENT, ACCEPTED or EXPIRED"
+// @Param customer_id query uint false "Customer ID"
+// @Success 200 {array} Quotation
+// @Router /quotations [get]
+func ListQuotationsHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		bizID := c.Locals("current_business_id").(uint)
+
+		filters := QuotationFilters{Status: QuotationStatus(c.Query("status"))}
+		if id := c.QueryInt("customer_id"); id > 0 {
+			cid := uint(id)
+			filters.CustomerID = &cid
+		}
+
+		quotes, err := ListQuotations(db, bizID, filters)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch quotations")
+		}
+
+		return c.JSON(quotes)
+	}
+}
+
+// GetQuotationHandler godoc
+// @Summary Get a quotation
+// @Tags Quotations
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Quotation ID"
+// @Success 200 {object} Quotation
+// @Failure 404 {object} map[string]string
+// @Router /quotations/{id} [get]
+func GetQuotationHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid quotation ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		q, err := GetQuotation(db, uint(id), bizID)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.JSON(q)
+	}
+}
+
+// UpdateQuotationHandler godoc
+// @Summary Replace a draft quotation
+// @Description Replaces the customer, items and terms of a quotation that has not been sent. Its number stays the same.
+// @Tags Quotations
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path uint true "Quotation ID"
+// @Param body body QuotationRequest true "Quotation"
+// @Success 200 {object} Quotation
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /quotations/{id} [put]
+func UpdateQuotationHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid quotation ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		var req QuotationRequest
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid payload")
+		}
+
+		q, err := UpdateQuotation(db, uint(id), bizID, claims.UserID, req)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.JSON(q)
+	}
+}
+
+// quotationStatusHandler moves a quotation to the given status
+func quotationStatusHandler(db *gorm.DB, status QuotationStatus) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid quotation ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		q, err := SetQuotationStatus(db, uint(id), bizID, status)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.JSON(q)
+	}
+}
+
+// SendQuotationHandler godoc
+// @Summary Mark a quotation sent
+// @Description Marks a draft quotation as sent to the customer; it can no longer be changed.
+// @Tags Quotations
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Quotation ID"
+// @Success 200 {object} Quotation
+// @Failure 422 {object} map[string]string
+// @Router /quotations/{id}/send [post]
+func SendQuotationHandler(db *gorm.DB) fiber.Handler {
+	return quotationStatusHandler(db, QuoteSent)
+}
+
+// AcceptQuotationHandler godoc
+// @Summary Mark a quotation accepted
+// @Tags Quotations
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Quotation ID"
+// @Success 200 {object} Quotation
+// @Failure 422 {object} map[string]string
+// @Router /quotations/{id}/accept [post]
+func AcceptQuotationHandler(db *gorm.DB) fiber.Handler {
+	return quotationStatusHandler(db, QuoteAccepted)
+}
+
+// ConvertQuotationHandler godoc
+// @Summary Convert a quotation into a draft sale
+// @Description Makes a draft sale with the quotation's customer, items and quoted prices (promotions are not applied on top) and marks the quotation accepted. Reserved stock moves to the sale.
+// @Tags Quotations
+// @Security BearerAuth
+// @Produce json
+// @Param id path uint true "Quotation ID"
+// @Success 201 {object} Sale
+// @Failure 404 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Router /quotations/{id}/convert [post]
+func ConvertQuotationHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid quotation ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+		claims := c.Locals("user").(*types.UserClaims)
+
+		outletID := uint(0)
+		if claims.OutletID != nil {
+			outletID = *claims.OutletID
+		}
+
+		sale, err := ConvertQuotation(db, uint(id), bizID, claims.TenantID, outletID, claims.UserID)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(sale)
+	}
+}
+
+// GetQuotationDocumentHandler godoc
+// @Summary Download a quotation
+// @Description Renders the quotation (or pro-forma invoice) with the business's receipt template.
+// @Tags Quotations
+// @Security BearerAuth
+// @Produce html
+// @Produce application/pdf
+// @Param id path uint true "Quotation ID"
+// @Param format query string false "pdf (default) or html"
+// @Success 200 {file} file
+// @Failure 404 {object} map[string]string
+// @Router /quotations/{id}/document [get]
+func GetQuotationDocumentHandler(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := c.ParamsInt("id")
+		if err != nil || id <= 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid quotation ID")
+		}
+
+		bizID := c.Locals("current_business_id").(uint)
+
+		format := c.Query("format", ReceiptFormatPDF)
+		var contentType string
+		switch format {
+		case ReceiptFormatHTML:
+			contentType = fiber.MIMETextHTMLCharsetUTF8
+		case ReceiptFormatPDF:
+			contentType = "application/pdf"
+			c.Set("Content-Disposition", fmt.Sprintf("inline; filename=quotation_%d.pdf", id))
+		default:
+			return fiber.NewError(fiber.StatusBadRequest, "format must be pdf or html")
+		}
+
+		content, err := RenderQuotation(db, uint(id), bizID, format)
+		if err != nil {
+			return handleSaleError(err)
+		}
+
+		c.Set("Content-Type", contentType)
+		return c.Send(content)
+	}
+}
@@ -0,0 +1,177 @@
+// internal/sale/receipt.go
+package sale
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"pos-fiber-app/internal/printing"
+
+	"gorm.io/gorm"
+)
+
+// Receipt output formats
+const (
+	ReceiptFormatHTML   = "html"
+	ReceiptFormatPDF    = "pdf"
+	ReceiptFormatESCPOS = "escpos"
+)
+
+// receiptFor builds the printable receipt for a sale
+func receiptFor(db *gorm.DB, r *SaleReceipt) printing.Receipt {
+	sale := r.Sale
+
+	var currency string
+	db.Table("businesses").Select("currency").Where("id = ?", sale.BusinessID).Scan(&currency)
+
+	out := printing.Receipt{
+		SaleID:        sale.ID,
+		ReceiptNo:     r.ReceiptNo,
+		Date:          sale.SaleDate,
+		Cashier:       sale.CashierName,
+		TableNumber:   sale.TableNumber,
+		CustomerName:  sale.CustomerName,
+		Currency:      currency,
+		Subtotal:      sale.Subtotal,
+		Discount:      sale.Discount,
+		Tax:           sale.Tax,
+		TaxInclusive:  sale.TaxInclusive,
+		ServiceCharge: sale.ServiceCharge,
+		DeliveryFee:   sale.DeliveryFee,
+		Total:         sale.Total,
+		Tip:           sale.Tip,
+		Change:        roundMoney(r.Change),
+	}
+
+	for _, item := range r.Items {
+		line := printing.ReceiptLine{
+			Name:     item.ProductName,
+			Quantity: item.Quantity,
+			Price:    roundMoney(item.UnitPrice + item.ModifierTotal),
+			Total:    item.TotalPrice,
+		}
+		for _, m := range item.Modifiers {
+			if m.PriceDelta != 0 {
+				line.Details = append(line.Details, fmt.Sprintf("%s (%+.2f)", m.Name, m.PriceDelta))
+			} else {
+				line.Details = append(line.Details, m.Name)
+			}
+		}
+		for _, p := range item.Promotions {
+			line.Details = append(line.Details, fmt.Sprintf("%s -%.2f", p.Name, p.Amount))
+		}
+		if item.ManualDiscount > 0 {
+			line.Details = append(line.Details, fmt.Sprintf("Discount -%.2f", item.ManualDiscount))
+		}
+		out.Lines = append(out.Lines, line)
+	}
+
+	for _, p := range sale.Payments {
+		if p.Amount > 0 {
+			out.Payments = append(out.Payments, printing.ReceiptPayment{Method: p.Provider, Amount: p.Amount})
+		}
+	}
+	return out
+}
+
+// loadSaleReceipt rebuilds the receipt of a finished sale
+func loadSaleReceipt(db *gorm.DB, saleID, businessID uint) (*SaleReceipt, error) {
+	result, err := GetSaleDetails(db, saleID, businessID)
+	if err != nil {
+		return nil, err
+	}
+	sale := result.Sale
+	switch sale.Status {
+	case StatusCompleted, StatusRefunded, StatusPendingPayment:
+	default:
+		return nil, fmt.Errorf("no receipt for a %s sale", sale.Status)
+	}
+
+	if err := db.Where("sale_id = ? AND amount > 0", sale.ID).Order("id ASC").Find(&sale.Payments).Error; err != nil {
+		return nil, err
+	}
+	paid := 0.0
+	for _, p := range sale.Payments {
+		paid += p.Amount
+	}
+
+	return &SaleReceipt{
+		Sale:        sale,
+		Items:       result.Items,
+		Change:      max(paid-sale.Total, 0),
+		ReceiptNo:   sale.SaleDate.Format("20060102") + "-" + fmt.Sprintf("%03d", sale.DailySequence),
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// RenderSaleReceipt renders a sale's receipt as HTML, PDF or ESC/POS. Once the receipt
+// has been printed, every rendering is marked COPY.
+func RenderSaleReceipt(db *gorm.DB, saleID, businessID uint, format string) ([]byte, error) {
+	return renderSaleReceipt(db, saleID, businessID, format, true)
+}
+
+// RenderCustomerReceipt renders a sale's receipt for the customer's public link,
+// which is never marked COPY
+func RenderCustomerReceipt(db *gorm.DB, saleID, businessID uint, format string) ([]byte, error) {
+	return renderSaleReceipt(db, saleID, businessID, format, false)
+}
+
+func renderSaleReceipt(db *gorm.DB, saleID, businessID uint, format string, markCopy bool) ([]byte, error) {
+	r, err := loadSaleReceipt(db, saleID, businessID)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := printing.GetReceiptTemplate(db, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := receiptFor(db, r)
+	receipt.Copy = markCopy && r.Sale.ReceiptPrintedAt != nil
+
+	switch format {
+	case ReceiptFormatHTML:
+		return printing.RenderHTML(receipt, tmpl)
+	case ReceiptFormatPDF:
+		return printing.RenderPDF(receipt, tmpl), nil
+	case ReceiptFormatESCPOS:
+		return printing.RenderESCPOS(receipt, tmpl), nil
+	}
+	return nil, errors.New("invalid receipt format")
+}
+
+// PrintSaleReceipt sends a sale's receipt to the receipt printers of its outlet. The
+// first print is the original; every print after it is marked COPY.
+func PrintSaleReceipt(db *gorm.DB, saleID, businessID uint, tenantID string) (*printing.Receipt, error) {
+	r, err := loadSaleReceipt(db, saleID, businessID)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := printing.GetReceiptTemplate(db, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Claim the original in one statement so two tills printing at once cannot both get it
+	now := time.Now()
+	claim := db.Model(&Sale{}).Where("id = ? AND receipt_printed_at IS NULL", saleID).UpdateColumn("receipt_printed_at", now)
+	if claim.Error != nil {
+		return nil, claim.Error
+	}
+
+	receipt := receiptFor(db, r)
+	receipt.Copy = claim.RowsAffected == 0
+
+	printed, err := printing.PrintReceipt(db, tenantID, r.Sale.OutletID, receipt, tmpl)
+	if err == nil && !printed {
+		err = errors.New("no receipt printer set up for this outlet")
+	}
+	if err != nil {
+		if !receipt.Copy {
+			db.Model(&Sale{}).Where("id = ?", saleID).UpdateColumn("receipt_printed_at", nil)
+		}
+		return nil, err
+	}
+	return &receipt, nil
+}
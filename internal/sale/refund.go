@@ -0,0 +1,526 @@
+// internal/sale/refund.go
+package sale
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"pos-fiber-app/internal/customer"
+	"pos-fiber-app/internal/giftcard"
+	"pos-fiber-app/internal/inventory"
+	"pos-fiber-app/internal/loyalty"
+	"pos-fiber-app/internal/recipe"
+	"pos-fiber-app/internal/shift"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RefundReason string
+
+const (
+	RefundDamaged        RefundReason = "DAMAGED"
+	RefundWrongItem      RefundReason = "WRONG_ITEM"
+	RefundCustomerReturn RefundReason = "CUSTOMER_RETURN"
+	RefundOvercharged    RefundReason = "OVERCHARGED"
+	RefundOther          RefundReason = "OTHER"
+)
+
+// Refund records money (and optionally stock) returned against a completed sale.
+// The money side is also written as negative Payment rows so tender totals net out.
+type Refund struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	SaleID      uint         `gorm:"index" json:"sale_id"`
+	BusinessID  uint         `gorm:"index" json:"business_id"`
+	ShiftID     *uint        `gorm:"index" json:"shift_id,omitempty"`
+	Amount      float64      `gorm:"type:decimal(12,2)" json:"amount"`
+	FeesAmount  float64      `gorm:"type:decimal(12,2);default:0" json:"fees_amount,omitempty"` // service charge and delivery fee refunded, included in Amount
+	ReasonCode  RefundReason `gorm:"type:varchar(30)" json:"reason_code"`
+	Notes       string       `gorm:"type:text" json:"notes,omitempty"`
+	ProcessedBy uint         `json:"processed_by"`
+	CreatedAt   time.Time    `gorm:"index" json:"created_at"`
+
+	Items       []RefundItem   `gorm:"foreignKey:RefundID;constraint:OnDelete:CASCADE" json:"items"`
+	Payments    []Payment      `gorm:"-" json:"payments,omitempty"`
+	StoreCredit *giftcard.Card `gorm:"-" json:"store_credit,omitempty"` // card issued for a STORE_CREDIT tender
+}
+
+// RefundItem is one returned line. CostReturned is the cost of goods that went back
+// on the shelf (zero when the item was not restocked, e.g. damaged).
+type RefundItem struct {
+	ID           uint    `gorm:"primaryKey" json:"id"`
+	RefundID     uint    `gorm:"index" json:"refund_id"`
+	SaleItemID   uint    `gorm:"index" json:"sale_item_id"`
+	ProductID    uint    `json:"product_id"`
+	ProductName  string  `json:"product_name"`
+	Quantity     float64 `gorm:"type:decimal(12,3)" json:"quantity"`
+	Amount       float64 `gorm:"type:decimal(12,2)" json:"amount"`
+	CostReturned float64 `gorm:"type:decimal(12,2)" json:"cost_returned"`
+	Restocked    bool    `json:"restocked"`
+}
+
+type RefundSaleRequest struct {
+	Items      []RefundItemRequest   `json:"items"` // empty = refund everything not yet refunded
+	ReasonCode RefundReason          `json:"reason_code" validate:"required,oneof=DAMAGED WRONG_ITEM CUSTOMER_RETURN OVERCHARGED OTHER"`
+	Notes      string                `json:"notes,omitempty"`
+	Tenders    []RefundTenderRequest `json:"tenders,omitempty"`     // defaults to the sale's original tenders
+	RefundFees *bool                 `json:"refund_fees,omitempty"` // service charge and delivery fee; defaults to true when refunding everything
+}
+
+type RefundItemRequest struct {
+	SaleItemID uint    `json:"sale_item_id" validate:"required"`
+	Quantity   float64 `json:"quantity" validate:"required,gt=0"`
+	Restock    *bool   `json:"restock,omitempty"` // defaults to true
+}
+
+type RefundTenderRequest struct {
+	Method string  `json:"method" validate:"required"`
+	Amount float64 `json:"amount" validate:"required,gt=0"`
+}
+
+// RefundSale refunds all or part of a completed sale.
+// Returned lines are restocked through the recipe-aware path, the money goes back as
+// negative Payment rows per tender and the shift totals are reversed. shiftID is the
+// shift the cash leaves from; when nil the sale's own shift is used.
+func RefundSale(db *gorm.DB, saleID, businessID, userID uint, shiftID *uint, req RefundSaleRequest) (*Refund, error) {
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	// The sale row is locked so concurrent refunds see each other's amounts below
+	var sale Sale
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("SaleItems").First(&sale, "id = ? AND business_id = ?", saleID, businessID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("sale not found")
+		}
+		return nil, err
+	}
+	if sale.Status != StatusCompleted {
+		return nil, errors.New("only completed sales can be refunded")
+	}
+
+	// What has already gone back on earlier refunds
+	refundedQty, err := refundedQuantities(tx, saleID)
+	if err != nil {
+		return nil, err
+	}
+	var already struct {
+		Amount float64
+		Fees   float64
+	}
+	tx.Model(&Refund{}).Where("sale_id = ?", saleID).
+		Select("COALESCE(SUM(amount), 0) AS amount, COALESCE(SUM(fees_amount), 0) AS fees").
+		Scan(&already)
+
+	itemsByID := make(map[uint]SaleItem, len(sale.SaleItems))
+	for _, item := range sale.SaleItems {
+		itemsByID[item.ID] = item
+	}
+
+	lines := req.Items
+	if len(lines) == 0 {
+		for _, item := range sale.SaleItems {
+			if left := inventory.RoundQuantity(item.Quantity - refundedQty[item.ID]); left > 0 {
+				lines = append(lines, RefundItemRequest{SaleItemID: item.ID, Quantity: left})
+			}
+		}
+		if len(lines) == 0 && len(sale.SaleItems) > 0 {
+			return nil, errors.New("sale has already been fully refunded")
+		}
+	}
+
+	refundFees := len(req.Items) == 0
+	if req.RefundFees != nil {
+		refundFees = *req.RefundFees
+	}
+
+	refund := &Refund{
+		SaleID:      saleID,
+		BusinessID:  businessID,
+		ShiftID:     sale.ShiftID,
+		ReasonCode:  req.ReasonCode,
+		Notes:       req.Notes,
+		ProcessedBy: userID,
+	}
+	if shiftID != nil {
+		refund.ShiftID = shiftID
+	}
+
+	fullyRefunded, err := priceRefund(refund, &sale, lines, refundedQty, already.Amount, already.Fees, refundFees)
+	if err != nil {
+		return nil, err
+	}
+	for _, ri := range refund.Items {
+		if err := checkItemQuantity(tx, ri.ProductID, ri.Quantity); err != nil {
+			return nil, fmt.Errorf("%s: %w", ri.ProductName, err)
+		}
+	}
+
+	tenders, err := resolveRefundTenders(tx, saleID, refund.Amount, req.Tenders)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Create(refund).Error; err != nil {
+		return nil, err
+	}
+
+	// Returned goods go back on the shelf against the refund
+	recipeSvc := recipe.NewRecipeService(db)
+	m := inventory.Movement{Reason: inventory.ReasonRefund, RefType: "refund", RefID: refund.ID, UserID: &userID}
+	if sale.OutletID != 0 {
+		m.OutletID = &sale.OutletID
+	}
+	stockTx := inventory.WithMovement(tx, m)
+	for _, ri := range refund.Items {
+		if !ri.Restocked {
+			continue
+		}
+		item := itemsByID[ri.SaleItemID]
+		if err := recipeSvc.RestockWithRecipe(stockTx, item.ProductID, businessID, ri.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to restock %s: %w", item.ProductName, err)
+		}
+		if err := restockModifierStock(stockTx, recipeSvc, businessID, item, ri.Quantity); err != nil {
+			return nil, fmt.Errorf("failed to restock %s modifiers: %w", item.ProductName, err)
+		}
+	}
+
+	// Returned gift cards are emptied; ones already spent from cannot be refunded
+	for _, ri := range refund.Items {
+		if itemsByID[ri.SaleItemID].IsGiftCard {
+			if err := giftcard.ReverseLoads(tx, businessID, ri.SaleItemID, int(ri.Quantity), &refund.ID, userID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	now := time.Now()
+	for i, t := range tenders {
+		payment := Payment{
+			SaleID:            saleID,
+			BusinessID:        businessID,
+			Amount:            -t.Amount,
+			NetAmount:         -t.Amount,
+			Provider:          t.Method,
+			InternalReference: fmt.Sprintf("REFUND-%d-%d", refund.ID, i+1),
+			Status:            ReconSuccess,
+			ReconciledAt:      now,
+			CreatedAt:         now,
+		}
+		if err := tx.Create(&payment).Error; err != nil {
+			return nil, err
+		}
+		refund.Payments = append(refund.Payments, payment)
+
+		// Money refunded to a CREDIT tender comes off the customer's account
+		if isCreditTender(t.Method) && sale.CustomerID != nil {
+			if err := customer.CreditRefund(tx, businessID, *sale.CustomerID, saleID, t.Amount, userID); err != nil {
+				return nil, err
+			}
+		}
+		// and money refunded to a LOYALTY tender goes back as points
+		if isLoyaltyTender(t.Method) {
+			if err := loyalty.RestoreRedeemed(tx, businessID, saleID, t.Amount); err != nil {
+				return nil, err
+			}
+		}
+		// GIFT_CARD refunds go back on the cards that paid; STORE_CREDIT pays out on a new card
+		if isGiftCardTender(t.Method) {
+			if err := giftcard.RefundToCards(tx, businessID, saleID, &refund.ID, t.Amount, userID); err != nil {
+				return nil, err
+			}
+		}
+		if isStoreCreditTender(t.Method) {
+			load := giftcard.Load{
+				Amount:     t.Amount,
+				Kind:       giftcard.KindStoreCredit,
+				CustomerID: sale.CustomerID,
+				SaleID:     &saleID,
+				RefundID:   &refund.ID,
+				Notes:      fmt.Sprintf("Store credit for refund #%d", refund.ID),
+				RecordedBy: userID,
+			}
+			if refund.StoreCredit != nil {
+				load.Code = refund.StoreCredit.Code // several STORE_CREDIT tenders share one card
+			}
+			card, err := giftcard.LoadValue(tx, businessID, load)
+			if err != nil {
+				return nil, err
+			}
+			refund.StoreCredit = card
+		}
+	}
+
+	// Points earned on the refunded part of the sale are taken back
+	if sale.Total > 0 {
+		fraction := refund.Amount / sale.Total
+		if fullyRefunded {
+			fraction = 1
+		}
+		if err := loyalty.ReverseEarned(tx, businessID, saleID, fraction); err != nil {
+			return nil, err
+		}
+	}
+
+	if refund.ShiftID != nil {
+		shiftSvc := shift.NewShiftService(tx)
+		for _, t := range tenders {
+			if err := shiftSvc.UpdateShiftMetrics(*refund.ShiftID, -t.Amount, t.Method); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if fullyRefunded {
+		if err := tx.Model(&sale).Update("status", StatusRefunded).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	methods := make([]string, 0, len(tenders))
+	for _, t := range tenders {
+		methods = append(methods, t.Method)
+	}
+	reason := string(req.ReasonCode)
+	if req.Notes != "" {
+		reason += ": " + req.Notes
+	}
+	if err := LogActivity(tx, saleID, businessID, userID, ActionRefunded, ActivityDetails{
+		Reason:        reason,
+		AmountPaid:    refund.Amount,
+		PaymentMethod: strings.Join(methods, ","),
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return refund, nil
+}
+
+// priceRefund fills in the lines and amount of a refund of lines from sale and reports
+// whether every unit of the sale is then back. refundedQty holds the quantities earlier
+// refunds took back and is updated; alreadyAmount and alreadyFees are what they paid out.
+// Line amounts carry their share of the sale-level discount and tax. The service
+// charge and delivery fee are not spread over the lines; they are refunded on their own.
+func priceRefund(refund *Refund, sale *Sale, lines []RefundItemRequest, refundedQty map[uint]float64, alreadyAmount, alreadyFees float64, refundFees bool) (bool, error) {
+	itemsByID := make(map[uint]SaleItem, len(sale.SaleItems))
+	for _, item := range sale.SaleItems {
+		itemsByID[item.ID] = item
+	}
+
+	fees := roundMoney(sale.ServiceCharge + sale.DeliveryFee)
+	itemsTotal := roundMoney(sale.Total - fees)
+	ratio := 1.0
+	if sale.Subtotal > 0 {
+		ratio = itemsTotal / sale.Subtotal
+	}
+
+	for _, line := range lines {
+		item, ok := itemsByID[line.SaleItemID]
+		if !ok {
+			return false, fmt.Errorf("item %d not found on sale %d", line.SaleItemID, sale.ID)
+		}
+		if line.Quantity <= 0 {
+			return false, fmt.Errorf("invalid quantity for %s", item.ProductName)
+		}
+		if left := inventory.RoundQuantity(item.Quantity - refundedQty[item.ID]); line.Quantity > left {
+			return false, fmt.Errorf("cannot refund %s of %s: only %s left to refund", inventory.FormatQuantity(line.Quantity), item.ProductName, inventory.FormatQuantity(left))
+		}
+		refundedQty[item.ID] = inventory.RoundQuantity(refundedQty[item.ID] + line.Quantity)
+
+		restock := (line.Restock == nil || *line.Restock) && !item.IsGiftCard
+		refundItem := RefundItem{
+			SaleItemID:  item.ID,
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			Quantity:    line.Quantity,
+			Amount:      roundMoney(item.TotalPrice / item.Quantity * line.Quantity * ratio),
+			Restocked:   restock,
+		}
+		if restock {
+			refundItem.CostReturned = roundMoney(item.CostPrice * line.Quantity)
+		}
+
+		refund.Amount += refundItem.Amount
+		refund.Items = append(refund.Items, refundItem)
+	}
+
+	// Once every unit is back, refund exactly what is left so rounding never leaves a few kobo behind
+	fullyRefunded := true
+	for _, item := range sale.SaleItems {
+		if refundedQty[item.ID] < item.Quantity {
+			fullyRefunded = false
+			break
+		}
+	}
+	if fullyRefunded {
+		refund.Amount = itemsTotal - (alreadyAmount - alreadyFees)
+	}
+	if refundFees {
+		refund.FeesAmount = roundMoney(fees - alreadyFees)
+		refund.Amount += refund.FeesAmount
+	}
+	refund.Amount = roundMoney(refund.Amount)
+	if refund.Amount <= 0 {
+		return false, errors.New("nothing left to refund on this sale")
+	}
+	return fullyRefunded, nil
+}
+
+// ListRefunds returns all refunds recorded against a sale
+func ListRefunds(db *gorm.DB, saleID, businessID uint) ([]Refund, error) {
+	refunds := []Refund{}
+	err := db.Preload("Items").
+		Where("sale_id = ? AND business_id = ?", saleID, businessID).
+		Order("created_at ASC").
+		Find(&refunds).Error
+	return refunds, err
+}
+
+// hasRefunds reports whether any refund has been recorded against a sale
+func hasRefunds(db *gorm.DB, saleID uint) bool {
+	var count int64
+	db.Model(&Refund{}).Where("sale_id = ?", saleID).Count(&count)
+	return count > 0
+}
+
+// refundedQuantities returns units already refunded per sale item
+func refundedQuantities(db *gorm.DB, saleID uint) (map[uint]float64, error) {
+	var rows []struct {
+		SaleItemID uint
+		Quantity   float64
+	}
+	err := db.Table("refund_items").
+		Joins("JOIN refunds ON refunds.id = refund_items.refund_id").
+		Where("refunds.sale_id = ?", saleID).
+		Select("refund_items.sale_item_id, SUM(refund_items.quantity) as quantity").
+		Group("refund_items.sale_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	qty := make(map[uint]float64, len(rows))
+	for _, r := range rows {
+		qty[r.SaleItemID] = r.Quantity
+	}
+	return qty, nil
+}
+
+// resolveRefundTenders validates explicit tenders, or spreads the amount back over the
+// sale's original tenders (in the order they were taken) net of anything already refunded.
+func resolveRefundTenders(db *gorm.DB, saleID uint, amount float64, requested []RefundTenderRequest) ([]RefundTenderRequest, error) {
+	if len(requested) > 0 {
+		var total float64
+		for _, t := range requested {
+			if t.Amount <= 0 {
+				return nil, errors.New("refund tender amounts must be positive")
+			}
+			total += t.Amount
+		}
+		if math.Abs(total-amount) > 0.01 {
+			return nil, fmt.Errorf("refund tenders total %.2f but the refund is %.2f", total, amount)
+		}
+		return requested, nil
+	}
+
+	var payments []Payment
+	if err := db.Where("sale_id = ?", saleID).Order("id ASC").Find(&payments).Error; err != nil {
+		return nil, err
+	}
+
+	// Net position per tender after earlier refunds
+	var order []string
+	net := make(map[string]float64)
+	for _, p := range payments {
+		if _, seen := net[p.Provider]; !seen {
+			order = append(order, p.Provider)
+		}
+		net[p.Provider] += p.Amount
+	}
+
+	var tenders []RefundTenderRequest
+	remaining := amount
+	for _, method := range order {
+		if remaining <= 0 {
+			break
+		}
+		available := roundMoney(net[method])
+		if available <= 0 {
+			continue
+		}
+		portion := math.Min(available, remaining)
+		tenders = append(tenders, RefundTenderRequest{Method: method, Amount: roundMoney(portion)})
+		remaining = roundMoney(remaining - portion)
+	}
+
+	if remaining > 0 {
+		// Paid amounts include change given; whatever is left goes back as cash
+		tenders = append(tenders, RefundTenderRequest{Method: "CASH", Amount: remaining})
+	}
+
+	return tenders, nil
+}
+
+// refundSummary aggregates refunds made within a reporting window
+type refundSummary struct {
+	ByMethod       map[string]float64
+	Total          float64
+	Count          int
+	CostReturned   float64 // cost of goods put back on the shelf
+	ProfitReversed float64 // refunded item amount not covered by returned stock
+	ServiceCharges float64 // service charge handed back with refunded fees, already in ByMethod
+}
+
+// summarizeRefunds totals refunds created in [from, to) for a business
+func summarizeRefunds(db *gorm.DB, businessID uint, from, to time.Time) refundSummary {
+	summary := refundSummary{ByMethod: map[string]float64{}}
+
+	var byMethod []struct {
+		Provider string
+		Amount   float64
+	}
+	db.Table("payments").
+		Where("business_id = ? AND amount < 0 AND created_at >= ? AND created_at < ? AND internal_reference LIKE ?",
+			businessID, from, to, "REFUND-%").
+		Select("provider, -SUM(amount) as amount").
+		Group("provider").
+		Scan(&byMethod)
+	for _, m := range byMethod {
+		summary.ByMethod[m.Provider] = m.Amount
+	}
+
+	var totals struct {
+		Total float64
+		Fees  float64
+		Count int
+	}
+	db.Model(&Refund{}).
+		Where("business_id = ? AND created_at >= ? AND created_at < ?", businessID, from, to).
+		Select("COALESCE(SUM(amount), 0) as total, COALESCE(SUM(fees_amount), 0) as fees, COUNT(*) as count").
+		Scan(&totals)
+	summary.Total = totals.Total
+	summary.Count = totals.Count
+
+	db.Table("refund_items").
+		Joins("JOIN refunds ON refunds.id = refund_items.refund_id").
+		Where("refunds.business_id = ? AND refunds.created_at >= ? AND refunds.created_at < ?", businessID, from, to).
+		Select("COALESCE(SUM(refund_items.cost_returned), 0)").
+		Scan(&summary.CostReturned)
+	summary.ProfitReversed = totals.Total - totals.Fees - summary.CostReturned
+
+	// Refunded fees are the sale's service charge and delivery fee, in that proportion
+	db.Table("refunds").
+		Joins("JOIN sales ON sales.id = refunds.sale_id").
+		Where("refunds.business_id = ? AND refunds.created_at >= ? AND refunds.created_at < ? AND refunds.fees_amount > 0", businessID, from, to).
+		Select("COALESCE(SUM(refunds.fees_amount * sales.service_charge / NULLIF(sales.service_charge + sales.delivery_fee, 0)), 0)").
+		Scan(&summary.ServiceCharges)
+	summary.ServiceCharges = roundMoney(summary.ServiceCharges)
+
+	return summary
+}
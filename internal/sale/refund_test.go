@@ -0,0 +1,98 @@
+// internal/sale/refund_test.go
+package sale
+
+import "testing"
+
+// A sale of 2 x 50 and 1 x 30 with 10 off, 7.5% VAT on top, 10% service charge and a
+// delivery fee: the lines carry 129 of the total, the fees 15
+func refundTestSale() Sale {
+	return Sale{
+		ID:            1,
+		Subtotal:      130,
+		Discount:      10,
+		Tax:           9,
+		ServiceCharge: 12,
+		DeliveryFee:   3,
+		Total:         144,
+		SaleItems: []SaleItem{
+			{ID: 1, ProductName: "Pizza", Quantity: 2, TotalPrice: 100, CostPrice: 20},
+			{ID: 2, ProductName: "Wine", Quantity: 1, TotalPrice: 30, CostPrice: 12},
+		},
+	}
+}
+
+func TestPriceRefund(t *testing.T) {
+	noRestock := false
+	tests := []struct {
+		name       string
+		lines      []RefundItemRequest
+		refundFees bool
+		amount     float64
+		fees       float64
+		cost       float64
+		full       bool
+	}{
+		{"one unit", []RefundItemRequest{{SaleItemID: 1, Quantity: 1}}, false, 49.62, 0, 20, false},
+		{"one unit with fees", []RefundItemRequest{{SaleItemID: 1, Quantity: 1}}, true, 64.62, 15, 20, false},
+		{"not restocked", []RefundItemRequest{{SaleItemID: 2, Quantity: 1, Restock: &noRestock}}, false, 29.77, 0, 0, false},
+		{"everything", []RefundItemRequest{{SaleItemID: 1, Quantity: 2}, {SaleItemID: 2, Quantity: 1}}, true, 144, 15, 52, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sale := refundTestSale()
+			refund := &Refund{}
+			full, err := priceRefund(refund, &sale, tt.lines, map[uint]float64{}, 0, 0, tt.refundFees)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cost float64
+			for _, ri := range refund.Items {
+				cost += ri.CostReturned
+			}
+			if refund.Amount != tt.amount || refund.FeesAmount != tt.fees || cost != tt.cost || full != tt.full {
+				t.Errorf("got amount %.2f, fees %.2f, cost %.2f, full %v; want %.2f, %.2f, %.2f, %v",
+					refund.Amount, refund.FeesAmount, cost, full, tt.amount, tt.fees, tt.cost, tt.full)
+			}
+		})
+	}
+}
+
+// Refunded a unit at a time, the refunds add up to exactly what was paid and the fees
+// go back once
+func TestPriceRefundInParts(t *testing.T) {
+	sale := refundTestSale()
+	refundedQty := map[uint]float64{}
+	var paid, fees float64
+	parts := []struct {
+		line       RefundItemRequest
+		refundFees bool
+	}{
+		{RefundItemRequest{SaleItemID: 1, Quantity: 1}, true},
+		{RefundItemRequest{SaleItemID: 2, Quantity: 0.5}, false},
+		{RefundItemRequest{SaleItemID: 2, Quantity: 0.5}, true},
+		{RefundItemRequest{SaleItemID: 1, Quantity: 1}, true},
+	}
+
+	for i, p := range parts {
+		refund := &Refund{}
+		full, err := priceRefund(refund, &sale, []RefundItemRequest{p.line}, refundedQty, paid, fees, p.refundFees)
+		if err != nil {
+			t.Fatalf("part %d: %v", i+1, err)
+		}
+		if full != (i == len(parts)-1) {
+			t.Errorf("part %d: fully refunded %v", i+1, full)
+		}
+		paid = roundMoney(paid + refund.Amount)
+		fees = roundMoney(fees + refund.FeesAmount)
+	}
+
+	if paid != sale.Total || fees != roundMoney(sale.ServiceCharge+sale.DeliveryFee) {
+		t.Errorf("refunds paid %.2f with %.2f fees; want %.2f with %.2f", paid, fees, sale.Total, sale.ServiceCharge+sale.DeliveryFee)
+	}
+
+	refund := &Refund{}
+	if _, err := priceRefund(refund, &sale, []RefundItemRequest{{SaleItemID: 1, Quantity: 1}}, refundedQty, paid, fees, true); err == nil {
+		t.Error("refunded more units than were sold")
+	}
+}
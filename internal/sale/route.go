@@ -2,6 +2,7 @@
 package sale
 
 import (
+	"pos-fiber-app/internal/config"
 	"pos-fiber-app/internal/middleware"
 	"pos-fiber-app/internal/subscription"
 
@@ -17,17 +18,57 @@ func RegisterManagementRoutes(r fiber.Router, db *gorm.DB) {
 	r.Post("/businesses/:id/purge", PurgeHandler(db))
 }
 
+// RegisterPublicSaleRoutes registers the endpoints customers reach without logging in
+func RegisterPublicSaleRoutes(r fiber.Router, db *gorm.DB) {
+	r.Get("/public/receipts/:token", PublicReceiptHandler(db))                                    // Signed e-receipt link
+	r.Post("/public/menu/:slug/orders", config.PublicOrderLimiter(), PlaceOnlineOrderHandler(db)) // Order from the QR menu
+	r.Get("/public/orders/:token", GetPublicOrderHandler(db))                                     // Follow a QR menu order
+	r.Post("/public/orders/:token/payment", PayOnlineOrderHandler(db))                            // Pay for a QR menu order online
+}
+
 // RegisterSaleRoutes registers all sales-related endpoints under the business-scoped group
 func RegisterSaleRoutes(r fiber.Router, db *gorm.DB) {
 	// 1. Un-guarded Sales Routes & Reports (No active shift required)
 	r.Get("/sales", ListSalesHandler(db))                            // List with filters
+	r.Get("/sales/layaways", ListLayawaysHandler(db))                // Open layaways with balances
 	r.Get("/sales/:sale_id", GetSaleHandler(db))                     // Get sale + items
 	r.Get("/sales/reports/daily", DailyReportHandler(db))            // Daily summary
 	r.Get("/sales/reports/range", SalesReportHandler(db))            // Custom date range report
 	r.Get("/sales/reports/products", ProductProfitReportHandler(db)) // Product-wise profit report
 	r.Get("/sales/reports/monthly", MonthlyReportHandler(db))        // Monthly for charting
+	r.Get("/sales/reports/gratuities", GratuityReportHandler(db))    // Service charges and tips per staff member
+	r.Get("/sales/reports/courses", CourseTimesHandler(db))          // Ticket times per course
 	r.Get("/activities", GetActivitiesHandler(db))                   // Global audit log
 	r.Get("/sales/:sale_id/history", GetSaleHistoryHandler(db))      // Get sale activity history
+	r.Get("/sales/:sale_id/refunds", ListRefundsHandler(db))         // Refunds against a sale
+	r.Get("/sales/:sale_id/layaway", GetLayawayHandler(db))          // Layaway payments and balance
+
+	// Offline sync: the shift each sale belongs to is checked against when it was made
+	r.Post("/sales/sync", SyncSalesHandler(db))                               // Upload sales made offline
+	r.Get("/sales/sync/shortfalls", ListShortfallsHandler(db))                // Stock offline sales took that was not on hand
+	r.Post("/sales/sync/shortfalls/:id/resolve", ResolveShortfallHandler(db)) // Mark a shortfall reviewed
+
+	// Receipts: reprints after the first print are marked COPY
+	r.Get("/sales/:sale_id/receipt", GetReceiptHandler(db))                       // ?format=html|pdf|escpos
+	r.Post("/sales/:sale_id/receipt/print", PrintReceiptHandler(db))              // Send to the outlet's receipt printers
+	r.Post("/sales/:sale_id/receipt/send", ResendReceiptHandler(db))              // Email / WhatsApp the receipt link
+	r.Get("/sales/:sale_id/receipt/deliveries", ListReceiptDeliveriesHandler(db)) // E-receipt delivery status
+
+	// Delivery orders: riders move their own deliveries along; status changes go out on the KDS socket
+	r.Post("/sales/:sale_id/delivery", CreateDeliveryHandler(db))
+	r.Get("/sales/:sale_id/delivery", GetDeliveryHandler(db))
+	r.Put("/sales/:sale_id/delivery", UpdateDeliveryHandler(db))
+	r.Post("/sales/:sale_id/delivery/assign", AssignRiderHandler(db))
+	r.Patch("/sales/:sale_id/delivery/status", UpdateDeliveryStatusHandler(db))
+	r.Get("/deliveries", ListDeliveriesHandler(db))
+	r.Get("/deliveries/riders", ListRidersHandler(db))
+	r.Get("/deliveries/riders/cash", RiderCashReportHandler(db))                                 // ?from&to&shift_id
+	r.Post("/deliveries/riders/:rider_id/remit", middleware.ShiftGuard(db), RemitRiderCashHandler(db)) // Cash handed in at the till
+
+	// Online orders from the QR menu wait here until staff accept or reject them
+	r.Get("/online-orders", ListOnlineOrdersHandler(db))
+	r.Post("/online-orders/:sale_id/accept", middleware.ShiftGuard(db), AcceptOnlineOrderHandler(db))
+	r.Post("/online-orders/:sale_id/reject", RejectOnlineOrderHandler(db))
 
 	// 2. Guarded Operations (Active shift required)
 	// Apply ShiftGuard to a group specifically for transactions
@@ -36,6 +77,12 @@ func RegisterSaleRoutes(r fiber.Router, db *gorm.DB) {
 	guardedSales.Post("", CreateSaleHandler(db))                     // One-shot sale
 	guardedSales.Post("/:sale_id/complete", CompleteSaleHandler(db)) // Finalize basic sale
 	guardedSales.Post("/:sale_id/void", VoidSaleHandler(db))         // Void basic sale
+	guardedSales.Post("/:sale_id/refund", RefundSaleHandler(db))     // Full or partial refund
+
+	// Layaways: stock is held until the instalments pay it off or the layaway is cancelled
+	guardedSales.Post("/:sale_id/layaway", StartLayawayHandler(db))
+	guardedSales.Post("/:sale_id/layaway/payments", PayLayawayHandler(db))
+	guardedSales.Post("/:sale_id/layaway/cancel", CancelLayawayHandler(db))
 
 	// 3. Drafts & Cart Management (Guarded by ModuleDrafts AND ShiftGuard)
 	shiftGuard := middleware.ShiftGuard(db)
@@ -46,9 +93,21 @@ func RegisterSaleRoutes(r fiber.Router, db *gorm.DB) {
 	drafts.Post("/:sale_id/items", AddItemHandler(db))
 	drafts.Post("/:sale_id/hold", HoldSaleHandler(db))
 	drafts.Get("/held", ListHeldSalesHandler(db))
+	drafts.Put("/:sale_id/items/:item_id", AdjustItemHandler(db))
 	drafts.Delete("/:sale_id/items/:item_id", RemoveItemHandler(db))
 	drafts.Get("/drafts", ListDraftsHandler(db))
 
+	// Quotations and pro-forma invoices; converting one opens a draft sale
+	quotes := r.Group("/quotations")
+	quotes.Get("", ListQuotationsHandler(db))
+	quotes.Post("", CreateQuotationHandler(db))
+	quotes.Get("/:id", GetQuotationHandler(db))
+	quotes.Put("/:id", UpdateQuotationHandler(db))
+	quotes.Get("/:id/document", GetQuotationDocumentHandler(db)) // ?format=pdf|html
+	quotes.Post("/:id/send", SendQuotationHandler(db))
+	quotes.Post("/:id/accept", AcceptQuotationHandler(db))
+	quotes.Post("/:id/convert", shiftGuard, draftGuard, ConvertQuotationHandler(db))
+
 	// 4. Tables Management (Guarded by both Drafts AND Tables)
 	tableGuard := middleware.ModuleGuard(db, subscription.ModuleTables)
 	tables := drafts.Group("/draft/tables", tableGuard)
@@ -58,6 +117,7 @@ func RegisterSaleRoutes(r fiber.Router, db *gorm.DB) {
 	tables.Delete("/:sale_id/draft", DeleteDraftHandler(db))
 	tables.Post("/:sale_id/transfer", TransferBillHandler(db))
 	tables.Post("/:sale_id/merge", MergeBillsHandler(db))
+	tables.Post("/:sale_id/split", SplitBillHandler(db))
 
 	// NEW: Enhanced Sale Actions with Reservations
 	tables.Post("/:sale_id/complete/reserve", CompleteSaleWithReservationHandler(db)) // /sales/:sale_id/complete/reserve
@@ -73,10 +133,30 @@ func RegisterSaleRoutes(r fiber.Router, db *gorm.DB) {
 		return fiber.ErrUpgradeRequired
 	}, KDSWebsocketHandler(db))
 
+	// CUSTOMER-FACING DISPLAY (WS), one channel per terminal: /ws/display?terminal_id=
+	r.Get("/ws/display", func(c *fiber.Ctx) error {
+		if websocket.IsWebSocketUpgrade(c) {
+			return c.Next()
+		}
+		return fiber.ErrUpgradeRequired
+	}, CustomerDisplayWebsocketHandler(db))
+
 	// KDS Action Routes (Guarded by ModuleKDS if you want, but for now they are inside RegisterSaleRoutes)
 	r.Patch("/sales/:sale_id/preparation", UpdateSalePrepStatusHandler(db))
 	r.Patch("/sales/:sale_id/items/:item_id/preparation", UpdateItemPrepStatusHandler(db))
 
+	// Kitchen stations: categories are routed to a station per outlet and each station bumps its own items
+	stations := r.Group("/kds/stations", middleware.ModuleGuard(db, subscription.ModuleKDS))
+	stations.Get("", ListStationsHandler(db))
+	stations.Post("", CreateStationHandler(db))
+	stations.Put("/:id", UpdateStationHandler(db))
+	stations.Delete("/:id", DeleteStationHandler(db))
+	stations.Get("/:id/queue", StationQueueHandler(db))
+	stations.Patch("/:id/sales/:sale_id", BumpStationHandler(db))
+	r.Post("/sales/:sale_id/courses/:course/fire", FireCourseHandler(db)) // Release a held course to the kitchen
+	r.Get("/kds/overdue", middleware.ModuleGuard(db, subscription.ModuleKDS), OverdueOrdersHandler(db))
+	r.Get("/kds/reports/prep-times", middleware.ModuleGuard(db, subscription.ModuleKDS), PrepTimesHandler(db)) // ?from&to&group_by
+
 	// AUTOMATED COMPLIANCE & REPORTING Module Guard
 	// Explicitly apply guard to specific routes to avoid group leakage
 	r.Get("/compliance/tax-report", middleware.ModuleGuard(db, subscription.ModuleCompliance), ExportTaxReportHandler(db))
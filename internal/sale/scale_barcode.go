@@ -0,0 +1,125 @@
+// internal/sale/scale_barcode.go
+package sale
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"pos-fiber-app/internal/inventory"
+	"pos-fiber-app/internal/product"
+
+	"gorm.io/gorm"
+)
+
+// ScaleBarcode is what a weighing scale printed into an in-store EAN-13 label:
+// 2F PPPPP VVVVV C, a 20-29 prefix, the product's 5-digit PLU, a 5-digit value
+// (grams or kobo, depending on the business's scale_barcode_value) and a check digit
+type ScaleBarcode struct {
+	PLU   string
+	Value int
+}
+
+// parseScaleBarcode reads an in-store EAN-13 label; ok is false for any other code
+func parseScaleBarcode(code string) (ScaleBarcode, bool) {
+	if len(code) != 13 || code[0] != '2' || !validEAN13(code) {
+		return ScaleBarcode{}, false
+	}
+	value, err := strconv.Atoi(code[7:12])
+	if err != nil {
+		return ScaleBarcode{}, false
+	}
+	return ScaleBarcode{PLU: code[2:7], Value: value}, true
+}
+
+// quantity is the quantity of prod a label stands for: its value in grams, or in PRICE
+// mode the quantity its printed price buys, returned with that price
+func (l ScaleBarcode) quantity(mode string, prod *product.Product) (float64, *float64, error) {
+	qty := float64(l.Value) / 1000 // grams to kg
+	var labelPrice *float64
+	if mode == "PRICE" {
+		if prod.Price <= 0 {
+			return 0, nil, fmt.Errorf("%s has no price to work the quantity out from", prod.Name)
+		}
+		price := float64(l.Value) / 100
+		qty = price / prod.Price
+		labelPrice = &price
+	}
+	qty = inventory.RoundQuantity(qty)
+	if qty <= 0 {
+		return 0, nil, errors.New("scale label has no quantity")
+	}
+	return qty, labelPrice, nil
+}
+
+// validEAN13 checks the digits and check digit of an EAN-13
+func validEAN13(code string) bool {
+	sum := 0
+	for i := 0; i < 13; i++ {
+		d := code[i]
+		if d < '0' || d > '9' {
+			return false
+		}
+		if i == 12 {
+			break
+		}
+		if i%2 == 1 {
+			sum += int(d-'0') * 3
+		} else {
+			sum += int(d - '0')
+		}
+	}
+	return int(code[12]-'0') == (10-sum%10)%10
+}
+
+// resolveScannedItem fills in the product and quantity of an item added by barcode. A
+// scale label gives the product by PLU and the weighed quantity, or the quantity its
+// printed price buys and that price, which the line is then charged exactly. Labels
+// are read to the gram, so the product must allow 3 decimal places. Any other barcode
+// is looked up on the product, one unit unless a quantity was sent.
+func resolveScannedItem(db *gorm.DB, businessID uint, req *AddItemRequest) error {
+	code := strings.TrimSpace(req.Barcode)
+	if code == "" {
+		return nil
+	}
+
+	var prod product.Product
+	if label, ok := parseScaleBarcode(code); ok {
+		if err := db.First(&prod, "business_id = ? AND plu = ? AND active = ?", businessID, label.PLU, true).Error; err != nil {
+			return fmt.Errorf("no product has PLU %s", label.PLU)
+		}
+
+		if prod.QuantityPrecision < inventory.MaxQuantityPrecision {
+			return fmt.Errorf("scale label: %s must allow %d decimal places to be sold by weight", prod.Name, inventory.MaxQuantityPrecision)
+		}
+
+		var mode string
+		db.Table("businesses").Select("scale_barcode_value").Where("id = ?", businessID).Scan(&mode)
+		qty, labelPrice, err := label.quantity(mode, &prod)
+		if err != nil {
+			return err
+		}
+
+		req.ProductID = prod.ID
+		req.Quantity = qty
+		req.labelPrice = labelPrice
+		return nil
+	}
+
+	if err := db.First(&prod, "business_id = ? AND barcode = ? AND active = ?", businessID, code, true).Error; err != nil {
+		return errors.New("product not found for barcode " + code)
+	}
+	req.ProductID = prod.ID
+	if req.Quantity == 0 {
+		req.Quantity = 1
+	}
+	return nil
+}
+
+// checkItemQuantity makes sure a line quantity suits the product's precision
+func checkItemQuantity(db *gorm.DB, productID uint, qty float64) error {
+	var precision int
+	db.Table("products").Select("quantity_precision").Where("id = ?", productID).Scan(&precision)
+	return inventory.CheckQuantity(qty, precision)
+}
@@ -0,0 +1,71 @@
+// internal/sale/scale_barcode_test.go
+package sale
+
+import (
+	"testing"
+
+	"pos-fiber-app/internal/product"
+)
+
+func TestParseScaleBarcode(t *testing.T) {
+	tests := []struct {
+		code  string
+		ok    bool
+		plu   string
+		value int
+	}{
+		{"2100123012503", true, "00123", 1250},
+		{"2200456006504", true, "00456", 650},
+		{"2100123012504", false, "", 0}, // wrong check digit
+		{"5901234123457", false, "", 0}, // a valid EAN-13, but not an in-store label
+		{"210012301250", false, "", 0},  // too short
+		{"21001230125O3", false, "", 0}, // not all digits
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			label, ok := parseScaleBarcode(tt.code)
+			if ok != tt.ok || label.PLU != tt.plu || label.Value != tt.value {
+				t.Errorf("got %+v, %v; want PLU %q value %d, %v", label, ok, tt.plu, tt.value, tt.ok)
+			}
+		})
+	}
+}
+
+func TestScaleBarcodeQuantity(t *testing.T) {
+	cheese := &product.Product{Name: "Cheese", Price: 13}
+	tests := []struct {
+		name    string
+		code    string
+		mode    string
+		prod    *product.Product
+		qty     float64
+		price   float64 // printed price the line is charged; 0 for none
+		wantErr bool
+	}{
+		{"weight in grams", "2100123012503", "WEIGHT", cheese, 1.25, 0, false},
+		{"a third of a kilo", "2100123003334", "", cheese, 0.333, 0, false},
+		{"price buys a weight", "2200456006504", "PRICE", cheese, 0.5, 6.5, false},
+		{"price of an unpriced product", "2200456006504", "PRICE", &product.Product{Name: "Loose tea"}, 0, 0, true},
+		{"nothing weighed", "2000123000008", "WEIGHT", cheese, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			label, ok := parseScaleBarcode(tt.code)
+			if !ok {
+				t.Fatalf("%s did not parse", tt.code)
+			}
+			qty, price, err := label.quantity(tt.mode, tt.prod)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if qty != tt.qty {
+				t.Errorf("quantity %v, want %v", qty, tt.qty)
+			}
+			if (price != nil) != (tt.price != 0) || (price != nil && *price != tt.price) {
+				t.Errorf("label price %v, want %v", price, tt.price)
+			}
+		})
+	}
+}
@@ -5,14 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"crypto/rand"
 	"encoding/hex"
 	"strings"
 	"time"
 
 	"pos-fiber-app/internal/common"
+	"pos-fiber-app/internal/customer"
 	"pos-fiber-app/internal/expense"
+	"pos-fiber-app/internal/giftcard"
 	"pos-fiber-app/internal/inventory"
+	"pos-fiber-app/internal/loyalty"
 	"pos-fiber-app/internal/notification"
 	"pos-fiber-app/internal/product"
 	"pos-fiber-app/internal/recipe"
@@ -22,8 +26,20 @@ import (
 )
 
 type AddItemRequest struct {
-	ProductID uint `json:"product_id" validate:"required"`
-	Quantity  int  `json:"quantity" validate:"required,gt=0"`
+	ProductID         uint   `json:"product_id" validate:"required_without=Barcode"`
+	Quantity          float64 `json:"quantity" validate:"omitempty,gt=0"` // may be fractional (e.g. 0.35 kg) up to the product's quantity_precision
+	Barcode           string `json:"barcode,omitempty"`                   // scanned code; a scale label also sets the weighed quantity
+	SeatNumber        int    `json:"seat_number,omitempty"`
+	Course            int    `json:"course,omitempty" validate:"gte=0"` // held for the kitchen until fired
+	ModifierOptionIDs []uint `json:"modifier_option_ids,omitempty"`
+	GiftCardCode      string `json:"gift_card_code,omitempty"` // card to top up, for gift card products
+
+	// Optional cashier adjustments; above the business's override threshold they need Approval
+	Discount      float64           `json:"discount,omitempty"`       // amount off the line
+	PriceOverride *float64          `json:"price_override,omitempty"` // replaces the unit price
+	Approval      *OverrideApproval `json:"approval,omitempty"`
+
+	labelPrice *float64 // printed price of a scale label, set when the barcode is resolved
 }
 
 // Removed singular CompleteSaleRequest in favor of multi-payment logic
@@ -32,28 +48,42 @@ type PaymentInfoRequest struct {
 	Method           string  `json:"method" validate:"required"` // CASH, CARD, TRANSFER, etc.
 	Amount           float64 `json:"amount" validate:"required,gt=0"`
 	TerminalProvider string  `json:"terminal_provider,omitempty"`
+	GiftCardCode     string  `json:"gift_card_code,omitempty"` // required for GIFT_CARD payments
+	Tip              float64 `json:"tip,omitempty"`            // gratuity paid with this tender, on top of Amount
 }
 
 type CreateSaleRequest struct {
 	Items         []SaleItemRequest    `json:"items" validate:"required,min=1"`
 	Payments      []PaymentInfoRequest `json:"payments" validate:"required,min=1"`
 	Discount      float64              `json:"discount" validate:"gte=0"`
-	Tax           float64              `json:"tax"`
 	CustomerName  string               `json:"customer_name,omitempty"`
 	CustomerPhone string               `json:"customer_phone,omitempty"`
+	CustomerEmail string               `json:"customer_email,omitempty" validate:"omitempty,email"`
+	CustomerID    *uint                `json:"customer_id,omitempty"` // required for CREDIT payments
 	ShiftID       *uint                `json:"shift_id,omitempty"`
+	Approval      *OverrideApproval    `json:"approval,omitempty"` // for a discount above the override threshold
 }
 
 type CompleteSaleRequest struct {
-	Payments []PaymentInfoRequest `json:"payments" validate:"required,min=1"`
-	Discount float64              `json:"discount" validate:"gte=0"`
-	Tax      float64              `json:"tax"`
-	ShiftID  *uint                `json:"shift_id,omitempty"`
+	Payments   []PaymentInfoRequest `json:"payments" validate:"required,min=1"`
+	Discount   float64              `json:"discount" validate:"gte=0"`
+	ShiftID    *uint                `json:"shift_id,omitempty"`
+	CustomerID *uint                `json:"customer_id,omitempty"` // required for CREDIT payments unless set on the draft
+	Approval   *OverrideApproval    `json:"approval,omitempty"`    // for a discount above the override threshold
 }
 
 type SaleItemRequest struct {
-	ProductID uint `json:"product_id" validate:"required"`
-	Quantity  int  `json:"quantity" validate:"required,gt=0"`
+	ProductID         uint   `json:"product_id" validate:"required"`
+	Quantity          float64 `json:"quantity" validate:"required,gt=0"`
+	SeatNumber        int    `json:"seat_number,omitempty"`
+	Course            int    `json:"course,omitempty" validate:"gte=0"` // held for the kitchen until fired
+	ModifierOptionIDs []uint `json:"modifier_option_ids,omitempty"`
+	GiftCardCode      string `json:"gift_card_code,omitempty"` // card to top up, for gift card products
+
+	// Optional cashier adjustments; above the business's override threshold they need Approval
+	Discount      float64           `json:"discount,omitempty"`       // amount off the line
+	PriceOverride *float64          `json:"price_override,omitempty"` // replaces the unit price
+	Approval      *OverrideApproval `json:"approval,omitempty"`
 }
 
 type VoidSaleRequest struct {
@@ -66,7 +96,10 @@ type CreateDraftRequest struct {
 	TableNumber   string            `json:"table_number"`
 	CustomerName  string            `json:"customer_name"`
 	CustomerPhone string            `json:"customer_phone"`
+	CustomerEmail string            `json:"customer_email" validate:"omitempty,email"`
+	CustomerID    *uint             `json:"customer_id,omitempty"`
 	OrderType     string            `json:"order_type"`
+	TerminalID    uint              `json:"terminal_id,omitempty"` // till the sale is rung up on; its customer display follows the cart
 }
 
 type SaleFilters struct {
@@ -82,16 +115,23 @@ type SaleResult struct {
 }
 
 type SaleReceipt struct {
-	Sale        *Sale      `json:"sale"`
-	Items       []SaleItem `json:"items"`
-	Change      float64    `json:"change"`
-	ReceiptNo   string     `json:"receipt_no"`
-	GeneratedAt time.Time  `json:"generated_at"`
+	Sale        *Sale           `json:"sale"`
+	Items       []SaleItem      `json:"items"`
+	Change      float64         `json:"change"`
+	ReceiptNo   string          `json:"receipt_no"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	GiftCards   []giftcard.Card `json:"gift_cards,omitempty"` // cards issued or topped up by this sale
 }
 
 type DailyReport struct {
 	Date                  string  `json:"date"`
-	TotalSales            float64 `json:"total_sales"`
+	GrossSales            float64 `json:"gross_sales"`
+	TotalRefunds          float64 `json:"total_refunds"`
+	RefundTransactions    int     `json:"refund_transactions"`
+	TotalSales            float64 `json:"total_sales"` // net of refunds, excluding service charges and tips
+	ServiceCharges        float64 `json:"service_charges"`
+	Tips                  float64 `json:"tips"`
+	LayawayForfeits       float64 `json:"layaway_forfeits"` // deposits kept on cancelled layaways, in TotalProfit
 	TotalCost             float64 `json:"total_cost"`
 	TotalProfit           float64 `json:"total_profit"`
 	TotalTransactions     int     `json:"total_transactions"`
@@ -126,7 +166,9 @@ func CreateDraft(db *gorm.DB, businessID uint, tenantID string, outletID uint, c
 		TableNumber:   req.TableNumber,
 		CustomerName:  req.CustomerName,
 		CustomerPhone: req.CustomerPhone,
+		CustomerEmail: req.CustomerEmail,
 		OrderType:     req.OrderType,
+		TerminalID:    req.TerminalID,
 		SaleDate:      time.Now(),
 	}
 
@@ -134,19 +176,32 @@ func CreateDraft(db *gorm.DB, businessID uint, tenantID string, outletID uint, c
 		sale.OrderType = "dine-in"
 	}
 
+	if err := checkTerminal(tx, tenantID, req.TerminalID); err != nil {
+		return nil, err
+	}
+
+	if err := attachCustomer(tx, sale, req.CustomerID); err != nil {
+		return nil, err
+	}
+
 	if err := tx.Create(sale).Error; err != nil {
 		return nil, err
 	}
 
-	var subtotal float64
 	for _, itemReq := range req.Items {
 		var prod product.Product
 		if err := tx.First(&prod, "id = ? AND business_id = ?", itemReq.ProductID, businessID).Error; err != nil {
 			continue // Skip if product not found
 		}
+		if err := inventory.CheckQuantity(itemReq.Quantity, prod.QuantityPrecision); err != nil {
+			return nil, fmt.Errorf("%s: %w", prod.Name, err)
+		}
+
+		modifiers, err := resolveLineModifiers(tx, businessID, &prod, itemReq.ModifierOptionIDs)
+		if err != nil {
+			return nil, err
+		}
 
-		itemTotal := float64(itemReq.Quantity) * prod.Price
-		itemProfit := (prod.Price - prod.Cost) * float64(itemReq.Quantity)
 		item := SaleItem{
 			SaleID:      sale.ID,
 			ProductID:   prod.ID,
@@ -154,19 +209,32 @@ func CreateDraft(db *gorm.DB, businessID uint, tenantID string, outletID uint, c
 			Quantity:    itemReq.Quantity,
 			UnitPrice:   prod.Price,
 			CostPrice:   prod.Cost,
-			TotalPrice:  itemTotal,
-			Profit:      itemProfit,
+			SeatNumber:  itemReq.SeatNumber,
+			Course:      itemReq.Course,
 		}
+		if err := setGiftCardLine(&item, &prod, itemReq.GiftCardCode); err != nil {
+			return nil, err
+		}
+		applyModifiers(&item, modifiers)
+		override, err := adjustLine(tx, sale, &item, lineDiscount(itemReq.Discount), itemReq.PriceOverride, itemReq.Approval, cashierID)
+		if err != nil {
+			return nil, err
+		}
+		priceSaleItem(&item)
 
 		if err := tx.Create(&item).Error; err != nil {
 			return nil, err
 		}
-		subtotal += itemTotal
+		if override != nil {
+			if err := LogActivity(tx, sale.ID, businessID, cashierID, ActionPriceOverride, *override); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	sale.Subtotal = subtotal
-	sale.Total = subtotal
-	if err := tx.Save(sale).Error; err != nil {
+	// Price the lines against running promotions
+	items, err := repriceSale(tx, sale)
+	if err != nil {
 		return nil, err
 	}
 
@@ -174,51 +242,117 @@ func CreateDraft(db *gorm.DB, businessID uint, tenantID string, outletID uint, c
 		return nil, err
 	}
 
+	// Attach items after saving so KDS and kitchen printers get the full order
+	sale.SaleItems = items
+
 	return sale, nil
 }
 
-// AddItemToSale adds or updates quantity of a product in a sale
-func AddItemToSale(db *gorm.DB, saleID, businessID uint, productID uint, qty int) (*SaleResult, error) {
+// AddItemToSale adds or updates quantity of a product in a sale.
+// Lines are kept per seat and per set of modifiers, so the same product ordered for two seats
+// (or once plain and once with extra cheese) stays on two lines.
+func AddItemToSale(db *gorm.DB, saleID, businessID, userID uint, req AddItemRequest) (*SaleResult, error) {
+	if err := resolveScannedItem(db, businessID, &req); err != nil {
+		return nil, err
+	}
+	productID, qty := req.ProductID, req.Quantity
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
 	var sale Sale
-	if err := db.First(&sale, "id = ? AND business_id = ? AND status IN ?", saleID, businessID, []SaleStatus{StatusDraft, StatusHeld}).Error; err != nil {
+	if err := tx.First(&sale, "id = ? AND business_id = ? AND status IN ?", saleID, businessID, []SaleStatus{StatusDraft, StatusHeld}).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("sale not found or not editable")
 		}
 		return nil, err
 	}
+	// Checked before the line is written, so a rejected add leaves nothing behind
+	if err := checkSplitUnpaid(tx, &sale); err != nil {
+		return nil, err
+	}
 
 	var prod product.Product
-	if err := db.First(&prod, "id = ? AND business_id = ?", productID, businessID).Error; err != nil {
+	if err := tx.First(&prod, "id = ? AND business_id = ?", productID, businessID).Error; err != nil {
 		return nil, errors.New("product not found")
 	}
+	if err := inventory.CheckQuantity(qty, prod.QuantityPrecision); err != nil {
+		return nil, err
+	}
+
+	// Check stock (gift cards carry none)
+	if !prod.IsGiftCard {
+		var inv inventory.Inventory
+		if err := tx.First(&inv, "product_id = ? AND business_id = ?", productID, businessID).Error; err != nil || inv.CurrentStock < qty {
+			return nil, errors.New("insufficient stock")
+		}
+	}
 
-	// Check stock
-	var inv inventory.Inventory
-	if err := db.First(&inv, "product_id = ? AND business_id = ?", productID, businessID).Error; err != nil || inv.CurrentStock < qty {
-		return nil, errors.New("insufficient stock")
+	modifiers, err := resolveLineModifiers(tx, businessID, &prod, req.ModifierOptionIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	// Upsert sale item
+	// Validate the gift card code before a line is created for it
+	if err := setGiftCardLine(&SaleItem{Quantity: qty}, &prod, req.GiftCardCode); err != nil {
+		return nil, err
+	}
+
+	// Upsert sale item (same product, seat and modifiers share a line). A line of a
+	// course already fired is never added to; the new quantity is held on a line of its own.
+	// A scale label is one weighed package at its printed price and always gets its own line.
 	var item SaleItem
-	db.FirstOrCreate(&item, "sale_id = ? AND product_id = ?", saleID, productID)
-	item.Quantity += qty
-	item.UnitPrice = prod.Price
+	if req.labelPrice != nil {
+		item = SaleItem{SaleID: saleID, ProductID: productID, SeatNumber: req.SeatNumber, Course: req.Course, LabelPrice: req.labelPrice}
+	} else {
+		tx.FirstOrInit(&item, map[string]interface{}{
+			"sale_id":        saleID,
+			"product_id":     productID,
+			"seat_number":    req.SeatNumber,
+			"course":         req.Course,
+			"fired_at":       nil,
+			"label_price":    nil,
+			"modifier_key":   product.ModifierKey(modifiers),
+			"gift_card_code": strings.TrimSpace(req.GiftCardCode),
+		})
+	}
+	item.Quantity = inventory.RoundQuantity(item.Quantity + qty)
+	if item.OriginalUnitPrice == nil {
+		item.UnitPrice = prod.Price
+	}
 	item.CostPrice = prod.Cost
-	item.TotalPrice = float64(item.Quantity) * prod.Price
-	item.Profit = (item.UnitPrice - item.CostPrice) * float64(item.Quantity)
 	item.ProductName = prod.Name
+	if err := setGiftCardLine(&item, &prod, req.GiftCardCode); err != nil {
+		return nil, err
+	}
+	applyModifiers(&item, modifiers)
+	override, err := adjustLine(tx, &sale, &item, lineDiscount(req.Discount), req.PriceOverride, req.Approval, userID)
+	if err != nil {
+		return nil, err
+	}
+	priceSaleItem(&item)
 
-	if err := db.Save(&item).Error; err != nil {
+	if err := tx.Save(&item).Error; err != nil {
 		return nil, err
 	}
+	// The override stands only if its audit record is written with it
+	if override != nil {
+		if err := LogActivity(tx, saleID, businessID, userID, ActionPriceOverride, *override); err != nil {
+			return nil, err
+		}
+	}
 
 	// Recalculate sale totals
-	if err := recalculateSaleTotals(db, &sale); err != nil {
+	if err := recalculateSaleTotals(tx, &sale); err != nil {
 		return nil, err
 	}
 
 	items := []SaleItem{}
-	db.Where("sale_id = ?", saleID).Find(&items)
+	tx.Where("sale_id = ?", saleID).Find(&items)
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
 
 	return &SaleResult{Sale: &sale, Items: items}, nil
 }
@@ -234,7 +368,15 @@ func CompleteSale(db *gorm.DB, saleID, businessID uint, req CompleteSaleRequest)
 	}
 
 	// Recalculate total with tax and discount
-	sale.Total = sale.Subtotal - req.Discount + req.Tax
+	if err := authorizeSaleDiscount(tx, &sale, req.Discount, req.Approval, sale.CashierID); err != nil {
+		return nil, err
+	}
+	if err := computeTotals(tx, &sale, sale.SaleItems, req.Discount); err != nil {
+		return nil, err
+	}
+	if err := collectTips(&sale, req.Payments); err != nil {
+		return nil, err
+	}
 
 	var totalPaid float64
 	for _, p := range req.Payments {
@@ -245,10 +387,38 @@ func CompleteSale(db *gorm.DB, saleID, businessID uint, req CompleteSaleRequest)
 		return nil, fmt.Errorf("insufficient payment: total %f, paid %f", sale.Total, totalPaid)
 	}
 
+	if err := attachCustomer(tx, &sale, req.CustomerID); err != nil {
+		return nil, err
+	}
+	if err := chargeCustomerCredit(tx, &sale, req.Payments, sale.CashierID); err != nil {
+		return nil, err
+	}
+	paidWithPoints, err := redeemLoyaltyPoints(tx, &sale, req.Payments)
+	if err != nil {
+		return nil, err
+	}
+	if err := earnLoyaltyPoints(tx, &sale, sale.SaleItems, paidWithPoints); err != nil {
+		return nil, err
+	}
+	if err := redeemGiftCards(tx, &sale, req.Payments, sale.CashierID); err != nil {
+		return nil, err
+	}
+	giftCards, err := issueGiftCards(tx, &sale, sale.SaleItems, sale.CashierID)
+	if err != nil {
+		return nil, err
+	}
+
 	// Deduct inventory
 	recipeSvc := recipe.NewRecipeService(db)
+	stockTx := saleStock(tx, &sale, inventory.ReasonSale, sale.CashierID)
 	for _, item := range sale.SaleItems {
-		if err := recipeSvc.AdjustStockWithRecipe(tx, item.ProductID, businessID, item.Quantity); err != nil {
+		if item.IsGiftCard {
+			continue
+		}
+		if err := recipeSvc.AdjustStockWithRecipe(stockTx, item.ProductID, businessID, item.Quantity); err != nil {
+			return nil, errors.New("failed to update inventory: " + err.Error())
+		}
+		if err := deductModifierStock(stockTx, recipeSvc, businessID, item, item.Quantity); err != nil {
 			return nil, errors.New("failed to update inventory: " + err.Error())
 		}
 	}
@@ -278,6 +448,7 @@ func CompleteSale(db *gorm.DB, saleID, businessID uint, req CompleteSaleRequest)
 				SaleID:            sale.ID,
 				BusinessID:        businessID,
 				Amount:            p.Amount,
+				Tip:               p.Tip,
 				Provider:          strings.ToLower(p.TerminalProvider),
 				InternalReference: ref,
 				Status:            ReconPending,
@@ -292,6 +463,7 @@ func CompleteSale(db *gorm.DB, saleID, businessID uint, req CompleteSaleRequest)
 				SaleID:            sale.ID,
 				BusinessID:        businessID,
 				Amount:            p.Amount,
+				Tip:               p.Tip,
 				Provider:          p.Method,
 				InternalReference: "DIRECT-" + p.Method + "-" + fmt.Sprintf("%d", time.Now().Unix()),
 				Status:            ReconSuccess,
@@ -312,7 +484,6 @@ func CompleteSale(db *gorm.DB, saleID, businessID uint, req CompleteSaleRequest)
 
 	sale.PaymentMethod = mainPaymentMethod
 	sale.Discount = req.Discount
-	sale.Tax = req.Tax
 	sale.SyncedAt = &now 
 	if req.ShiftID != nil {
 		sale.ShiftID = req.ShiftID
@@ -332,27 +503,50 @@ func CompleteSale(db *gorm.DB, saleID, businessID uint, req CompleteSaleRequest)
 		return nil, err
 	}
 
+	_ = recordShiftGratuities(db, &sale, 1)
+
 	// Log Activity
 	_ = LogActivity(db, sale.ID, businessID, sale.CashierID, ActionCompleted, ActivityDetails{
 		AmountPaid:    totalPaid,
 		PaymentMethod: mainPaymentMethod,
 	})
 
+	queueEReceipts(db, &sale)
+
 	return &SaleReceipt{
 		Sale:        &sale,
 		Items:       sale.SaleItems,
 		Change:      totalPaid - sale.Total,
 		ReceiptNo:   generateReceiptNo(sale.DailySequence),
 		GeneratedAt: time.Now(),
+		GiftCards:   giftCards,
 	}, nil
 }
 
 // CreateSale creates and completes a sale in one atomic operation (One-Shot)
 func CreateSale(db *gorm.DB, businessID uint, tenantID string, outletID uint, cashierID uint, req CreateSaleRequest) (*SaleReceipt, error) {
+	receipt, err := createSale(db, businessID, tenantID, outletID, cashierID, req, nil)
+	if err != nil {
+		return nil, err
+	}
+	queueEReceipts(db, receipt.Sale)
+	return receipt, nil
+}
+
+// createSale records a one-shot sale. For a sale uploaded by an offline device, offline
+// carries when it was made, and stock it took that was not on hand is flagged rather
+// than refused.
+func createSale(db *gorm.DB, businessID uint, tenantID string, outletID uint, cashierID uint, req CreateSaleRequest, offline *offlineSale) (*SaleReceipt, error) {
 	tx := db.Begin()
 	defer tx.Rollback()
 
 	now := time.Now()
+	stockTx := tx
+	if offline != nil {
+		stockTx = inventory.AllowShortfalls(tx, func(s inventory.Shortfall) {
+			offline.shortfalls = append(offline.shortfalls, s)
+		})
+	}
 
 	seq, err := getNextDailySequence(tx, businessID)
 	if err != nil {
@@ -372,9 +566,9 @@ func CreateSale(db *gorm.DB, businessID uint, tenantID string, outletID uint, ca
 		Status:        StatusCompleted, 
 		PaymentMethod: mainMethod,
 		Discount:      req.Discount,
-		Tax:           req.Tax,
 		CustomerName:  req.CustomerName,
 		CustomerPhone: req.CustomerPhone,
+		CustomerEmail: req.CustomerEmail,
 		SaleDate:      now,
 		DailySequence: seq,
 		Subtotal:      0.0,
@@ -382,27 +576,37 @@ func CreateSale(db *gorm.DB, businessID uint, tenantID string, outletID uint, ca
 		SyncedAt:      &now,
 		ShiftID:       req.ShiftID,
 	}
+	if offline != nil {
+		sale.SaleDate = offline.SoldAt
+		sale.ClientUUID = &offline.ClientUUID
+		sale.IdempotencyKey = &offline.IdempotencyKey
+	}
 
-	if err := tx.Create(sale).Error; err != nil {
+	if err := attachCustomer(tx, sale, req.CustomerID); err != nil {
 		return nil, err
 	}
 
-	var subtotal float64
-	var saleItems []SaleItem
+	if err := tx.Create(sale).Error; err != nil {
+		return nil, err
+	}
+	stockTx = saleStock(stockTx, sale, inventory.ReasonSale, cashierID)
 
 	for _, itemReq := range req.Items {
 		var prod product.Product
 		if err := tx.First(&prod, "id = ? AND business_id = ?", itemReq.ProductID, businessID).Error; err != nil {
 			return nil, fmt.Errorf("product %d not found", itemReq.ProductID)
 		}
-
-		recipeSvc := recipe.NewRecipeService(db)
-		if err := recipeSvc.AdjustStockWithRecipe(tx, prod.ID, businessID, itemReq.Quantity); err != nil {
-			return nil, fmt.Errorf("insufficient stock for %s: %w", prod.Name, err)
+		// Sales made offline have already happened; they are recorded as rung up
+		if offline == nil {
+			if err := inventory.CheckQuantity(itemReq.Quantity, prod.QuantityPrecision); err != nil {
+				return nil, fmt.Errorf("%s: %w", prod.Name, err)
+			}
 		}
 
-		itemTotal := float64(itemReq.Quantity) * prod.Price
-		itemProfit := (prod.Price - prod.Cost) * float64(itemReq.Quantity)
+		modifiers, err := resolveLineModifiers(tx, businessID, &prod, itemReq.ModifierOptionIDs)
+		if err != nil {
+			return nil, err
+		}
 
 		saleItem := SaleItem{
 			SaleID:      sale.ID,
@@ -411,20 +615,59 @@ func CreateSale(db *gorm.DB, businessID uint, tenantID string, outletID uint, ca
 			Quantity:    itemReq.Quantity,
 			UnitPrice:   prod.Price,
 			CostPrice:   prod.Cost,
-			TotalPrice:  itemTotal,
-			Profit:      itemProfit,
+			SeatNumber:  itemReq.SeatNumber,
+			Course:      itemReq.Course,
+		}
+		if err := setGiftCardLine(&saleItem, &prod, itemReq.GiftCardCode); err != nil {
+			return nil, err
+		}
+		applyModifiers(&saleItem, modifiers)
+		override, err := adjustLine(tx, sale, &saleItem, lineDiscount(itemReq.Discount), itemReq.PriceOverride, itemReq.Approval, cashierID)
+		if err != nil {
+			return nil, err
+		}
+		priceSaleItem(&saleItem)
+
+		if !saleItem.IsGiftCard {
+			recipeSvc := recipe.NewRecipeService(db)
+			if err := recipeSvc.AdjustStockWithRecipe(stockTx, prod.ID, businessID, itemReq.Quantity); err != nil {
+				return nil, fmt.Errorf("insufficient stock for %s: %w", prod.Name, err)
+			}
+			if err := deductModifierStock(stockTx, recipeSvc, businessID, saleItem, itemReq.Quantity); err != nil {
+				return nil, fmt.Errorf("insufficient stock for %s modifiers: %w", prod.Name, err)
+			}
 		}
 
 		if err := tx.Create(&saleItem).Error; err != nil {
 			return nil, err
 		}
+		if override != nil {
+			if err := LogActivity(tx, sale.ID, businessID, cashierID, ActionPriceOverride, *override); err != nil {
+				return nil, err
+			}
+		}
+	}
 
-		subtotal += itemTotal
-		saleItems = append(saleItems, saleItem)
+	if offline != nil {
+		if err := flagShortfalls(tx, sale, offline.shortfalls); err != nil {
+			return nil, err
+		}
 	}
 
-	sale.Subtotal = subtotal
-	sale.Total = subtotal - req.Discount + req.Tax
+	// Price the lines against running promotions
+	saleItems, err := repriceSale(tx, sale)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeSaleDiscount(tx, sale, req.Discount, req.Approval, cashierID); err != nil {
+		return nil, err
+	}
+	if err := computeTotals(tx, sale, saleItems, req.Discount); err != nil {
+		return nil, err
+	}
+	if err := collectTips(sale, req.Payments); err != nil {
+		return nil, err
+	}
 
 	var totalPaid float64
 	for _, p := range req.Payments {
@@ -435,6 +678,7 @@ func CreateSale(db *gorm.DB, businessID uint, tenantID string, outletID uint, ca
 			SaleID:            sale.ID,
 			BusinessID:        businessID,
 			Amount:            p.Amount,
+			Tip:               p.Tip,
 			Provider:          p.Method,
 			InternalReference: "ONESHOT-" + p.Method + "-" + fmt.Sprintf("%d", time.Now().UnixNano()),
 			Status:            ReconSuccess,
@@ -450,6 +694,24 @@ func CreateSale(db *gorm.DB, businessID uint, tenantID string, outletID uint, ca
 		return nil, errors.New("insufficient payment")
 	}
 
+	if err := chargeCustomerCredit(tx, sale, req.Payments, cashierID); err != nil {
+		return nil, err
+	}
+	paidWithPoints, err := redeemLoyaltyPoints(tx, sale, req.Payments)
+	if err != nil {
+		return nil, err
+	}
+	if err := earnLoyaltyPoints(tx, sale, saleItems, paidWithPoints); err != nil {
+		return nil, err
+	}
+	if err := redeemGiftCards(tx, sale, req.Payments, cashierID); err != nil {
+		return nil, err
+	}
+	giftCards, err := issueGiftCards(tx, sale, saleItems, cashierID)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := tx.Save(sale).Error; err != nil {
 		return nil, err
 	}
@@ -463,6 +725,7 @@ func CreateSale(db *gorm.DB, businessID uint, tenantID string, outletID uint, ca
 		for _, p := range req.Payments {
 			_ = shiftSvc.UpdateShiftMetrics(*sale.ShiftID, p.Amount, p.Method)
 		}
+		_ = recordShiftGratuities(db, sale, 1)
 	}
 
 	return &SaleReceipt{
@@ -471,6 +734,7 @@ func CreateSale(db *gorm.DB, businessID uint, tenantID string, outletID uint, ca
 		Change:      totalPaid - sale.Total,
 		ReceiptNo:   generateReceiptNo(sale.DailySequence),
 		GeneratedAt: time.Now(),
+		GiftCards:   giftCards,
 	}, nil
 }
 
@@ -487,6 +751,22 @@ func generateReceiptNo(sequence int) string {
 	return time.Now().Format("20060102") + "-" + fmt.Sprintf("%03d", sequence)
 }
 
+// saleStock returns a session whose stock changes are recorded against the sale, as
+// done by userID at the sale's outlet
+func saleStock(tx *gorm.DB, sale *Sale, reason inventory.MovementReason, userID uint) *gorm.DB {
+	m := inventory.Movement{Reason: reason, RefType: "sale", RefID: sale.ID, UserID: &userID}
+	if sale.OutletID != 0 {
+		outletID := sale.OutletID
+		m.OutletID = &outletID
+	}
+	return inventory.WithMovement(tx, m)
+}
+
+// roundMoney rounds an amount to 2 decimal places (kobo/cents)
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
 func getNextDailySequence(tx *gorm.DB, businessID uint) (int, error) {
 	var sales []Sale
 	startOfDay := time.Now().Truncate(24 * time.Hour) // 00:00:00
@@ -513,6 +793,11 @@ func getNextDailySequence(tx *gorm.DB, businessID uint) (int, error) {
 }
 
 func HoldSale(db *gorm.DB, saleID, businessID uint) (*Sale, error) {
+	var count int64
+	db.Model(&Sale{}).Where("id = ? AND business_id = ? AND status IN ?", saleID, businessID, []SaleStatus{StatusDraft, StatusHeld}).Count(&count)
+	if count == 0 {
+		return nil, errors.New("sale not found or not editable")
+	}
 	return updateSaleStatus(db, saleID, businessID, StatusHeld)
 }
 
@@ -521,14 +806,43 @@ func VoidSale(db *gorm.DB, saleID, businessID, userID uint, reason string) (*Sal
 
 	var sale Sale
 	if err := tx.Preload("SaleItems").First(&sale, "id = ? AND business_id = ? AND status = ?", saleID, businessID, StatusCompleted).Error; err != nil {
+		tx.Rollback()
 		return nil, errors.New("sale not found or cannot be voided")
 	}
 
+	if hasRefunds(tx, sale.ID) {
+		tx.Rollback()
+		return nil, errors.New("sale has refunds; refund the remaining items instead of voiding")
+	}
+
 	// Restock
 	recipeSvc := recipe.NewRecipeService(db)
+	stockTx := saleStock(tx, &sale, inventory.ReasonVoid, userID)
 	for _, item := range sale.SaleItems {
+		if item.IsGiftCard {
+			continue
+		}
 		// Pass negative quantity to AdjustStockWithRecipe to restock (since it negates the input)
-		_ = recipeSvc.AdjustStockWithRecipe(tx, item.ProductID, businessID, -item.Quantity)
+		_ = recipeSvc.AdjustStockWithRecipe(stockTx, item.ProductID, businessID, -item.Quantity)
+		_ = restockModifierStock(stockTx, recipeSvc, businessID, item, item.Quantity)
+	}
+
+	// Take back anything the sale put on a customer's account
+	if err := customer.ReverseSaleCredit(tx, businessID, sale.ID, userID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := loyalty.ReverseSale(tx, businessID, sale.ID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := giftcard.ReverseSale(tx, businessID, sale.ID, userID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := recordShiftGratuities(tx, &sale, -1); err != nil {
+		tx.Rollback()
+		return nil, err
 	}
 
 	sale.Status = StatusVoided
@@ -580,20 +894,8 @@ func updateSaleStatus(db *gorm.DB, saleID, businessID uint, status SaleStatus) (
 }
 
 func recalculateSaleTotals(db *gorm.DB, sale *Sale) error {
-	var items []SaleItem
-	if err := db.Where("sale_id = ?", sale.ID).Find(&items).Error; err != nil {
-		return err
-	}
-
-	subtotal := 0.0
-	for _, item := range items {
-		subtotal += item.TotalPrice
-	}
-
-	sale.Subtotal = subtotal
-	sale.Total = subtotal // discount applied later
-
-	return db.Save(sale).Error
+	_, err := repriceSale(db, sale)
+	return err
 }
 
 // RemoveItemFromSale removes a specific sale item and recalculates totals
@@ -605,6 +907,9 @@ func RemoveItemFromSale(db *gorm.DB, saleID, itemID, businessID uint) (*SaleResu
 		}
 		return nil, err
 	}
+	if err := checkSplitUnpaid(db, &sale); err != nil {
+		return nil, err
+	}
 
 	// Delete the item
 	result := db.Where("id = ? AND sale_id = ?", itemID, saleID).Delete(&SaleItem{})
@@ -746,8 +1051,8 @@ func GenerateDailyReport(db *gorm.DB, businessID uint, dateStr string) (*DailyRe
 
 	err := db.Table("payments").
 		Joins("JOIN sales ON sales.id = payments.sale_id").
-		Where("sales.business_id = ? AND sales.sale_date >= ? AND sales.sale_date < ? AND sales.status = ?",
-			businessID, startOfDay, endOfDay, StatusCompleted).
+		Where("sales.business_id = ? AND sales.sale_date >= ? AND sales.sale_date < ? AND sales.status IN ? AND payments.amount > 0",
+			businessID, startOfDay, endOfDay, revenueStatuses).
 		Select("payments.provider as payment_method, SUM(payments.amount) as total_sales, COUNT(DISTINCT sales.id) as total_transactions").
 		Group("payments.provider").
 		Scan(&results).Error
@@ -763,16 +1068,16 @@ func GenerateDailyReport(db *gorm.DB, businessID uint, dateStr string) (*DailyRe
 	}
 	db.Table("sales").
 		Joins("JOIN sale_items ON sale_items.sale_id = sales.id").
-		Where("sales.business_id = ? AND sales.sale_date >= ? AND sales.sale_date < ? AND sales.status = ?",
-			businessID, startOfDay, endOfDay, StatusCompleted).
+		Where("sales.business_id = ? AND sales.sale_date >= ? AND sales.sale_date < ? AND sales.status IN ?",
+			businessID, startOfDay, endOfDay, revenueStatuses).
 		Select("SUM(sale_items.cost_price * sale_items.quantity) as total_cost, SUM(sale_items.profit) as total_items_profit").
 		Scan(&financialSummary)
 
 	// 3. Get Total Discounts applied at sale level
 	var totalDiscount float64
 	db.Model(&Sale{}).
-		Where("business_id = ? AND sale_date >= ? AND sale_date < ? AND status = ?",
-			businessID, startOfDay, endOfDay, StatusCompleted).
+		Where("business_id = ? AND sale_date >= ? AND sale_date < ? AND status IN ?",
+			businessID, startOfDay, endOfDay, revenueStatuses).
 		Select("SUM(discount)").
 		Scan(&totalDiscount)
 
@@ -803,6 +1108,12 @@ func GenerateDailyReport(db *gorm.DB, businessID uint, dateStr string) (*DailyRe
 		}
 	}
 
+	// Refunds are reported on the day the money went back, netted off each tender
+	refunds := summarizeRefunds(db, businessID, startOfDay, endOfDay)
+	for method, amount := range refunds.ByMethod {
+		results = append(results, result{PaymentMethod: method, TotalSales: -amount})
+	}
+
 	for _, r := range results {
 		grandTotalSales += r.TotalSales
 		grandTotalTransactions += r.TotalTransactions
@@ -810,26 +1121,36 @@ func GenerateDailyReport(db *gorm.DB, businessID uint, dateStr string) (*DailyRe
 		// Map payment methods
 		switch r.PaymentMethod {
 		case "CASH":
-			report.CashSales = r.TotalSales
+			report.CashSales += r.TotalSales
 		case "CARD":
-			report.CardSales = r.TotalSales
+			report.CardSales += r.TotalSales
 		case "TRANSFER":
-			report.TransferSales = r.TotalSales
+			report.TransferSales += r.TotalSales
 		case "EXTERNAL_TERMINAL":
-			report.ExternalTerminalSales = r.TotalSales
+			report.ExternalTerminalSales += r.TotalSales
 		case "CREDIT":
-			report.CreditSales = r.TotalSales
+			report.CreditSales += r.TotalSales
 		default:
 			report.CashSales += r.TotalSales
 		}
 	}
 
+	// Service charges are collected with the tenders but are not takings; tips never are.
+	// Service charge refunded has already come off the tenders above.
+	report.ServiceCharges, report.Tips = sumGratuities(db, businessID, startOfDay, endOfDay)
+	report.ServiceCharges -= refunds.ServiceCharges
+	grandTotalSales -= report.ServiceCharges
+
 	// Fill final fields
+	report.GrossSales = grandTotalSales + refunds.Total
+	report.TotalRefunds = refunds.Total
+	report.RefundTransactions = refunds.Count
 	report.TotalSales = grandTotalSales
 	report.TotalTransactions = grandTotalTransactions
-	report.TotalCost = financialSummary.TotalCost
+	report.TotalCost = financialSummary.TotalCost - refunds.CostReturned
 	report.TotalExpenses = totalExpenses
-	report.TotalProfit = financialSummary.TotalItemsProfit - totalDiscount
+	report.LayawayForfeits = sumLayawayForfeits(db, businessID, startOfDay, endOfDay)
+	report.TotalProfit = financialSummary.TotalItemsProfit - totalDiscount - refunds.ProfitReversed + report.LayawayForfeits
 	report.NetProfit = report.TotalProfit - totalExpenses
 
 	if grandTotalTransactions > 0 {
@@ -949,8 +1270,8 @@ func GenerateSalesReport(db *gorm.DB, businessID uint, startDateStr, endDateStr,
 
 	err = db.Table("payments").
 		Joins("JOIN sales ON sales.id = payments.sale_id").
-		Where("sales.business_id = ? AND sales.sale_date >= ? AND sales.sale_date <= ? AND sales.status = ?",
-			businessID, startOfPeriod, endOfPeriod, StatusCompleted).
+		Where("sales.business_id = ? AND sales.sale_date >= ? AND sales.sale_date <= ? AND sales.status IN ? AND payments.amount > 0",
+			businessID, startOfPeriod, endOfPeriod, revenueStatuses).
 		Select("payments.provider as payment_method, SUM(payments.amount) as total_sales, COUNT(DISTINCT sales.id) as total_transactions").
 		Group("payments.provider").
 		Scan(&results).Error
@@ -966,16 +1287,16 @@ func GenerateSalesReport(db *gorm.DB, businessID uint, startDateStr, endDateStr,
 	}
 	db.Table("sales").
 		Joins("JOIN sale_items ON sale_items.sale_id = sales.id").
-		Where("sales.business_id = ? AND sales.sale_date >= ? AND sales.sale_date <= ? AND sales.status = ?",
-			businessID, startOfPeriod, endOfPeriod, StatusCompleted).
+		Where("sales.business_id = ? AND sales.sale_date >= ? AND sales.sale_date <= ? AND sales.status IN ?",
+			businessID, startOfPeriod, endOfPeriod, revenueStatuses).
 		Select("SUM(sale_items.cost_price * sale_items.quantity) as total_cost, SUM(sale_items.profit) as total_items_profit").
 		Scan(&financialSummary)
 
 	// 3. Get Total Discounts for this period
 	var totalDiscount float64
 	db.Model(&Sale{}).
-		Where("business_id = ? AND sale_date >= ? AND sale_date <= ? AND status = ?",
-			businessID, startOfPeriod, endOfPeriod, StatusCompleted).
+		Where("business_id = ? AND sale_date >= ? AND sale_date <= ? AND status IN ?",
+			businessID, startOfPeriod, endOfPeriod, revenueStatuses).
 		Select("SUM(discount)").
 		Scan(&totalDiscount)
 
@@ -993,6 +1314,12 @@ func GenerateSalesReport(db *gorm.DB, businessID uint, startDateStr, endDateStr,
 	var grandTotalCost float64
 	var grandTotalProfit float64
 
+	// Refunds made in the period are netted off each tender
+	refunds := summarizeRefunds(db, businessID, startOfPeriod, endOfPeriod.Add(time.Nanosecond))
+	for method, amount := range refunds.ByMethod {
+		results = append(results, result{PaymentMethod: method, TotalSales: -amount})
+	}
+
 	// 1. Process Raw Data (Status COMPLETED)
 	for _, r := range results {
 		grandTotalSales += r.TotalSales
@@ -1023,8 +1350,15 @@ func GenerateSalesReport(db *gorm.DB, businessID uint, startDateStr, endDateStr,
 		}
 	}
 
-	grandTotalCost = financialSummary.TotalCost
-	grandTotalProfit = financialSummary.TotalItemsProfit - totalDiscount
+	// Service charges are collected with the tenders but are not takings; tips never are.
+	// Service charge refunded has already come off the tenders above.
+	report.ServiceCharges, report.Tips = sumGratuities(db, businessID, startOfPeriod, endOfPeriod.Add(time.Nanosecond))
+	report.ServiceCharges -= refunds.ServiceCharges
+	grandTotalSales -= report.ServiceCharges
+
+	grandTotalCost = financialSummary.TotalCost - refunds.CostReturned
+	report.LayawayForfeits = sumLayawayForfeits(db, businessID, startOfPeriod, endOfPeriod.Add(time.Nanosecond))
+	grandTotalProfit = financialSummary.TotalItemsProfit - totalDiscount - refunds.ProfitReversed + report.LayawayForfeits
 	grandTotalExpenses := totalExpenses
 
 	// 2. Process Archived Data (SaleSummary)
@@ -1047,6 +1381,9 @@ func GenerateSalesReport(db *gorm.DB, businessID uint, startDateStr, endDateStr,
 		}
 	}
 
+	report.GrossSales = grandTotalSales + refunds.Total
+	report.TotalRefunds = refunds.Total
+	report.RefundTransactions = refunds.Count
 	report.TotalSales = grandTotalSales
 	report.TotalTransactions = grandTotalTransactions
 	report.TotalCost = grandTotalCost
@@ -1176,8 +1513,9 @@ func PerformCleanup(db *gorm.DB, businessID uint, retentionMonths int, businessN
 type ProductProfitStat struct {
 	ProductID   uint    `json:"product_id"`
 	ProductName string  `json:"product_name"`
-	TotalQty    int     `json:"total_qty"`
+	TotalQty    float64 `json:"total_qty"`
 	Revenue     float64 `json:"revenue"`
+	Discount    float64 `json:"discount"` // promotion and line discounts, already out of revenue
 	Cost        float64 `json:"cost"`
 	Profit      float64 `json:"profit"`
 }
@@ -1205,6 +1543,7 @@ func GetProductProfitReport(db *gorm.DB, businessID uint, from, to string) ([]Pr
 		product_name, 
 		SUM(quantity) as total_qty, 
 		SUM(total_price) as revenue, 
+		SUM(promo_discount + manual_discount) as discount, 
 		SUM(cost_price * quantity) as cost, 
 		SUM(profit) as profit
 	`).
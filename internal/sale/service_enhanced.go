@@ -3,10 +3,15 @@ package sale
 
 import (
 	"errors"
+	"strings"
 	"time"
 
+	"pos-fiber-app/internal/customer"
+	"pos-fiber-app/internal/giftcard"
 	"pos-fiber-app/internal/inventory"
+	"pos-fiber-app/internal/loyalty"
 	"pos-fiber-app/internal/product"
+	"pos-fiber-app/internal/recipe"
 
 	"gorm.io/gorm"
 )
@@ -24,6 +29,7 @@ func CreateDraftWithReservation(db *gorm.DB, businessID uint, tenantID string, c
 		CustomerName:  req.CustomerName,
 		CustomerPhone: req.CustomerPhone,
 		OrderType:     req.OrderType,
+		TerminalID:    req.TerminalID,
 		SaleDate:      time.Now(),
 	}
 
@@ -31,6 +37,14 @@ func CreateDraftWithReservation(db *gorm.DB, businessID uint, tenantID string, c
 		sale.OrderType = "dine-in"
 	}
 
+	if err := checkTerminal(db, tenantID, req.TerminalID); err != nil {
+		return nil, err
+	}
+
+	if err := attachCustomer(db, sale, req.CustomerID); err != nil {
+		return nil, err
+	}
+
 	if err := db.Create(sale).Error; err != nil {
 		return nil, err
 	}
@@ -46,7 +60,12 @@ func CreateDraftWithReservation(db *gorm.DB, businessID uint, tenantID string, c
 }
 
 // AddItemToSaleWithReservation adds item to sale and creates stock reservation
-func AddItemToSaleWithReservation(db *gorm.DB, saleID, businessID, cashierID uint, productID uint, qty int) (*SaleResult, error) {
+func AddItemToSaleWithReservation(db *gorm.DB, saleID, businessID, cashierID uint, req AddItemRequest) (*SaleResult, error) {
+	if err := resolveScannedItem(db, businessID, &req); err != nil {
+		return nil, err
+	}
+	productID, qty := req.ProductID, req.Quantity
+
 	tx := db.Begin()
 	defer tx.Rollback()
 
@@ -64,34 +83,50 @@ func AddItemToSaleWithReservation(db *gorm.DB, saleID, businessID, cashierID uin
 	if err := tx.Table("products").First(&prod, "id = ? AND business_id = ?", productID, businessID).Error; err != nil {
 		return nil, errors.New("product not found")
 	}
+	if err := inventory.CheckQuantity(qty, prod.QuantityPrecision); err != nil {
+		return nil, err
+	}
+
+	modifiers, err := resolveLineModifiers(tx, businessID, &prod, req.ModifierOptionIDs)
+	if err != nil {
+		return nil, err
+	}
 
 	// Initialize reservation service
 	reservationService := inventory.NewReservationService(tx)
 
-	// Check if we already have a reservation for this product in this sale
+	// Check if this seat already has a line for this product with the same modifiers,
+	// not yet fired to the kitchen. A scale label always gets a line of its own.
 	var existingItem SaleItem
-	existingErr := tx.First(&existingItem, "sale_id = ? AND product_id = ?", saleID, productID).Error
-
-	var currentReservedQty int
-	if existingErr == nil {
-		// Item already exists, we have a reservation
-		currentReservedQty = existingItem.Quantity
+	existingErr := gorm.ErrRecordNotFound
+	if req.labelPrice == nil {
+		existingErr = tx.First(&existingItem, "sale_id = ? AND product_id = ? AND seat_number = ? AND course = ? AND fired_at IS NULL AND label_price IS NULL AND modifier_key = ? AND gift_card_code = ?",
+			saleID, productID, req.SeatNumber, req.Course, product.ModifierKey(modifiers), strings.TrimSpace(req.GiftCardCode)).Error
 	}
 
+	// The reservation covers the product across every seat on the sale
+	var currentReservedQty float64
+	tx.Model(&SaleItem{}).
+		Where("sale_id = ? AND product_id = ?", saleID, productID).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&currentReservedQty)
+
 	// Calculate new total quantity
 	newTotalQty := currentReservedQty + qty
 
-	// Check available stock (accounting for current reservation)
-	availableStock, err := reservationService.GetAvailableStock(productID, businessID)
-	if err != nil {
-		return nil, err
-	}
+	// Check available stock (accounting for current reservation); gift cards carry none
+	if !prod.IsGiftCard {
+		availableStock, err := reservationService.GetAvailableStock(productID, businessID)
+		if err != nil {
+			return nil, err
+		}
 
-	// Add back current reservation to available for this check
-	effectiveAvailable := availableStock + currentReservedQty
+		// Add back current reservation to available for this check
+		effectiveAvailable := availableStock + currentReservedQty
 
-	if effectiveAvailable < newTotalQty {
-		return nil, errors.New("insufficient stock available for reservation")
+		if effectiveAvailable < newTotalQty {
+			return nil, errors.New("insufficient stock available for reservation")
+		}
 	}
 
 	// Upsert sale item
@@ -99,8 +134,7 @@ func AddItemToSaleWithReservation(db *gorm.DB, saleID, businessID, cashierID uin
 	if existingErr == nil {
 		// Update existing item
 		item = existingItem
-		item.Quantity = newTotalQty
-		item.TotalPrice = float64(item.Quantity) * prod.Price
+		item.Quantity = inventory.RoundQuantity(item.Quantity + qty)
 	} else {
 		// Create new item
 		item = SaleItem{
@@ -109,21 +143,42 @@ func AddItemToSaleWithReservation(db *gorm.DB, saleID, businessID, cashierID uin
 			ProductName: prod.Name,
 			Quantity:    qty,
 			UnitPrice:   prod.Price,
-			TotalPrice:  float64(qty) * prod.Price,
+			CostPrice:   prod.Cost,
+			SeatNumber:  req.SeatNumber,
+			Course:      req.Course,
+			LabelPrice:  req.labelPrice,
 		}
 	}
+	if err := setGiftCardLine(&item, &prod, req.GiftCardCode); err != nil {
+		return nil, err
+	}
+	applyModifiers(&item, modifiers)
+	override, err := adjustLine(tx, &sale, &item, lineDiscount(req.Discount), req.PriceOverride, req.Approval, cashierID)
+	if err != nil {
+		return nil, err
+	}
+	priceSaleItem(&item)
 
 	if err := tx.Save(&item).Error; err != nil {
 		return nil, err
 	}
+	// The override stands only if its audit record is written with it
+	if override != nil {
+		if err := LogActivity(tx, saleID, businessID, cashierID, ActionPriceOverride, *override); err != nil {
+			return nil, err
+		}
+	}
 
 	// Update or create stock reservation
-	if currentReservedQty > 0 {
+	switch {
+	case prod.IsGiftCard:
+		// Gift cards carry no stock to reserve
+	case currentReservedQty > 0:
 		// Update existing reservation
 		if err := reservationService.UpdateReservationQuantity(saleID, productID, newTotalQty); err != nil {
 			return nil, err
 		}
-	} else {
+	default:
 		// Create new reservation
 		if err := reservationService.ReserveStock(saleID, productID, businessID, cashierID, qty); err != nil {
 			return nil, err
@@ -164,7 +219,15 @@ func CompleteSaleWithReservation(db *gorm.DB, saleID, businessID, cashierID uint
 		return nil, errors.New("sale not found or already completed")
 	}
 
-	sale.Total = sale.Subtotal - req.Discount + req.Tax
+	if err := authorizeSaleDiscount(tx, &sale, req.Discount, req.Approval, cashierID); err != nil {
+		return nil, err
+	}
+	if err := computeTotals(tx, &sale, sale.SaleItems, req.Discount); err != nil {
+		return nil, err
+	}
+	if err := collectTips(&sale, req.Payments); err != nil {
+		return nil, err
+	}
 
 	var totalPaid float64
 	for _, p := range req.Payments {
@@ -175,6 +238,27 @@ func CompleteSaleWithReservation(db *gorm.DB, saleID, businessID, cashierID uint
 		return nil, errors.New("insufficient payment")
 	}
 
+	if err := attachCustomer(tx, &sale, req.CustomerID); err != nil {
+		return nil, err
+	}
+	if err := chargeCustomerCredit(tx, &sale, req.Payments, cashierID); err != nil {
+		return nil, err
+	}
+	paidWithPoints, err := redeemLoyaltyPoints(tx, &sale, req.Payments)
+	if err != nil {
+		return nil, err
+	}
+	if err := earnLoyaltyPoints(tx, &sale, sale.SaleItems, paidWithPoints); err != nil {
+		return nil, err
+	}
+	if err := redeemGiftCards(tx, &sale, req.Payments, cashierID); err != nil {
+		return nil, err
+	}
+	giftCards, err := issueGiftCards(tx, &sale, sale.SaleItems, cashierID)
+	if err != nil {
+		return nil, err
+	}
+
 	mainMethod := "SPLIT"
 	if len(req.Payments) == 1 {
 		mainMethod = req.Payments[0].Method
@@ -182,11 +266,19 @@ func CompleteSaleWithReservation(db *gorm.DB, saleID, businessID, cashierID uint
 
 	// Initialize reservation service
 	reservationService := inventory.NewReservationService(tx)
+	recipeSvc := recipe.NewRecipeService(db)
 
 	// Deduct inventory and release reservations
+	stockTx := saleStock(tx, &sale, inventory.ReasonSale, cashierID)
 	for _, item := range sale.SaleItems {
+		if item.IsGiftCard {
+			continue
+		}
 		// Deduct actual inventory
-		if err := inventory.AdjustStock(tx, item.ProductID, businessID, -item.Quantity); err != nil {
+		if err := inventory.AdjustStock(stockTx, item.ProductID, businessID, -item.Quantity); err != nil {
+			return nil, errors.New("failed to update inventory: " + err.Error())
+		}
+		if err := deductModifierStock(stockTx, recipeSvc, businessID, item, item.Quantity); err != nil {
 			return nil, errors.New("failed to update inventory: " + err.Error())
 		}
 
@@ -200,7 +292,6 @@ func CompleteSaleWithReservation(db *gorm.DB, saleID, businessID, cashierID uint
 	sale.Status = StatusCompleted
 	sale.PaymentMethod = mainMethod
 	sale.Discount = req.Discount
-	sale.Tax = req.Tax
 	sale.SyncedAt = &now
 
 	// Assign daily sequence number
@@ -220,6 +311,9 @@ func CompleteSaleWithReservation(db *gorm.DB, saleID, businessID, cashierID uint
 			tx.Exec("UPDATE shifts SET total_sales = total_sales + ?, transaction_count = transaction_count + 1 WHERE id = ?",
 				p.Amount, *sale.ShiftID)
 		}
+		if err := recordShiftGratuities(tx, &sale, 1); err != nil {
+			return nil, err
+		}
 	}
 
 	// Log activity
@@ -239,6 +333,7 @@ func CompleteSaleWithReservation(db *gorm.DB, saleID, businessID, cashierID uint
 		Change:      totalPaid - sale.Total,
 		ReceiptNo:   generateReceiptNo(sale.DailySequence),
 		GeneratedAt: time.Now(),
+		GiftCards:   giftCards,
 	}, nil
 }
 
@@ -255,16 +350,44 @@ func VoidSaleWithReservation(db *gorm.DB, saleID, businessID, cashierID uint, re
 	// Initialize reservation service
 	reservationService := inventory.NewReservationService(tx)
 
+	if sale.Status == StatusLayaway {
+		return nil, errors.New("cancel the layaway instead of voiding it")
+	}
+
+	if sale.Status == StatusCompleted && hasRefunds(tx, sale.ID) {
+		return nil, errors.New("sale has refunds; refund the remaining items instead of voiding")
+	}
+
 	if sale.Status == StatusCompleted {
 		// Restock inventory for completed sales
+		recipeSvc := recipe.NewRecipeService(db)
+		stockTx := saleStock(tx, &sale, inventory.ReasonVoid, cashierID)
 		for _, item := range sale.SaleItems {
-			inventory.AdjustStock(tx, item.ProductID, businessID, item.Quantity)
+			if item.IsGiftCard {
+				continue
+			}
+			inventory.AdjustStock(stockTx, item.ProductID, businessID, item.Quantity)
+			restockModifierStock(stockTx, recipeSvc, businessID, item, item.Quantity)
+		}
+
+		// Take back anything the sale put on a customer's account
+		if err := customer.ReverseSaleCredit(tx, businessID, sale.ID, cashierID); err != nil {
+			return nil, err
+		}
+		if err := loyalty.ReverseSale(tx, businessID, sale.ID); err != nil {
+			return nil, err
+		}
+		if err := giftcard.ReverseSale(tx, businessID, sale.ID, cashierID); err != nil {
+			return nil, err
 		}
 
 		// Update shift metrics if applicable
 		if sale.ShiftID != nil {
 			tx.Exec("UPDATE shifts SET total_sales = total_sales - ?, transaction_count = transaction_count - 1 WHERE id = ?",
 				sale.Total-sale.Discount, *sale.ShiftID)
+			if err := recordShiftGratuities(tx, &sale, -1); err != nil {
+				return nil, err
+			}
 		}
 	} else if sale.Status == StatusDraft || sale.Status == StatusHeld {
 		// Release reservations for draft/held sales
@@ -0,0 +1,230 @@
+// internal/sale/sync.go
+package sale
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"pos-fiber-app/internal/inventory"
+	"pos-fiber-app/internal/shift"
+
+	"gorm.io/gorm"
+)
+
+type SyncStatus string
+
+const (
+	SyncCreated   SyncStatus = "CREATED"
+	SyncDuplicate SyncStatus = "DUPLICATE" // already uploaded; SaleID is the sale it created
+	SyncRejected  SyncStatus = "REJECTED"
+)
+
+// SyncSaleRequest is a sale a device made while offline. ClientUUID is the device's ID
+// for the sale and IdempotencyKey identifies the upload; either seen before makes the
+// upload a duplicate.
+type SyncSaleRequest struct {
+	CreateSaleRequest
+	ClientUUID     string    `json:"client_uuid" validate:"required,max=36"`
+	IdempotencyKey string    `json:"idempotency_key" validate:"required,max=100"`
+	SoldAt         time.Time `json:"sold_at" validate:"required"`
+}
+
+type SyncRequest struct {
+	Sales []SyncSaleRequest `json:"sales" validate:"required,min=1,max=200,dive"`
+}
+
+// SyncResult is what became of one uploaded sale
+type SyncResult struct {
+	ClientUUID string           `json:"client_uuid"`
+	Status     SyncStatus       `json:"status"`
+	SaleID     *uint            `json:"sale_id,omitempty"`
+	ReceiptNo  string           `json:"receipt_no,omitempty"`
+	Reason     string           `json:"reason,omitempty"`
+	Shortfalls []StockShortfall `json:"shortfalls,omitempty"` // stock the sale took that was not on hand
+}
+
+// StockShortfall flags stock an offline sale took that the server did not have by the
+// time it was uploaded. The sale stands and stock goes negative; someone should recount.
+type StockShortfall struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	BusinessID  uint       `gorm:"index" json:"business_id"`
+	SaleID      uint       `gorm:"index" json:"sale_id"`
+	ProductID   uint       `json:"product_id"`
+	ProductName string     `json:"product_name"`
+	Available   float64    `gorm:"type:decimal(12,3)" json:"available"`
+	Needed      float64    `gorm:"type:decimal(12,3)" json:"needed"`
+	Resolved    bool       `gorm:"default:false;index" json:"resolved"`
+	ResolvedBy  *uint      `json:"resolved_by,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	Note        string     `gorm:"type:text" json:"note,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type ResolveShortfallRequest struct {
+	Note string `json:"note"`
+}
+
+// offlineSale is what createSale needs to know about a sale made offline
+type offlineSale struct {
+	ClientUUID     string
+	IdempotencyKey string
+	SoldAt         time.Time
+	shortfalls     []inventory.Shortfall
+}
+
+// SyncSales records a batch of sales made offline, in the order given. Each sale is
+// applied on its own, so one rejected sale does not hold back the rest.
+func SyncSales(db *gorm.DB, businessID uint, tenantID string, outletID, cashierID uint, req SyncRequest) []SyncResult {
+	results := make([]SyncResult, 0, len(req.Sales))
+	for _, s := range req.Sales {
+		results = append(results, syncSale(db, businessID, tenantID, outletID, cashierID, s))
+	}
+	return results
+}
+
+func syncSale(db *gorm.DB, businessID uint, tenantID string, outletID, cashierID uint, req SyncSaleRequest) SyncResult {
+	result := SyncResult{ClientUUID: req.ClientUUID}
+	reject := func(reason string) SyncResult {
+		result.Status = SyncRejected
+		result.Reason = reason
+		return result
+	}
+
+	if req.ClientUUID == "" || req.IdempotencyKey == "" {
+		return reject("client_uuid and idempotency_key are required")
+	}
+	if existing := findSyncedSale(db, businessID, req.ClientUUID, req.IdempotencyKey); existing != nil {
+		result.Status = SyncDuplicate
+		result.SaleID = &existing.ID
+		return result
+	}
+
+	if req.SoldAt.IsZero() {
+		return reject("sold_at is required")
+	}
+	if req.SoldAt.After(time.Now().Add(5 * time.Minute)) {
+		return reject("sold_at is in the future")
+	}
+	if len(req.Items) == 0 || len(req.Payments) == 0 {
+		return reject("a sale needs items and payments")
+	}
+
+	shiftID, err := syncShift(db, businessID, cashierID, req.ShiftID, req.SoldAt)
+	if err != nil {
+		return reject(err.Error())
+	}
+	req.ShiftID = shiftID
+
+	offline := &offlineSale{ClientUUID: req.ClientUUID, IdempotencyKey: req.IdempotencyKey, SoldAt: req.SoldAt}
+	receipt, err := createSale(db, businessID, tenantID, outletID, cashierID, req.CreateSaleRequest, offline)
+	if err != nil {
+		// A concurrent upload of the same sale loses on the unique index
+		if existing := findSyncedSale(db, businessID, req.ClientUUID, req.IdempotencyKey); existing != nil {
+			result.Status = SyncDuplicate
+			result.SaleID = &existing.ID
+			return result
+		}
+		return reject(err.Error())
+	}
+
+	result.Status = SyncCreated
+	result.SaleID = &receipt.Sale.ID
+	result.ReceiptNo = receipt.ReceiptNo
+	if len(offline.shortfalls) > 0 {
+		db.Where("sale_id = ?", receipt.Sale.ID).Order("id ASC").Find(&result.Shortfalls)
+	}
+	return result
+}
+
+// findSyncedSale returns the sale an earlier upload created, if any
+func findSyncedSale(db *gorm.DB, businessID uint, clientUUID, idempotencyKey string) *Sale {
+	var sales []Sale
+	db.Select("id").
+		Where("business_id = ? AND (client_uuid = ? OR idempotency_key = ?)", businessID, clientUUID, idempotencyKey).
+		Limit(1).
+		Find(&sales)
+	if len(sales) == 0 {
+		return nil
+	}
+	return &sales[0]
+}
+
+// syncShift finds the cashier's shift an offline sale belongs to: the one given, which
+// must have been open when the sale was made, or else whichever was.
+func syncShift(db *gorm.DB, businessID, cashierID uint, shiftID *uint, soldAt time.Time) (*uint, error) {
+	var s shift.Shift
+	if shiftID != nil {
+		if err := db.First(&s, "id = ? AND business_id = ?", *shiftID, businessID).Error; err != nil {
+			return nil, fmt.Errorf("shift %d not found", *shiftID)
+		}
+		if soldAt.Before(s.StartTime) || (s.EndTime != nil && soldAt.After(*s.EndTime)) {
+			return nil, fmt.Errorf("sale was made outside shift %d", s.ID)
+		}
+		return &s.ID, nil
+	}
+
+	err := db.Where("business_id = ? AND user_id = ? AND start_time <= ? AND (end_time IS NULL OR end_time >= ?)",
+		businessID, cashierID, soldAt, soldAt).
+		Order("start_time DESC").
+		First(&s).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("no shift of this cashier was open when the sale was made")
+		}
+		return nil, err
+	}
+	return &s.ID, nil
+}
+
+// flagShortfalls records the stock an offline sale took that was not on hand
+func flagShortfalls(tx *gorm.DB, sale *Sale, shortfalls []inventory.Shortfall) error {
+	for _, s := range shortfalls {
+		var name string
+		tx.Table("products").Select("name").Where("id = ?", s.ProductID).Scan(&name)
+		flag := StockShortfall{
+			BusinessID:  sale.BusinessID,
+			SaleID:      sale.ID,
+			ProductID:   s.ProductID,
+			ProductName: name,
+			Available:   s.Available,
+			Needed:      s.Needed,
+		}
+		if err := tx.Create(&flag).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListShortfalls returns the business's stock shortfalls, newest first
+func ListShortfalls(db *gorm.DB, businessID uint, includeResolved bool) ([]StockShortfall, error) {
+	shortfalls := []StockShortfall{}
+	query := db.Where("business_id = ?", businessID)
+	if !includeResolved {
+		query = query.Where("resolved = ?", false)
+	}
+	err := query.Order("created_at DESC").Limit(200).Find(&shortfalls).Error
+	return shortfalls, err
+}
+
+// ResolveShortfall marks a stock shortfall as reviewed
+func ResolveShortfall(db *gorm.DB, id, businessID, userID uint, note string) (*StockShortfall, error) {
+	var flag StockShortfall
+	if err := db.First(&flag, "id = ? AND business_id = ?", id, businessID).Error; err != nil {
+		return nil, errors.New("shortfall not found")
+	}
+	if flag.Resolved {
+		return nil, errors.New("shortfall is already resolved")
+	}
+
+	now := time.Now()
+	flag.Resolved = true
+	flag.ResolvedBy = &userID
+	flag.ResolvedAt = &now
+	flag.Note = note
+	if err := db.Save(&flag).Error; err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
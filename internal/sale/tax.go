@@ -0,0 +1,138 @@
+// internal/sale/tax.go
+package sale
+
+import (
+	"pos-fiber-app/internal/common"
+	"pos-fiber-app/internal/product"
+
+	"gorm.io/gorm"
+)
+
+// applyTax works out the VAT on each line of a sale and sets the sale's Tax and Total.
+// A line's share of the sale discount comes off its taxable amount first. When the
+// business's prices include tax the VAT is taken out of the line; otherwise it is
+// added on top of the sale.
+func applyTax(db *gorm.DB, sale *Sale, items []SaleItem, discount float64) error {
+	var biz struct {
+		VATRate          float64
+		PricesIncludeTax bool
+	}
+	if err := db.Table("businesses").Select("vat_rate, prices_include_tax").Where("id = ?", sale.BusinessID).Scan(&biz).Error; err != nil {
+		return err
+	}
+
+	classes, err := productTaxClasses(db, sale.BusinessID, items)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range taxLines(sale, items, classes, biz.VATRate, biz.PricesIncludeTax, discount) {
+		item := &items[i]
+		if item.ID == 0 {
+			continue
+		}
+		if err := db.Model(item).Updates(map[string]interface{}{
+			"tax_class":  item.TaxClass,
+			"tax_rate":   item.TaxRate,
+			"tax":        item.Tax,
+			"net_amount": item.NetAmount,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// taxLines works out the VAT on each line of a sale at the business's rate for its
+// class and sets the sale's Tax and Total. Returns the indexes of the lines whose tax
+// changed.
+func taxLines(sale *Sale, items []SaleItem, classes map[uint]common.TaxClass, vatRate float64, inclusive bool, discount float64) []int {
+	share := 1.0
+	if sale.Subtotal > 0 {
+		share = (sale.Subtotal - discount) / sale.Subtotal
+	}
+
+	var totalTax float64
+	var changed []int
+	for i := range items {
+		item := &items[i]
+
+		class := classes[item.ProductID]
+		if item.IsGiftCard {
+			class = common.TaxExempt // stored value is taxed when it is spent
+		}
+		rate := 0.0
+		if class == common.TaxStandard {
+			rate = vatRate
+		}
+
+		amount := item.TotalPrice * share
+		var tax, net float64
+		if inclusive {
+			tax = amount - amount/(1+rate/100)
+			net = amount - tax
+		} else {
+			tax = amount * rate / 100
+			net = amount
+		}
+		tax, net = roundMoney(tax), roundMoney(net)
+
+		if item.TaxClass != class || item.TaxRate != rate || item.Tax != tax || item.NetAmount != net {
+			item.TaxClass, item.TaxRate, item.Tax, item.NetAmount = class, rate, tax, net
+			changed = append(changed, i)
+		}
+		totalTax += tax
+	}
+
+	sale.Tax = roundMoney(totalTax)
+	sale.TaxInclusive = inclusive
+	sale.Total = roundMoney(sale.Subtotal - discount)
+	if !inclusive {
+		sale.Total = roundMoney(sale.Total + sale.Tax)
+	}
+	return changed
+}
+
+// productTaxClasses resolves each product's tax class: its own, else its category's,
+// else standard
+func productTaxClasses(db *gorm.DB, businessID uint, items []SaleItem) (map[uint]common.TaxClass, error) {
+	ids := make([]uint, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductID)
+	}
+
+	classes := make(map[uint]common.TaxClass, len(ids))
+	if len(ids) == 0 {
+		return classes, nil
+	}
+
+	var rows []struct {
+		ID               uint
+		TaxClass         common.TaxClass
+		CategoryTaxClass common.TaxClass
+	}
+	if err := db.Model(&product.Product{}).
+		Select("products.id, products.tax_class, categories.tax_class AS category_tax_class").
+		Joins("LEFT JOIN categories ON categories.id = products.category_id").
+		Where("products.business_id = ? AND products.id IN ?", businessID, ids).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	for _, r := range rows {
+		switch {
+		case r.TaxClass.Valid():
+			classes[r.ID] = r.TaxClass
+		case r.CategoryTaxClass.Valid():
+			classes[r.ID] = r.CategoryTaxClass
+		default:
+			classes[r.ID] = common.TaxStandard
+		}
+	}
+	for _, id := range ids {
+		if _, ok := classes[id]; !ok {
+			classes[id] = common.TaxStandard
+		}
+	}
+	return classes, nil
+}
@@ -0,0 +1,89 @@
+// internal/sale/tax_test.go
+package sale
+
+import (
+	"testing"
+
+	"pos-fiber-app/internal/common"
+)
+
+func TestTaxLines(t *testing.T) {
+	classes := map[uint]common.TaxClass{
+		1: common.TaxStandard,
+		2: common.TaxZeroRated,
+		3: common.TaxExempt,
+	}
+	tests := []struct {
+		name      string
+		items     []SaleItem
+		inclusive bool
+		discount  float64
+		lineTax   []float64
+		lineNet   []float64
+		tax       float64
+		total     float64
+	}{
+		{
+			name:    "exclusive, added on top",
+			items:   []SaleItem{{ProductID: 1, TotalPrice: 100}, {ProductID: 2, TotalPrice: 50}},
+			lineTax: []float64{7.5, 0},
+			lineNet: []float64{100, 50},
+			tax:     7.5,
+			total:   157.5,
+		},
+		{
+			name:      "inclusive, taken out of the line",
+			items:     []SaleItem{{ProductID: 1, TotalPrice: 107.5}, {ProductID: 3, TotalPrice: 20}},
+			inclusive: true,
+			lineTax:   []float64{7.5, 0},
+			lineNet:   []float64{100, 20},
+			tax:       7.5,
+			total:     127.5,
+		},
+		{
+			name:     "discount spread before tax",
+			items:    []SaleItem{{ProductID: 1, TotalPrice: 150}, {ProductID: 3, TotalPrice: 50}},
+			discount: 20,
+			lineTax:  []float64{10.13, 0},
+			lineNet:  []float64{135, 45},
+			tax:      10.13,
+			total:    190.13,
+		},
+		{
+			name:    "gift cards are exempt",
+			items:   []SaleItem{{ProductID: 1, TotalPrice: 40, IsGiftCard: true}},
+			lineTax: []float64{0},
+			lineNet: []float64{40},
+			tax:     0,
+			total:   40,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subtotal float64
+			for _, item := range tt.items {
+				subtotal += item.TotalPrice
+			}
+			sale := Sale{Subtotal: subtotal}
+			changed := taxLines(&sale, tt.items, classes, 7.5, tt.inclusive, tt.discount)
+
+			for i, item := range tt.items {
+				if item.Tax != tt.lineTax[i] || item.NetAmount != tt.lineNet[i] {
+					t.Errorf("line %d: tax %.2f net %.2f; want %.2f net %.2f", i, item.Tax, item.NetAmount, tt.lineTax[i], tt.lineNet[i])
+				}
+			}
+			if sale.Tax != tt.tax || sale.Total != tt.total || sale.TaxInclusive != tt.inclusive {
+				t.Errorf("sale tax %.2f total %.2f inclusive %v; want %.2f, %.2f, %v", sale.Tax, sale.Total, sale.TaxInclusive, tt.tax, tt.total, tt.inclusive)
+			}
+			if len(changed) != len(tt.items) {
+				t.Errorf("%d lines reported changed, want %d", len(changed), len(tt.items))
+			}
+
+			// Taxed again unchanged, nothing needs saving
+			if again := taxLines(&sale, tt.items, classes, 7.5, tt.inclusive, tt.discount); len(again) != 0 {
+				t.Errorf("lines %v reported changed on a second pass", again)
+			}
+		})
+	}
+}
@@ -8,7 +8,7 @@ type SampleProduct struct {
 	Name      string
 	Price     float64
 	Cost      float64
-	Stock         int
+	Stock         float64
 	SKUPrefix     string
 	TrackByRound  bool
 	UnitOfMeasure string
@@ -156,6 +156,9 @@ func SeedSampleData(db *gorm.DB, bizID uint, bizType common.BusinessType) error
 			return fmt.Errorf("business already seeded")
 		}
 
+		// Sample stock is booked on each product's stock card as its opening balance
+		opening := inventory.WithMovement(tx, inventory.Movement{Reason: inventory.ReasonRestock, Note: "sample data"})
+
 		for i, sc := range categories {
 			cat := category.Category{
 				BusinessID: bizID,
@@ -206,6 +209,9 @@ func SeedSampleData(db *gorm.DB, bizID uint, bizType common.BusinessType) error
 					if err := tx.Create(&round).Error; err != nil {
 						return err
 					}
+					if err := inventory.RecordMovement(opening, p.ID, bizID, volume, volume); err != nil {
+						return err
+					}
 				} else {
 					// Create normal inventory record
 					inv := inventory.Inventory{
@@ -217,6 +223,9 @@ func SeedSampleData(db *gorm.DB, bizID uint, bizType common.BusinessType) error
 					if err := tx.Create(&inv).Error; err != nil {
 						return err
 					}
+					if err := inventory.RecordMovement(opening, p.ID, bizID, p.Stock, p.Stock); err != nil {
+						return err
+					}
 				}
 			}
 		}
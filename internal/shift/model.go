@@ -24,6 +24,11 @@ type Shift struct {
 	TotalTransferSales         float64        `gorm:"type:decimal(12,2);default:0" json:"total_transfer_sales"`
 	TotalExternalTerminalSales float64        `gorm:"type:decimal(12,2);default:0" json:"total_external_terminal_sales"`
 	TotalCreditSales           float64        `gorm:"type:decimal(12,2);default:0" json:"total_credit_sales"`
+	TotalRefunds               float64        `gorm:"type:decimal(12,2);default:0" json:"total_refunds"`
+	TotalServiceCharges        float64        `gorm:"type:decimal(12,2);default:0" json:"total_service_charges"` // included in TotalSales
+	TotalTips                  float64        `gorm:"type:decimal(12,2);default:0" json:"total_tips"`            // on top of TotalSales, owed to staff
+	TotalCashTips              float64        `gorm:"type:decimal(12,2);default:0" json:"total_cash_tips"`       // part of TotalTips, taken into the drawer
+	TotalRiderCash             float64        `gorm:"type:decimal(12,2);default:0" json:"total_rider_cash"`      // cash on delivery riders handed in during the shift
 	TransactionCount           int            `gorm:"default:0" json:"transaction_count"`
 	ExpectedCash               float64        `gorm:"type:decimal(12,2);default:0" json:"expected_cash"`
 	CashVariance               float64        `gorm:"type:decimal(12,2);default:0" json:"cash_variance"`
@@ -104,7 +104,7 @@ func (s *ShiftService) EndShift(shiftID uint, endCash float64, closedByName stri
 	}
 
 	// Calculate variance
-	shift.ExpectedCash = shift.StartCash + shift.TotalCashSales
+	shift.ExpectedCash = shift.StartCash + shift.TotalCashSales + shift.TotalCashTips + shift.TotalRiderCash
 	shift.CashVariance = endCash - shift.ExpectedCash
 
 	if err := s.db.Save(&shift).Error; err != nil {
@@ -183,6 +183,10 @@ type ShiftSummary struct {
 	Shift            Shift   `json:"shift"`
 	TotalSales       float64 `json:"total_sales"`
 	TransactionCount int     `json:"transaction_count"`
+	ServiceCharges   float64 `json:"service_charges"`
+	Tips             float64 `json:"tips"`
+	CashTips         float64 `json:"cash_tips"`  // tips paid in cash, part of ExpectedCash
+	RiderCash        float64 `json:"rider_cash"` // cash on delivery handed in by riders, part of ExpectedCash
 	ExpectedCash     float64 `json:"expected_cash"`
 	ActualCash       float64 `json:"actual_cash"`
 	Variance         float64 `json:"variance"`
@@ -199,6 +203,10 @@ func (s *ShiftService) GetShiftSummary(shiftID uint) (*ShiftSummary, error) {
 		Shift:            shift,
 		TotalSales:       shift.TotalSales,
 		TransactionCount: shift.TransactionCount,
+		ServiceCharges:   shift.TotalServiceCharges,
+		Tips:             shift.TotalTips,
+		CashTips:         shift.TotalCashTips,
+		RiderCash:        shift.TotalRiderCash,
 		ExpectedCash:     shift.ExpectedCash,
 		ActualCash:       0,
 		Variance:         shift.CashVariance,
@@ -211,10 +219,17 @@ func (s *ShiftService) GetShiftSummary(shiftID uint) (*ShiftSummary, error) {
 	return summary, nil
 }
 
+// UpdateShiftMetrics adds a payment to the shift totals.
+// A negative saleAmount reverses money already taken (refunds): the per-method
+// totals go down, the amount is added to total_refunds and the transaction count is left as is.
 func (s *ShiftService) UpdateShiftMetrics(shiftID uint, saleAmount float64, paymentMethod string) error {
 	updates := map[string]interface{}{
-		"total_sales":       gorm.Expr("total_sales + ?", saleAmount),
-		"transaction_count": gorm.Expr("transaction_count + 1"),
+		"total_sales": gorm.Expr("total_sales + ?", saleAmount),
+	}
+	if saleAmount < 0 {
+		updates["total_refunds"] = gorm.Expr("total_refunds + ?", -saleAmount)
+	} else {
+		updates["transaction_count"] = gorm.Expr("transaction_count + 1")
 	}
 
 	switch paymentMethod {
@@ -233,6 +248,23 @@ func (s *ShiftService) UpdateShiftMetrics(shiftID uint, saleAmount float64, paym
 	return s.db.Model(&Shift{}).Where("id = ?", shiftID).Updates(updates).Error
 }
 
+// AddGratuities adds a sale's service charge and tips to the shift totals.
+// cashTips is the part of tips paid in cash, which the drawer is expected to hold.
+// Negative amounts take them back when the sale is voided.
+func (s *ShiftService) AddGratuities(shiftID uint, serviceCharge, tips, cashTips float64) error {
+	return s.db.Model(&Shift{}).Where("id = ?", shiftID).Updates(map[string]interface{}{
+		"total_service_charges": gorm.Expr("total_service_charges + ?", serviceCharge),
+		"total_tips":            gorm.Expr("total_tips + ?", tips),
+		"total_cash_tips":       gorm.Expr("total_cash_tips + ?", cashTips),
+	}).Error
+}
+
+// AddRiderCash adds cash on delivery a rider handed in to the shift's drawer
+func (s *ShiftService) AddRiderCash(shiftID uint, amount float64) error {
+	return s.db.Model(&Shift{}).Where("id = ?", shiftID).
+		Update("total_rider_cash", gorm.Expr("total_rider_cash + ?", amount)).Error
+}
+
 // ValidateActiveShift checks if a user has an active shift for the business
 // Returns the shift ID if active, error if not
 func (s *ShiftService) ValidateActiveShift(businessID, userID uint) (*Shift, error) {
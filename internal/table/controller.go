@@ -219,3 +219,97 @@ func (c *TableController) GetSections(ctx *fiber.Ctx) error {
 		"data":    sections,
 	})
 }
+
+// GetTableQR godoc
+// @Summary Get a table's QR code
+// @Description Renders the QR code to print for the table. It encodes the public menu link with the table's signed token, so orders placed from it are dine-in at this table.
+// @Tags tables
+// @Produce png
+// @Produce image/svg+xml
+// @Param id path int true "Table ID"
+// @Param format query string false "png (default) or svg"
+// @Param size query int false "Image size in pixels, 128-2048 (default 512)"
+// @Success 200 {file} file
+// @Failure 404 {object} fiber.Map
+// @Router /tables/{id}/qr [get]
+func (c *TableController) GetTableQR(ctx *fiber.Ctx) error {
+	tableID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid table ID")
+	}
+
+	businessID := ctx.Locals("business_id").(uint)
+
+	img, contentType, err := c.service.TableQRImage(uint(tableID), businessID, ctx.Query("format", "png"), ctx.QueryInt("size", 512))
+	if err != nil {
+		if err.Error() == "table not found" {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	ctx.Set(fiber.HeaderContentType, contentType)
+	return ctx.Send(img)
+}
+
+// GetTableQRLink godoc
+// @Summary Get the link a table's QR code encodes
+// @Tags tables
+// @Produce json
+// @Param id path int true "Table ID"
+// @Success 200 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /tables/{id}/qr/link [get]
+func (c *TableController) GetTableQRLink(ctx *fiber.Ctx) error {
+	tableID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid table ID")
+	}
+
+	businessID := ctx.Locals("business_id").(uint)
+
+	qr, err := c.service.GetTableQR(uint(tableID), businessID)
+	if err != nil {
+		if err.Error() == "table not found" {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"data":    qr,
+	})
+}
+
+// RotateTableQR godoc
+// @Summary Rotate a table's QR code
+// @Description Issues the table a new signed token. Codes printed before stop working, so reprint the table's QR code afterwards.
+// @Tags tables
+// @Produce json
+// @Param id path int true "Table ID"
+// @Success 200 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Router /tables/{id}/qr/rotate [post]
+func (c *TableController) RotateTableQR(ctx *fiber.Ctx) error {
+	tableID, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "invalid table ID")
+	}
+
+	businessID := ctx.Locals("business_id").(uint)
+
+	qr, err := c.service.RotateQRToken(uint(tableID), businessID)
+	if err != nil {
+		if err.Error() == "table not found" {
+			return fiber.NewError(fiber.StatusNotFound, err.Error())
+		}
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+
+	return ctx.JSON(fiber.Map{
+		"success": true,
+		"message": "table QR code rotated; reprint it",
+		"data":    qr,
+	})
+}
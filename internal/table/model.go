@@ -23,6 +23,7 @@ type Table struct {
 	Section     string      `json:"section,omitempty"`                            // "Outdoor", "VIP", "Main Hall"
 	Capacity    int         `json:"capacity" gorm:"default:4"`
 	Status      TableStatus `gorm:"type:varchar(20);default:'available'" json:"status"`
+	QRVersion   uint        `gorm:"default:1" json:"qr_version"` // Bumped to rotate the table's QR code; older codes stop working
 	CreatedAt   time.Time   `json:"created_at"`
 	UpdatedAt   time.Time   `json:"updated_at"`
 }
@@ -0,0 +1,120 @@
+// internal/table/qr.go
+package table
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+	"gorm.io/gorm"
+)
+
+// qrSecret signs the table tokens in QR menu links
+func qrSecret() []byte {
+	if secret := os.Getenv("TABLE_QR_SECRET"); secret != "" {
+		return []byte(secret)
+	}
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+func signQR(payload string) string {
+	h := hmac.New(sha256.New, qrSecret())
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// QRToken is the signed token a table's QR code carries, binding menu orders to the table.
+// It includes the table's QR version, so rotating the version invalidates printed codes.
+func QRToken(t *Table) string {
+	version := t.QRVersion
+	if version == 0 {
+		version = 1
+	}
+	payload := fmt.Sprintf("%d.%d.%d", t.ID, t.BusinessID, version)
+	return payload + "." + signQR(payload)
+}
+
+// ResolveQRToken checks a table token's signature and version and returns the business's
+// table it is for
+func ResolveQRToken(db *gorm.DB, businessID uint, token string) (*Table, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 {
+		return nil, errors.New("invalid table QR code")
+	}
+	payload := strings.Join(parts[:3], ".")
+	if !hmac.Equal([]byte(parts[3]), []byte(signQR(payload))) {
+		return nil, errors.New("invalid table QR code")
+	}
+	tableID, err1 := strconv.ParseUint(parts[0], 10, 64)
+	bizID, err2 := strconv.ParseUint(parts[1], 10, 64)
+	version, err3 := strconv.ParseUint(parts[2], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || uint(bizID) != businessID {
+		return nil, errors.New("invalid table QR code")
+	}
+
+	var t Table
+	if err := db.First(&t, "id = ? AND business_id = ?", tableID, businessID).Error; err != nil {
+		return nil, errors.New("table not found")
+	}
+	if uint(version) != t.QRVersion {
+		return nil, errors.New("this table QR code has been replaced, scan the one on the table")
+	}
+	return &t, nil
+}
+
+// TableQR is what a table's QR code encodes
+type TableQR struct {
+	TableID     uint   `json:"table_id"`
+	TableNumber string `json:"table_number"`
+	Version     uint   `json:"qr_version"`
+	Token       string `json:"token"`
+	URL         string `json:"url"`
+}
+
+// menuURL is the customer-facing menu page of a business, opened on the given table
+func menuURL(slug, token string) string {
+	base := os.Getenv("MENU_URL")
+	if base == "" {
+		base = os.Getenv("FRONTEND_URL")
+	}
+	if base == "" {
+		base = "http://localhost:3000"
+	}
+	return fmt.Sprintf("%s/menu/%s?table=%s", strings.TrimRight(base, "/"), url.PathEscape(slug), url.QueryEscape(token))
+}
+
+// encodeQRPNG renders a QR code as a PNG of the given size in pixels
+func encodeQRPNG(content string, size int) ([]byte, error) {
+	return qrcode.Encode(content, qrcode.Medium, size)
+}
+
+// encodeQRSVG renders a QR code as an SVG, one unit per module, scaled to size pixels
+func encodeQRSVG(content string, size int) ([]byte, error) {
+	q, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	bitmap := q.Bitmap() // includes the quiet zone
+	n := len(bitmap)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, n, n)
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
+}
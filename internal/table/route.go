@@ -13,4 +13,7 @@ func (c *TableController) RegisterRoutes(router fiber.Router) {
 	router.Put("/tables/:id", c.UpdateTable)
 	router.Delete("/tables/:id", c.DeleteTable)
 	router.Get("/tables/:id/orders", c.GetTableOrders)
+	router.Get("/tables/:id/qr", c.GetTableQR)
+	router.Get("/tables/:id/qr/link", c.GetTableQRLink)
+	router.Post("/tables/:id/qr/rotate", c.RotateTableQR)
 }
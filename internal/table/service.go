@@ -180,3 +180,66 @@ func (s *TableService) GetSectionList(businessID uint) ([]string, error) {
 
 	return sections, err
 }
+
+// GetTableQR returns the menu link and token a table's QR code encodes
+func (s *TableService) GetTableQR(tableID, businessID uint) (*TableQR, error) {
+	table, err := s.GetTable(tableID, businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	var slug string
+	s.db.Table("businesses").Select("slug").Where("id = ?", businessID).Scan(&slug)
+	if slug == "" {
+		return nil, errors.New("set a business slug to publish the menu first")
+	}
+
+	token := QRToken(table)
+	return &TableQR{
+		TableID:     table.ID,
+		TableNumber: table.TableNumber,
+		Version:     table.QRVersion,
+		Token:       token,
+		URL:         menuURL(slug, token),
+	}, nil
+}
+
+// RotateQRToken issues the table a new QR code; codes printed before stop working
+func (s *TableService) RotateQRToken(tableID, businessID uint) (*TableQR, error) {
+	result := s.db.Model(&Table{}).
+		Where("id = ? AND business_id = ?", tableID, businessID).
+		Update("qr_version", gorm.Expr("COALESCE(qr_version, 1) + 1"))
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil, errors.New("table not found")
+	}
+
+	return s.GetTableQR(tableID, businessID)
+}
+
+// TableQRImage renders a table's QR code as a "png" or "svg" image of size pixels
+func (s *TableService) TableQRImage(tableID, businessID uint, format string, size int) ([]byte, string, error) {
+	qr, err := s.GetTableQR(tableID, businessID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if size < 128 || size > 2048 {
+		size = 512
+	}
+
+	switch format {
+	case "", "png":
+		img, err := encodeQRPNG(qr.URL, size)
+		return img, "image/png", err
+	case "svg":
+		img, err := encodeQRSVG(qr.URL, size)
+		return img, "image/svg+xml", err
+	default:
+		return nil, "", errors.New("format must be png or svg")
+	}
+}
@@ -323,6 +323,53 @@ func ResetPasswordHandler(service *UserService) fiber.Handler {
 	}
 }
 
+// SetPINHandler sets a manager's or owner's approval PIN
+// @Summary Set approval PIN
+// @Description Set the PIN a manager or owner enters to approve discounts and price overrides
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param request body map[string]string true "PIN payload"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Security BearerAuth
+// @Router /users/{id}/pin [post]
+func SetPINHandler(service *UserService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		idParam := c.Params("id")
+		id, err := strconv.ParseUint(idParam, 10, 64)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid user ID"})
+		}
+
+		var payload struct {
+			PIN string `json:"pin"`
+		}
+
+		if err := c.BodyParser(&payload); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		actor := c.Locals("user").(*types.UserClaims)
+		if err := service.SetPIN(actor, uint(id), payload.PIN); err != nil {
+			if err.Error() == "user not found" {
+				return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+			}
+			if strings.HasPrefix(err.Error(), "forbidden") {
+				return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+			}
+			if strings.Contains(err.Error(), "PIN") {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+			}
+			return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		return c.JSON(fiber.Map{"message": "PIN set successfully"})
+	}
+}
+
 // ProfileHandler retrieves the logged-in user's profile
 // @Summary Get profile
 // @Description Get profile of logged-in user
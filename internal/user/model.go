@@ -18,7 +18,8 @@ type User struct {
 	Active            bool      `json:"active"`
 	TenantID          string    `json:"tenant_id"`
 	OutletID          *uint     `json:"outlet_id"`
-	Role              string    `json:"role"` // OWNER / MANAGER / CASHIER / INSTALLER
+	Role              string    `json:"role"` // OWNER / MANAGER / CASHIER / INSTALLER / RIDER
+	PIN               string    `json:"-"`    // hashed; managers and owners use it to approve overrides at the till
 	IsVerified        bool      `gorm:"default:false" json:"is_verified"`
 	BankName          string    `json:"bank_name"`
 	AccountNumber     string    `json:"account_number"`
@@ -22,6 +22,7 @@ func RegisterUserRoutes(public fiber.Router, protected fiber.Router, db *gorm.DB
 	userGroup.Put("/:id", UpdateUserHandler(service))
 	userGroup.Delete("/:id", DeleteUserHandler(service))
 	userGroup.Post("/:id/reset-password", ResetPasswordHandler(service))
+	userGroup.Post("/:id/pin", SetPINHandler(service))
 
 	// Logged-in user profile
 	protected.Get("/profile", ProfileHandler(service))
@@ -3,6 +3,8 @@ package user
 import (
 	"errors"
 
+	"pos-fiber-app/internal/types"
+
 	"gorm.io/gorm"
 )
 
@@ -115,6 +117,43 @@ func (s *UserService) Delete(id uint) error {
 	return s.db.Delete(user).Error
 }
 
+// SetPIN sets the PIN a manager or owner enters to approve discounts and price overrides.
+// Only the user themselves or an owner of their business may set it.
+func (s *UserService) SetPIN(actor *types.UserClaims, id uint, pin string) error {
+	user, err := s.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if user.TenantID != actor.TenantID {
+		return errors.New("user not found")
+	}
+	if actor.UserID != user.ID {
+		// The claims' role may be stale; check the caller as they are now
+		var caller User
+		if err := s.db.Select("id, role, active").First(&caller, "id = ? AND tenant_id = ?", actor.UserID, actor.TenantID).Error; err != nil ||
+			!caller.Active || caller.Role != "OWNER" {
+			return errors.New("forbidden: only the user or an owner can set an approval PIN")
+		}
+	}
+	if user.Role != "OWNER" && user.Role != "MANAGER" {
+		return errors.New("only managers and owners can have an approval PIN")
+	}
+	if len(pin) < 4 || len(pin) > 6 {
+		return errors.New("PIN must be 4 to 6 digits")
+	}
+	for _, r := range pin {
+		if r < '0' || r > '9' {
+			return errors.New("PIN must be 4 to 6 digits")
+		}
+	}
+
+	hashed, err := HashPassword(pin)
+	if err != nil {
+		return err
+	}
+	return s.db.Model(user).Update("pin", hashed).Error
+}
+
 // ResetPassword updates a user's password
 func (s *UserService) ResetPassword(id uint, password string) error {
 	user, err := s.GetByID(id)
@@ -14,13 +14,19 @@ import (
 	"pos-fiber-app/internal/auth" // if you have password_reset_otp table
 	"pos-fiber-app/internal/business"
 	"pos-fiber-app/internal/category"
+	"pos-fiber-app/internal/changefeed"
 	"pos-fiber-app/internal/config"
+	"pos-fiber-app/internal/customer"
 	"pos-fiber-app/internal/expense"
+	"pos-fiber-app/internal/giftcard"
 	"pos-fiber-app/internal/inventory"
+	"pos-fiber-app/internal/loyalty"
 	"pos-fiber-app/internal/notification"
 	"pos-fiber-app/internal/otp"
 	"pos-fiber-app/internal/outlet"
+	"pos-fiber-app/internal/printing"
 	"pos-fiber-app/internal/product"
+	"pos-fiber-app/internal/promotion"
 	"pos-fiber-app/internal/recipe"
 	"pos-fiber-app/internal/sale"
 	"pos-fiber-app/internal/shift"
@@ -72,15 +78,36 @@ func RunMigrations(db *gorm.DB) error {
 		&outlet.Outlet{},
 		&terminal.Terminal{},
 		&terminal.Printer{},
+		&printing.ReceiptTemplate{}, // NEW: Receipt layouts
 		&category.Category{},
 		&product.Product{},
+		&product.ModifierGroup{}, // NEW: Product modifiers (size, add-ons, ...)
+		&product.ModifierOption{},
+		&product.ProductModifierGroup{},
 		&inventory.Inventory{},
 		&inventory.InventoryRound{},   // NEW: Bulk stock rounds
 		&inventory.StockReservation{}, // NEW: Stock reservations
+		&inventory.StockMovement{},    // NEW: Stock movement ledger
+		&customer.Customer{},          // NEW: Customer directory and credit accounts
+		&customer.LedgerEntry{},
+		&loyalty.Config{}, // NEW: Loyalty points programme
+		&loyalty.Entry{},
+		&giftcard.Card{}, // NEW: Gift cards and store credit
+		&giftcard.Transaction{},
+		&promotion.Promotion{}, // NEW: Automatic promotions
 		&sale.Sale{},
 		&sale.SaleItem{},
-		&sale.SaleSummary{},        // NEW: Sale summaries for archiving
-		&sale.SaleActivityLog{},    // NEW: Sale activity logs
+		&sale.SaleSummary{},     // NEW: Sale summaries for archiving
+		&sale.SaleActivityLog{}, // NEW: Sale activity logs
+		&sale.Refund{},          // NEW: Refunds against completed sales
+		&sale.RefundItem{},
+		&sale.StockShortfall{},  // NEW: Stock shortfalls from offline sales
+		&sale.ReceiptDelivery{}, // NEW: E-receipt deliveries
+		&sale.Quotation{},       // NEW: Quotations and pro-forma invoices
+		&sale.QuotationItem{},
+		&sale.Delivery{}, // NEW: Delivery orders and riders
+		&sale.RiderRemittance{},
+		&sale.KitchenStation{},     // NEW: KDS stations with category routing
 		&expense.Expense{},         // NEW: Expense tracking
 		&shift.Shift{},             // NEW: Shift management
 		&shift.ShiftReading{},      // NEW: Shift readings for fuel/gas stations
@@ -96,12 +123,33 @@ func RunMigrations(db *gorm.DB) error {
 		&subscription.PayoutRequest{},
 		&tutorial.Tutorial{},
 		&notification.DeviceToken{},
+		&changefeed.Change{}, // NEW: Change feed for device sync
 	)
 
 	if err != nil {
 		return err
 	}
 
+	// Synced tables record every write for the device change feed
+	if err := changefeed.InstallTriggers(db); err != nil {
+		return err
+	}
+
+	// Stock on hand before the movement ledger existed becomes its opening balance
+	if err := inventory.OpenLedger(db); err != nil {
+		return err
+	}
+
+	// Offline sale keys are unique per business under named composite indexes; drop the
+	// indexes they were first created under
+	for _, idx := range []string{"idx_sale_client_uuid", "idx_sale_idempotency"} {
+		if db.Migrator().HasIndex(&sale.Sale{}, idx) {
+			if err := db.Migrator().DropIndex(&sale.Sale{}, idx); err != nil {
+				log.Printf("Warning: Failed to drop index %s: %v", idx, err)
+			}
+		}
+	}
+
 	// Fallsafe: Manually ensure outlet_id exists in sales table if AutoMigrate skipped it
 	if !db.Migrator().HasColumn(&sale.Sale{}, "OutletID") {
 		log.Println("Migrator: adding missing outlet_id column to sales table")
@@ -16,8 +16,13 @@ type PaystackClient struct {
 }
 
 func NewClient() *PaystackClient {
+	return NewClientWithKey(os.Getenv("PAYSTACK_SECRET_KEY"))
+}
+
+// NewClientWithKey is a client for another Paystack account, e.g. a business's own
+func NewClientWithKey(secretKey string) *PaystackClient {
 	return &PaystackClient{
-		SecretKey: os.Getenv("PAYSTACK_SECRET_KEY"),
+		SecretKey: secretKey,
 		BaseURL:   "https://api.paystack.co",
 	}
 }
@@ -26,11 +31,12 @@ type VerificationResponse struct {
 	Status  bool   `json:"status"`
 	Message string `json:"message"`
 	Data    struct {
-		Amount    float64 `json:"amount"`
-		Currency  string  `json:"currency"`
-		Status    string  `json:"status"`
-		Reference string  `json:"reference"`
-		PaidAt    string  `json:"paid_at"`
+		Amount    float64         `json:"amount"`
+		Currency  string          `json:"currency"`
+		Status    string          `json:"status"`
+		Reference string          `json:"reference"`
+		PaidAt    string          `json:"paid_at"`
+		Metadata  json.RawMessage `json:"metadata"` // as sent when the transaction was started; an object or a JSON string
 		Customer  struct {
 			Email string `json:"email"`
 		} `json:"customer"`